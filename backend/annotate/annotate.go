@@ -0,0 +1,156 @@
+// Package annotate locates users' annotations in the original text of
+// policies. A target keeps the text an annotation quotes and the text around
+// it, so that when a new version of the policy's text is written the
+// annotation can be found again: by its quote, wherever text before it was
+// inserted or removed, or between the text around it if the quote itself was
+// amended. Annotations found in neither way are orphaned and keep their
+// offsets in the version they were made on.
+package annotate
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/legislation"
+)
+
+// contextLength is the number of characters kept on each side of a quote
+const contextLength = 32
+
+// Target returns the target of the characters from start to end of policy's
+// current text, which must be within it
+func Target(policy *models.Policy, start, end int) *models.AnnotationTarget {
+	text := []rune(policy.OriginalText)
+	target := &models.AnnotationTarget{
+		TextVersion: policy.TextVersion,
+		Start:       start,
+		End:         end,
+		Quote:       string(text[start:end]),
+		Prefix:      string(text[max(0, start-contextLength):start]),
+		Suffix:      string(text[end:min(len(text), end+contextLength)]),
+		Status:      models.AnnotationAnchored,
+	}
+	if provision, _ := legislation.Containing(policy.Sections, start, end); provision != nil {
+		target.Anchor = provision.Anchor
+	}
+	return target
+}
+
+// Relocate returns target, made on an earlier version of policy's text, in
+// the current version, and whether it was found there. Targets that are not
+// found are returned orphaned and unchanged otherwise.
+func Relocate(target models.AnnotationTarget, policy *models.Policy) (*models.AnnotationTarget, bool) {
+	text := policy.OriginalText
+	start, end, ok := findQuote(text, target)
+	if !ok {
+		start, end, ok = findBetween(text, target)
+	}
+	if !ok {
+		target.Status = models.AnnotationOrphaned
+		return &target, false
+	}
+	return Target(policy, utf8.RuneCountInString(text[:start]), utf8.RuneCountInString(text[:end])), true
+}
+
+// findQuote returns the byte offsets of the occurrence of target's quote in
+// text whose surroundings best match target's prefix and suffix. Ties go to
+// the occurrence nearest where the quote was.
+func findQuote(text string, target models.AnnotationTarget) (int, int, bool) {
+	if target.Quote == "" {
+		return 0, 0, false
+	}
+	best, bestScore, bestDistance := -1, -1, 0
+	for from := 0; ; {
+		i := strings.Index(text[from:], target.Quote)
+		if i < 0 {
+			break
+		}
+		at := from + i
+		score := commonSuffix(text[:at], target.Prefix) + commonPrefix(text[at+len(target.Quote):], target.Suffix)
+		distance := abs(utf8.RuneCountInString(text[:at]) - target.Start)
+		if score > bestScore || score == bestScore && distance < bestDistance {
+			best, bestScore, bestDistance = at, score, distance
+		}
+		_, size := utf8.DecodeRuneInString(text[at:])
+		from = at + size
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+	return best, best + len(target.Quote), true
+}
+
+// findBetween returns the byte offsets of the text between target's prefix
+// and suffix, which must both be found with at most about twice the length
+// of the quote between them. An empty prefix or suffix stands for the start
+// or end of the text. Ties go to the text nearest where the quote was.
+func findBetween(text string, target models.AnnotationTarget) (int, int, bool) {
+	if target.Prefix == "" && target.Suffix == "" {
+		return 0, 0, false
+	}
+	maxGap := 2*len(target.Quote) + contextLength
+	best, bestEnd, bestDistance := -1, 0, 0
+	for from := 0; from <= len(text); {
+		start := 0
+		if target.Prefix != "" {
+			i := strings.Index(text[from:], target.Prefix)
+			if i < 0 {
+				break
+			}
+			start = from + i + len(target.Prefix)
+			_, size := utf8.DecodeRuneInString(text[from+i:])
+			from += i + size
+		} else {
+			from = len(text) + 1
+		}
+
+		end := -1
+		if target.Suffix == "" {
+			if len(text)-start <= maxGap {
+				end = len(text)
+			}
+		} else {
+			window := text[start:min(len(text), start+maxGap+len(target.Suffix))]
+			if j := strings.Index(window, target.Suffix); j > 0 {
+				end = start + j
+			}
+		}
+		if end < 0 {
+			continue
+		}
+		distance := abs(utf8.RuneCountInString(text[:start]) - target.Start)
+		if best < 0 || distance < bestDistance {
+			best, bestEnd, bestDistance = start, end, distance
+		}
+	}
+	if best < 0 || strings.TrimSpace(text[best:bestEnd]) == "" {
+		return 0, 0, false
+	}
+	return best, bestEnd, true
+}
+
+// commonSuffix returns the number of bytes a and b end with in common
+func commonSuffix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+// commonPrefix returns the number of bytes a and b start with in common
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,162 @@
+package annotate
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/legislation"
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+const billText = `SEC. 1. SHORT TITLE.
+This Act may be cited as the Clean Water Act.
+
+SEC. 2. WATER TESTING.
+(a) IN GENERAL.—Every city must test its drinking water for lead each year.
+(b) PUBLICATION.—Cities must publish the results of each test online.`
+
+func policyWithText(text string, version int64) *models.Policy {
+	return &models.Policy{OriginalText: text, Sections: legislation.Parse(text), TextVersion: version}
+}
+
+// targetOf returns the target of the first occurrence of quote in policy
+func targetOf(t *testing.T, policy *models.Policy, quote string) *models.AnnotationTarget {
+	t.Helper()
+	i := strings.Index(policy.OriginalText, quote)
+	if i < 0 {
+		t.Fatalf("%q is not in the text", quote)
+	}
+	start := len([]rune(policy.OriginalText[:i]))
+	return Target(policy, start, start+len([]rune(quote)))
+}
+
+func TestTarget(t *testing.T) {
+	policy := policyWithText(billText, 1)
+	target := targetOf(t, policy, "for lead each year")
+	if target.Anchor != "sec-2-a" || target.TextVersion != 1 || target.Status != models.AnnotationAnchored {
+		t.Errorf("target = %+v, want version 1 anchored in sec-2-a", target)
+	}
+	if target.Prefix != "ty must test its drinking water " || !strings.HasPrefix(target.Suffix, ".\n(b) PUBLICATION.") {
+		t.Errorf("context = %q and %q", target.Prefix, target.Suffix)
+	}
+
+	// Context stops at the ends of the text
+	target = Target(policy, 0, 4)
+	if target.Quote != "SEC." || target.Prefix != "" || target.Anchor != "sec-1" {
+		t.Errorf("target = %+v", target)
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	policy := policyWithText(billText, 1)
+	target := targetOf(t, policy, "publish the results")
+
+	tests := []struct {
+		name   string
+		text   string
+		found  bool
+		quote  string
+		anchor string
+	}{
+		{
+			name:   "text inserted before",
+			text:   strings.Replace(billText, "(b) PUBLICATION", "(b) TESTING LABS.—Tests must be made by certified labs.\n(c) PUBLICATION", 1),
+			found:  true,
+			quote:  "publish the results",
+			anchor: "sec-2-c",
+		},
+		{
+			name:   "quote amended",
+			text:   strings.Replace(billText, "publish the results", "post the full results", 1),
+			found:  true,
+			quote:  "post the full results",
+			anchor: "sec-2-b",
+		},
+		{
+			name:  "provision struck",
+			text:  strings.Split(billText, "\n(b)")[0],
+			found: false,
+			quote: "publish the results",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			amended := policyWithText(test.text, 2)
+			relocated, found := Relocate(*target, amended)
+			if found != test.found || relocated.Quote != test.quote || relocated.Anchor != test.anchor && found {
+				t.Fatalf("Relocate = %+v, %v", relocated, found)
+			}
+			if !found {
+				if relocated.Status != models.AnnotationOrphaned || relocated.TextVersion != 1 || relocated.Start != target.Start {
+					t.Errorf("orphaned target = %+v, want the old one orphaned", relocated)
+				}
+				return
+			}
+			got := string([]rune(test.text)[relocated.Start:relocated.End])
+			if got != test.quote || relocated.TextVersion != 2 || relocated.Status != models.AnnotationAnchored {
+				t.Errorf("relocated = %+v, quoting %q", relocated, got)
+			}
+		})
+	}
+
+	// The end of the text stands in for a missing suffix
+	last := targetOf(t, policy, "each test online.")
+	relocated, found := Relocate(*last, policyWithText(strings.Replace(billText, "each test online.", "every test on its website.", 1), 2))
+	if !found || relocated.Quote != "every test on its website." {
+		t.Errorf("Relocate = %+v, %v, want the amended end of the text", relocated, found)
+	}
+
+	// A repeated quote is found where its context matches
+	repeated := policyWithText(strings.Replace(billText, "This Act", "Cities must publish the results of each test online. This Act", 1), 2)
+	relocated, found = Relocate(*targetOf(t, policy, "publish the results"), repeated)
+	if !found || relocated.Anchor != "sec-2-b" {
+		t.Errorf("Relocate = %+v, %v, want the quote in sec-2-b", relocated, found)
+	}
+}
+
+func TestReanchor(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	policy := policyWithText(billText, 1)
+	if err := store.Policies.Create(ctx, policy); err != nil {
+		t.Fatal(err)
+	}
+	create := func(quote string) *models.Annotation {
+		annotation := &models.Annotation{PolicyID: policy.ID, Visibility: models.AnnotationPrivate, Target: targetOf(t, policy, quote), CreatedAt: time.Now()}
+		if err := store.Annotations.Create(ctx, annotation); err != nil {
+			t.Fatal(err)
+		}
+		return annotation
+	}
+	kept := create("drinking water")
+	struck := create("publish the results")
+
+	amended := policyWithText("SEC. 1. TESTING.\nEvery city must test its drinking water for lead each year.", 2)
+	amended.ID = policy.ID
+	reanchorer := NewReanchorer(store)
+	result, err := reanchorer.Reanchor(ctx, amended)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (Result{Moved: 1, Orphaned: 1}) {
+		t.Errorf("result = %+v", result)
+	}
+	if moved, _ := store.Annotations.FindByID(ctx, kept.ID); moved.Target.Anchor != "sec-1" || moved.Target.TextVersion != 2 {
+		t.Errorf("moved target = %+v", moved.Target)
+	}
+	if orphaned, _ := store.Annotations.FindByID(ctx, struck.ID); orphaned.Target.Status != models.AnnotationOrphaned {
+		t.Errorf("orphaned target = %+v", orphaned.Target)
+	}
+
+	// Orphans are only changed again once they are found
+	if result, err = reanchorer.Reanchor(ctx, amended); err != nil || result != (Result{}) {
+		t.Errorf("Reanchor = %+v, %v, want nothing changed", result, err)
+	}
+	entries, err := store.Audit.Range(ctx, 0, 10)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("audit entries = %d, %v, want one per change", len(entries), err)
+	}
+}
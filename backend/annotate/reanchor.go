@@ -0,0 +1,112 @@
+package annotate
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxMoveAttempts bounds how often moving an annotation is retried when its
+// author changes it at the same time
+const maxMoveAttempts = 3
+
+// Result counts the annotations changed by a Reanchor
+type Result struct {
+	Moved    int
+	Orphaned int
+}
+
+// Reanchorer moves the annotations of policies to new versions of their text
+type Reanchorer struct {
+	annotations repository.AnnotationRepository
+	audit       *audit.Log
+}
+
+// NewReanchorer creates a Reanchorer for the annotations in store
+func NewReanchorer(store *repository.Store) *Reanchorer {
+	return &Reanchorer{annotations: store.Annotations, audit: audit.NewLog(store.Audit)}
+}
+
+// Reanchor moves the annotations made on earlier versions of policy's text
+// to its current version, orphaning those that are no longer found. Orphaned
+// annotations are tried again with every later version.
+func (r *Reanchorer) Reanchor(ctx context.Context, policy *models.Policy) (Result, error) {
+	var result Result
+	stale, err := r.annotations.List(ctx, repository.AnnotationFilter{PolicyID: policy.ID, TextVersionBelow: policy.TextVersion})
+	if err != nil {
+		return result, err
+	}
+	for _, annotation := range stale {
+		moved, err := r.move(ctx, annotation.ID, policy)
+		if err != nil {
+			return result, err
+		}
+		switch {
+		case moved == nil:
+		case moved.Target.Status == models.AnnotationAnchored:
+			result.Moved++
+		default:
+			result.Orphaned++
+		}
+	}
+	return result, nil
+}
+
+// move relocates the annotation with the given ID in policy's text and
+// returns it, or nil if nothing changed
+func (r *Reanchorer) move(ctx context.Context, id primitive.ObjectID, policy *models.Policy) (*models.Annotation, error) {
+	for attempt := 1; ; attempt++ {
+		before, err := r.annotations.FindByID(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if before.Target == nil || before.Target.TextVersion >= policy.TextVersion {
+			return nil, nil
+		}
+
+		annotation := *before
+		target, found := Relocate(*before.Target, policy)
+		if !found && before.Target.Status == models.AnnotationOrphaned {
+			return nil, nil
+		}
+		annotation.Target = target
+
+		err = r.annotations.Update(ctx, &annotation)
+		switch {
+		case errors.Is(err, repository.ErrRevisionConflict) && attempt < maxMoveAttempts:
+			continue
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, nil
+		case err != nil:
+			return nil, err
+		}
+		r.record(ctx, before, &annotation)
+		return &annotation, nil
+	}
+}
+
+// record adds a moved annotation to the audit log. Annotations are moved
+// because a policy's text changed, not by their author, so the entry has no
+// actor.
+func (r *Reanchorer) record(ctx context.Context, before, after *models.Annotation) {
+	changes, err := audit.Diff(before, after)
+	if err == nil {
+		err = r.audit.Record(ctx, &models.AuditEntry{
+			Action:       audit.ActionUpdate,
+			ResourceType: audit.ResourceAnnotation,
+			ResourceID:   after.ID,
+			Changes:      changes,
+		})
+	}
+	if err != nil {
+		slog.Error("Error recording audit entry", "error", err, "resource", audit.ResourceAnnotation, "id", after.ID.Hex())
+	}
+}
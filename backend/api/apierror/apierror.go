@@ -0,0 +1,184 @@
+// Package apierror defines the error responses returned by the API. Every
+// error is written as an RFC 9457 application/problem+json body carrying a
+// stable machine-readable code and the request ID. Internal errors are logged
+// and replaced with a generic message so that details such as database
+// errors are never sent to clients.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/benjamingetches/govtrack/api/requestid"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/logging"
+)
+
+// ContentType is the media type of error responses
+const ContentType = "application/problem+json"
+
+// Code is a stable, machine-readable error code
+type Code string
+
+// Error codes. Codes are part of the API contract: add new ones freely but
+// never change the meaning of an existing one.
+const (
+	CodeMalformedBody      Code = "malformed_body"
+	CodeValidationFailed   Code = "validation_failed"
+	CodeInvalidID          Code = "invalid_id"
+	CodeInvalidParameter   Code = "invalid_parameter"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeNotFound           Code = "not_found"
+	CodeRouteNotFound      Code = "route_not_found"
+	CodeMethodNotAllowed   Code = "method_not_allowed"
+	CodeConflict           Code = "conflict"
+	CodeEmailTaken         Code = "email_taken"
+	CodeRateLimited        Code = "rate_limited"
+	CodeAccountLocked      Code = "account_locked"
+	CodeInvalidAPIKey      Code = "invalid_api_key"
+	CodeInsufficientScope  Code = "insufficient_scope"
+	CodeForbidden          Code = "forbidden"
+	CodeBanned             Code = "banned"
+	CodeQuotaExceeded      Code = "quota_exceeded"
+	CodePreconditionFailed Code = "precondition_failed"
+	CodeUnsupportedMedia   Code = "unsupported_media_type"
+	CodePatchFailed        Code = "patch_failed"
+	CodeInternal           Code = "internal_error"
+	CodeGeneratorFailed    Code = "generator_failed"
+)
+
+// internalMessage replaces the details of unexpected errors in responses
+const internalMessage = "An internal error occurred"
+
+// Error is an error with a safe public message and an HTTP status
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Fields  validation.Errors
+
+	// Current is the stored version of a resource that a conditional write
+	// expected to be different, returned so the client can reconcile
+	Current interface{}
+
+	// Err is the underlying cause. It is logged but never sent to clients.
+	Err error
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates an Error with a public message
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Internal wraps an unexpected error. Its details are logged, not returned.
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: internalMessage, Err: err}
+}
+
+// InvalidID reports a malformed ID in the URL, such as "Invalid policy ID"
+func InvalidID(resource string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidID, "Invalid "+resource+" ID")
+}
+
+// NotFound reports a missing resource, such as "Policy not found"
+func NotFound(resource string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, strings.ToUpper(resource[:1])+resource[1:]+" not found")
+}
+
+// PreconditionFailed reports a write that expected a revision of the
+// resource other than current, the one now stored
+func PreconditionFailed(resource string, current interface{}) *Error {
+	err := New(http.StatusPreconditionFailed, CodePreconditionFailed, "The "+resource+" has been modified since the expected revision")
+	err.Current = current
+	return err
+}
+
+// Problem is the JSON body of an error response
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail"`
+	Code      Code              `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    validation.Errors `json:"errors,omitempty"`
+	Current   interface{}       `json:"current,omitempty"`
+}
+
+// From converts any error into an Error. Errors returned by validation.Decode
+// become 400 or 422 responses; anything else unrecognized is internal.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		return &Error{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeValidationFailed,
+			Message: "The request contains invalid fields",
+			Fields:  fields,
+		}
+	}
+
+	var malformed *validation.MalformedError
+	if errors.As(err, &malformed) {
+		return New(http.StatusBadRequest, CodeMalformedBody, malformed.Detail)
+	}
+
+	return Internal(err)
+}
+
+// Write writes err as a problem details response. Internal errors are logged
+// with the request ID so that a client's report can be traced.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := From(err)
+	id := requestid.FromContext(r.Context())
+
+	logging.AddFields(r.Context(), "error_code", apiErr.Code)
+	if apiErr.Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("internal error", "method", r.Method, "path", r.URL.Path, "error", apiErr.Err)
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(apiErr.Status),
+		Status:    apiErr.Status,
+		Detail:    apiErr.Message,
+		Code:      apiErr.Code,
+		RequestID: id,
+		Errors:    apiErr.Fields,
+		Current:   apiErr.Current,
+	})
+}
+
+// NotFoundHandler responds to requests for unknown routes
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusNotFound, CodeRouteNotFound, "No route matches "+r.URL.Path))
+}
+
+// MethodNotAllowedHandler responds to requests using an unsupported method
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not supported on "+r.URL.Path))
+}
@@ -0,0 +1,44 @@
+// Package apikey generates developer API keys and derives the hashes they
+// are stored and looked up by.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Header is the request header that carries an API key
+const Header = "X-API-Key"
+
+// Prefix starts every key so that leaked keys are easy to recognize
+const Prefix = "gt_"
+
+// displayLength is how much of a key is kept to identify it in listings
+const displayLength = len(Prefix) + 8
+
+// Generate returns a new random key, the prefix shown to identify it and the
+// hash to store. The key itself must not be stored.
+func Generate() (key, display, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+	key = Prefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:displayLength], Hash(key), nil
+}
+
+// Hash returns the hex SHA-256 of key. Keys carry 256 bits of randomness, so
+// a fast unsalted hash is enough and lets keys be looked up by their hash.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LooksValid reports whether key has the format of a generated key, so that
+// malformed keys are rejected without a database lookup
+func LooksValid(key string) bool {
+	return strings.HasPrefix(key, Prefix) && len(key) == len(Prefix)+43
+}
@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/benjamingetches/govtrack/annotate"
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/legislation"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AnnotationHandler handles users' highlights of and notes on policies'
+// original text, and the replies to them. Annotations are private to their
+// author unless made public, and replies share the visibility of the
+// annotation they reply to. Only authors change their annotations; authors
+// and editors can delete them.
+type AnnotationHandler struct {
+	annotations repository.AnnotationRepository
+	policies    repository.PolicyRepository
+	users       repository.UserRepository
+	audit       *Auditor
+}
+
+// NewAnnotationHandler creates a new AnnotationHandler that records changes
+// with auditor
+func NewAnnotationHandler(store *repository.Store, auditor *Auditor) *AnnotationHandler {
+	return &AnnotationHandler{
+		annotations: store.Annotations,
+		policies:    store.Policies,
+		users:       store.Users,
+		audit:       auditor,
+	}
+}
+
+// CreateAnnotation handles POST requests to annotate a policy's text or
+// reply to an annotation
+func (h *AnnotationHandler) CreateAnnotation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return
+	}
+
+	// Decode and validate request body
+	var req models.AnnotationRequest
+	err := validation.Decode(r, &req)
+	if req.PolicyID.IsZero() {
+		err = validation.Append(err, "policy_id", "is required")
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	policy, err := h.policies.FindByID(ctx, req.PolicyID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	annotation := models.Annotation{
+		PolicyID:   policy.ID,
+		AuthorID:   userID,
+		Body:       req.Body,
+		Visibility: req.Visibility,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if req.ParentID != nil {
+		err = h.reply(ctx, &annotation, &req, *req.ParentID)
+	} else {
+		annotation.Target, err = target(policy, &req)
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if annotation.Visibility == "" {
+		annotation.Visibility = models.AnnotationPrivate
+	}
+
+	// Insert annotation into database
+	if err := h.annotations.Create(ctx, &annotation); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionCreate, audit.ResourceAnnotation, annotation.ID, nil, &annotation)
+
+	// Return created annotation as JSON
+	w.Header().Set("ETag", annotationTag(&annotation))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(annotation)
+}
+
+// reply completes annotation as a reply to the annotation with parentID
+func (h *AnnotationHandler) reply(ctx context.Context, annotation *models.Annotation, req *models.AnnotationRequest, parentID primitive.ObjectID) error {
+	parent, err := h.annotations.FindByID(ctx, parentID)
+	if errors.Is(err, repository.ErrNotFound) || err == nil && !visible(parent, annotation.AuthorID) {
+		return apierror.NotFound("annotation")
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	if parent.PolicyID != req.PolicyID {
+		errs = validation.Append(errs, "parent_id", "is an annotation of another policy")
+	}
+	if req.Anchor != "" || req.Start != nil || req.End != nil {
+		errs = validation.Append(errs, "anchor", "cannot be set on replies; they refer to the annotation they reply to")
+	}
+	if req.Body == "" {
+		errs = validation.Append(errs, "body", "is required for replies")
+	}
+	if req.Visibility != "" && req.Visibility != parent.Visibility {
+		errs = validation.Append(errs, "visibility", "must be "+parent.Visibility+", like the annotation replied to")
+	}
+	annotation.ParentID = &parent.ID
+	annotation.Visibility = parent.Visibility
+	return errs
+}
+
+// target returns the part of policy's text that req annotates: a range, a
+// provision, or a range within a provision
+func target(policy *models.Policy, req *models.AnnotationRequest) (*models.AnnotationTarget, error) {
+	start, end := 0, utf8.RuneCountInString(policy.OriginalText)
+	length := end
+	if req.Anchor != "" {
+		provision, _ := legislation.Find(policy.Sections, req.Anchor)
+		if provision == nil {
+			return nil, validation.Append(nil, "anchor", "is not a section of the policy's text")
+		}
+		start, end = provision.Start, provision.End
+	}
+
+	switch {
+	case req.Start == nil && req.End == nil && req.Anchor == "":
+		return nil, validation.Append(nil, "anchor", "or a range from start to end is required")
+	case req.Start == nil && req.End == nil:
+	case req.Start == nil:
+		return nil, validation.Append(nil, "start", "is required with end")
+	case req.End == nil:
+		return nil, validation.Append(nil, "end", "is required with start")
+	case *req.Start < start || *req.End > end:
+		if req.Anchor != "" {
+			return nil, validation.Append(nil, "start", "must be within the section")
+		}
+		return nil, validation.Append(nil, "end", "must be within the policy's text, which has "+strconv.Itoa(length)+" characters")
+	case *req.Start >= *req.End:
+		return nil, validation.Append(nil, "end", "must be after start")
+	default:
+		start, end = *req.Start, *req.End
+	}
+	if start >= end {
+		return nil, validation.Append(nil, "anchor", "is an empty section")
+	}
+	return annotate.Target(policy, start, end), nil
+}
+
+// GetAnnotations handles GET requests for a policy's public annotations and
+// the caller's private ones
+func (h *AnnotationHandler) GetAnnotations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return
+	}
+
+	// Parse query parameters for filtering
+	query := r.URL.Query()
+	filter := repository.AnnotationFilter{Anchor: query.Get("anchor"), VisibleTo: userID}
+	policyID, err := primitive.ObjectIDFromHex(query.Get("policy_id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "policy_id must be a policy ID"))
+		return
+	}
+	filter.PolicyID = policyID
+	if query.Get("mine") == "true" {
+		filter.AuthorID = userID
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	annotations, err := h.annotations.List(ctx, filter)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return annotations as JSON
+	writeWithETag(w, r, annotationsTag(annotations), annotations)
+}
+
+// GetPublicAnnotations handles GET requests for the public annotations of a
+// policy
+func (h *AnnotationHandler) GetPublicAnnotations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get policy ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("policy"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// The policy must exist
+	if _, err := h.policies.FindByID(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	annotations, err := h.annotations.List(ctx, repository.AnnotationFilter{PolicyID: id, Anchor: r.URL.Query().Get("anchor"), PublicOnly: true})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return annotations as JSON
+	writeWithETag(w, r, annotationsTag(annotations), annotations)
+}
+
+// GetAnnotation handles GET requests for a single annotation
+func (h *AnnotationHandler) GetAnnotation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	annotation, _, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+
+	// Return annotation as JSON
+	writeWithETag(w, r, annotationTag(annotation), annotation)
+}
+
+// UpdateAnnotation handles PUT requests by an annotation's author to change
+// its body or visibility. The visibility of replies, and of annotations
+// that have been replied to, cannot change.
+func (h *AnnotationHandler) UpdateAnnotation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	before, userID, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+	if before.AuthorID != userID {
+		apierror.Write(w, r, forbidden("Only the author can change an annotation"))
+		return
+	}
+
+	// Decode and validate request body
+	var req models.AnnotationUpdate
+	if err := validation.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	current := func() (*models.Annotation, error) { return h.annotations.FindByID(ctx, before.ID) }
+
+	// Only change the revision the client expects, if any
+	revision, ok := expectedRevision(r, before.ID, 0)
+	if !ok {
+		writePreconditionFailed(w, r, "annotation", current, annotationTag)
+		return
+	}
+
+	var errs error
+	if before.ParentID != nil && req.Body == "" {
+		errs = validation.Append(errs, "body", "is required for replies")
+	}
+	if req.Visibility != "" && req.Visibility != before.Visibility {
+		replies, err := h.annotations.List(ctx, repository.AnnotationFilter{PolicyID: before.PolicyID})
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		switch {
+		case before.ParentID != nil:
+			errs = validation.Append(errs, "visibility", "of replies is that of the annotation replied to")
+		case len(thread(replies, before.ID)) > 1:
+			errs = validation.Append(errs, "visibility", "cannot change once the annotation has replies")
+		}
+	}
+	if errs != nil {
+		apierror.Write(w, r, errs)
+		return
+	}
+
+	annotation := *before
+	annotation.Body = req.Body
+	if req.Visibility != "" {
+		annotation.Visibility = req.Visibility
+	}
+	annotation.UpdatedAt = time.Now()
+	annotation.Revision = revision
+
+	// Update annotation in database
+	err := h.annotations.Update(ctx, &annotation)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			apierror.Write(w, r, apierror.NotFound("annotation"))
+		case errors.Is(err, repository.ErrRevisionConflict):
+			writePreconditionFailed(w, r, "annotation", current, annotationTag)
+		default:
+			apierror.Write(w, r, err)
+		}
+		return
+	}
+	h.audit.record(r, audit.ActionUpdate, audit.ResourceAnnotation, annotation.ID, before, &annotation)
+
+	// Return updated annotation as JSON
+	w.Header().Set("ETag", annotationTag(&annotation))
+	json.NewEncoder(w).Encode(annotation)
+}
+
+// DeleteAnnotation handles DELETE requests by an annotation's author or an
+// editor, deleting the annotation and every reply to it
+func (h *AnnotationHandler) DeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	annotation, userID, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if annotation.AuthorID != userID {
+		caller, err := h.users.FindByID(ctx, userID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, err)
+			return
+		}
+		if err != nil || !caller.CanEdit() {
+			apierror.Write(w, r, forbidden("Only the author and editors can delete an annotation"))
+			return
+		}
+	}
+
+	// Delete the annotation and its replies
+	all, err := h.annotations.List(ctx, repository.AnnotationFilter{PolicyID: annotation.PolicyID})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	deleted := thread(all, annotation.ID)
+	ids := make([]primitive.ObjectID, len(deleted))
+	for i := range deleted {
+		ids[i] = deleted[i].ID
+	}
+	if err := h.annotations.Delete(ctx, ids); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	for i := range deleted {
+		h.audit.record(r, audit.ActionDelete, audit.ResourceAnnotation, deleted[i].ID, &deleted[i], nil)
+	}
+
+	// Return success message
+	json.NewEncoder(w).Encode(map[string]string{"message": "Annotation deleted successfully"})
+}
+
+// find reads the annotation whose ID is in the URL, writing an error
+// response if there is none or the caller cannot see it. It also returns
+// the caller's ID.
+func (h *AnnotationHandler) find(w http.ResponseWriter, r *http.Request) (*models.Annotation, primitive.ObjectID, bool) {
+	// Get annotation ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("annotation"))
+		return nil, primitive.NilObjectID, false
+	}
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return nil, primitive.NilObjectID, false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Private annotations of others are reported as missing
+	annotation, err := h.annotations.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) || err == nil && !visible(annotation, userID) {
+		apierror.Write(w, r, apierror.NotFound("annotation"))
+		return nil, primitive.NilObjectID, false
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return nil, primitive.NilObjectID, false
+	}
+	return annotation, userID, true
+}
+
+// visible reports whether the user can see annotation
+func visible(annotation *models.Annotation, userID primitive.ObjectID) bool {
+	return annotation.Visibility == models.AnnotationPublic || annotation.AuthorID == userID
+}
+
+// thread returns the annotation with the given ID among annotations and
+// every reply to it, directly or not
+func thread(annotations []models.Annotation, id primitive.ObjectID) []models.Annotation {
+	var found []models.Annotation
+	ids := map[primitive.ObjectID]bool{id: true}
+	// Replies are created after the annotations they reply to, so one pass
+	// in creation order finds every reply
+	for _, annotation := range annotations {
+		if ids[annotation.ID] || annotation.ParentID != nil && ids[*annotation.ParentID] {
+			ids[annotation.ID] = true
+			found = append(found, annotation)
+		}
+	}
+	return found
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/apikey"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/logging"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyHandler handles the management of users' developer API keys
+type APIKeyHandler struct {
+	keys  repository.APIKeyRepository
+	users repository.UserRepository
+	cfg   config.APIKeysConfig
+	audit *Auditor
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler that applies the quotas and
+// limits in cfg and records changes with auditor
+func NewAPIKeyHandler(keys repository.APIKeyRepository, users repository.UserRepository, cfg config.APIKeysConfig, auditor *Auditor) *APIKeyHandler {
+	return &APIKeyHandler{
+		keys:  keys,
+		users: users,
+		cfg:   cfg,
+		audit: auditor,
+	}
+}
+
+// CreateAPIKey handles POST requests to create a key for the caller. The key
+// is returned once and only its hash is stored.
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return
+	}
+
+	// Decode and validate request body
+	var req models.CreateAPIKeyRequest
+	err := validation.Decode(r, &req)
+	if req.DailyQuota > h.cfg.MaxDailyQuota {
+		err = validation.Append(err, "daily_quota", "must be at most "+strconv.FormatInt(h.cfg.MaxDailyQuota, 10))
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if req.DailyQuota == 0 {
+		req.DailyQuota = h.cfg.DefaultDailyQuota
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Only editors can create keys that write
+	for _, scope := range req.Scopes {
+		if scope != models.ScopeWrite {
+			continue
+		}
+		user, err := h.users.FindByID(ctx, userID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, err)
+			return
+		}
+		if user == nil || !user.CanEdit() {
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Only editors can create keys with the write scope"))
+			return
+		}
+	}
+
+	// Limit the number of active keys per user
+	existing, err := h.keys.ListByUser(ctx, userID)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	active := 0
+	for _, key := range existing {
+		if !key.Revoked() {
+			active++
+		}
+	}
+	if active >= h.cfg.MaxPerUser {
+		apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "Revoke an API key before creating another; each user can hold "+strconv.Itoa(h.cfg.MaxPerUser)))
+		return
+	}
+
+	raw, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal(err))
+		return
+	}
+	key := models.APIKey{
+		UserID:     userID,
+		Name:       req.Name,
+		Prefix:     prefix,
+		Hash:       hash,
+		Scopes:     req.Scopes,
+		DailyQuota: req.DailyQuota,
+		CreatedAt:  time.Now(),
+	}
+
+	// Insert key into database
+	if err := h.keys.Create(ctx, &key); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("API key created", "api_key_id", key.ID.Hex(), "scopes", key.Scopes)
+	h.audit.record(r, audit.ActionCreate, audit.ResourceAPIKey, key.ID, nil, &key)
+
+	// Return the key; it cannot be retrieved again
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.CreateAPIKeyResponse{APIKey: key, Key: raw})
+}
+
+// GetAPIKeys handles GET requests for the caller's keys, newest first
+func (h *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	keys, err := h.keys.ListByUser(ctx, userID)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey handles DELETE requests to revoke one of the caller's keys.
+// Revoked keys are kept so that their usage remains visible.
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return
+	}
+
+	// Get key ID from URL
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("API key"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Other users' keys are reported as missing
+	key, err := h.keys.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && key.UserID != userID) {
+		apierror.Write(w, r, apierror.NotFound("API key"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	if err := h.keys.Revoke(ctx, id, now); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("API key revoked", "api_key_id", id.Hex())
+
+	// Keys that were already revoked keep their revocation time
+	revoked := *key
+	if revoked.RevokedAt == nil {
+		revoked.RevokedAt = &now
+	}
+	h.audit.record(r, audit.ActionRevoke, audit.ResourceAPIKey, id, key, &revoked)
+
+	// Return success message
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "API key revoked successfully"})
+}
+
+// callerID returns the ID of the authenticated user
+func callerID(r *http.Request) (primitive.ObjectID, bool) {
+	userID, _ := r.Context().Value("userId").(string)
+	id, err := primitive.ObjectIDFromHex(userID)
+	return id, err == nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/middleware"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/requestid"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Auditor records the changes made through the handlers in the audit log
+type Auditor struct {
+	log               *audit.Log
+	trustForwardedFor bool
+}
+
+// NewAuditor creates an Auditor writing to log. Clients are identified by
+// their IP address, taken from X-Forwarded-For if trustForwardedFor is set.
+func NewAuditor(log *audit.Log, trustForwardedFor bool) *Auditor {
+	return &Auditor{
+		log:               log,
+		trustForwardedFor: trustForwardedFor,
+	}
+}
+
+// record logs the change of a document from before to after, either of
+// which is nil for documents that were created or deleted. The change has
+// already been made, so a failure is logged rather than returned, and the
+// entry is written even if the client has gone away.
+func (a *Auditor) record(r *http.Request, action, resource string, id primitive.ObjectID, before, after interface{}, redacted ...string) {
+	log := logging.FromContext(r.Context())
+	changes, err := audit.Diff(before, after, redacted...)
+	if err != nil {
+		log.Error("Error recording audit entry", "error", err, "resource", resource, "id", id.Hex())
+		return
+	}
+
+	entry := models.AuditEntry{
+		Action:       action,
+		ResourceType: resource,
+		ResourceID:   id,
+		Changes:      changes,
+		IP:           middleware.ClientIP(r, a.trustForwardedFor),
+		RequestID:    requestid.FromContext(r.Context()),
+	}
+	if actor, ok := callerID(r); ok {
+		entry.ActorID = &actor
+	}
+	if keyID, err := primitive.ObjectIDFromHex(apiKeyID(r)); err == nil {
+		entry.APIKeyID = &keyID
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 10*time.Second)
+	defer cancel()
+	if err := a.log.Record(ctx, &entry); err != nil {
+		log.Error("Error recording audit entry", "error", err, "resource", resource, "id", id.Hex())
+	}
+}
+
+// apiKeyID returns the ID of the API key the request was made with, or ""
+func apiKeyID(r *http.Request) string {
+	id, _ := r.Context().Value("apiKeyId").(string)
+	return id
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit log page sizes
+const (
+	DefaultAuditLimit = 50
+	MaxAuditLimit     = 500
+)
+
+// AuditHandler handles the administrators' audit log endpoints
+type AuditHandler struct {
+	entries repository.AuditRepository
+}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler(entries repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		entries: entries,
+	}
+}
+
+// GetAudit handles GET requests listing audit log entries, newest first,
+// with optional filtering
+func (h *AuditHandler) GetAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse query parameters for filtering
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	entries, err := h.entries.List(ctx, filter)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return entries as JSON
+	json.NewEncoder(w).Encode(entries)
+}
+
+// VerifyAudit handles GET requests checking the audit log's hash chain
+func (h *AuditHandler) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	result, err := audit.Verify(ctx, h.entries)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return verification as JSON
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseAuditFilter reads an audit log filter from the query parameters
+func parseAuditFilter(r *http.Request) (repository.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := repository.AuditFilter{
+		Action:       query.Get("action"),
+		ResourceType: query.Get("resource_type"),
+		Limit:        DefaultAuditLimit,
+	}
+	invalid := func(message string) (repository.AuditFilter, error) {
+		return repository.AuditFilter{}, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, message)
+	}
+
+	if filter.Action != "" && !slices.Contains(audit.Actions, filter.Action) {
+		return invalid("action must be one of: " + strings.Join(audit.Actions, ", "))
+	}
+	if filter.ResourceType != "" && !slices.Contains(audit.ResourceTypes, filter.ResourceType) {
+		return invalid("resource_type must be one of: " + strings.Join(audit.ResourceTypes, ", "))
+	}
+
+	var err error
+	if value := query.Get("actor"); value != "" {
+		if filter.ActorID, err = primitive.ObjectIDFromHex(value); err != nil {
+			return invalid("actor must be a user ID")
+		}
+	}
+	if value := query.Get("resource_id"); value != "" {
+		if filter.ResourceID, err = primitive.ObjectIDFromHex(value); err != nil {
+			return invalid("resource_id must be a document ID")
+		}
+	}
+	if value := query.Get("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return invalid("from must be an RFC 3339 time")
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return invalid("to must be an RFC 3339 time")
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.ParseInt(value, 10, 64); err != nil || filter.Limit <= 0 || filter.Limit > MaxAuditLimit {
+			return invalid("limit must be between 1 and " + strconv.Itoa(MaxAuditLimit))
+		}
+	}
+	if value := query.Get("skip"); value != "" {
+		if filter.Skip, err = strconv.ParseInt(value, 10, 64); err != nil || filter.Skip < 0 {
+			return invalid("skip must be a non-negative number")
+		}
+	}
+	return filter, nil
+}
@@ -3,88 +3,96 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
 
+	"github.com/benjamingetches/govtrack/api/apierror"
 	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/logging"
+	"github.com/benjamingetches/govtrack/metrics"
+	"github.com/benjamingetches/govtrack/ratelimit"
+	"github.com/benjamingetches/govtrack/repository"
 	"github.com/golang-jwt/jwt/v4"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // AuthHandler handles authentication requests
 type AuthHandler struct {
-	collection *mongo.Collection
+	users   repository.UserRepository
+	auth    config.AuthConfig
+	lockout *ratelimit.Lockout
 }
 
-// NewAuthHandler creates a new AuthHandler
-func NewAuthHandler(client *mongo.Client) *AuthHandler {
-	collection := client.Database("govtrack").Collection("users")
+// NewAuthHandler creates a new AuthHandler that signs tokens and locks out
+// accounts as configured by auth
+func NewAuthHandler(users repository.UserRepository, auth config.AuthConfig) *AuthHandler {
 	return &AuthHandler{
-		collection: collection,
+		users:   users,
+		auth:    auth,
+		lockout: ratelimit.NewLockout(auth.Lockout),
 	}
 }
 
 // Login handles user login
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	fmt.Println("Login request received")
 
 	var loginReq models.LoginRequest
-	err := json.NewDecoder(r.Body).Decode(&loginReq)
+	err := validation.Decode(r, &loginReq)
 	if err != nil {
-		fmt.Printf("Error decoding login request: %v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		apierror.Write(w, r, err)
 		return
 	}
 
-	fmt.Printf("Login attempt for email: %s\n", loginReq.Email)
+	// Refuse locked accounts before checking the password. Unknown emails are
+	// tracked too, so lockouts do not reveal which accounts exist.
+	account := models.NormalizeEmail(loginReq.Email)
+	if wait := h.lockout.Locked(account); wait > 0 {
+		logging.FromContext(r.Context()).Info("Login refused", "reason", "locked")
+		metrics.LoginLocked()
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, apierror.CodeAccountLocked, "Too many failed logins, please retry later"))
+		return
+	}
 
 	// Find user by email
-	var user models.User
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	err = h.collection.FindOne(ctx, bson.M{"email": loginReq.Email}).Decode(&user)
+	user, err := h.users.FindByEmail(ctx, account)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			fmt.Printf("User not found with email: %s\n", loginReq.Email)
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid email or password"})
+		if errors.Is(err, repository.ErrNotFound) {
+			h.loginFailed(w, r, account, "reason", "unknown_email")
 			return
 		}
-		fmt.Printf("Database error finding user: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		apierror.Write(w, r, apierror.Internal(err))
 		return
 	}
 
 	// Check password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginReq.Password))
 	if err != nil {
-		fmt.Println("Password mismatch")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid email or password"})
+		h.loginFailed(w, r, account, "reason", "wrong_password", "user_id", user.ID.Hex())
 		return
 	}
-
-	fmt.Println("Password verified, generating JWT token")
+	h.lockout.Succeed(account)
 
 	// Generate JWT token
-	token, err := generateJWT(user)
+	token, err := h.generateJWT(*user)
 	if err != nil {
-		fmt.Printf("Error generating token: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate token"})
+		apierror.Write(w, r, apierror.Internal(err))
 		return
 	}
 
-	fmt.Println("JWT token generated successfully")
+	logging.AddFields(r.Context(), "user_id", user.ID.Hex())
+	metrics.LoginSucceeded()
+	metrics.SessionSeen(user.ID.Hex())
 
 	// Remove password from response
 	user.Password = ""
@@ -92,66 +100,55 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Return token and user
 	response := models.AuthResponse{
 		Token: token,
-		User:  user,
+		User:  *user,
 	}
 
-	fmt.Println("Sending login response")
 	json.NewEncoder(w).Encode(response)
 }
 
+// loginFailed records a failed login for account and responds 401
+func (h *AuthHandler) loginFailed(w http.ResponseWriter, r *http.Request, account string, args ...any) {
+	if lock := h.lockout.Fail(account); lock > 0 {
+		args = append(args, "locked_for", lock.String())
+	}
+	logging.FromContext(r.Context()).Info("Login failed", args...)
+	metrics.LoginFailed()
+	apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid email or password"))
+}
+
 // Register handles user registration
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	fmt.Println("Register request received")
 
 	var registerReq models.RegisterRequest
-	err := json.NewDecoder(r.Body).Decode(&registerReq)
+	err := validation.Decode(r, &registerReq)
 	if err != nil {
-		fmt.Printf("Error decoding register request: %v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
-		return
-	}
-
-	// Validate input
-	if registerReq.Email == "" || registerReq.Password == "" || registerReq.Name == "" {
-		fmt.Println("Missing required fields in registration")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Name, email, and password are required"})
+		apierror.Write(w, r, err)
 		return
 	}
 
-	fmt.Printf("Registration attempt for email: %s\n", registerReq.Email)
-
 	// Check if user already exists
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	var existingUser models.User
-	err = h.collection.FindOne(ctx, bson.M{"email": registerReq.Email}).Decode(&existingUser)
+	registerReq.Email = models.NormalizeEmail(registerReq.Email)
+	_, err = h.users.FindByEmail(ctx, registerReq.Email)
 	if err == nil {
-		fmt.Printf("Email already in use: %s\n", registerReq.Email)
-		w.WriteHeader(http.StatusConflict)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Email already in use"})
+		logging.FromContext(r.Context()).Info("Registration rejected", "reason", "email_taken")
+		apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeEmailTaken, "Email already in use"))
 		return
-	} else if err != mongo.ErrNoDocuments {
-		fmt.Printf("Database error checking existing user: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		apierror.Write(w, r, apierror.Internal(err))
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registerReq.Password), bcrypt.DefaultCost)
 	if err != nil {
-		fmt.Printf("Error hashing password: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to hash password"})
+		apierror.Write(w, r, apierror.Internal(err))
 		return
 	}
 
-	fmt.Println("Password hashed successfully")
-
 	// Create new user
 	now := time.Now()
 	newUser := models.User{
@@ -159,34 +156,36 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		Name:      registerReq.Name,
 		Email:     registerReq.Email,
 		Password:  string(hashedPassword),
+		Role:      models.RoleUser,
 		CreatedAt: now,
 		UpdatedAt: now,
 		Location:  models.Location{}, // Initialize with empty location
 	}
 
 	// Insert user into database
-	_, err = h.collection.InsertOne(ctx, newUser)
+	err = h.users.Create(ctx, &newUser)
+	if errors.Is(err, repository.ErrDuplicate) {
+		// A concurrent registration claimed the email after our check
+		logging.FromContext(r.Context()).Info("Registration rejected", "reason", "email_taken")
+		apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeEmailTaken, "Email already in use"))
+		return
+	}
 	if err != nil {
-		fmt.Printf("Error creating user in database: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create user"})
+		apierror.Write(w, r, apierror.Internal(err))
 		return
 	}
 
-	fmt.Printf("User created successfully with ID: %s\n", newUser.ID.Hex())
-	fmt.Println("Generating JWT token")
+	logging.AddFields(r.Context(), "user_id", newUser.ID.Hex())
+	logging.FromContext(r.Context()).Info("User registered")
+	metrics.SessionSeen(newUser.ID.Hex())
 
 	// Generate JWT token
-	token, err := generateJWT(newUser)
+	token, err := h.generateJWT(newUser)
 	if err != nil {
-		fmt.Printf("Error generating token: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate token"})
+		apierror.Write(w, r, apierror.Internal(err))
 		return
 	}
 
-	fmt.Println("JWT token generated successfully")
-
 	// Remove password from response
 	newUser.Password = ""
 
@@ -197,22 +196,13 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	fmt.Println("Sending registration response")
 	json.NewEncoder(w).Encode(response)
 }
 
 // generateJWT generates a JWT token for the user
-func generateJWT(user models.User) (string, error) {
-	// Get JWT secret from environment
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		// Use a default secret if environment variable is not set
-		jwtSecret = "govtrack_jwt_secret_key_for_local_authentication"
-		fmt.Println("WARNING: Using default JWT secret. Set JWT_SECRET environment variable for production.")
-	}
-
+func (h *AuthHandler) generateJWT(user models.User) (string, error) {
 	// Set expiration time
-	expirationTime := time.Now().Add(time.Hour * 24 * 7) // 7 days
+	expirationTime := time.Now().Add(h.auth.TokenTTL)
 
 	// Create claims with user data
 	claims := jwt.MapClaims{
@@ -226,11 +216,10 @@ func generateJWT(user models.User) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// Sign token with secret
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := token.SignedString([]byte(h.auth.JWTSecret))
 	if err != nil {
-		fmt.Printf("Error signing token: %v\n", err)
 		return "", err
 	}
 
 	return tokenString, nil
-} 
\ No newline at end of file
+}
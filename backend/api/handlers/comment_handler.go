@@ -0,0 +1,843 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/metrics"
+	"github.com/benjamingetches/govtrack/moderation"
+	"github.com/benjamingetches/govtrack/ratelimit"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Orders of comment listings
+const (
+	CommentsOldest = "oldest"
+	CommentsNewest = "newest"
+	CommentsTop    = "top" // Highest score first
+)
+
+// CommentHandler handles public comments on policies and their moderation.
+// New and edited comments are checked by the content filter, which holds
+// suspicious ones for a moderator, and comments reported by enough users are
+// held too. Moderators approve, hide or remove held comments and can ban
+// their authors from commenting. Each user can only post a few comments at a
+// time.
+type CommentHandler struct {
+	comments repository.CommentRepository
+	policies repository.PolicyRepository
+	users    repository.UserRepository
+	cfg      config.CommentConfig
+	filter   moderation.Filter
+	limiter  *ratelimit.Limiter
+	audit    *Auditor
+}
+
+// NewCommentHandler creates a new CommentHandler that limits and holds
+// comments as set by cfg, checks them with filter and records changes with
+// auditor
+func NewCommentHandler(store *repository.Store, cfg config.CommentConfig, filter moderation.Filter, auditor *Auditor) *CommentHandler {
+	return &CommentHandler{
+		comments: store.Comments,
+		policies: store.Policies,
+		users:    store.Users,
+		cfg:      cfg,
+		filter:   filter,
+		limiter:  ratelimit.NewLimiter(cfg.Limit),
+		audit:    auditor,
+	}
+}
+
+// CreateComment handles POST requests to comment on a policy or reply to a
+// comment
+func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Decode and validate request body
+	var req models.PolicyCommentRequest
+	err := validation.Decode(r, &req)
+	if req.PolicyID.IsZero() {
+		err = validation.Append(err, "policy_id", "is required")
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	caller, ok := h.caller(ctx, w, r)
+	if !ok || !h.allow(w, r, caller) {
+		return
+	}
+
+	if _, err := h.policies.FindByID(ctx, req.PolicyID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Replies are only made to published comments of the same policy
+	if req.ParentID != nil {
+		parent, err := h.comments.FindByID(ctx, *req.ParentID)
+		if errors.Is(err, repository.ErrNotFound) || err == nil && !visibleComment(parent, caller) {
+			apierror.Write(w, r, apierror.NotFound("comment"))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		switch {
+		case parent.PolicyID != req.PolicyID:
+			err = validation.Append(nil, "parent_id", "is a comment on another policy")
+		case parent.Status != models.CommentPublished:
+			err = validation.Append(nil, "parent_id", "is a "+parent.Status+" comment; only published comments can be replied to")
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+	}
+
+	now := time.Now()
+	comment := models.Comment{
+		PolicyID:  req.PolicyID,
+		AuthorID:  caller.ID,
+		ParentID:  req.ParentID,
+		Body:      req.Body,
+		Status:    models.CommentPublished,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := h.check(ctx, &comment); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Insert comment into database
+	if err := h.comments.Create(ctx, &comment); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionCreate, audit.ResourceComment, comment.ID, nil, &comment)
+
+	// Return created comment as JSON
+	comment = presentComment(comment, caller)
+	w.Header().Set("ETag", commentTag(&comment))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// GetComments handles GET requests for the thread of a policy, including
+// the caller's held and hidden comments
+func (h *CommentHandler) GetComments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse query parameters for filtering
+	query := r.URL.Query()
+	policyID, err := primitive.ObjectIDFromHex(query.Get("policy_id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "policy_id must be a policy ID"))
+		return
+	}
+	order, ok := commentOrder(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	caller, ok := h.caller(ctx, w, r)
+	if !ok {
+		return
+	}
+	comments, err := h.comments.List(ctx, repository.CommentFilter{PolicyID: policyID, VisibleTo: caller.ID})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	for i := range comments {
+		comments[i] = presentComment(comments[i], caller)
+	}
+	sortComments(comments, order)
+
+	// Return comments as JSON
+	writeWithETag(w, r, commentsTag(comments), comments)
+}
+
+// GetPublicComments handles GET requests for the thread of a policy
+func (h *CommentHandler) GetPublicComments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get policy ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("policy"))
+		return
+	}
+	order, ok := commentOrder(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// The policy must exist
+	if _, err := h.policies.FindByID(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	comments, err := h.comments.List(ctx, repository.CommentFilter{PolicyID: id, Statuses: repository.ThreadStatuses})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	for i := range comments {
+		comments[i] = presentComment(comments[i], nil)
+	}
+	sortComments(comments, order)
+
+	// Return comments as JSON
+	writeWithETag(w, r, commentsTag(comments), comments)
+}
+
+// GetComment handles GET requests for a single comment
+func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get comment ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("comment"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	caller, ok := h.caller(ctx, w, r)
+	if !ok {
+		return
+	}
+	comment, err := h.comments.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) || err == nil && !visibleComment(comment, caller) {
+		apierror.Write(w, r, apierror.NotFound("comment"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return comment as JSON
+	presented := presentComment(*comment, caller)
+	writeWithETag(w, r, commentTag(&presented), presented)
+}
+
+// UpdateComment handles PUT requests by a comment's author to edit it. The
+// earlier body is kept in the comment's edits, and the new one is checked by
+// the content filter again.
+func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	var req models.CommentUpdate
+	if err := validation.Decode(r, &req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		apierror.Write(w, r, err)
+		return
+	}
+
+	comment, caller := h.change(w, r, audit.ActionUpdate, func(ctx context.Context, comment *models.Comment, caller *models.User) error {
+		if comment.AuthorID != caller.ID {
+			return forbidden("Only the author can edit a comment")
+		}
+		if comment.Status != models.CommentPublished && comment.Status != models.CommentHeld {
+			return forbidden("A " + comment.Status + " comment cannot be edited")
+		}
+		if req.Body == comment.Body {
+			return nil
+		}
+
+		now := time.Now()
+		comment.Edits = append(comment.Edits, models.CommentEdit{Body: comment.Body, EditedAt: now})
+		comment.Body = req.Body
+		comment.UpdatedAt = now
+		return h.check(ctx, comment)
+	}, h.allow)
+	if comment != nil {
+		writeComment(w, comment, caller)
+	}
+}
+
+// DeleteComment handles DELETE requests by a comment's author. The comment
+// keeps its place in the thread without its body, so that replies to it
+// still make sense.
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	comment, _ := h.change(w, r, audit.ActionDelete, func(ctx context.Context, comment *models.Comment, caller *models.User) error {
+		if comment.AuthorID != caller.ID {
+			return forbidden("Only the author can delete a comment")
+		}
+		if comment.Status == models.CommentDeleted {
+			return apierror.NotFound("comment")
+		}
+		comment.Status = models.CommentDeleted
+		comment.Body = ""
+		comment.Edits = nil
+		comment.UpdatedAt = time.Now()
+		return nil
+	}, nil)
+	if comment != nil {
+		json.NewEncoder(w).Encode(map[string]string{"message": "Comment deleted successfully"})
+	}
+}
+
+// VoteOnComment handles POST requests voting a published comment up or
+// down, or withdrawing the caller's vote. Authors cannot vote on their own
+// comments.
+func (h *CommentHandler) VoteOnComment(w http.ResponseWriter, r *http.Request) {
+	var req models.CommentVoteRequest
+	if err := validation.Decode(r, &req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Votes are not audited, like quiz submissions
+	comment, caller := h.change(w, r, "", func(ctx context.Context, comment *models.Comment, caller *models.User) error {
+		if err := requirePublished(comment); err != nil {
+			return err
+		}
+		if comment.AuthorID == caller.ID {
+			return forbidden("Authors cannot vote on their own comments")
+		}
+		if err := banned(caller); err != nil {
+			return err
+		}
+
+		votes := make([]models.CommentVote, 0, len(comment.Votes)+1)
+		for _, vote := range comment.Votes {
+			if vote.UserID != caller.ID {
+				votes = append(votes, vote)
+			}
+		}
+		if req.Value != 0 {
+			votes = append(votes, models.CommentVote{UserID: caller.ID, Value: req.Value})
+		}
+		comment.Votes = votes
+		comment.Upvotes, comment.Downvotes = 0, 0
+		for _, vote := range votes {
+			if vote.Value > 0 {
+				comment.Upvotes++
+			} else {
+				comment.Downvotes++
+			}
+		}
+		return nil
+	}, nil)
+	if comment != nil {
+		writeComment(w, comment, caller)
+	}
+}
+
+// ReportComment handles POST requests reporting a published comment to the
+// moderators. Each user's latest report counts; once enough users have
+// reported a comment it is held for moderation.
+func (h *CommentHandler) ReportComment(w http.ResponseWriter, r *http.Request) {
+	var req models.CommentReportRequest
+	if err := validation.Decode(r, &req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		apierror.Write(w, r, err)
+		return
+	}
+
+	comment, _ := h.change(w, r, audit.ActionUpdate, func(ctx context.Context, comment *models.Comment, caller *models.User) error {
+		if err := requirePublished(comment); err != nil {
+			return err
+		}
+		if comment.AuthorID == caller.ID {
+			return forbidden("Authors cannot report their own comments")
+		}
+
+		reports := make([]models.CommentReport, 0, len(comment.Reports)+1)
+		for _, report := range comment.Reports {
+			if report.ReporterID != caller.ID {
+				reports = append(reports, report)
+			}
+		}
+		comment.Reports = append(reports, models.CommentReport{
+			ReporterID: caller.ID,
+			Reason:     req.Reason,
+			Details:    req.Details,
+			ReportedAt: time.Now(),
+		})
+		if len(comment.Reports) >= h.cfg.ReportThreshold {
+			comment.Status = models.CommentHeld
+			comment.Moderation = &models.CommentModeration{Reasons: reportReasons(comment.Reports)}
+		}
+		return nil
+	}, nil)
+	if comment != nil {
+		json.NewEncoder(w).Encode(map[string]string{"message": "Comment reported; thank you"})
+	}
+}
+
+// GetModerationQueue handles GET requests by moderators for the comments
+// awaiting moderation, oldest first: held comments and published comments
+// that were reported. A status lists the comments with that status instead.
+func (h *CommentHandler) GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	caller, ok := h.moderator(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	// Parse query parameters for filtering
+	query := r.URL.Query()
+	filter := repository.CommentFilter{Queue: true, Limit: parseLimit(query.Get("limit"))}
+	if value := query.Get("policy_id"); value != "" {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "policy_id must be a policy ID"))
+			return
+		}
+		filter.PolicyID = id
+	}
+	if status := query.Get("status"); status != "" {
+		if !slices.Contains(commentStatuses, status) {
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "status must be one of published, held, hidden, removed or deleted"))
+			return
+		}
+		filter.Queue, filter.Statuses = false, []string{status}
+	}
+
+	comments, err := h.comments.List(ctx, filter)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	for i := range comments {
+		comments[i] = presentComment(comments[i], caller)
+	}
+
+	// Return comments as JSON
+	writeWithETag(w, r, commentsTag(comments), comments)
+}
+
+// ModerateComment handles POST requests by moderators approving, hiding or
+// removing a comment, or removing it and banning its author from commenting
+func (h *CommentHandler) ModerateComment(w http.ResponseWriter, r *http.Request) {
+	var req models.ModerationRequest
+	if err := validation.Decode(r, &req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		apierror.Write(w, r, err)
+		return
+	}
+
+	comment, caller := h.change(w, r, audit.ActionModerate, func(ctx context.Context, comment *models.Comment, caller *models.User) error {
+		if !caller.CanModerate() {
+			return forbidden("Only moderators can moderate comments")
+		}
+		if comment.Status == models.CommentDeleted {
+			return apierror.New(http.StatusConflict, apierror.CodeConflict, "Comment was deleted by its author")
+		}
+
+		switch req.Action {
+		case models.ModerationApprove:
+			comment.Status = models.CommentPublished
+			comment.Reports = nil
+		case models.ModerationHide:
+			comment.Status = models.CommentHidden
+		case models.ModerationRemove:
+			comment.Status = models.CommentRemoved
+		case models.ModerationBan:
+			if comment.AuthorID == caller.ID {
+				return forbidden("Moderators cannot ban themselves")
+			}
+			author, err := h.users.FindByID(ctx, comment.AuthorID)
+			if err == nil && author.CanModerate() {
+				return forbidden("Moderators cannot be banned")
+			}
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return err
+			}
+			comment.Status = models.CommentRemoved
+		}
+
+		now := time.Now()
+		moderation := models.CommentModeration{Action: req.Action, ModeratorID: &caller.ID, Note: req.Note, ModeratedAt: &now}
+		if comment.Moderation != nil {
+			moderation.Reasons = comment.Moderation.Reasons
+		}
+		comment.Moderation = &moderation
+		return nil
+	}, nil)
+	if comment == nil {
+		return
+	}
+	if req.Action == models.ModerationBan {
+		if err := h.ban(r, comment, caller, &req); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+	}
+	writeComment(w, comment, caller)
+}
+
+// LiftBan handles DELETE requests by moderators allowing a banned user to
+// comment again
+func (h *CommentHandler) LiftBan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get user ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["user_id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("user"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := h.moderator(ctx, w, r); !ok {
+		return
+	}
+
+	err = h.updateUser(r, id, func(user *models.User) error {
+		if user.CommentBan == nil {
+			return apierror.NotFound("ban")
+		}
+		user.CommentBan = nil
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return success message
+	json.NewEncoder(w).Encode(map[string]string{"message": "Ban lifted successfully"})
+}
+
+// ban bans the author of comment from commenting on behalf of the moderator
+func (h *CommentHandler) ban(r *http.Request, comment *models.Comment, moderator *models.User, req *models.ModerationRequest) error {
+	now := time.Now()
+	ban := &models.CommentBan{ModeratorID: moderator.ID, CommentID: comment.ID, Note: req.Note, BannedAt: now}
+	if req.BanDays > 0 {
+		until := now.AddDate(0, 0, req.BanDays)
+		ban.Until = &until
+	}
+	err := h.updateUser(r, comment.AuthorID, func(user *models.User) error {
+		user.CommentBan = ban
+		return nil
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		// Comments of deleted users are removed without banning anyone
+		return nil
+	}
+	return err
+}
+
+// updateUser applies change to the user with the given ID, retrying if the
+// user changes meanwhile, and audits the change
+func (h *CommentHandler) updateUser(r *http.Request, id primitive.ObjectID, change func(*models.User) error) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		before, err := h.users.FindByID(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apierror.NotFound("user")
+		}
+		if err != nil {
+			return err
+		}
+		user := *before
+		if err := change(&user); err != nil {
+			return err
+		}
+		user.UpdatedAt = time.Now()
+
+		err = h.users.Update(ctx, &user)
+		if errors.Is(err, repository.ErrRevisionConflict) && attempt < maxPatchAttempts {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		h.audit.record(r, audit.ActionUpdate, audit.ResourceUser, id, before, &user, userSecrets...)
+		return nil
+	}
+}
+
+// change applies step to the comment named by the id URL variable on behalf
+// of the caller, after limit if it is set, and records the change with
+// action unless it is empty. Changes are conditional on If-Match, if given;
+// otherwise the step is reapplied if the comment changes while it is
+// applied. It returns the written comment and the caller, or nil if an error
+// response was written.
+func (h *CommentHandler) change(w http.ResponseWriter, r *http.Request, action string, step func(ctx context.Context, comment *models.Comment, caller *models.User) error, limit func(w http.ResponseWriter, r *http.Request, caller *models.User) bool) (*models.Comment, *models.User) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get comment ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("comment"))
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	current := func() (*models.Comment, error) { return h.comments.FindByID(ctx, id) }
+
+	// Only change the revision the client expects, if any
+	expected, ok := expectedRevision(r, id, 0)
+	if !ok {
+		writePreconditionFailed(w, r, "comment", current, commentTag)
+		return nil, nil
+	}
+
+	caller, ok := h.caller(ctx, w, r)
+	if !ok || limit != nil && !limit(w, r, caller) {
+		return nil, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		before, err := h.comments.FindByID(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) || err == nil && !visibleComment(before, caller) {
+			apierror.Write(w, r, apierror.NotFound("comment"))
+			return nil, nil
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return nil, nil
+		}
+		if expected != 0 && expected != before.Revision {
+			writePreconditionFailed(w, r, "comment", current, commentTag)
+			return nil, nil
+		}
+
+		comment := *before
+		comment.Edits = append([]models.CommentEdit{}, before.Edits...)
+		comment.Votes = append([]models.CommentVote{}, before.Votes...)
+		comment.Reports = append([]models.CommentReport{}, before.Reports...)
+		if err := step(ctx, &comment, caller); err != nil {
+			apierror.Write(w, r, err)
+			return nil, nil
+		}
+
+		err = h.comments.Update(ctx, &comment)
+		switch {
+		case errors.Is(err, repository.ErrRevisionConflict) && expected == 0 && attempt < maxPatchAttempts:
+			continue
+		case errors.Is(err, repository.ErrRevisionConflict):
+			writePreconditionFailed(w, r, "comment", current, commentTag)
+			return nil, nil
+		case errors.Is(err, repository.ErrNotFound):
+			apierror.Write(w, r, apierror.NotFound("comment"))
+			return nil, nil
+		case err != nil:
+			apierror.Write(w, r, err)
+			return nil, nil
+		}
+		if action != "" {
+			h.audit.record(r, action, audit.ResourceComment, id, before, &comment)
+		}
+		return &comment, caller
+	}
+}
+
+// check runs the content filter on comment's body, holding the comment for
+// moderation if the filter finds it suspicious. Held comments stay held
+// until a moderator approves them, even if an edit removes what held them.
+func (h *CommentHandler) check(ctx context.Context, comment *models.Comment) error {
+	verdict, err := h.filter.Check(ctx, comment.Body)
+	if err != nil {
+		return err
+	}
+	if verdict.Hold {
+		comment.Status = models.CommentHeld
+		comment.Moderation = &models.CommentModeration{Reasons: verdict.Reasons}
+	}
+	metrics.CommentPosted(comment.Status)
+	return nil
+}
+
+// caller reads the user making the request, writing an error response if
+// there is none. Roles and bans are read for every request, so changes to
+// them apply at once.
+func (h *CommentHandler) caller(ctx context.Context, w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return nil, false
+	}
+	caller, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "User no longer exists"))
+			return nil, false
+		}
+		apierror.Write(w, r, err)
+		return nil, false
+	}
+	return caller, true
+}
+
+// moderator reads the user making the request, writing an error response
+// unless they are a moderator
+func (h *CommentHandler) moderator(ctx context.Context, w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	caller, ok := h.caller(ctx, w, r)
+	if !ok {
+		return nil, false
+	}
+	if !caller.CanModerate() {
+		apierror.Write(w, r, forbidden("Only moderators can moderate comments"))
+		return nil, false
+	}
+	return caller, true
+}
+
+// allow takes one of the caller's comments, writing an error response if
+// they are banned or have posted too many comments recently
+func (h *CommentHandler) allow(w http.ResponseWriter, r *http.Request, caller *models.User) bool {
+	if err := banned(caller); err != nil {
+		apierror.Write(w, r, err)
+		return false
+	}
+	result := h.limiter.Allow(caller.ID.Hex())
+	if !result.Allowed {
+		metrics.RateLimited("comments")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+		apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many comments, please wait before posting again"))
+		return false
+	}
+	return true
+}
+
+// banned returns an error if user is banned from commenting
+func banned(user *models.User) error {
+	if !user.CommentBan.Active(time.Now()) {
+		return nil
+	}
+	message := "You are banned from commenting"
+	if until := user.CommentBan.Until; until != nil {
+		message += " until " + until.UTC().Format(time.RFC3339)
+	}
+	return apierror.New(http.StatusForbidden, apierror.CodeBanned, message)
+}
+
+// requirePublished returns a conflict unless comment is published
+func requirePublished(comment *models.Comment) error {
+	if comment.Status == models.CommentPublished {
+		return nil
+	}
+	return apierror.New(http.StatusConflict, apierror.CodeConflict, "Comment is "+comment.Status+"; only published comments can be voted on or reported")
+}
+
+// commentStatuses lists every comment status
+var commentStatuses = []string{models.CommentPublished, models.CommentHeld, models.CommentHidden, models.CommentRemoved, models.CommentDeleted}
+
+// visibleComment reports whether user can see comment. Held and hidden
+// comments are only seen by their author and moderators.
+func visibleComment(comment *models.Comment, user *models.User) bool {
+	return slices.Contains(repository.ThreadStatuses, comment.Status) || comment.AuthorID == user.ID || user.CanModerate()
+}
+
+// presentComment prepares comment for viewer, who is nil on public routes.
+// Voters are never shown, and only moderators see reports, who moderated a
+// comment, and what removed comments said.
+func presentComment(comment models.Comment, viewer *models.User) models.Comment {
+	if viewer != nil {
+		for _, vote := range comment.Votes {
+			if vote.UserID == viewer.ID {
+				comment.MyVote = vote.Value
+			}
+		}
+		if viewer.CanModerate() {
+			return comment
+		}
+	}
+	comment.Reports = nil
+	if comment.Status == models.CommentRemoved {
+		comment.Body = ""
+		comment.Edits = nil
+	}
+	if comment.Moderation != nil {
+		moderation := *comment.Moderation
+		moderation.ModeratorID = nil
+		comment.Moderation = &moderation
+	}
+	return comment
+}
+
+// writeComment writes comment as presented to viewer with its ETag
+func writeComment(w http.ResponseWriter, comment *models.Comment, viewer *models.User) {
+	presented := presentComment(*comment, viewer)
+	w.Header().Set("ETag", commentTag(&presented))
+	json.NewEncoder(w).Encode(presented)
+}
+
+// commentOrder parses the sort query parameter, writing an error response if
+// it is invalid
+func commentOrder(w http.ResponseWriter, r *http.Request) (string, bool) {
+	order := r.URL.Query().Get("sort")
+	switch order {
+	case "":
+		return CommentsOldest, true
+	case CommentsOldest, CommentsNewest, CommentsTop:
+		return order, true
+	}
+	apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "sort must be oldest, newest or top"))
+	return "", false
+}
+
+// sortComments orders comments, which are listed oldest first
+func sortComments(comments []models.Comment, order string) {
+	switch order {
+	case CommentsNewest:
+		sort.SliceStable(comments, func(i, j int) bool { return comments[i].CreatedAt.After(comments[j].CreatedAt) })
+	case CommentsTop:
+		sort.SliceStable(comments, func(i, j int) bool { return comments[i].Score() > comments[j].Score() })
+	}
+}
+
+// reportReasons returns the distinct reasons of reports, in the order they
+// were first given
+func reportReasons(reports []models.CommentReport) []string {
+	var reasons []string
+	for _, report := range reports {
+		reason := "reported as " + report.Reason
+		if !slices.Contains(reasons, reason) {
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/httpcache"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// writeWithETag writes v as JSON with an ETag, or responds 304 Not Modified
+// if the client's If-None-Match shows its copy is current
+func writeWithETag(w http.ResponseWriter, r *http.Request, etag string, v interface{}) {
+	w.Header().Set("ETag", etag)
+	if httpcache.NoneMatch(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+// expectedRevision returns the revision of the document with the given ID
+// that a write expects to replace, from the If-Match header or else the
+// revision in the body. Zero means the write is unconditional. It returns
+// false if the precondition cannot hold: If-Match names no revision of the
+// document, or a different one than the body.
+func expectedRevision(r *http.Request, id primitive.ObjectID, body int64) (int64, bool) {
+	revision, ok := httpcache.IfMatch(r.Header.Get("If-Match"), id)
+	switch {
+	case !ok:
+		return 0, false
+	case revision == 0:
+		return body, true
+	case body != 0 && body != revision:
+		return 0, false
+	}
+	return revision, true
+}
+
+// writePreconditionFailed responds 412 Precondition Failed with the stored
+// document returned by find and its ETag, or 404 if it no longer exists
+func writePreconditionFailed[T any](w http.ResponseWriter, r *http.Request, resource string, find func() (*T, error), etag func(*T) string) {
+	current, err := find()
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound(resource))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(current))
+	apierror.Write(w, r, apierror.PreconditionFailed(resource, current))
+}
+
+// policyTag returns the ETag of a policy
+func policyTag(policy *models.Policy) string {
+	return httpcache.VersionTag(policy.ID, policy.Revision)
+}
+
+// policiesTag returns the ETag of a list of policies
+func policiesTag(policies []models.Policy) string {
+	tags := make([]string, len(policies))
+	for i := range policies {
+		tags[i] = policyTag(&policies[i])
+	}
+	return httpcache.CombinedTag(tags)
+}
+
+// representativeTag returns the ETag of a representative
+func representativeTag(representative *models.Representative) string {
+	return httpcache.VersionTag(representative.ID, representative.Revision)
+}
+
+// representativesTag returns the ETag of a list of representatives
+func representativesTag(representatives []models.Representative) string {
+	tags := make([]string, len(representatives))
+	for i := range representatives {
+		tags[i] = representativeTag(&representatives[i])
+	}
+	return httpcache.CombinedTag(tags)
+}
+
+// userTag returns the ETag of a user
+func userTag(user *models.User) string {
+	return httpcache.VersionTag(user.ID, user.Revision)
+}
+
+// quizTag returns the ETag of a quiz
+func quizTag(quiz *models.PoliticalQuiz) string {
+	return httpcache.VersionTag(quiz.ID, quiz.Revision)
+}
+
+// summaryTag returns the ETag of a summary
+func summaryTag(summary *models.Summary) string {
+	return httpcache.VersionTag(summary.ID, summary.Revision)
+}
+
+// annotationTag returns the ETag of an annotation
+func annotationTag(annotation *models.Annotation) string {
+	return httpcache.VersionTag(annotation.ID, annotation.Revision)
+}
+
+// annotationsTag returns the ETag of a list of annotations
+func annotationsTag(annotations []models.Annotation) string {
+	tags := make([]string, len(annotations))
+	for i := range annotations {
+		tags[i] = annotationTag(&annotations[i])
+	}
+	return httpcache.CombinedTag(tags)
+}
+
+// commentTag returns the ETag of a comment
+func commentTag(comment *models.Comment) string {
+	return httpcache.VersionTag(comment.ID, comment.Revision)
+}
+
+// commentsTag returns the ETag of a list of comments
+func commentsTag(comments []models.Comment) string {
+	tags := make([]string, len(comments))
+	for i := range comments {
+		tags[i] = commentTag(&comments[i])
+	}
+	return httpcache.CombinedTag(tags)
+}
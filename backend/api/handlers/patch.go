@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/patch"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxPatchAttempts bounds how often a patch without a precondition is
+// reapplied after losing a race with another write
+const maxPatchAttempts = 3
+
+// patchTarget describes how PATCH requests change one kind of document
+type patchTarget[T any] struct {
+	resource string
+	find     func(ctx context.Context, id primitive.ObjectID) (*T, error)
+	patch    func(ctx context.Context, doc *T, fields []string) error
+	revision func(*T) *int64
+	etag     func(*T) string
+
+	// prepare restores the fields clients may not change from stored and
+	// completes patched before it is compared with stored and written
+	prepare func(stored, patched *T) error
+	// touch sets the update time of a document that changed
+	touch func(*T)
+	// written, if set, is called after a change is written
+	written func(r *http.Request, stored, patched *T)
+	// redact, if set, clears fields that are never shown to clients
+	redact func(*T)
+	// duplicate, if set, is the error for a patch that violates a unique index
+	duplicate error
+
+	// audit records the changes; the values of the secret fields are left out
+	audit  *Auditor
+	secret []string
+}
+
+// servePatch applies the request's JSON Merge Patch or JSON Patch to the
+// document named by the id URL variable. The patch is applied to the JSON
+// form of the stored document and the result is validated like a PUT body;
+// only the top-level fields that changed are written. Like PUT, the write is
+// conditional on If-Match or a revision set by the patch. Patches without a
+// precondition are reapplied if the document changes while they are applied.
+func servePatch[T any](w http.ResponseWriter, r *http.Request, target patchTarget[T]) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID(target.resource))
+		return
+	}
+
+	// Only patch formats are accepted, so a full document sent by mistake
+	// cannot wipe fields
+	contentType := r.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != patch.MergePatchType && mediaType != patch.JSONPatchType {
+		w.Header().Set("Accept-Patch", patch.Accepted)
+		apierror.Write(w, r, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMedia, "PATCH requires a Content-Type of "+patch.MergePatchType+" or "+patch.JSONPatchType))
+		return
+	}
+	body, err := validation.ReadBody(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	current := func() (*T, error) {
+		doc, err := target.find(ctx, id)
+		if doc != nil && target.redact != nil {
+			target.redact(doc)
+		}
+		return doc, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		stored, err := target.find(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				apierror.Write(w, r, apierror.NotFound(target.resource))
+				return
+			}
+			apierror.Write(w, r, err)
+			return
+		}
+
+		patched, err := applyPatch(contentType, body, stored, target.redact)
+		if err == nil {
+			err = target.prepare(stored, patched)
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// A patch that sets the revision expects it, as a PUT body would
+		revision := *target.revision(stored)
+		var bodyRevision int64
+		if patchedRevision := *target.revision(patched); patchedRevision != revision {
+			bodyRevision = patchedRevision
+		}
+		expected, ok := expectedRevision(r, id, bodyRevision)
+		if !ok || (expected != 0 && expected != revision) {
+			writePreconditionFailed(w, r, target.resource, current, target.etag)
+			return
+		}
+		*target.revision(patched) = revision
+
+		// Nothing is written for a patch that changes nothing
+		fields, err := repository.ChangedFields(stored, patched)
+		if err == nil && len(fields) > 0 {
+			target.touch(patched)
+			fields, err = repository.ChangedFields(stored, patched)
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		if len(fields) == 0 {
+			writePatched(w, stored, target)
+			return
+		}
+
+		err = target.patch(ctx, patched, fields)
+		switch {
+		case err == nil:
+			target.audit.record(r, audit.ActionUpdate, target.resource, id, stored, patched, target.secret...)
+			if target.written != nil {
+				target.written(r, stored, patched)
+			}
+			writePatched(w, patched, target)
+		case errors.Is(err, repository.ErrRevisionConflict) && expected == 0 && attempt < maxPatchAttempts:
+			continue
+		case errors.Is(err, repository.ErrRevisionConflict):
+			writePreconditionFailed(w, r, target.resource, current, target.etag)
+		case errors.Is(err, repository.ErrNotFound):
+			apierror.Write(w, r, apierror.NotFound(target.resource))
+		case errors.Is(err, repository.ErrDuplicate) && target.duplicate != nil:
+			apierror.Write(w, r, target.duplicate)
+		default:
+			apierror.Write(w, r, err)
+		}
+		return
+	}
+}
+
+// applyPatch applies a patch to the JSON form of stored and decodes and
+// validates the result
+func applyPatch[T any](contentType string, body []byte, stored *T, redact func(*T)) (*T, error) {
+	view := *stored
+	if redact != nil {
+		redact(&view)
+	}
+	doc, err := json.Marshal(view)
+	if err != nil {
+		return nil, apierror.Internal(err)
+	}
+
+	result, err := patch.Apply(contentType, doc, body)
+	var failed *patch.FailedError
+	if errors.As(err, &failed) {
+		return nil, apierror.New(http.StatusConflict, apierror.CodePatchFailed, "Operation "+strconv.Itoa(failed.Index)+" failed: "+failed.Reason)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var patched T
+	if err := validation.DecodeJSON(result, &patched); err != nil {
+		return nil, err
+	}
+	return &patched, nil
+}
+
+// writePatched writes the patched document with its ETag
+func writePatched[T any](w http.ResponseWriter, doc *T, target patchTarget[T]) {
+	if target.redact != nil {
+		target.redact(doc)
+	}
+	w.Header().Set("ETag", target.etag(doc))
+	json.NewEncoder(w).Encode(doc)
+}
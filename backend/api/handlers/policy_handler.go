@@ -1,276 +1,403 @@
-package handlers
-
-import (
-	"context"
-	"encoding/json"
-	"net/http"
-	"time"
-
-	"github.com/benjamingetches/govtrack/api/models"
-	"github.com/benjamingetches/govtrack/config"
-	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-// PolicyHandler handles policy-related API endpoints
-type PolicyHandler struct {
-	collection *mongo.Collection
-}
-
-// NewPolicyHandler creates a new PolicyHandler
-func NewPolicyHandler(client *mongo.Client) *PolicyHandler {
-	collection := config.GetCollection(config.PoliciesCollection)
-	return &PolicyHandler{
-		collection: collection,
-	}
-}
-
-// GetPolicy handles GET requests for a single policy
-func (h *PolicyHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get policy ID from URL
-	params := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(params["id"])
-	if err != nil {
-		http.Error(w, "Invalid policy ID", http.StatusBadRequest)
-		return
-	}
-
-	// Find policy in database
-	var policy models.Policy
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	err = h.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&policy)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "Policy not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Return policy as JSON
-	json.NewEncoder(w).Encode(policy)
-}
-
-// GetPolicies handles GET requests for multiple policies with filtering
-func (h *PolicyHandler) GetPolicies(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Parse query parameters for filtering
-	query := bson.M{}
-
-	// Filter by level (federal, state, local)
-	if level := r.URL.Query().Get("level"); level != "" {
-		query["level"] = level
-	}
-
-	// Filter by jurisdiction
-	if state := r.URL.Query().Get("state"); state != "" {
-		query["jurisdiction.state"] = state
-	}
-	if city := r.URL.Query().Get("city"); city != "" {
-		query["jurisdiction.city"] = city
-	}
-
-	// Filter by status
-	if status := r.URL.Query().Get("status"); status != "" {
-		query["status"] = status
-	}
-
-	// Filter by type
-	if policyType := r.URL.Query().Get("type"); policyType != "" {
-		query["type"] = policyType
-	}
-
-	// Parse pagination parameters
-	var limit int64 = 10
-	//var page int64 = 1
-	var skip int64 = 0
-
-	// Find policies in database
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	options := options.Find()
-	options.SetLimit(limit)
-	options.SetSkip(skip)
-	options.SetSort(bson.M{"introduced_date": -1}) // Sort by introduced date, newest first
-
-	cursor, err := h.collection.Find(ctx, query, options)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	// Decode policies from cursor
-	var policies []models.Policy
-	if err := cursor.All(ctx, &policies); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Return policies as JSON
-	json.NewEncoder(w).Encode(policies)
-}
-
-// CreatePolicy handles POST requests to create a new policy
-func (h *PolicyHandler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Decode request body
-	var policy models.Policy
-	err := json.NewDecoder(r.Body).Decode(&policy)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Set last updated time
-	policy.LastUpdated = time.Now()
-
-	// Insert policy into database
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	result, err := h.collection.InsertOne(ctx, policy)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Set ID from insert result
-	policy.ID = result.InsertedID.(primitive.ObjectID)
-
-	// Return created policy as JSON
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(policy)
-}
-
-// UpdatePolicy handles PUT requests to update a policy
-func (h *PolicyHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get policy ID from URL
-	params := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(params["id"])
-	if err != nil {
-		http.Error(w, "Invalid policy ID", http.StatusBadRequest)
-		return
-	}
-
-	// Decode request body
-	var policy models.Policy
-	err = json.NewDecoder(r.Body).Decode(&policy)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Set last updated time
-	policy.LastUpdated = time.Now()
-
-	// Update policy in database
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	result, err := h.collection.ReplaceOne(ctx, bson.M{"_id": id}, policy)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if result.MatchedCount == 0 {
-		http.Error(w, "Policy not found", http.StatusNotFound)
-		return
-	}
-
-	// Return updated policy as JSON
-	policy.ID = id
-	json.NewEncoder(w).Encode(policy)
-}
-
-// DeletePolicy handles DELETE requests to delete a policy
-func (h *PolicyHandler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get policy ID from URL
-	params := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(params["id"])
-	if err != nil {
-		http.Error(w, "Invalid policy ID", http.StatusBadRequest)
-		return
-	}
-
-	// Delete policy from database
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	result, err := h.collection.DeleteOne(ctx, bson.M{"_id": id})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if result.DeletedCount == 0 {
-		http.Error(w, "Policy not found", http.StatusNotFound)
-		return
-	}
-
-	// Return success message
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Policy deleted successfully"})
-}
-
-// GetPoliciesByLocation handles GET requests to find policies by location
-func (h *PolicyHandler) GetPoliciesByLocation(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Parse location parameters
-	state := r.URL.Query().Get("state")
-	city := r.URL.Query().Get("city")
-
-	if state == "" {
-		http.Error(w, "State parameter is required", http.StatusBadRequest)
-		return
-	}
-
-	// Build query based on location
-	query := bson.M{}
-	query["jurisdiction.state"] = state
-
-	if city != "" {
-		query["jurisdiction.city"] = city
-	}
-
-	// Find policies in database
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	options := options.Find()
-	options.SetSort(bson.M{"introduced_date": -1}) // Sort by introduced date, newest first
-
-	cursor, err := h.collection.Find(ctx, query, options)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	// Decode policies from cursor
-	var policies []models.Policy
-	if err := cursor.All(ctx, &policies); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Return policies as JSON
-	json.NewEncoder(w).Encode(policies)
-}
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/benjamingetches/govtrack/annotate"
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/legislation"
+	"github.com/benjamingetches/govtrack/logging"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// simplifiedDescReadOnly explains why policies' simplified descriptions
+// cannot be written directly
+const simplifiedDescReadOnly = "is read-only; it is set by publishing a reviewed summary"
+
+// PolicyHandler handles policy-related API endpoints. Writing a new version
+// of a policy's original text moves its annotations to the new version.
+type PolicyHandler struct {
+	policies   repository.PolicyRepository
+	reanchorer *annotate.Reanchorer
+	audit      *Auditor
+}
+
+// NewPolicyHandler creates a new PolicyHandler that moves annotations with
+// reanchorer and records changes with auditor
+func NewPolicyHandler(policies repository.PolicyRepository, reanchorer *annotate.Reanchorer, auditor *Auditor) *PolicyHandler {
+	return &PolicyHandler{
+		policies:   policies,
+		reanchorer: reanchorer,
+		audit:      auditor,
+	}
+}
+
+// GetPolicy handles GET requests for a single policy
+func (h *PolicyHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get policy ID from URL
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("policy"))
+		return
+	}
+
+	// Find policy in database
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	policy, err := h.policies.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return policy as JSON
+	writeWithETag(w, r, policyTag(policy), policy)
+}
+
+// GetPolicySections handles GET requests for the structure of a policy's
+// original text
+func (h *PolicyHandler) GetPolicySections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	policy, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+
+	// Return sections as JSON
+	sections := policy.Sections
+	if sections == nil {
+		sections = []models.Provision{}
+	}
+	writeWithETag(w, r, policyTag(policy), sections)
+}
+
+// GetPolicySection handles GET requests for a single provision of a
+// policy's original text by its anchor
+func (h *PolicyHandler) GetPolicySection(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	policy, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+
+	// Find the provision and the provisions containing it
+	provision, path := legislation.Find(policy.Sections, mux.Vars(r)["anchor"])
+	if provision == nil {
+		apierror.Write(w, r, apierror.NotFound("section"))
+		return
+	}
+	section := models.PolicySection{
+		PolicyID:  policy.ID,
+		Citation:  policy.Title + " " + legislation.Cite(path, *provision),
+		Path:      make([]models.ProvisionRef, len(path)),
+		Provision: *provision,
+	}
+	for i, p := range path {
+		section.Path[i] = models.ProvisionRef{Anchor: p.Anchor, Kind: p.Kind, Number: p.Number, Heading: p.Heading}
+	}
+
+	// Return section as JSON
+	writeWithETag(w, r, policyTag(policy), section)
+}
+
+// find reads the policy whose ID is in the URL, writing an error response
+// if there is none
+func (h *PolicyHandler) find(w http.ResponseWriter, r *http.Request) (*models.Policy, bool) {
+	// Get policy ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("policy"))
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	policy, err := h.policies.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return nil, false
+		}
+		apierror.Write(w, r, err)
+		return nil, false
+	}
+	return policy, true
+}
+
+// GetPolicies handles GET requests for multiple policies with filtering
+func (h *PolicyHandler) GetPolicies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse query parameters for filtering
+	query := r.URL.Query()
+	filter := repository.PolicyFilter{
+		Level:  query.Get("level"),
+		State:  query.Get("state"),
+		City:   query.Get("city"),
+		Status: query.Get("status"),
+		Type:   query.Get("type"),
+		Limit:  10,
+	}
+
+	// Find policies in database
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	policies, err := h.policies.List(ctx, filter)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return policies as JSON
+	writeWithETag(w, r, policiesTag(policies), policies)
+}
+
+// CreatePolicy handles POST requests to create a new policy
+func (h *PolicyHandler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Decode and validate request body
+	var policy models.Policy
+	err := validation.Decode(r, &policy)
+	if policy.SimplifiedDesc != "" {
+		err = validation.Append(err, "simplified_desc", simplifiedDescReadOnly)
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Set last updated time; policies are only trashed through DELETE
+	policy.LastUpdated = time.Now()
+	policy.Summary, policy.Readability = nil, nil
+	policy.Sections, policy.TextVersion = legislation.Parse(policy.OriginalText), 1
+	policy.DeletedAt, policy.DeletedBy = nil, nil
+
+	// Insert policy into database
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.policies.Create(ctx, &policy); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionCreate, audit.ResourcePolicy, policy.ID, nil, &policy)
+
+	// Return created policy as JSON
+	w.Header().Set("ETag", policyTag(&policy))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(policy)
+}
+
+// UpdatePolicy handles PUT requests to update a policy
+func (h *PolicyHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get policy ID from URL
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("policy"))
+		return
+	}
+
+	// Decode and validate request body
+	var policy models.Policy
+	err = validation.Decode(r, &policy)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	current := func() (*models.Policy, error) { return h.policies.FindByID(ctx, id) }
+
+	// Only replace the revision the client expects, if any
+	revision, ok := expectedRevision(r, id, policy.Revision)
+	if !ok {
+		writePreconditionFailed(w, r, "policy", current, policyTag)
+		return
+	}
+
+	// Read the stored policy for the audit log
+	before, err := h.policies.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// The simplified description is only changed by publishing a summary
+	if policy.SimplifiedDesc != "" && policy.SimplifiedDesc != before.SimplifiedDesc {
+		apierror.Write(w, r, validation.Append(nil, "simplified_desc", simplifiedDescReadOnly))
+		return
+	}
+	policy.SimplifiedDesc, policy.Summary, policy.Readability = before.SimplifiedDesc, before.Summary, before.Readability
+	policy.Sections, policy.TextVersion = legislation.Parse(policy.OriginalText), nextTextVersion(before, &policy)
+
+	// Ensure ID matches path parameter and set last updated time
+	policy.ID = id
+	policy.Revision = revision
+	policy.LastUpdated = time.Now()
+	policy.DeletedAt, policy.DeletedBy = nil, nil
+
+	// Update policy in database
+	err = h.policies.Update(ctx, &policy)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			apierror.Write(w, r, apierror.NotFound("policy"))
+		case errors.Is(err, repository.ErrRevisionConflict):
+			writePreconditionFailed(w, r, "policy", current, policyTag)
+		default:
+			apierror.Write(w, r, err)
+		}
+		return
+	}
+	h.audit.record(r, audit.ActionUpdate, audit.ResourcePolicy, id, before, &policy)
+	h.reanchor(r, before, &policy)
+
+	// Return updated policy as JSON
+	w.Header().Set("ETag", policyTag(&policy))
+	json.NewEncoder(w).Encode(policy)
+}
+
+// PatchPolicy handles PATCH requests to change some fields of a policy
+func (h *PolicyHandler) PatchPolicy(w http.ResponseWriter, r *http.Request) {
+	servePatch(w, r, patchTarget[models.Policy]{
+		resource: "policy",
+		find:     h.policies.FindByID,
+		patch:    h.policies.Patch,
+		revision: func(p *models.Policy) *int64 { return &p.Revision },
+		etag:     policyTag,
+		audit:    h.audit,
+		prepare: func(stored, patched *models.Policy) error {
+			// The simplified description is only changed by publishing a summary
+			if patched.SimplifiedDesc != stored.SimplifiedDesc {
+				return validation.Append(nil, "simplified_desc", simplifiedDescReadOnly)
+			}
+			patched.ID = stored.ID
+			patched.Summary, patched.Readability = stored.Summary, stored.Readability
+			patched.Sections, patched.TextVersion = legislation.Parse(patched.OriginalText), nextTextVersion(stored, patched)
+			patched.LastUpdated = stored.LastUpdated
+			patched.DeletedAt, patched.DeletedBy = stored.DeletedAt, stored.DeletedBy
+			return nil
+		},
+		touch:   func(p *models.Policy) { p.LastUpdated = time.Now() },
+		written: h.reanchor,
+	})
+}
+
+// nextTextVersion returns the text version of policy, replacing stored
+func nextTextVersion(stored, policy *models.Policy) int64 {
+	if policy.OriginalText != stored.OriginalText {
+		return stored.TextVersion + 1
+	}
+	return stored.TextVersion
+}
+
+// reanchor moves the annotations of policy to a new version of its text
+// written in place of stored. The policy has been written either way, so
+// failures are only logged.
+func (h *PolicyHandler) reanchor(r *http.Request, stored, policy *models.Policy) {
+	if policy.TextVersion == stored.TextVersion {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	result, err := h.reanchorer.Reanchor(ctx, policy)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error moving annotations", "error", err, "policy_id", policy.ID.Hex())
+		return
+	}
+	logging.AddFields(r.Context(), "annotations_moved", result.Moved, "annotations_orphaned", result.Orphaned)
+}
+
+// DeletePolicy handles DELETE requests to move a policy to the trash
+func (h *PolicyHandler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get policy ID from URL
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("policy"))
+		return
+	}
+
+	// Move policy to the trash
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	before, err := h.policies.FindByID(ctx, id)
+	if err == nil {
+		by, _ := callerID(r)
+		err = h.policies.Delete(ctx, id, by, time.Now())
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionDelete, audit.ResourcePolicy, id, before, nil)
+
+	// Return success message
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Policy deleted successfully"})
+}
+
+// GetPoliciesByLocation handles GET requests to find policies by location
+func (h *PolicyHandler) GetPoliciesByLocation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse location parameters
+	state := r.URL.Query().Get("state")
+	city := r.URL.Query().Get("city")
+
+	if state == "" {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "State parameter is required"))
+		return
+	}
+
+	// Find policies in database
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	policies, err := h.policies.List(ctx, repository.PolicyFilter{State: state, City: city})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return policies as JSON
+	writeWithETag(w, r, policiesTag(policies), policies)
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/httpcache"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/metrics"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PollHandler handles the polls of policies, asking users how they would
+// vote on them, and the comparison of representatives' votes with how their
+// constituents answered. Ballots are anonymous in results, which only show
+// states and districts with enough ballots, and are not audited.
+type PollHandler struct {
+	ballots         repository.BallotRepository
+	policies        repository.PolicyRepository
+	representatives repository.RepresentativeRepository
+	users           repository.UserRepository
+	cfg             config.PollConfig
+}
+
+// NewPollHandler creates a new PollHandler that shows the results of states
+// and districts with at least cfg.MinBallots ballots
+func NewPollHandler(store *repository.Store, cfg config.PollConfig) *PollHandler {
+	return &PollHandler{
+		ballots:         store.Ballots,
+		policies:        store.Policies,
+		representatives: store.Representatives,
+		users:           store.Users,
+		cfg:             cfg,
+	}
+}
+
+// GetPoll handles GET requests for the results of a policy's poll with the
+// caller's choice
+func (h *PollHandler) GetPoll(w http.ResponseWriter, r *http.Request) {
+	h.writeResults(w, r, true)
+}
+
+// GetPublicPoll handles GET requests for the results of a policy's poll
+func (h *PollHandler) GetPublicPoll(w http.ResponseWriter, r *http.Request) {
+	h.writeResults(w, r, false)
+}
+
+// CastBallot handles PUT requests answering a policy's poll, or changing the
+// caller's answer. The caller's state and congressional district are taken
+// from their location at the time.
+func (h *PollHandler) CastBallot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get policy ID from URL
+	policyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("policy"))
+		return
+	}
+
+	// Decode and validate request body
+	var req models.BallotRequest
+	if err := validation.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return
+	}
+	user, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "User no longer exists"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	if err := h.requirePolicy(ctx, policyID); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	state := normalizeState(user.Location.State)
+	ballot := models.Ballot{
+		PolicyID: policyID,
+		UserID:   user.ID,
+		Choice:   req.Choice,
+		State:    state,
+		CastAt:   time.Now(),
+	}
+	if state != "" {
+		ballot.District = normalizeDistrict(state, user.Location.CongressionalDistrict)
+	}
+	if err := h.ballots.Cast(ctx, &ballot); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "Another ballot was cast at the same time; try again"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	metrics.BallotCast(ballot.Choice)
+
+	// Return ballot as JSON
+	json.NewEncoder(w).Encode(ballot)
+}
+
+// WithdrawBallot handles DELETE requests withdrawing the caller's answer to
+// a policy's poll
+func (h *PollHandler) WithdrawBallot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get policy ID from URL
+	policyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("policy"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return
+	}
+	if err := h.ballots.Withdraw(ctx, policyID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("ballot"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return success message
+	json.NewEncoder(w).Encode(map[string]string{"message": "Ballot withdrawn successfully"})
+}
+
+// GetConstituentAlignment handles GET requests comparing a representative's
+// votes with the majority of their constituents in the polls of the same
+// policies
+func (h *PollHandler) GetConstituentAlignment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get representative ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("representative"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	representative, err := h.representatives.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("representative"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	votes, err := h.policies.VotesByRepresentative(ctx, id)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	policyIDs := make([]primitive.ObjectID, len(votes))
+	for i, vote := range votes {
+		policyIDs[i] = vote.PolicyID
+	}
+	counts, err := h.ballots.Count(ctx, policyIDs)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Tally the ballots of the representative's constituents by policy
+	state, district := constituency(representative)
+	tallies := map[primitive.ObjectID]*models.Tally{}
+	for _, count := range counts {
+		if state == "" || count.State != state || district != "" && count.District != district {
+			continue
+		}
+		tally, ok := tallies[count.PolicyID]
+		if !ok {
+			tally = &models.Tally{}
+			tallies[count.PolicyID] = tally
+		}
+		tally.Add(count.Choice, count.Count)
+	}
+
+	alignment := models.ConstituentAlignment{
+		RepresentativeID: id,
+		State:            state,
+		District:         district,
+		Policies:         make([]models.PolicyAlignment, 0, len(votes)),
+	}
+	for _, vote := range votes {
+		policy := models.PolicyAlignment{PolicyID: vote.PolicyID, Title: vote.Title, Vote: vote.Vote.Vote}
+		if tally := tallies[vote.PolicyID]; tally != nil && tally.Total >= int64(h.cfg.MinBallots) {
+			policy.Constituents = tally
+			if tally.Majority != "" && (policy.Vote == models.VoteYes || policy.Vote == models.VoteNo) {
+				aligned := policy.Vote == tally.Majority
+				policy.Aligned = &aligned
+				alignment.Compared++
+				if aligned {
+					alignment.Aligned++
+				}
+			}
+		}
+		alignment.Policies = append(alignment.Policies, policy)
+	}
+	if alignment.Compared > 0 {
+		rate := 100 * float64(alignment.Aligned) / float64(alignment.Compared)
+		alignment.AlignmentRate = &rate
+	}
+
+	// Alignment comes from policies and ballots, so its tag is derived from the body
+	writeDerived(w, r, alignment)
+}
+
+// writeResults writes the results of the poll of the policy named by the id
+// URL variable, with the caller's choice if mine is set
+func (h *PollHandler) writeResults(w http.ResponseWriter, r *http.Request, mine bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get policy ID from URL
+	policyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("policy"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.requirePolicy(ctx, policyID); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	counts, err := h.ballots.Count(ctx, []primitive.ObjectID{policyID})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	results := tallyResults(policyID, counts, h.cfg.MinBallots)
+
+	if mine {
+		userID, ok := callerID(r)
+		if !ok {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+			return
+		}
+		ballot, err := h.ballots.Find(ctx, policyID, userID)
+		switch {
+		case err == nil:
+			results.MyChoice = ballot.Choice
+		case !errors.Is(err, repository.ErrNotFound):
+			apierror.Write(w, r, err)
+			return
+		}
+	}
+
+	// Results are counted from ballots, so their tag is derived from the body
+	writeDerived(w, r, results)
+}
+
+// requirePolicy returns a not found error unless the policy exists
+func (h *PollHandler) requirePolicy(ctx context.Context, id primitive.ObjectID) error {
+	_, err := h.policies.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return apierror.NotFound("policy")
+	}
+	return err
+}
+
+// writeDerived writes v with an ETag derived from its JSON form
+func writeDerived(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal(err))
+		return
+	}
+	writeWithETag(w, r, httpcache.BodyTag(body), v)
+}
+
+// tallyResults counts the ballots on a policy overall and by state and
+// district, leaving out states and districts with fewer than minBallots
+func tallyResults(policyID primitive.ObjectID, counts []models.BallotCount, minBallots int) models.PollResults {
+	results := models.PollResults{
+		PolicyID:   policyID,
+		States:     make([]models.DistrictTally, 0),
+		Districts:  make([]models.DistrictTally, 0),
+		MinBallots: minBallots,
+	}
+	states := map[string]*models.DistrictTally{}
+	districts := map[[2]string]*models.DistrictTally{}
+	for _, count := range counts {
+		results.Overall.Add(count.Choice, count.Count)
+		if count.State == "" {
+			continue
+		}
+		state, ok := states[count.State]
+		if !ok {
+			state = &models.DistrictTally{State: count.State}
+			states[count.State] = state
+		}
+		state.Add(count.Choice, count.Count)
+		if count.District == "" {
+			continue
+		}
+		key := [2]string{count.State, count.District}
+		district, ok := districts[key]
+		if !ok {
+			district = &models.DistrictTally{State: count.State, District: count.District}
+			districts[key] = district
+		}
+		district.Add(count.Choice, count.Count)
+	}
+
+	for _, state := range states {
+		if state.Total >= int64(minBallots) {
+			results.States = append(results.States, *state)
+		}
+	}
+	for _, district := range districts {
+		if district.Total >= int64(minBallots) {
+			results.Districts = append(results.Districts, *district)
+		}
+	}
+	sort.Slice(results.States, func(i, j int) bool { return results.States[i].State < results.States[j].State })
+	sort.Slice(results.Districts, func(i, j int) bool {
+		a, b := results.Districts[i], results.Districts[j]
+		if a.State != b.State {
+			return a.State < b.State
+		}
+		return lessDistrict(a.District, b.District)
+	})
+	return results
+}
+
+// constituency returns the state and congressional district whose voters
+// representative answers to. Only federal representatives have
+// congressional districts; senators, and state and local officials, whose
+// districts are drawn differently, answer to their whole state.
+func constituency(representative *models.Representative) (string, string) {
+	state := normalizeState(representative.State)
+	if representative.Level != models.LevelFederal {
+		return state, ""
+	}
+	return state, normalizeDistrict(state, representative.District)
+}
+
+// normalizeState returns a state as compared in polls: trimmed and in upper
+// case
+func normalizeState(state string) string {
+	return strings.ToUpper(strings.TrimSpace(state))
+}
+
+// normalizeDistrict returns a congressional district of state as compared
+// in polls, so that "TX-05", "05" and "5" are the same district
+func normalizeDistrict(state, district string) string {
+	district = strings.ToUpper(strings.TrimSpace(district))
+	district = strings.TrimPrefix(district, state+"-")
+	if trimmed := strings.TrimLeft(district, "0"); trimmed != "" {
+		return trimmed
+	}
+	return district
+}
+
+// lessDistrict orders numbered districts by number and others by name
+func lessDistrict(a, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return a < b
+}
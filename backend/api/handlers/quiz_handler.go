@@ -1,365 +1,452 @@
-package handlers
-
-import (
-	"context"
-	"encoding/json"
-	"net/http"
-	"time"
-
-	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-// QuizHandler handles quiz-related API endpoints
-type QuizHandler struct {
-	collection               *mongo.Collection
-	userCollection           *mongo.Collection
-	representativeCollection *mongo.Collection
-}
-
-// NewQuizHandler creates a new QuizHandler
-func NewQuizHandler(client *mongo.Client) *QuizHandler {
-	collection := client.Database("govtrack").Collection("quizzes")
-	userCollection := client.Database("govtrack").Collection("users")
-	representativeCollection := client.Database("govtrack").Collection("representatives")
-	return &QuizHandler{
-		collection:               collection,
-		userCollection:           userCollection,
-		representativeCollection: representativeCollection,
-	}
-}
-
-// GetQuiz handles GET requests for a single quiz
-func (h *QuizHandler) GetQuiz(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid quiz ID", http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	var quiz bson.M
-	err = h.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&quiz)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "Quiz not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(quiz)
-}
-
-// GetQuizzes handles GET requests for quizzes with optional filtering
-func (h *QuizHandler) GetQuizzes(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Parse query parameters for filtering
-	query := bson.M{}
-
-	// Filter by category if provided
-	if category := r.URL.Query().Get("category"); category != "" {
-		query["category"] = category
-	}
-
-	// Filter by topic if provided
-	if topic := r.URL.Query().Get("topic"); topic != "" {
-		query["topic"] = topic
-	}
-
-	// Set up options for pagination
-	findOptions := options.Find()
-	if limit := r.URL.Query().Get("limit"); limit != "" {
-		limitInt := 10 // default limit
-		// Parse limit from query parameter
-		// Omitting error handling for brevity
-		findOptions.SetLimit(int64(limitInt))
-	}
-
-	cursor, err := h.collection.Find(ctx, query, findOptions)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	var quizzes []bson.M
-	if err = cursor.All(ctx, &quizzes); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(quizzes)
-}
-
-// CreateQuiz handles POST requests to create a new quiz
-func (h *QuizHandler) CreateQuiz(w http.ResponseWriter, r *http.Request) {
-	var quiz bson.M
-	if err := json.NewDecoder(r.Body).Decode(&quiz); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Generate a new ID if not provided
-	if _, ok := quiz["_id"]; !ok {
-		quiz["_id"] = primitive.NewObjectID()
-	}
-
-	result, err := h.collection.InsertOne(ctx, quiz)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	quiz["_id"] = result.InsertedID
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(quiz)
-}
-
-// UpdateQuiz handles PUT requests to update a quiz
-func (h *QuizHandler) UpdateQuiz(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid quiz ID", http.StatusBadRequest)
-		return
-	}
-
-	var quiz bson.M
-	if err := json.NewDecoder(r.Body).Decode(&quiz); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Ensure ID matches path parameter
-	quiz["_id"] = id
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	result, err := h.collection.ReplaceOne(ctx, bson.M{"_id": id}, quiz)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if result.MatchedCount == 0 {
-		http.Error(w, "Quiz not found", http.StatusNotFound)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(quiz)
-}
-
-// DeleteQuiz handles DELETE requests to remove a quiz
-func (h *QuizHandler) DeleteQuiz(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid quiz ID", http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	result, err := h.collection.DeleteOne(ctx, bson.M{"_id": id})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if result.DeletedCount == 0 {
-		http.Error(w, "Quiz not found", http.StatusNotFound)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-// SubmitQuizResults handles POST requests to submit quiz results
-func (h *QuizHandler) SubmitQuizResults(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	quizID, err := primitive.ObjectIDFromHex(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid quiz ID", http.StatusBadRequest)
-		return
-	}
-
-	var results bson.M
-	if err := json.NewDecoder(r.Body).Decode(&results); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Set quiz ID from path parameter
-	results["quiz_id"] = quizID
-
-	// Generate a new ID if not provided
-	if _, ok := results["_id"]; !ok {
-		results["_id"] = primitive.NewObjectID()
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// First check if the quiz exists
-	var quiz bson.M
-	err = h.collection.FindOne(ctx, bson.M{"_id": quizID}).Decode(&quiz)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "Quiz not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Calculate alignment with representatives
-	// This is a simplified version - in a real app, this would be more complex
-	if answers, ok := results["answers"].([]interface{}); ok && len(answers) > 0 {
-		// Get all representatives
-		repCursor, err := h.representativeCollection.Find(ctx, bson.M{})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer repCursor.Close(ctx)
-
-		var representatives []bson.M
-		if err = repCursor.All(ctx, &representatives); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Calculate alignment for each representative
-		// This is a simplified algorithm
-		representativeAlignment := []bson.M{}
-		for _, rep := range representatives {
-			// In a real app, you would compare the user's answers with the representative's stances
-			// For now, we'll just create a placeholder alignment
-			alignment := bson.M{
-				"representative_id":   rep["_id"],
-				"representative_name": rep["name"],
-				"alignment_score":     0.5, // Placeholder score
-			}
-			representativeAlignment = append(representativeAlignment, alignment)
-		}
-		results["representative_alignment"] = representativeAlignment
-	}
-
-	// Save the results
-	resultsCollection := h.collection.Database().Collection("quiz_results")
-	_, err = resultsCollection.InsertOne(ctx, results)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// If user ID is provided, update the user's quiz results
-	if userID, ok := results["user_id"].(primitive.ObjectID); ok {
-		update := bson.M{
-			"$push": bson.M{
-				"quiz_results": results["_id"],
-			},
-		}
-		_, err = h.userCollection.UpdateOne(ctx, bson.M{"_id": userID}, update)
-		if err != nil {
-			// Log the error but don't fail the request
-			// The quiz results are still saved
-			// In a real app, you might want to handle this differently
-		}
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(results)
-}
-
-// GetQuizResults handles GET requests for quiz results
-func (h *QuizHandler) GetQuizResults(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	resultID, err := primitive.ObjectIDFromHex(vars["result_id"])
-	if err != nil {
-		http.Error(w, "Invalid result ID", http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	resultsCollection := h.collection.Database().Collection("quiz_results")
-	var result bson.M
-	err = resultsCollection.FindOne(ctx, bson.M{"_id": resultID}).Decode(&result)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "Quiz result not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
-}
-
-// GetUserQuizResults handles GET requests for a user's quiz results
-func (h *QuizHandler) GetUserQuizResults(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID, err := primitive.ObjectIDFromHex(vars["user_id"])
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// First check if the user exists
-	var user bson.M
-	err = h.userCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "User not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Get the user's quiz results
-	resultsCollection := h.collection.Database().Collection("quiz_results")
-	cursor, err := resultsCollection.Find(ctx, bson.M{"user_id": userID})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	var results []bson.M
-	if err = cursor.All(ctx, &results); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(results)
-}
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/metrics"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QuizHandler handles quiz-related API endpoints
+type QuizHandler struct {
+	quizzes         repository.QuizRepository
+	results         repository.QuizResultRepository
+	users           repository.UserRepository
+	representatives repository.RepresentativeRepository
+	audit           *Auditor
+}
+
+// NewQuizHandler creates a new QuizHandler that records changes to quizzes
+// with auditor
+func NewQuizHandler(store *repository.Store, auditor *Auditor) *QuizHandler {
+	return &QuizHandler{
+		quizzes:         store.Quizzes,
+		results:         store.QuizResults,
+		users:           store.Users,
+		representatives: store.Representatives,
+		audit:           auditor,
+	}
+}
+
+// GetQuiz handles GET requests for a single quiz
+func (h *QuizHandler) GetQuiz(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("quiz"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	quiz, err := h.quizzes.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("quiz"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeWithETag(w, r, quizTag(quiz), quiz)
+}
+
+// GetQuizzes handles GET requests for quizzes with optional filtering
+func (h *QuizHandler) GetQuizzes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Parse query parameters for filtering
+	query := r.URL.Query()
+	filter := repository.QuizFilter{
+		Category: query.Get("category"),
+		Limit:    parseLimit(query.Get("limit")),
+	}
+
+	quizzes, err := h.quizzes.List(ctx, filter)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quizzes)
+}
+
+// CreateQuiz handles POST requests to create a new quiz
+func (h *QuizHandler) CreateQuiz(w http.ResponseWriter, r *http.Request) {
+	var quiz models.PoliticalQuiz
+	if err := validation.Decode(r, &quiz); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	quiz.CreatedAt = now
+	quiz.UpdatedAt = now
+	quiz.DeletedAt, quiz.DeletedBy = nil, nil
+	assignQuestionIDs(&quiz)
+
+	if err := h.quizzes.Create(ctx, &quiz); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "Quiz already exists"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionCreate, audit.ResourceQuiz, quiz.ID, nil, &quiz)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", quizTag(&quiz))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quiz)
+}
+
+// UpdateQuiz handles PUT requests to update a quiz
+func (h *QuizHandler) UpdateQuiz(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("quiz"))
+		return
+	}
+
+	var quiz models.PoliticalQuiz
+	if err := validation.Decode(r, &quiz); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	current := func() (*models.PoliticalQuiz, error) { return h.quizzes.FindByID(ctx, id) }
+
+	// Only replace the revision the client expects, if any
+	revision, ok := expectedRevision(r, id, quiz.Revision)
+	if !ok {
+		writePreconditionFailed(w, r, "quiz", current, quizTag)
+		return
+	}
+
+	// Read the stored quiz for the audit log
+	before, err := h.quizzes.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("quiz"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Ensure ID matches path parameter
+	quiz.ID = id
+	quiz.Revision = revision
+	quiz.UpdatedAt = time.Now()
+	quiz.DeletedAt, quiz.DeletedBy = nil, nil
+	assignQuestionIDs(&quiz)
+
+	if err := h.quizzes.Update(ctx, &quiz); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			apierror.Write(w, r, apierror.NotFound("quiz"))
+		case errors.Is(err, repository.ErrRevisionConflict):
+			writePreconditionFailed(w, r, "quiz", current, quizTag)
+		default:
+			apierror.Write(w, r, err)
+		}
+		return
+	}
+	h.audit.record(r, audit.ActionUpdate, audit.ResourceQuiz, id, before, &quiz)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", quizTag(&quiz))
+	json.NewEncoder(w).Encode(quiz)
+}
+
+// PatchQuiz handles PATCH requests to change some fields of a quiz
+func (h *QuizHandler) PatchQuiz(w http.ResponseWriter, r *http.Request) {
+	servePatch(w, r, patchTarget[models.PoliticalQuiz]{
+		resource: "quiz",
+		find:     h.quizzes.FindByID,
+		patch:    h.quizzes.Patch,
+		revision: func(q *models.PoliticalQuiz) *int64 { return &q.Revision },
+		etag:     quizTag,
+		audit:    h.audit,
+		prepare: func(stored, patched *models.PoliticalQuiz) error {
+			patched.ID = stored.ID
+			patched.CreatedAt = stored.CreatedAt
+			patched.UpdatedAt = stored.UpdatedAt
+			patched.DeletedAt, patched.DeletedBy = stored.DeletedAt, stored.DeletedBy
+			assignQuestionIDs(patched)
+			return nil
+		},
+		touch: func(q *models.PoliticalQuiz) { q.UpdatedAt = time.Now() },
+	})
+}
+
+// DeleteQuiz handles DELETE requests to move a quiz to the trash
+func (h *QuizHandler) DeleteQuiz(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("quiz"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	before, err := h.quizzes.FindByID(ctx, id)
+	if err == nil {
+		by, _ := callerID(r)
+		err = h.quizzes.Delete(ctx, id, by, time.Now())
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("quiz"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionDelete, audit.ResourceQuiz, id, before, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SubmitQuizResults handles POST requests to submit quiz results
+func (h *QuizHandler) SubmitQuizResults(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	quizID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("quiz"))
+		return
+	}
+
+	var result models.QuizResult
+	if err := validation.Decode(r, &result); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Set quiz ID from path parameter and default the user to the caller
+	result.ID = primitive.NilObjectID
+	result.QuizID = quizID
+	result.TakenAt = time.Now()
+	if result.UserID.IsZero() {
+		if userID, ok := r.Context().Value("userId").(string); ok {
+			result.UserID, _ = primitive.ObjectIDFromHex(userID)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// First check if the quiz exists
+	quiz, err := h.quizzes.FindByID(ctx, quizID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("quiz"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Score the responses and calculate alignment with representatives
+	if len(result.Responses) > 0 {
+		representatives, err := h.representatives.List(ctx, repository.RepresentativeFilter{})
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		result.Categories = categoryScores(quiz, result.Responses)
+		result.RepresentativeAlignment = representativeAlignment(quiz, result.Responses, representatives)
+	}
+
+	// Save the results
+	if err := h.results.Create(ctx, &result); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	metrics.QuizSubmitted()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetQuizResults handles GET requests for quiz results
+func (h *QuizHandler) GetQuizResults(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	resultID, err := primitive.ObjectIDFromHex(vars["result_id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("result"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.results.FindByID(ctx, resultID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("quiz result"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetUserQuizResults handles GET requests for a user's quiz results
+func (h *QuizHandler) GetUserQuizResults(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := primitive.ObjectIDFromHex(vars["user_id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("user"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// First check if the user exists
+	if _, err := h.users.FindByID(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("user"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Get the user's quiz results
+	results, err := h.results.ListByUser(ctx, userID)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// assignQuestionIDs gives every question and option without an ID a new one,
+// so responses can reference them
+func assignQuestionIDs(quiz *models.PoliticalQuiz) {
+	for i := range quiz.Questions {
+		question := &quiz.Questions[i]
+		if question.ID.IsZero() {
+			question.ID = primitive.NewObjectID()
+		}
+		for j := range question.Options {
+			if question.Options[j].ID.IsZero() {
+				question.Options[j].ID = primitive.NewObjectID()
+			}
+		}
+	}
+}
+
+// categoryScores averages the user's answers within each question category
+func categoryScores(quiz *models.PoliticalQuiz, responses []models.QuizResponse) map[string]float64 {
+	questions := make(map[primitive.ObjectID]models.QuizQuestion, len(quiz.Questions))
+	for _, question := range quiz.Questions {
+		questions[question.ID] = question
+	}
+
+	totals := map[string]float64{}
+	counts := map[string]int{}
+	for _, response := range responses {
+		question, ok := questions[response.QuestionID]
+		if !ok {
+			continue
+		}
+		totals[question.Category] += float64(response.Answer)
+		counts[question.Category]++
+	}
+
+	scores := make(map[string]float64, len(totals))
+	for category, total := range totals {
+		scores[category] = total / float64(counts[category])
+	}
+	return scores
+}
+
+// representativeAlignment compares the user's answers with each
+// representative's recorded stance on the same questions. Answers and stances
+// use the same 1-5 scale, so identical positions score 100% and opposite
+// extremes score 0%. Representatives with no stances on the answered
+// questions are omitted.
+func representativeAlignment(quiz *models.PoliticalQuiz, responses []models.QuizResponse, representatives []models.Representative) []models.RepresentativeAlignment {
+	const scaleRange = 4.0 // 1-5 scale
+
+	answers := make(map[primitive.ObjectID]int, len(responses))
+	for _, response := range responses {
+		answers[response.QuestionID] = response.Answer
+	}
+
+	alignments := []models.RepresentativeAlignment{}
+	for _, rep := range representatives {
+		var total float64
+		var count int
+		categoryTotals := map[string]float64{}
+		categoryCounts := map[string]int{}
+
+		for _, question := range quiz.Questions {
+			answer, answered := answers[question.ID]
+			if !answered {
+				continue
+			}
+			for _, stance := range question.RepresentativeStances {
+				if stance.RepresentativeID != rep.ID {
+					continue
+				}
+				distance := math.Abs(float64(answer - stance.Stance))
+				score := math.Max(0, 1-distance/scaleRange) * 100
+				total += score
+				count++
+				categoryTotals[question.Category] += score
+				categoryCounts[question.Category]++
+			}
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		categoryScores := make(map[string]float64, len(categoryTotals))
+		for category, categoryTotal := range categoryTotals {
+			categoryScores[category] = categoryTotal / float64(categoryCounts[category])
+		}
+		alignments = append(alignments, models.RepresentativeAlignment{
+			RepresentativeID: rep.ID,
+			OverallScore:     total / float64(count),
+			CategoryScores:   categoryScores,
+		})
+	}
+	return alignments
+}
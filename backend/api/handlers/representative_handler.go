@@ -1,264 +1,280 @@
-package handlers
-
-import (
-	"context"
-	"encoding/json"
-	"net/http"
-	"time"
-
-	"github.com/benjamingetches/govtrack/api/models"
-	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-// RepresentativeHandler handles representative-related API endpoints
-type RepresentativeHandler struct {
-	collection *mongo.Collection
-}
-
-// NewRepresentativeHandler creates a new RepresentativeHandler
-func NewRepresentativeHandler(client *mongo.Client) *RepresentativeHandler {
-	collection := client.Database("govtrack").Collection("representatives")
-	return &RepresentativeHandler{
-		collection: collection,
-	}
-}
-
-// GetRepresentative handles GET requests for a single representative
-func (h *RepresentativeHandler) GetRepresentative(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid representative ID", http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	var representative models.Representative
-	err = h.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&representative)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "Representative not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(representative)
-}
-
-// GetRepresentatives handles GET requests for representatives with optional filtering
-func (h *RepresentativeHandler) GetRepresentatives(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Parse query parameters for filtering
-	query := bson.M{}
-
-	// Filter by state if provided
-	if state := r.URL.Query().Get("state"); state != "" {
-		query["state"] = state
-	}
-
-	// Filter by party if provided
-	if party := r.URL.Query().Get("party"); party != "" {
-		query["party"] = party
-	}
-
-	// Filter by title if provided
-	if title := r.URL.Query().Get("title"); title != "" {
-		query["title"] = title
-	}
-
-	// Set up options for pagination
-	findOptions := options.Find()
-	if limit := r.URL.Query().Get("limit"); limit != "" {
-		limitInt := 10 // default limit
-		// Parse limit from query parameter
-		// Omitting error handling for brevity
-		findOptions.SetLimit(int64(limitInt))
-	}
-
-	cursor, err := h.collection.Find(ctx, query, findOptions)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	var representatives []models.Representative
-	if err = cursor.All(ctx, &representatives); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(representatives)
-}
-
-// CreateRepresentative handles POST requests to create a new representative
-func (h *RepresentativeHandler) CreateRepresentative(w http.ResponseWriter, r *http.Request) {
-	var representative models.Representative
-	if err := json.NewDecoder(r.Body).Decode(&representative); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Generate a new ID if not provided
-	if representative.ID.IsZero() {
-		representative.ID = primitive.NewObjectID()
-	}
-
-	result, err := h.collection.InsertOne(ctx, representative)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	representative.ID = result.InsertedID.(primitive.ObjectID)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(representative)
-}
-
-// UpdateRepresentative handles PUT requests to update a representative
-func (h *RepresentativeHandler) UpdateRepresentative(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid representative ID", http.StatusBadRequest)
-		return
-	}
-
-	var representative models.Representative
-	if err := json.NewDecoder(r.Body).Decode(&representative); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Ensure ID matches path parameter
-	representative.ID = id
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	result, err := h.collection.ReplaceOne(ctx, bson.M{"_id": id}, representative)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if result.MatchedCount == 0 {
-		http.Error(w, "Representative not found", http.StatusNotFound)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(representative)
-}
-
-// DeleteRepresentative handles DELETE requests to remove a representative
-func (h *RepresentativeHandler) DeleteRepresentative(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid representative ID", http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	result, err := h.collection.DeleteOne(ctx, bson.M{"_id": id})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if result.DeletedCount == 0 {
-		http.Error(w, "Representative not found", http.StatusNotFound)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-// GetRepresentativeVotes handles GET requests for a representative's voting record
-func (h *RepresentativeHandler) GetRepresentativeVotes(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid representative ID", http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// First check if the representative exists
-	var representative models.Representative
-	err = h.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&representative)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "Representative not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Get the policies collection to find votes
-	policiesCollection := h.collection.Database().Collection("policies")
-
-	// Find policies where this representative has voted
-	pipeline := []bson.M{
-		{
-			"$match": bson.M{
-				"votes.representative_id": id,
-			},
-		},
-		{
-			"$project": bson.M{
-				"_id":     1,
-				"title":   1,
-				"summary": 1,
-				"vote": bson.M{
-					"$filter": bson.M{
-						"input": "$votes",
-						"as":    "vote",
-						"cond": bson.M{
-							"$eq": []interface{}{"$$vote.representative_id", id},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	cursor, err := policiesCollection.Aggregate(ctx, pipeline)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	var votes []bson.M
-	if err = cursor.All(ctx, &votes); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(votes)
-}
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/httpcache"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RepresentativeHandler handles representative-related API endpoints
+type RepresentativeHandler struct {
+	representatives repository.RepresentativeRepository
+	policies        repository.PolicyRepository
+	audit           *Auditor
+}
+
+// NewRepresentativeHandler creates a new RepresentativeHandler that records
+// changes with auditor
+func NewRepresentativeHandler(representatives repository.RepresentativeRepository, policies repository.PolicyRepository, auditor *Auditor) *RepresentativeHandler {
+	return &RepresentativeHandler{
+		representatives: representatives,
+		policies:        policies,
+		audit:           auditor,
+	}
+}
+
+// GetRepresentative handles GET requests for a single representative
+func (h *RepresentativeHandler) GetRepresentative(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("representative"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	representative, err := h.representatives.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("representative"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeWithETag(w, r, representativeTag(representative), representative)
+}
+
+// GetRepresentatives handles GET requests for representatives with optional filtering
+func (h *RepresentativeHandler) GetRepresentatives(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Parse query parameters for filtering
+	query := r.URL.Query()
+	filter := repository.RepresentativeFilter{
+		State: query.Get("state"),
+		Party: query.Get("party"),
+		Title: query.Get("title"),
+		Limit: parseLimit(query.Get("limit")),
+	}
+
+	representatives, err := h.representatives.List(ctx, filter)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeWithETag(w, r, representativesTag(representatives), representatives)
+}
+
+// CreateRepresentative handles POST requests to create a new representative
+func (h *RepresentativeHandler) CreateRepresentative(w http.ResponseWriter, r *http.Request) {
+	var representative models.Representative
+	if err := validation.Decode(r, &representative); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	representative.LastUpdated = time.Now()
+	representative.DeletedAt, representative.DeletedBy = nil, nil
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.representatives.Create(ctx, &representative); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "Representative already exists"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionCreate, audit.ResourceRepresentative, representative.ID, nil, &representative)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", representativeTag(&representative))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(representative)
+}
+
+// UpdateRepresentative handles PUT requests to update a representative
+func (h *RepresentativeHandler) UpdateRepresentative(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("representative"))
+		return
+	}
+
+	var representative models.Representative
+	if err := validation.Decode(r, &representative); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	current := func() (*models.Representative, error) { return h.representatives.FindByID(ctx, id) }
+
+	// Only replace the revision the client expects, if any
+	revision, ok := expectedRevision(r, id, representative.Revision)
+	if !ok {
+		writePreconditionFailed(w, r, "representative", current, representativeTag)
+		return
+	}
+
+	// Read the stored representative for the audit log
+	before, err := h.representatives.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("representative"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Ensure ID matches path parameter and set last updated time
+	representative.ID = id
+	representative.Revision = revision
+	representative.LastUpdated = time.Now()
+	representative.DeletedAt, representative.DeletedBy = nil, nil
+
+	if err := h.representatives.Update(ctx, &representative); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			apierror.Write(w, r, apierror.NotFound("representative"))
+		case errors.Is(err, repository.ErrRevisionConflict):
+			writePreconditionFailed(w, r, "representative", current, representativeTag)
+		default:
+			apierror.Write(w, r, err)
+		}
+		return
+	}
+	h.audit.record(r, audit.ActionUpdate, audit.ResourceRepresentative, id, before, &representative)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", representativeTag(&representative))
+	json.NewEncoder(w).Encode(representative)
+}
+
+// PatchRepresentative handles PATCH requests to change some fields of a representative
+func (h *RepresentativeHandler) PatchRepresentative(w http.ResponseWriter, r *http.Request) {
+	servePatch(w, r, patchTarget[models.Representative]{
+		resource: "representative",
+		find:     h.representatives.FindByID,
+		patch:    h.representatives.Patch,
+		revision: func(rep *models.Representative) *int64 { return &rep.Revision },
+		etag:     representativeTag,
+		audit:    h.audit,
+		prepare: func(stored, patched *models.Representative) error {
+			patched.ID = stored.ID
+			patched.LastUpdated = stored.LastUpdated
+			patched.DeletedAt, patched.DeletedBy = stored.DeletedAt, stored.DeletedBy
+			return nil
+		},
+		touch:     func(rep *models.Representative) { rep.LastUpdated = time.Now() },
+		duplicate: apierror.New(http.StatusConflict, apierror.CodeConflict, "Representative already exists"),
+	})
+}
+
+// DeleteRepresentative handles DELETE requests to move a representative to the trash
+func (h *RepresentativeHandler) DeleteRepresentative(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("representative"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	before, err := h.representatives.FindByID(ctx, id)
+	if err == nil {
+		by, _ := callerID(r)
+		err = h.representatives.Delete(ctx, id, by, time.Now())
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("representative"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionDelete, audit.ResourceRepresentative, id, before, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRepresentativeVotes handles GET requests for a representative's voting record
+func (h *RepresentativeHandler) GetRepresentativeVotes(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("representative"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// First check if the representative exists
+	if _, err := h.representatives.FindByID(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("representative"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Find policies where this representative has voted
+	votes, err := h.policies.VotesByRepresentative(ctx, id)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Votes come from policies, so their tag is derived from the body
+	body, err := json.Marshal(votes)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeWithETag(w, r, httpcache.BodyTag(body), votes)
+}
+
+// parseLimit parses a limit query parameter. A missing limit means no limit,
+// and an invalid one falls back to the default page size.
+func parseLimit(value string) int64 {
+	const defaultLimit = 10
+	if value == "" {
+		return 0
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		return defaultLimit
+	}
+	return limit
+}
@@ -0,0 +1,662 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/logging"
+	"github.com/benjamingetches/govtrack/readability"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/benjamingetches/govtrack/summarize"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SummaryHandler handles the editorial review of policy summaries. Summaries
+// are drafted by any user, reviewed by editors and published by an editor
+// once editors of enough different affiliations have approved them.
+// Summaries are scored for readability whenever they change and can only be
+// published if they meet the analyzer's thresholds. Editors can also have a
+// draft generated from a policy's original text.
+type SummaryHandler struct {
+	summaries repository.SummaryRepository
+	policies  repository.PolicyRepository
+	users     repository.UserRepository
+	cfg       config.SummaryConfig
+	analyzer  *readability.Analyzer
+	drafter   *summarize.Drafter
+	audit     *Auditor
+}
+
+// NewSummaryHandler creates a new SummaryHandler that requires the approvals
+// in cfg, scores summaries with analyzer, generates drafts with drafter and
+// records changes with auditor
+func NewSummaryHandler(store *repository.Store, cfg config.SummaryConfig, analyzer *readability.Analyzer, drafter *summarize.Drafter, auditor *Auditor) *SummaryHandler {
+	return &SummaryHandler{
+		summaries: store.Summaries,
+		policies:  store.Policies,
+		users:     store.Users,
+		cfg:       cfg,
+		analyzer:  analyzer,
+		drafter:   drafter,
+		audit:     auditor,
+	}
+}
+
+// CreateSummary handles POST requests to draft a summary of a policy
+func (h *SummaryHandler) CreateSummary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return
+	}
+
+	// Decode and validate request body
+	var req models.SummaryRequest
+	err := validation.Decode(r, &req)
+	if req.PolicyID.IsZero() {
+		err = validation.Append(err, "policy_id", "is required")
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// The policy must exist
+	if _, err := h.policies.FindByID(ctx, req.PolicyID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	summary := models.Summary{
+		PolicyID:  req.PolicyID,
+		Text:      req.Text,
+		Status:    models.SummaryDraft,
+		AuthorID:  &userID,
+		Reviewers: []primitive.ObjectID{},
+		Comments:  []models.SummaryComment{},
+		Approvals: []models.SummaryApproval{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	summary.Readability = h.analyzer.Analyze(summary.Text)
+
+	// Insert summary into database
+	if err := h.summaries.Create(ctx, &summary); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionCreate, audit.ResourceSummary, summary.ID, nil, &summary)
+
+	// Return created summary as JSON
+	w.Header().Set("ETag", summaryTag(&summary))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(summary)
+}
+
+// GenerateSummary handles POST requests from editors to draft a summary of
+// a policy with the summary generator
+func (h *SummaryHandler) GenerateSummary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return
+	}
+
+	// Decode and validate request body
+	var req models.GenerateSummaryRequest
+	err := validation.Decode(r, &req)
+	if req.PolicyID.IsZero() {
+		err = validation.Append(err, "policy_id", "is required")
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	caller, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "User no longer exists"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	if !caller.CanEdit() {
+		apierror.Write(w, r, forbidden("Only editors can generate summaries"))
+		return
+	}
+
+	policy, err := h.policies.FindByID(ctx, req.PolicyID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("policy"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// The generator's own timeout bounds the request rather than ctx's
+	summary, err := h.drafter.Draft(r.Context(), policy)
+	switch {
+	case errors.Is(err, summarize.ErrNoText):
+		apierror.Write(w, r, validation.Append(nil, "policy_id", "has no original text to summarize"))
+		return
+	case errors.Is(err, summarize.ErrGenerator), errors.Is(err, summarize.ErrUnusable):
+		apierror.Write(w, r, &apierror.Error{Status: http.StatusBadGateway, Code: apierror.CodeGeneratorFailed, Message: "The summary generator failed", Err: err})
+		return
+	case err != nil:
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionCreate, audit.ResourceSummary, summary.ID, nil, summary)
+
+	// Return generated summary as JSON
+	w.Header().Set("ETag", summaryTag(summary))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(summary)
+}
+
+// GetSummaries handles GET requests for summaries, most recently updated
+// first, optionally filtered by policy and status
+func (h *SummaryHandler) GetSummaries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse query parameters for filtering
+	query := r.URL.Query()
+	filter := repository.SummaryFilter{Status: query.Get("status")}
+	if filter.Status != "" && !slices.Contains(models.SummaryStatuses, filter.Status) {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "status must be one of: "+strings.Join(models.SummaryStatuses, ", ")))
+		return
+	}
+	if value := query.Get("policy_id"); value != "" {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "policy_id must be a policy ID"))
+			return
+		}
+		filter.PolicyID = id
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	summaries, err := h.summaries.List(ctx, filter)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return summaries as JSON
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// GetSummary handles GET requests for a single summary
+func (h *SummaryHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get summary ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("summary"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	summary, err := h.summaries.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("summary"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return summary as JSON
+	writeWithETag(w, r, summaryTag(summary), summary)
+}
+
+// UpdateSummary handles PUT requests to change the text of a draft. Only
+// its author and editors can change it.
+func (h *SummaryHandler) UpdateSummary(w http.ResponseWriter, r *http.Request) {
+	var req models.SummaryRequest
+	if err := validation.Decode(r, &req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		apierror.Write(w, r, err)
+		return
+	}
+
+	h.change(w, r, func(ctx context.Context, summary *models.Summary, caller *models.User) error {
+		if err := requireStatus(summary, models.SummaryDraft); err != nil {
+			return err
+		}
+		if !isAuthor(summary, caller) && !caller.CanEdit() {
+			return forbidden("Only the author and editors can change a draft")
+		}
+		summary.Text = req.Text
+		return nil
+	})
+}
+
+// SubmitSummary handles POST requests to submit a draft for review
+func (h *SummaryHandler) SubmitSummary(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, func(ctx context.Context, summary *models.Summary, caller *models.User) error {
+		if err := requireStatus(summary, models.SummaryDraft); err != nil {
+			return err
+		}
+		if !isAuthor(summary, caller) && !caller.CanEdit() {
+			return forbidden("Only the author and editors can submit a draft")
+		}
+		summary.Status = models.SummaryInReview
+		return nil
+	})
+}
+
+// AssignReviewers handles POST requests by editors adding reviewers to a
+// summary. Reviewers must be editors who have declared an affiliation and
+// cannot review their own summaries.
+func (h *SummaryHandler) AssignReviewers(w http.ResponseWriter, r *http.Request) {
+	var req models.ReviewersRequest
+	if err := validation.Decode(r, &req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		apierror.Write(w, r, err)
+		return
+	}
+
+	h.change(w, r, func(ctx context.Context, summary *models.Summary, caller *models.User) error {
+		if err := requireStatus(summary, models.SummaryDraft, models.SummaryInReview); err != nil {
+			return err
+		}
+		if !caller.CanEdit() {
+			return forbidden("Only editors can assign reviewers")
+		}
+
+		var errs error
+		for i, id := range req.Reviewers {
+			field := "reviewers[" + strconv.Itoa(i) + "]"
+			reviewer, err := h.users.FindByID(ctx, id)
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				errs = validation.Append(errs, field, "is not a user")
+			case err != nil:
+				return err
+			case !reviewer.CanEdit():
+				errs = validation.Append(errs, field, "is not an editor")
+			case affiliation(reviewer) == "":
+				errs = validation.Append(errs, field, "has not declared an affiliation")
+			case summary.AuthorID != nil && *summary.AuthorID == id:
+				errs = validation.Append(errs, field, "is the summary's author")
+			case !slices.Contains(summary.Reviewers, id):
+				summary.Reviewers = append(summary.Reviewers, id)
+			}
+		}
+		return errs
+	})
+}
+
+// CommentOnSummary handles POST requests commenting on a summary under
+// review. The author, the reviewers and editors can comment.
+func (h *SummaryHandler) CommentOnSummary(w http.ResponseWriter, r *http.Request) {
+	var req models.CommentRequest
+	err := validation.Decode(r, &req)
+	if err == nil && strings.TrimSpace(req.Body) == "" {
+		err = validation.Append(err, "body", "is required")
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		apierror.Write(w, r, err)
+		return
+	}
+
+	h.change(w, r, func(ctx context.Context, summary *models.Summary, caller *models.User) error {
+		if err := requireStatus(summary, models.SummaryDraft, models.SummaryInReview, models.SummaryApproved); err != nil {
+			return err
+		}
+		if !isAuthor(summary, caller) && !slices.Contains(summary.Reviewers, caller.ID) && !caller.CanEdit() {
+			return forbidden("Only the author, reviewers and editors can comment on a summary")
+		}
+		addComment(summary, caller, req.Body)
+		return nil
+	})
+}
+
+// ApproveSummary handles POST requests by an assigned reviewer approving a
+// summary under review. The approval records the reviewer's affiliation;
+// once approvals cover the required number of different affiliations the
+// summary is approved.
+func (h *SummaryHandler) ApproveSummary(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, func(ctx context.Context, summary *models.Summary, caller *models.User) error {
+		if err := requireStatus(summary, models.SummaryInReview); err != nil {
+			return err
+		}
+		if !slices.Contains(summary.Reviewers, caller.ID) || isAuthor(summary, caller) {
+			return forbidden("Only the summary's reviewers can approve it")
+		}
+		if !caller.CanEdit() || affiliation(caller) == "" {
+			return forbidden("Only editors who have declared an affiliation can approve summaries")
+		}
+		for _, approval := range summary.Approvals {
+			if approval.ReviewerID == caller.ID {
+				return apierror.New(http.StatusConflict, apierror.CodeConflict, "You have already approved this summary")
+			}
+		}
+
+		summary.Approvals = append(summary.Approvals, models.SummaryApproval{
+			ReviewerID:  caller.ID,
+			Affiliation: strings.TrimSpace(caller.Affiliation),
+			At:          time.Now(),
+		})
+		if approvedAffiliations(summary) >= h.cfg.RequiredApprovals {
+			summary.Status = models.SummaryApproved
+		}
+		return nil
+	})
+}
+
+// RequestSummaryChanges handles POST requests by a reviewer or editor
+// returning a summary to its author. The summary becomes a draft again and
+// its approvals are cleared; the optional comment explains why.
+func (h *SummaryHandler) RequestSummaryChanges(w http.ResponseWriter, r *http.Request) {
+	var req models.CommentRequest
+	if r.ContentLength != 0 {
+		if err := validation.Decode(r, &req); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			apierror.Write(w, r, err)
+			return
+		}
+	}
+
+	h.change(w, r, func(ctx context.Context, summary *models.Summary, caller *models.User) error {
+		if err := requireStatus(summary, models.SummaryInReview, models.SummaryApproved); err != nil {
+			return err
+		}
+		if !slices.Contains(summary.Reviewers, caller.ID) && !caller.CanEdit() {
+			return forbidden("Only reviewers and editors can request changes")
+		}
+		summary.Status = models.SummaryDraft
+		summary.Approvals = []models.SummaryApproval{}
+		if strings.TrimSpace(req.Body) != "" {
+			addComment(summary, caller, req.Body)
+		}
+		return nil
+	})
+}
+
+// PublishSummary handles POST requests by an editor publishing an approved
+// summary. The summary becomes the policy's simplified description, naming
+// its approvers and publisher, and supersedes the one published before.
+func (h *SummaryHandler) PublishSummary(w http.ResponseWriter, r *http.Request) {
+	published := h.change(w, r, func(ctx context.Context, summary *models.Summary, caller *models.User) error {
+		if err := requireStatus(summary, models.SummaryApproved); err != nil {
+			return err
+		}
+		if !caller.CanEdit() {
+			return forbidden("Only editors can publish summaries")
+		}
+
+		// The text must meet the current thresholds, which may have
+		// changed since it was approved
+		summary.Readability = h.analyzer.Analyze(summary.Text)
+		var err error
+		for _, problem := range summary.Readability.Problems {
+			err = validation.Append(err, "text", problem)
+		}
+		if err != nil {
+			return err
+		}
+
+		// The policy is written first, so a summary is only marked published
+		// once its policy shows it; if that fails it stays approved
+		publisher, at := caller.ID, time.Now()
+		summary.Status = models.SummaryPublished
+		summary.PublishedBy, summary.PublishedAt = &publisher, &at
+		return h.publish(ctx, r, summary)
+	})
+	if published == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 10*time.Second)
+	defer cancel()
+	h.supersede(ctx, r, published)
+}
+
+// publish writes a summary that is being published to its policy
+func (h *SummaryHandler) publish(ctx context.Context, r *http.Request, summary *models.Summary) error {
+	approvers := make([]primitive.ObjectID, len(summary.Approvals))
+	for i, approval := range summary.Approvals {
+		approvers[i] = approval.ReviewerID
+	}
+
+	for attempt := 1; ; attempt++ {
+		before, err := h.policies.FindByID(ctx, summary.PolicyID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apierror.NotFound("policy")
+			}
+			return err
+		}
+		policy := *before
+		policy.SimplifiedDesc = summary.Text
+		policy.Summary = &models.PublishedSummary{
+			ID:          summary.ID,
+			ApprovedBy:  approvers,
+			PublishedBy: *summary.PublishedBy,
+			PublishedAt: *summary.PublishedAt,
+		}
+		policy.Readability = summary.Readability
+		policy.LastUpdated = time.Now()
+
+		err = h.policies.Patch(ctx, &policy, []string{"simplified_desc", "summary", "readability", "last_updated"})
+		switch {
+		case errors.Is(err, repository.ErrRevisionConflict) && attempt < maxPatchAttempts:
+			continue
+		case errors.Is(err, repository.ErrRevisionConflict):
+			return apierror.New(http.StatusConflict, apierror.CodeConflict, "The policy is being changed; retry publishing the summary")
+		case errors.Is(err, repository.ErrNotFound):
+			return apierror.NotFound("policy")
+		case err != nil:
+			return err
+		}
+		h.audit.record(r, audit.ActionUpdate, audit.ResourcePolicy, policy.ID, before, &policy)
+		return nil
+	}
+}
+
+// supersede marks the summaries published before summary on its policy as
+// superseded. The summary's status has already been written, so failures
+// are logged rather than returned.
+func (h *SummaryHandler) supersede(ctx context.Context, r *http.Request, summary *models.Summary) {
+	previous, err := h.summaries.List(ctx, repository.SummaryFilter{PolicyID: summary.PolicyID, Status: models.SummaryPublished})
+	if err != nil {
+		logSummaryError(r, "Error superseding summaries", err, summary)
+		return
+	}
+	for i := range previous {
+		old := previous[i]
+		if old.ID == summary.ID {
+			continue
+		}
+		before := old
+		old.Status = models.SummarySuperseded
+		old.UpdatedAt = time.Now()
+		if err := h.summaries.Update(ctx, &old); err != nil {
+			logSummaryError(r, "Error superseding summary", err, &old)
+			continue
+		}
+		h.audit.record(r, audit.ActionUpdate, audit.ResourceSummary, old.ID, &before, &old)
+	}
+}
+
+// change applies a review step to the summary named by the id URL variable
+// on behalf of the caller and writes the result. Steps are conditional on
+// If-Match, if given; otherwise the step is reapplied if the summary changes
+// while it is applied. It returns the written summary, or nil if an error
+// response was written.
+func (h *SummaryHandler) change(w http.ResponseWriter, r *http.Request, step func(ctx context.Context, summary *models.Summary, caller *models.User) error) *models.Summary {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get summary ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("summary"))
+		return nil
+	}
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	current := func() (*models.Summary, error) { return h.summaries.FindByID(ctx, id) }
+
+	// Only change the revision the client expects, if any
+	expected, ok := expectedRevision(r, id, 0)
+	if !ok {
+		writePreconditionFailed(w, r, "summary", current, summaryTag)
+		return nil
+	}
+
+	// Roles and affiliations are read for every step, so changes to them
+	// apply at once
+	caller, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "User no longer exists"))
+			return nil
+		}
+		apierror.Write(w, r, err)
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		before, err := h.summaries.FindByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				apierror.Write(w, r, apierror.NotFound("summary"))
+				return nil
+			}
+			apierror.Write(w, r, err)
+			return nil
+		}
+		if expected != 0 && expected != before.Revision {
+			writePreconditionFailed(w, r, "summary", current, summaryTag)
+			return nil
+		}
+
+		summary := *before
+		summary.Reviewers = append([]primitive.ObjectID{}, before.Reviewers...)
+		summary.Comments = append([]models.SummaryComment{}, before.Comments...)
+		summary.Approvals = append([]models.SummaryApproval{}, before.Approvals...)
+		if err := step(ctx, &summary, caller); err != nil {
+			apierror.Write(w, r, err)
+			return nil
+		}
+		if summary.Text != before.Text || summary.Readability == nil {
+			summary.Readability = h.analyzer.Analyze(summary.Text)
+		}
+		summary.UpdatedAt = time.Now()
+
+		err = h.summaries.Update(ctx, &summary)
+		switch {
+		case errors.Is(err, repository.ErrRevisionConflict) && expected == 0 && attempt < maxPatchAttempts:
+			continue
+		case errors.Is(err, repository.ErrRevisionConflict):
+			writePreconditionFailed(w, r, "summary", current, summaryTag)
+			return nil
+		case errors.Is(err, repository.ErrNotFound):
+			apierror.Write(w, r, apierror.NotFound("summary"))
+			return nil
+		case err != nil:
+			apierror.Write(w, r, err)
+			return nil
+		}
+		h.audit.record(r, audit.ActionUpdate, audit.ResourceSummary, id, before, &summary)
+
+		// Return updated summary as JSON
+		w.Header().Set("ETag", summaryTag(&summary))
+		json.NewEncoder(w).Encode(summary)
+		return &summary
+	}
+}
+
+// requireStatus returns a conflict unless summary has one of statuses
+func requireStatus(summary *models.Summary, statuses ...string) error {
+	if slices.Contains(statuses, summary.Status) {
+		return nil
+	}
+	return apierror.New(http.StatusConflict, apierror.CodeConflict, "Summary is "+summary.Status+"; this requires "+strings.Join(statuses, " or "))
+}
+
+// forbidden returns a forbidden error with message
+func forbidden(message string) error {
+	return apierror.New(http.StatusForbidden, apierror.CodeForbidden, message)
+}
+
+// isAuthor reports whether user wrote summary
+func isAuthor(summary *models.Summary, user *models.User) bool {
+	return summary.AuthorID != nil && *summary.AuthorID == user.ID
+}
+
+// affiliation returns the user's declared affiliation in the form that is
+// compared, or "" if they have not declared one
+func affiliation(user *models.User) string {
+	return strings.ToLower(strings.TrimSpace(user.Affiliation))
+}
+
+// approvedAffiliations counts the different affiliations of summary's approvals
+func approvedAffiliations(summary *models.Summary) int {
+	seen := make(map[string]bool)
+	for _, approval := range summary.Approvals {
+		seen[strings.ToLower(approval.Affiliation)] = true
+	}
+	return len(seen)
+}
+
+// addComment appends a comment by user to summary
+func addComment(summary *models.Summary, user *models.User, body string) {
+	summary.Comments = append(summary.Comments, models.SummaryComment{
+		ID:        primitive.NewObjectID(),
+		AuthorID:  user.ID,
+		Body:      strings.TrimSpace(body),
+		CreatedAt: time.Now(),
+	})
+}
+
+// logSummaryError logs an error in publishing summary
+func logSummaryError(r *http.Request, message string, err error, summary *models.Summary) {
+	logging.FromContext(r.Context()).Error(message, "error", err, "summary_id", summary.ID.Hex(), "policy_id", summary.PolicyID.Hex())
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/logging"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trash listing types
+const (
+	TrashPolicies        = "policies"
+	TrashRepresentatives = "representatives"
+	TrashQuizzes         = "quizzes"
+)
+
+// TrashHandler handles the administrators' trash endpoints
+type TrashHandler struct {
+	policies        repository.PolicyRepository
+	representatives repository.RepresentativeRepository
+	quizzes         repository.QuizRepository
+	audit           *Auditor
+}
+
+// NewTrashHandler creates a new TrashHandler that records restores with auditor
+func NewTrashHandler(store *repository.Store, auditor *Auditor) *TrashHandler {
+	return &TrashHandler{
+		policies:        store.Policies,
+		representatives: store.Representatives,
+		quizzes:         store.Quizzes,
+		audit:           auditor,
+	}
+}
+
+// GetTrash handles GET requests listing deleted content, optionally of one type
+func (h *TrashHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	kind := r.URL.Query().Get("type")
+	switch kind {
+	case "", TrashPolicies, TrashRepresentatives, TrashQuizzes:
+	default:
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "type must be one of: policies, representatives, quizzes"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	trash := models.Trash{
+		Policies:        []models.Policy{},
+		Representatives: []models.Representative{},
+		Quizzes:         []models.PoliticalQuiz{},
+	}
+	var err error
+	if kind == "" || kind == TrashPolicies {
+		trash.Policies, err = h.policies.ListTrash(ctx)
+	}
+	if err == nil && (kind == "" || kind == TrashRepresentatives) {
+		trash.Representatives, err = h.representatives.ListTrash(ctx)
+	}
+	if err == nil && (kind == "" || kind == TrashQuizzes) {
+		trash.Quizzes, err = h.quizzes.ListTrash(ctx)
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return trash as JSON
+	json.NewEncoder(w).Encode(trash)
+}
+
+// RestorePolicy handles POST requests to take a policy out of the trash
+func (h *TrashHandler) RestorePolicy(w http.ResponseWriter, r *http.Request) {
+	serveRestore(w, r, h.audit, audit.ResourcePolicy, h.policies.Restore, policyTag)
+}
+
+// RestoreRepresentative handles POST requests to take a representative out of the trash
+func (h *TrashHandler) RestoreRepresentative(w http.ResponseWriter, r *http.Request) {
+	serveRestore(w, r, h.audit, audit.ResourceRepresentative, h.representatives.Restore, representativeTag)
+}
+
+// RestoreQuiz handles POST requests to take a quiz out of the trash
+func (h *TrashHandler) RestoreQuiz(w http.ResponseWriter, r *http.Request) {
+	serveRestore(w, r, h.audit, audit.ResourceQuiz, h.quizzes.Restore, quizTag)
+}
+
+// serveRestore restores the trashed document named by the id URL variable
+// and responds with it
+func serveRestore[T any](w http.ResponseWriter, r *http.Request, auditor *Auditor, resource string, restore func(ctx context.Context, id primitive.ObjectID) (*T, error), etag func(*T) string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get ID from URL
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID(resource))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	doc, err := restore(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "No "+resource+" with this ID is in the trash"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("Restored from trash", "resource", resource, "id", id.Hex())
+	auditor.record(r, audit.ActionRestore, resource, id, nil, doc)
+
+	// Return restored document as JSON
+	w.Header().Set("ETag", etag(doc))
+	json.NewEncoder(w).Encode(doc)
+}
@@ -1,201 +1,388 @@
-package handlers
-
-import (
-	"context"
-	"encoding/json"
-	"net/http"
-	"time"
-
-	"github.com/benjamingetches/govtrack/api/models"
-	"github.com/benjamingetches/govtrack/config"
-	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-// UserHandler handles user-related API endpoints
-type UserHandler struct {
-	collection *mongo.Collection
-}
-
-// NewUserHandler creates a new UserHandler
-func NewUserHandler(client *mongo.Client) *UserHandler {
-	collection := config.GetCollection(config.UsersCollection)
-	return &UserHandler{
-		collection: collection,
-	}
-}
-
-// GetUser handles GET requests for a single user
-func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get user ID from URL
-	params := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(params["id"])
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-
-	// Find user in database
-	var user models.User
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	err = h.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "User not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Return user as JSON
-	json.NewEncoder(w).Encode(user)
-}
-
-// GetUsers handles GET requests to retrieve all users
-func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Find all users in database
-	var users []models.User
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	cursor, err := h.collection.Find(ctx, bson.M{})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	// Decode results
-	if err = cursor.All(ctx, &users); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Return users as JSON
-	json.NewEncoder(w).Encode(users)
-}
-
-// CreateUser handles POST requests to create a new user
-func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Decode request body
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Set creation and update times
-	now := time.Now()
-	user.CreatedAt = now
-	user.UpdatedAt = now
-
-	// Insert user into database
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	result, err := h.collection.InsertOne(ctx, user)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Set ID from insert result
-	user.ID = result.InsertedID.(primitive.ObjectID)
-
-	// Return created user as JSON
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
-}
-
-// UpdateUser handles PUT requests to update a user
-func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get user ID from URL
-	params := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(params["id"])
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-
-	// Decode request body
-	var user models.User
-	err = json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Set update time
-	user.UpdatedAt = time.Now()
-
-	// Update user in database
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	result, err := h.collection.ReplaceOne(ctx, bson.M{"_id": id}, user)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if result.MatchedCount == 0 {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	// Return updated user as JSON
-	user.ID = id
-	json.NewEncoder(w).Encode(user)
-}
-
-// DeleteUser handles DELETE requests to delete a user
-func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get user ID from URL
-	params := mux.Vars(r)
-	id, err := primitive.ObjectIDFromHex(params["id"])
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-
-	// Delete user from database
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	result, err := h.collection.DeleteOne(ctx, bson.M{"_id": id})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if result.DeletedCount == 0 {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	// Return success message
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "User deleted successfully"})
-}
-
-// GetUserByAuth0ID handles GET requests to find a user by Auth0 ID
-func (h *UserHandler) GetUserByAuth0ID(w http.ResponseWriter, r *http.Request) {
-	// This method is no longer needed with JWT authentication
-	http.Error(w, "Method not supported", http.StatusNotFound)
-} 
\ No newline at end of file
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/validation"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userSecrets are the user fields whose values are left out of the audit log
+var userSecrets = []string{"password"}
+
+// UserHandler handles user-related API endpoints
+type UserHandler struct {
+	users repository.UserRepository
+	audit *Auditor
+}
+
+// NewUserHandler creates a new UserHandler that records changes with auditor
+func NewUserHandler(users repository.UserRepository, auditor *Auditor) *UserHandler {
+	return &UserHandler{
+		users: users,
+		audit: auditor,
+	}
+}
+
+// GetUser handles GET requests for a single user
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get user ID from URL
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("user"))
+		return
+	}
+
+	// Find user in database
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.users.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("user"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return user as JSON without the password hash
+	user.Password = ""
+	writeWithETag(w, r, userTag(user), user)
+}
+
+// GetUsers handles GET requests to retrieve all users
+func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Find all users in database
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	users, err := h.users.List(ctx)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Return users as JSON without password hashes
+	for i := range users {
+		users[i].Password = ""
+	}
+	json.NewEncoder(w).Encode(users)
+}
+
+// CreateUser handles POST requests to create a new user
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Decode and validate request body
+	var user models.User
+	err := validation.Decode(r, &user)
+	if user.Password == "" {
+		err = validation.Append(err, "password", "is required")
+	}
+	if user.Role != "" && user.Role != models.RoleUser {
+		err = validation.Append(err, "role", "is read-only")
+	}
+	if user.Affiliation != "" {
+		err = validation.Append(err, "affiliation", "can only be set by administrators")
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Never store a plain text password
+	if user.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		user.Password = string(hashedPassword)
+	}
+
+	// Set role, creation and update times; only moderators ban users
+	now := time.Now()
+	user.Email = models.NormalizeEmail(user.Email)
+	user.Role = models.RoleUser
+	user.CommentBan = nil
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Location.SyncGeo()
+
+	// Insert user into database
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	err = h.users.Create(ctx, &user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeEmailTaken, "Email already in use"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionCreate, audit.ResourceUser, user.ID, nil, &user, userSecrets...)
+
+	// Return created user as JSON
+	user.Password = ""
+	w.Header().Set("ETag", userTag(&user))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+// UpdateUser handles PUT requests to update a user
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get user ID from URL
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("user"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	caller, ok := h.authorize(ctx, w, r, id)
+	if !ok {
+		return
+	}
+
+	// Decode and validate request body
+	var user models.User
+	err = validation.Decode(r, &user)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	current := func() (*models.User, error) {
+		user, err := h.users.FindByID(ctx, id)
+		if user != nil {
+			user.Password = ""
+		}
+		return user, err
+	}
+
+	// Only replace the revision the client expects, if any
+	revision, ok := expectedRevision(r, id, user.Revision)
+	if !ok {
+		writePreconditionFailed(w, r, "user", current, userTag)
+		return
+	}
+
+	// The role can only be changed in the database
+	existing, err := h.users.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("user"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	if user.Role != "" && user.Role != existing.Role {
+		apierror.Write(w, r, validation.Append(nil, "role", "is read-only"))
+		return
+	}
+	user.Role = existing.Role
+
+	// Affiliations decide whose approvals count as balanced review, so only
+	// administrators set them
+	if caller.Role != models.RoleAdmin {
+		if user.Affiliation != "" && user.Affiliation != existing.Affiliation {
+			apierror.Write(w, r, validation.Append(nil, "affiliation", "can only be set by administrators"))
+			return
+		}
+		user.Affiliation = existing.Affiliation
+	}
+
+	// Only moderators ban users from commenting
+	user.CommentBan = existing.CommentBan
+
+	// Never store a plain text password
+	if user.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		user.Password = string(hashedPassword)
+	}
+
+	// Ensure ID matches path parameter and set update time
+	user.ID = id
+	user.Email = models.NormalizeEmail(user.Email)
+	user.Revision = revision
+	user.UpdatedAt = time.Now()
+	user.Location.SyncGeo()
+
+	// Update user in database
+	err = h.users.Update(ctx, &user)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			apierror.Write(w, r, apierror.NotFound("user"))
+		case errors.Is(err, repository.ErrDuplicate):
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeEmailTaken, "Email already in use"))
+		case errors.Is(err, repository.ErrRevisionConflict):
+			writePreconditionFailed(w, r, "user", current, userTag)
+		default:
+			apierror.Write(w, r, err)
+		}
+		return
+	}
+	h.audit.record(r, audit.ActionUpdate, audit.ResourceUser, id, existing, &user, userSecrets...)
+
+	// Return updated user as JSON
+	user.Password = ""
+	w.Header().Set("ETag", userTag(&user))
+	json.NewEncoder(w).Encode(user)
+}
+
+// PatchUser handles PATCH requests to change some fields of a user. The
+// password hash is never part of the patched document: a patch that sets a
+// password replaces it.
+func (h *UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	// Invalid IDs are reported by servePatch
+	var caller *models.User
+	if id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"]); err == nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		var ok bool
+		if caller, ok = h.authorize(ctx, w, r, id); !ok {
+			return
+		}
+	}
+
+	servePatch(w, r, patchTarget[models.User]{
+		resource: "user",
+		find:     h.users.FindByID,
+		patch:    h.users.Patch,
+		revision: func(u *models.User) *int64 { return &u.Revision },
+		etag:     userTag,
+		audit:    h.audit,
+		secret:   userSecrets,
+		prepare: func(stored, patched *models.User) error {
+			// The role can only be changed in the database
+			if patched.Role != stored.Role {
+				return validation.Append(nil, "role", "is read-only")
+			}
+
+			// Only administrators set affiliations
+			if patched.Affiliation != stored.Affiliation && caller.Role != models.RoleAdmin {
+				return validation.Append(nil, "affiliation", "can only be set by administrators")
+			}
+
+			// Never store a plain text password
+			if patched.Password == "" {
+				patched.Password = stored.Password
+			} else {
+				hashedPassword, err := bcrypt.GenerateFromPassword([]byte(patched.Password), bcrypt.DefaultCost)
+				if err != nil {
+					return apierror.Internal(err)
+				}
+				patched.Password = string(hashedPassword)
+			}
+
+			patched.ID = stored.ID
+			patched.Email = models.NormalizeEmail(patched.Email)
+			patched.CommentBan = stored.CommentBan
+			patched.CreatedAt = stored.CreatedAt
+			patched.UpdatedAt = stored.UpdatedAt
+			patched.Location.SyncGeo()
+			return nil
+		},
+		touch:     func(u *models.User) { u.UpdatedAt = time.Now() },
+		redact:    func(u *models.User) { u.Password = "" },
+		duplicate: apierror.New(http.StatusConflict, apierror.CodeEmailTaken, "Email already in use"),
+	})
+}
+
+// DeleteUser handles DELETE requests to delete a user
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get user ID from URL
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidID("user"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if _, ok := h.authorize(ctx, w, r, id); !ok {
+		return
+	}
+
+	// Delete user from database
+	before, err := h.users.FindByID(ctx, id)
+	if err == nil {
+		err = h.users.Delete(ctx, id)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound("user"))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	h.audit.record(r, audit.ActionDelete, audit.ResourceUser, id, before, nil, userSecrets...)
+
+	// Return success message
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "User deleted successfully"})
+}
+
+// authorize reads the user making the request, writing an error response
+// unless they are the user with id or an administrator. Only an account's
+// owner and administrators can change or delete it.
+func (h *UserHandler) authorize(ctx context.Context, w http.ResponseWriter, r *http.Request, id primitive.ObjectID) (*models.User, bool) {
+	userID, ok := callerID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		return nil, false
+	}
+	caller, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "User no longer exists"))
+			return nil, false
+		}
+		apierror.Write(w, r, err)
+		return nil, false
+	}
+	if caller.ID != id && caller.Role != models.RoleAdmin {
+		apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Only the account's owner and administrators can change it"))
+		return nil, false
+	}
+	return caller, true
+}
+
+// GetUserByAuth0ID handles GET requests to find a user by Auth0 ID
+func (h *UserHandler) GetUserByAuth0ID(w http.ResponseWriter, r *http.Request) {
+	// This method is no longer needed with JWT authentication
+	apierror.Write(w, r, apierror.New(http.StatusNotFound, apierror.CodeRouteNotFound, "Method not supported"))
+}
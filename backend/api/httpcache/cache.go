@@ -0,0 +1,140 @@
+package httpcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Entry is a cached response
+type Entry struct {
+	Body        []byte
+	ContentType string
+	ETag        string
+}
+
+// item is an entry together with its key, groups and expiry
+type item struct {
+	key     string
+	entry   Entry
+	groups  []string
+	expires time.Time
+}
+
+// Cache is a least-recently-used cache of responses, keyed by request URL.
+// Entries belong to groups, such as "policies", and are dropped when their
+// group is invalidated or after a TTL, so that writes by other server
+// instances are picked up too. A Cache is safe for concurrent use.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[string]*list.Element
+	order    *list.List // most recently used first
+
+	// generation increases on every invalidation, so that responses
+	// computed before a write are not stored after it
+	generation uint64
+
+	now func() time.Time
+}
+
+// New creates a Cache holding at most capacity entries for ttl each
+func New(capacity int, ttl time.Duration) *Cache {
+	return &Cache{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// Get returns the entry stored under key, if it has not expired
+func (c *Cache) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	it := element.Value.(*item)
+	if c.now().After(it.expires) {
+		c.removeElement(element)
+		return Entry{}, false
+	}
+	c.order.MoveToFront(element)
+	return it.entry, true
+}
+
+// Generation returns a token to pass to Put. Take it before computing the
+// response to be stored.
+func (c *Cache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Put stores entry under key in groups, evicting the least recently used
+// entry if the cache is full. The entry is discarded if any group was
+// invalidated since generation was taken, as it may be stale.
+func (c *Cache) Put(key string, entry Entry, generation uint64, groups ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation || c.capacity <= 0 {
+		return
+	}
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+	c.items[key] = c.order.PushFront(&item{
+		key:     key,
+		entry:   entry,
+		groups:  groups,
+		expires: c.now().Add(c.ttl),
+	})
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Invalidate drops every entry in any of groups
+func (c *Cache) Invalidate(groups ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if inAny(element.Value.(*item).groups, groups) {
+			c.removeElement(element)
+		}
+		element = next
+	}
+}
+
+// Len returns the number of entries in the cache
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// removeElement must be called with the lock held
+func (c *Cache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*item).key)
+}
+
+func inAny(groups, targets []string) bool {
+	for _, group := range groups {
+		for _, target := range targets {
+			if group == target {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package httpcache
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := New(2, time.Minute)
+	cache.Put("a", Entry{ETag: `"a"`}, cache.Generation())
+	cache.Put("b", Entry{ETag: `"b"`}, cache.Generation())
+
+	// Reading a makes b the least recently used
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("a should be cached")
+	}
+	cache.Put("c", Entry{ETag: `"c"`}, cache.Generation())
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("%s should be cached", key)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len = %d, want 2", cache.Len())
+	}
+}
+
+func TestCacheExpiresEntries(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := New(10, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.Put("a", Entry{}, cache.Generation())
+	now = now.Add(59 * time.Second)
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("a should be cached before its TTL")
+	}
+	now = now.Add(2 * time.Second)
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("a should expire after its TTL")
+	}
+}
+
+func TestCacheInvalidatesGroups(t *testing.T) {
+	cache := New(10, time.Minute)
+	cache.Put("policy", Entry{}, cache.Generation(), "policies")
+	cache.Put("votes", Entry{}, cache.Generation(), "representatives", "policies")
+	cache.Put("rep", Entry{}, cache.Generation(), "representatives")
+
+	cache.Invalidate("policies")
+	for key, want := range map[string]bool{"policy": false, "votes": false, "rep": true} {
+		if _, ok := cache.Get(key); ok != want {
+			t.Errorf("%s cached = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestCacheDiscardsResponsesComputedBeforeAWrite(t *testing.T) {
+	cache := New(10, time.Minute)
+	generation := cache.Generation()
+	cache.Invalidate("policies")
+
+	cache.Put("policy", Entry{}, generation, "policies")
+	if _, ok := cache.Get("policy"); ok {
+		t.Fatal("a response computed before an invalidation must not be stored")
+	}
+}
+
+func TestNoneMatch(t *testing.T) {
+	tag := VersionTag(primitive.NewObjectID(), 3)
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{tag, true},
+		{"W/" + tag, true},
+		{`"other", ` + tag, true},
+		{"*", true},
+		{`"other"`, false},
+	}
+	for _, c := range cases {
+		if got := NoneMatch(c.header, tag); got != c.want {
+			t.Errorf("NoneMatch(%q) = %v, want %v", c.header, got, c.want)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	id := primitive.NewObjectID()
+	tag := VersionTag(id, 7)
+	cases := []struct {
+		header   string
+		revision int64
+		ok       bool
+	}{
+		{"", 0, true},
+		{"*", 0, true},
+		{tag, 7, true},
+		{VersionTag(primitive.NewObjectID(), 2) + ", " + tag, 7, true},
+		{"W/" + tag, 0, false},
+		{VersionTag(primitive.NewObjectID(), 7), 0, false},
+		{`"` + id.Hex() + `-x"`, 0, false},
+	}
+	for _, c := range cases {
+		revision, ok := IfMatch(c.header, id)
+		if revision != c.revision || ok != c.ok {
+			t.Errorf("IfMatch(%q) = %d, %v; want %d, %v", c.header, revision, ok, c.revision, c.ok)
+		}
+	}
+}
+
+func TestTagsChangeWithContent(t *testing.T) {
+	id := primitive.NewObjectID()
+	if VersionTag(id, 1) == VersionTag(id, 2) {
+		t.Error("the version tag should change when the document is updated")
+	}
+
+	a, b := VersionTag(id, 1), VersionTag(primitive.NewObjectID(), 1)
+	if CombinedTag([]string{a, b}) == CombinedTag([]string{b, a}) {
+		t.Error("the list tag should change when the order changes")
+	}
+	if CombinedTag([]string{a}) == CombinedTag([]string{a, b}) {
+		t.Error("the list tag should change when a document is added")
+	}
+}
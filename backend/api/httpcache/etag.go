@@ -0,0 +1,73 @@
+// Package httpcache implements HTTP caching: entity tags for conditional
+// requests and an in-process LRU cache of GET responses that is invalidated
+// when the underlying data is written.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VersionTag returns a strong ETag for a document from its ID and revision
+func VersionTag(id primitive.ObjectID, revision int64) string {
+	return `"` + id.Hex() + "-" + strconv.FormatInt(revision, 10) + `"`
+}
+
+// IfMatch returns the revision of the document with the given ID that an
+// If-Match header value requires. It returns zero if there is no header or it
+// is "*", and false if no tag in it can match the document: weak tags never
+// do, as RFC 9110 requires strong comparison. Lists use their first tag for
+// the document.
+func IfMatch(header string, id primitive.ObjectID) (int64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	prefix := `"` + id.Hex() + "-"
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if !strings.HasPrefix(candidate, prefix) || !strings.HasSuffix(candidate, `"`) {
+			continue
+		}
+		revision, err := strconv.ParseInt(candidate[len(prefix):len(candidate)-1], 10, 64)
+		if err == nil && revision > 0 {
+			return revision, true
+		}
+	}
+	return 0, false
+}
+
+// CombinedTag returns a strong ETag for a list of documents from their tags,
+// so that it changes whenever a document is added, removed, reordered or
+// updated
+func CombinedTag(tags []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(tags, "\n")))
+	return `"l-` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// BodyTag returns a strong ETag for a response body that is not derived
+// from a single collection's documents
+func BodyTag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"b-` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NoneMatch reports whether an If-None-Match header value matches etag, in
+// which case a GET should be answered with 304 Not Modified. Tags are compared
+// weakly, as RFC 9110 requires for If-None-Match.
+func NoneMatch(header, etag string) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
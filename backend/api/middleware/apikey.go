@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/apikey"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/logging"
+	"github.com/benjamingetches/govtrack/metrics"
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+// Quota headers sent with every response to a request made with an API key
+const (
+	QuotaLimitHeader     = "X-API-Quota-Limit"
+	QuotaRemainingHeader = "X-API-Quota-Remaining"
+)
+
+// APIKeyAuth authenticates requests that carry an X-API-Key header. The key
+// must be active, hold the scope the route requires and be under its daily
+// quota, and write requests need an owner who can still edit. Accepted
+// requests run as the key's owner, with the context values "userId",
+// "apiKeyId" and "scopes" set.
+type APIKeyAuth struct {
+	keys  repository.APIKeyRepository
+	users repository.UserRepository
+	now   func() time.Time
+}
+
+// NewAPIKeyAuth creates an APIKeyAuth that looks keys up in keys and their
+// owners in users
+func NewAPIKeyAuth(keys repository.APIKeyRepository, users repository.UserRepository) *APIKeyAuth {
+	return &APIKeyAuth{keys: keys, users: users, now: time.Now}
+}
+
+// OrJWT returns a middleware that authenticates requests with an API key if
+// one is sent, and with a bearer token checked by verifyJWT otherwise
+func (a *APIKeyAuth) OrJWT(verifyJWT func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		withJWT := verifyJWT(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(apikey.Header) == "" {
+				withJWT.ServeHTTP(w, r)
+				return
+			}
+			a.authenticate(w, r, next)
+		})
+	}
+}
+
+// Optional is a middleware for public routes. Requests with an API key are
+// authenticated, so that the key's scope and quota apply; requests without
+// one pass through unchanged.
+func (a *APIKeyAuth) Optional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(apikey.Header) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		a.authenticate(w, r, next)
+	})
+}
+
+func (a *APIKeyAuth) authenticate(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	w.Header().Set("Content-Type", "application/json")
+
+	scope := RequiredScope(r)
+	if scope == "" {
+		metrics.APIKeyRequest("forbidden")
+		apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "API keys cannot be used on this route"))
+		return
+	}
+
+	raw := r.Header.Get(apikey.Header)
+	if !apikey.LooksValid(raw) {
+		metrics.APIKeyRequest("invalid")
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "Invalid API key"))
+		return
+	}
+
+	key, err := a.keys.FindByHash(r.Context(), apikey.Hash(raw))
+	if errors.Is(err, repository.ErrNotFound) {
+		metrics.APIKeyRequest("invalid")
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "Invalid API key"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	logging.AddFields(r.Context(), "api_key_id", key.ID.Hex())
+
+	if key.Revoked() {
+		metrics.APIKeyRequest("revoked")
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "API key has been revoked"))
+		return
+	}
+	if !key.HasScope(scope) {
+		metrics.APIKeyRequest("insufficient_scope")
+		apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeInsufficientScope, "API key lacks the "+scope+" scope"))
+		return
+	}
+	// Write keys are only issued to editors, and stop working if their
+	// owner loses the role
+	if scope == models.ScopeWrite {
+		owner, err := a.users.FindByID(r.Context(), key.UserID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, err)
+			return
+		}
+		if owner == nil || !owner.CanEdit() {
+			metrics.APIKeyRequest("forbidden")
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "The API key's owner can no longer write"))
+			return
+		}
+	}
+
+	// Count the request against the key's daily quota
+	now := a.now()
+	used, err := a.keys.RecordUse(r.Context(), key.ID, now)
+	if errors.Is(err, repository.ErrQuotaExceeded) {
+		metrics.APIKeyRequest("quota_exceeded")
+		w.Header().Set(QuotaLimitHeader, strconv.FormatInt(key.DailyQuota, 10))
+		w.Header().Set(QuotaRemainingHeader, "0")
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(untilNextDay(now))))
+		apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, apierror.CodeQuotaExceeded, "API key has used its daily quota"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	metrics.APIKeyRequest("accepted")
+	w.Header().Set(QuotaLimitHeader, strconv.FormatInt(used.DailyQuota, 10))
+	w.Header().Set(QuotaRemainingHeader, strconv.FormatInt(used.DailyQuota-used.UsageToday, 10))
+
+	// Run the request as the key's owner
+	userID := used.UserID.Hex()
+	ctx := context.WithValue(r.Context(), "userId", userID)
+	ctx = context.WithValue(ctx, "apiKeyId", used.ID.Hex())
+	ctx = context.WithValue(ctx, "scopes", used.Scopes)
+	logging.AddFields(ctx, "user_id", userID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequiredScope returns the API key scope needed for the route r matched, or
+// "" if API keys cannot be used on it
+func RequiredScope(r *http.Request) string {
+	return ScopeFor(r.Method, routeTemplate(r))
+}
+
+// ScopeFor returns the API key scope needed to call method on a route
+// template, or "" if API keys cannot be used on it. Account, login, key
+// management, administration, summary review, annotation, comment,
+// moderation, poll and personal quiz result routes need a user's token.
+// Constituent alignment is read from voting records.
+func ScopeFor(method, route string) string {
+	switch {
+	case strings.HasPrefix(route, "/api/auth"),
+		strings.HasPrefix(route, "/api/admin"),
+		strings.HasPrefix(route, "/api/users"),
+		strings.HasPrefix(route, "/api/public/users"),
+		strings.HasPrefix(route, "/api/keys"),
+		strings.HasPrefix(route, "/api/summaries"),
+		strings.HasPrefix(route, "/api/annotations"),
+		strings.HasPrefix(route, "/api/comments"),
+		strings.HasPrefix(route, "/api/moderation"),
+		strings.HasPrefix(route, "/api/polls"),
+		strings.Contains(route, "/results"):
+		return ""
+	case strings.HasSuffix(route, "/votes"), strings.HasSuffix(route, "/alignment"):
+		return models.ScopeVotesRead
+	case method == http.MethodGet || method == http.MethodHead:
+		return models.ScopePublicRead
+	default:
+		return models.ScopeWrite
+	}
+}
+
+// untilNextDay returns the time left until quotas reset at midnight UTC
+func untilNextDay(now time.Time) time.Duration {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(now)
+}
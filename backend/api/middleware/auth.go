@@ -1,80 +1,104 @@
-package middleware
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"os"
-	"strings"
-
-	"github.com/golang-jwt/jwt/v4"
-)
-
-// LocalClaims represents the claims in a JWT token
-type LocalClaims struct {
-	UserID string `json:"userId"`
-	Email  string `json:"email"`
-	Name   string `json:"name"`
-	jwt.RegisteredClaims
-}
-
-// VerifyJWT is a middleware that verifies JWT tokens
-func VerifyJWT(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		// Get token from Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Authorization header required"})
-			return
-		}
-
-		// Check if the header has the Bearer prefix
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Authorization header must be Bearer token"})
-			return
-		}
-
-		// Extract the token
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-		// Get JWT secret from environment
-		jwtSecret := os.Getenv("JWT_SECRET")
-		if jwtSecret == "" {
-			// Use a default secret if environment variable is not set
-			jwtSecret = "govtrack_jwt_secret_key_for_local_authentication"
-			fmt.Println("WARNING: Using default JWT secret in middleware. Set JWT_SECRET environment variable for production.")
-		}
-
-		// Parse and validate the token
-		token, err := jwt.ParseWithClaims(tokenString, &LocalClaims{}, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired token"})
-			return
-		}
-
-		// Check if token is valid
-		if claims, ok := token.Claims.(*LocalClaims); ok && token.Valid {
-			// Add user ID to request context
-			ctx := context.WithValue(r.Context(), "userId", claims.UserID)
-			ctx = context.WithValue(ctx, "email", claims.Email)
-			ctx = context.WithValue(ctx, "name", claims.Name)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		} else {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid token claims"})
-		}
-	})
-}
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/logging"
+	"github.com/benjamingetches/govtrack/metrics"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LocalClaims represents the claims in a JWT token
+type LocalClaims struct {
+	UserID string `json:"userId"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// VerifyJWT returns a middleware that verifies JWT tokens signed with secret
+func VerifyJWT(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return verifyJWT(secret, next)
+	}
+}
+
+func verifyJWT(jwtSecret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Get token from Authorization header
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "Authorization header required"))
+			return
+		}
+
+		// Check if the header has the Bearer prefix
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "Authorization header must be Bearer token"))
+			return
+		}
+
+		// Extract the token
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+		// Parse and validate the token
+		token, err := jwt.ParseWithClaims(tokenString, &LocalClaims{}, func(token *jwt.Token) (interface{}, error) {
+			// Validate the signing method
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return []byte(jwtSecret), nil
+		})
+
+		if err != nil {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token"))
+			return
+		}
+
+		// Check if token is valid
+		if claims, ok := token.Claims.(*LocalClaims); ok && token.Valid {
+			// Add user ID to request context
+			ctx := context.WithValue(r.Context(), "userId", claims.UserID)
+			ctx = context.WithValue(ctx, "email", claims.Email)
+			ctx = context.WithValue(ctx, "name", claims.Name)
+			logging.AddFields(ctx, "user_id", claims.UserID)
+			metrics.SessionSeen(claims.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		} else {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
+		}
+	})
+}
+
+// RequireAdmin returns a middleware that only lets administrators through.
+// It must run after authentication.
+func RequireAdmin(users repository.UserRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value("userId").(string)
+			var user *models.User
+			if id, err := primitive.ObjectIDFromHex(userID); err == nil {
+				user, err = users.FindByID(r.Context(), id)
+				if err != nil && !errors.Is(err, repository.ErrNotFound) {
+					apierror.Write(w, r, err)
+					return
+				}
+			}
+			if user == nil || user.Role != models.RoleAdmin {
+				apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Only administrators can use this route"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/benjamingetches/govtrack/api/httpcache"
+	"github.com/benjamingetches/govtrack/metrics"
+)
+
+// CacheStatusHeader reports whether a response was served from the response
+// cache ("hit") or computed ("miss")
+const CacheStatusHeader = "X-Cache"
+
+// headerHook calls before with the status code just before the response
+// headers are written
+type headerHook struct {
+	http.ResponseWriter
+	before      func(status int)
+	wroteHeader bool
+}
+
+func (h *headerHook) WriteHeader(status int) {
+	if !h.wroteHeader {
+		h.wroteHeader = true
+		h.before(status)
+	}
+	h.ResponseWriter.WriteHeader(status)
+}
+
+func (h *headerHook) Write(b []byte) (int, error) {
+	if !h.wroteHeader {
+		h.WriteHeader(http.StatusOK)
+	}
+	return h.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (h *headerHook) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
+
+// CacheControl returns a middleware that sets the Cache-Control header of
+// successful and 304 GET responses to policy. Other GET responses, such as
+// errors, are marked no-store.
+func CacheControl(policy string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&headerHook{ResponseWriter: w, before: func(status int) {
+				if status == http.StatusOK || status == http.StatusNotModified {
+					w.Header().Set("Cache-Control", policy)
+				} else {
+					w.Header().Set("Cache-Control", "no-store")
+				}
+			}}, r)
+		})
+	}
+}
+
+// ResponseCache returns a middleware that serves GET requests from cache.
+// Successful responses carrying an ETag are stored in groups, and hits are
+// answered with 304 when the client's If-None-Match matches.
+func ResponseCache(cache *httpcache.Cache, groups ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := r.URL.Path + "?" + r.URL.Query().Encode()
+			if entry, ok := cache.Get(key); ok {
+				metrics.CacheLookup(true)
+				header := w.Header()
+				header.Set(CacheStatusHeader, "hit")
+				header.Set("ETag", entry.ETag)
+				if httpcache.NoneMatch(r.Header.Get("If-None-Match"), entry.ETag) {
+					header.Del("Content-Type")
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				header.Set("Content-Type", entry.ContentType)
+				w.Write(entry.Body)
+				return
+			}
+			metrics.CacheLookup(false)
+
+			generation := cache.Generation()
+			w.Header().Set(CacheStatusHeader, "miss")
+			recorder := &bodyRecorder{statusRecorder: statusRecorder{ResponseWriter: w}}
+			next.ServeHTTP(recorder, r)
+
+			etag := w.Header().Get("ETag")
+			if recorder.Status() == http.StatusOK && etag != "" {
+				cache.Put(key, httpcache.Entry{
+					Body:        recorder.body.Bytes(),
+					ContentType: w.Header().Get("Content-Type"),
+					ETag:        etag,
+				}, generation, groups...)
+			}
+		})
+	}
+}
+
+// InvalidateCache returns a middleware that invalidates groups in cache when
+// a request that writes succeeds. Invalidation happens before the response
+// is sent, so a client that reads after its write sees the change.
+func InvalidateCache(cache *httpcache.Cache, groups ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&headerHook{ResponseWriter: w, before: func(status int) {
+				if status < http.StatusBadRequest {
+					cache.Invalidate(groups...)
+				}
+			}}, r)
+		})
+	}
+}
+
+// bodyRecorder copies the response body while writing it through
+type bodyRecorder struct {
+	statusRecorder
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.statusRecorder.Write(b)
+}
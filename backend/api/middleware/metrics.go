@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/benjamingetches/govtrack/metrics"
+)
+
+// Metrics is a middleware that records the count, status and latency of
+// every request, labelled with the matched route template
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		metrics.ObserveRequest(r.Method, routeTemplate(r), recorder.Status(), time.Since(start))
+	})
+}
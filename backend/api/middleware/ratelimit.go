@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/metrics"
+	"github.com/benjamingetches/govtrack/ratelimit"
+)
+
+// RateLimitKey identifies the client a request is counted against
+type RateLimitKey func(r *http.Request) string
+
+// ByIP counts requests against the client's IP address. The first address in
+// X-Forwarded-For is used only when trustForwardedFor is set, since clients
+// can forge it when the server is not behind a proxy.
+func ByIP(trustForwardedFor bool) RateLimitKey {
+	return func(r *http.Request) string {
+		return "ip:" + ClientIP(r, trustForwardedFor)
+	}
+}
+
+// ByClient counts requests against the API key they were made with, then
+// the authenticated user, falling back to the client's IP address. It must
+// run after authentication.
+func ByClient(trustForwardedFor bool) RateLimitKey {
+	byIP := ByIP(trustForwardedFor)
+	return func(r *http.Request) string {
+		if keyID, ok := r.Context().Value("apiKeyId").(string); ok && keyID != "" {
+			return "key:" + keyID
+		}
+		if userID, ok := r.Context().Value("userId").(string); ok && userID != "" {
+			return "user:" + userID
+		}
+		return byIP(r)
+	}
+}
+
+// ClientIP returns the IP address of the client that sent r
+func ClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimit returns a middleware that limits each client identified by key.
+// Every response carries RateLimit-* headers describing the client's quota;
+// requests over the limit get 429 with Retry-After. group names the limit in
+// metrics.
+func RateLimit(limiter *ratelimit.Limiter, group string, key RateLimitKey) func(http.Handler) http.Handler {
+	limit := limiter.Limit()
+	policy := strconv.Itoa(limit.Requests) + ";w=" + strconv.Itoa(ceilSeconds(limit.Per))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result := limiter.Allow(key(r))
+
+			header := w.Header()
+			header.Set("RateLimit-Policy", policy)
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				metrics.RateLimited(group)
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests, please retry later"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds, as HTTP headers require
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Annotation visibilities
+const (
+	AnnotationPrivate = "private" // Only its author sees it
+	AnnotationPublic  = "public"
+)
+
+// Annotation target statuses
+const (
+	AnnotationAnchored = "anchored"
+	// AnnotationOrphaned marks annotations whose text was not found in a
+	// later version of the policy's text
+	AnnotationOrphaned = "orphaned"
+)
+
+// Annotation is a user's highlight of, or note on, part of a policy's
+// original text, or a reply to another annotation
+type Annotation struct {
+	ID       primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	PolicyID primitive.ObjectID  `bson:"policy_id" json:"policy_id"`
+	AuthorID primitive.ObjectID  `bson:"author_id" json:"author_id"`
+	ParentID *primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitempty"` // Set on replies
+	// Visibility of replies is that of the annotation they reply to
+	Visibility string `bson:"visibility" json:"visibility" enum:"private,public"`
+	Body       string `bson:"body" json:"body"` // Empty for highlights
+	// Target is unset on replies
+	Target    *AnnotationTarget `bson:"target,omitempty" json:"target,omitempty"`
+	CreatedAt time.Time         `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time         `bson:"updated_at" json:"updated_at"`
+	Revision  int64             `bson:"revision" json:"revision,omitempty"` // Incremented on every write
+}
+
+// AnnotationTarget locates an annotation in a version of a policy's original
+// text. Start and End are character offsets into that version. The quoted
+// text and the text around it are kept so that the annotation can be found
+// again in later versions.
+type AnnotationTarget struct {
+	TextVersion int64  `bson:"text_version" json:"text_version"`
+	Anchor      string `bson:"anchor,omitempty" json:"anchor,omitempty"` // The innermost provision containing the range
+	Start       int    `bson:"start" json:"start"`
+	End         int    `bson:"end" json:"end"`
+	Quote       string `bson:"quote" json:"quote"`
+	Prefix      string `bson:"prefix" json:"prefix"`
+	Suffix      string `bson:"suffix" json:"suffix"`
+	Status      string `bson:"status" json:"status" enum:"anchored,orphaned"`
+}
+
+// AnnotationRequest is the body of requests creating an annotation. Replies
+// set ParentID; other annotations set the provision's Anchor, a range, or
+// both to annotate a range within the provision.
+type AnnotationRequest struct {
+	PolicyID   primitive.ObjectID  `json:"policy_id"`
+	ParentID   *primitive.ObjectID `json:"parent_id,omitempty"`
+	Anchor     string              `json:"anchor,omitempty"`
+	Start      *int                `json:"start,omitempty"`
+	End        *int                `json:"end,omitempty"`
+	Body       string              `json:"body" validate:"max=5000"`
+	Visibility string              `json:"visibility" enum:"private,public"` // Private by default
+}
+
+// AnnotationUpdate is the body of requests editing an annotation
+type AnnotationUpdate struct {
+	Body       string `json:"body" validate:"max=5000"`
+	Visibility string `json:"visibility" enum:"private,public"` // Unchanged if empty
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/validation"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// API key scopes
+const (
+	// ScopePublicRead allows reading policies, representatives and quizzes
+	ScopePublicRead = "public:read"
+	// ScopeVotesRead allows reading representatives' voting records
+	ScopeVotesRead = "votes:read"
+	// ScopeWrite allows creating, updating and deleting data. Only editors
+	// and admins can create keys with this scope.
+	ScopeWrite = "write"
+)
+
+// Scopes lists every API key scope
+var Scopes = []string{ScopePublicRead, ScopeVotesRead, ScopeWrite}
+
+// APIKey is a credential for third-party access to the API. Only a hash of
+// the key is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name   string             `bson:"name" json:"name"`
+	// Prefix is the start of the key, shown so that users can tell keys apart
+	Prefix     string     `bson:"prefix" json:"prefix"`
+	Hash       string     `bson:"hash" json:"-"`
+	Scopes     []string   `bson:"scopes" json:"scopes"`
+	DailyQuota int64      `bson:"daily_quota" json:"daily_quota"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+
+	// UsageCount counts every request made with the key
+	UsageCount int64 `bson:"usage_count" json:"usage_count"`
+	// UsageToday counts the requests made on UsageDay (UTC, YYYY-MM-DD)
+	UsageToday int64  `bson:"usage_today" json:"usage_today"`
+	UsageDay   string `bson:"usage_day,omitempty" json:"usage_day,omitempty"`
+}
+
+// HasScope reports whether the key grants scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoked reports whether the key has been revoked
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// CreateAPIKeyRequest is the body of a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required"`
+	// DailyQuota limits requests per UTC day; zero uses the server default
+	DailyQuota int64 `json:"daily_quota,omitempty" validate:"min=0,max=10000000"`
+}
+
+// Validate checks that every requested scope exists and is listed once
+func (req CreateAPIKeyRequest) Validate() validation.Errors {
+	var errs validation.Errors
+	seen := map[string]bool{}
+	for i, scope := range req.Scopes {
+		field := fmt.Sprintf("scopes[%d]", i)
+		switch {
+		case !slices.Contains(Scopes, scope):
+			errs.Add(field, "must be one of: public:read, votes:read, write")
+		case seen[scope]:
+			errs.Add(field, "is listed more than once")
+		}
+		seen[scope] = true
+	}
+	return errs
+}
+
+// CreateAPIKeyResponse returns a new key. Key is never shown again.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
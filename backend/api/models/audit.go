@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEntry records one change made through the API. Entries form a hash
+// chain: each entry's hash covers its content and the hash of the entry
+// before it, so altering or removing an entry breaks the chain from there on.
+type AuditEntry struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Sequence int64              `bson:"sequence" json:"sequence"` // 1 for the first entry, then consecutive
+	At       time.Time          `bson:"at" json:"at"`
+
+	// ActorID is the user who made the change, unset for the server's own
+	// changes such as purging the trash
+	ActorID  *primitive.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	APIKeyID *primitive.ObjectID `bson:"api_key_id,omitempty" json:"api_key_id,omitempty"`
+
+	Action       string             `bson:"action" json:"action"`
+	ResourceType string             `bson:"resource_type" json:"resource_type"`
+	ResourceID   primitive.ObjectID `bson:"resource_id" json:"resource_id"`
+	Changes      []FieldChange      `bson:"changes" json:"changes"`
+	IP           string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	RequestID    string             `bson:"request_id,omitempty" json:"request_id,omitempty"`
+
+	PrevHash string `bson:"prev_hash" json:"prev_hash"`
+	Hash     string `bson:"hash" json:"hash"`
+}
+
+// FieldChange is the change of one top-level field of a document, with the
+// values as they appear in API responses. Before is unset for fields the
+// change added and After for fields it removed.
+type FieldChange struct {
+	Field  string          `bson:"field" json:"field"`
+	Before json.RawMessage `bson:"before,omitempty" json:"before,omitempty"`
+	After  json.RawMessage `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// AuditVerification reports whether the audit log's hash chain is intact
+type AuditVerification struct {
+	Valid   bool  `json:"valid"`
+	Entries int64 `json:"entries"` // Number of entries checked
+	// LastHash is the hash of the last entry checked. Recording it elsewhere
+	// lets later verifications detect entries removed from the end.
+	LastHash string `json:"last_hash,omitempty"`
+	// BrokenAt is the sequence of the first entry that fails verification
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
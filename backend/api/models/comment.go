@@ -0,0 +1,142 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment statuses
+const (
+	CommentPublished = "published"
+	// CommentHeld marks comments awaiting moderation, because the content
+	// filter flagged them or enough users reported them
+	CommentHeld    = "held"
+	CommentHidden  = "hidden"  // Hidden by a moderator; only its author sees it
+	CommentRemoved = "removed" // Removed by a moderator; shown without its body
+	CommentDeleted = "deleted" // Deleted by its author; shown without its body
+)
+
+// Moderation actions
+const (
+	ModerationApprove = "approve"
+	ModerationHide    = "hide"
+	ModerationRemove  = "remove"
+	ModerationBan     = "ban" // Removes the comment and bans its author from commenting
+)
+
+// Report reasons
+const (
+	ReportSpam           = "spam"
+	ReportAbuse          = "abuse"
+	ReportOffTopic       = "off_topic"
+	ReportMisinformation = "misinformation"
+	ReportOther          = "other"
+)
+
+// Comment is a user's public comment on a policy, or a reply to another
+// comment. Removed and deleted comments keep their place in the thread.
+type Comment struct {
+	ID       primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	PolicyID primitive.ObjectID  `bson:"policy_id" json:"policy_id"`
+	AuthorID primitive.ObjectID  `bson:"author_id" json:"author_id"`
+	ParentID *primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitempty"` // Set on replies
+	Body     string              `bson:"body" json:"body"`
+	Status   string              `bson:"status" json:"status" enum:"published,held,hidden,removed,deleted"`
+	// Edits keeps the earlier bodies of the comment, oldest first
+	Edits     []CommentEdit `bson:"edits,omitempty" json:"edits,omitempty"`
+	Upvotes   int           `bson:"upvotes" json:"upvotes"`
+	Downvotes int           `bson:"downvotes" json:"downvotes"`
+	Votes     []CommentVote `bson:"votes,omitempty" json:"-"`
+	MyVote    int           `bson:"-" json:"my_vote,omitempty"` // The caller's vote, in authenticated responses
+	// Reports are only shown to moderators
+	Reports    []CommentReport    `bson:"reports,omitempty" json:"reports,omitempty"`
+	Moderation *CommentModeration `bson:"moderation,omitempty" json:"moderation,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	Revision   int64              `bson:"revision" json:"revision,omitempty"` // Incremented on every write
+}
+
+// Score returns the comment's upvotes less its downvotes
+func (c *Comment) Score() int {
+	return c.Upvotes - c.Downvotes
+}
+
+// CommentEdit is an earlier body of an edited comment
+type CommentEdit struct {
+	Body     string    `bson:"body" json:"body"`
+	EditedAt time.Time `bson:"edited_at" json:"edited_at"` // When this body was replaced
+}
+
+// CommentVote is a user's vote on a comment: 1 up or -1 down
+type CommentVote struct {
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Value  int                `bson:"value" json:"value"`
+}
+
+// CommentReport is a user's report of a comment to the moderators
+type CommentReport struct {
+	ReporterID primitive.ObjectID `bson:"reporter_id" json:"reporter_id"`
+	Reason     string             `bson:"reason" json:"reason" enum:"spam,abuse,off_topic,misinformation,other"`
+	Details    string             `bson:"details,omitempty" json:"details,omitempty"`
+	ReportedAt time.Time          `bson:"reported_at" json:"reported_at"`
+}
+
+// CommentModeration records why a comment was held and what a moderator
+// decided
+type CommentModeration struct {
+	// Reasons lists what held the comment: the content filter's findings or
+	// the reasons it was reported for
+	Reasons     []string            `bson:"reasons,omitempty" json:"reasons,omitempty"`
+	Action      string              `bson:"action,omitempty" json:"action,omitempty" enum:"approve,hide,remove,ban"`
+	ModeratorID *primitive.ObjectID `bson:"moderator_id,omitempty" json:"moderator_id,omitempty"`
+	Note        string              `bson:"note,omitempty" json:"note,omitempty"`
+	ModeratedAt *time.Time          `bson:"moderated_at,omitempty" json:"moderated_at,omitempty"`
+}
+
+// PolicyCommentRequest is the body of requests posting a comment. Replies
+// set ParentID.
+type PolicyCommentRequest struct {
+	PolicyID primitive.ObjectID  `json:"policy_id"`
+	ParentID *primitive.ObjectID `json:"parent_id,omitempty"`
+	Body     string              `json:"body" validate:"required,max=5000"`
+}
+
+// CommentUpdate is the body of requests editing a comment
+type CommentUpdate struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
+
+// CommentVoteRequest is the body of requests voting on a comment. A value
+// of 0 withdraws the caller's vote.
+type CommentVoteRequest struct {
+	Value int `json:"value" validate:"min=-1,max=1"`
+}
+
+// CommentReportRequest is the body of requests reporting a comment
+type CommentReportRequest struct {
+	Reason  string `json:"reason" validate:"required" enum:"spam,abuse,off_topic,misinformation,other"`
+	Details string `json:"details" validate:"max=500"`
+}
+
+// ModerationRequest is the body of requests moderating a comment
+type ModerationRequest struct {
+	Action string `json:"action" validate:"required" enum:"approve,hide,remove,ban"`
+	Note   string `json:"note" validate:"max=500"`
+	// BanDays limits a ban to some days; bans are indefinite by default
+	BanDays int `json:"ban_days,omitempty" validate:"min=0,max=3650"`
+}
+
+// CommentBan bars a user from commenting, until a time or indefinitely
+type CommentBan struct {
+	ModeratorID primitive.ObjectID `bson:"moderator_id" json:"moderator_id"`
+	CommentID   primitive.ObjectID `bson:"comment_id" json:"comment_id"` // The comment the user was banned for
+	Note        string             `bson:"note,omitempty" json:"note,omitempty"`
+	BannedAt    time.Time          `bson:"banned_at" json:"banned_at"`
+	Until       *time.Time         `bson:"until,omitempty" json:"until,omitempty"`
+}
+
+// Active reports whether the ban applies at now
+func (b *CommentBan) Active(now time.Time) bool {
+	return b != nil && (b.Until == nil || now.Before(*b.Until))
+}
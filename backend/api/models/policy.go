@@ -1,51 +1,117 @@
-package models
-
-import (
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-)
-
-// Policy represents a government policy or legislation
-type Policy struct {
-	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Title            string             `bson:"title" json:"title"`
-	Description      string             `bson:"description" json:"description"`
-	SimplifiedDesc   string             `bson:"simplified_desc" json:"simplified_desc"` // Humanified version
-	OriginalText     string             `bson:"original_text" json:"original_text"`
-	Status           string             `bson:"status" json:"status"` // e.g., "proposed", "passed", "failed"
-	IntroducedDate   time.Time          `bson:"introduced_date" json:"introduced_date"`
-	LastUpdated      time.Time          `bson:"last_updated" json:"last_updated"`
-	Type             string             `bson:"type" json:"type"` // e.g., "bill", "executive order", "local ordinance"
-	Level            string             `bson:"level" json:"level"` // "federal", "state", "local"
-	Jurisdiction     Jurisdiction       `bson:"jurisdiction" json:"jurisdiction"`
-	Tags             []string           `bson:"tags" json:"tags"`
-	Sponsors         []Representative   `bson:"sponsors" json:"sponsors"`
-	VotingRecord     []Vote             `bson:"voting_record" json:"voting_record"`
-	Sources          []Source           `bson:"sources" json:"sources"`
-	RelatedPolicies  []primitive.ObjectID `bson:"related_policies,omitempty" json:"related_policies,omitempty"`
-}
-
-// Jurisdiction represents the geographical jurisdiction of a policy
-type Jurisdiction struct {
-	Country string `bson:"country" json:"country"`
-	State   string `bson:"state,omitempty" json:"state,omitempty"`
-	County  string `bson:"county,omitempty" json:"county,omitempty"`
-	City    string `bson:"city,omitempty" json:"city,omitempty"`
-}
-
-// Vote represents a vote on a policy by a representative
-type Vote struct {
-	RepresentativeID primitive.ObjectID `bson:"representative_id" json:"representative_id"`
-	Vote             string             `bson:"vote" json:"vote"` // "yes", "no", "abstain", etc.
-	Date             time.Time          `bson:"date" json:"date"`
-	Comments         string             `bson:"comments,omitempty" json:"comments,omitempty"`
-}
-
-// Source represents a source of information about a policy
-type Source struct {
-	URL         string    `bson:"url" json:"url"`
-	Title       string    `bson:"title" json:"title"`
-	PublishedAt time.Time `bson:"published_at,omitempty" json:"published_at,omitempty"`
-	Publisher   string    `bson:"publisher,omitempty" json:"publisher,omitempty"`
-} 
\ No newline at end of file
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/validation"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Government levels
+const (
+	LevelFederal = "federal"
+	LevelState   = "state"
+	LevelLocal   = "local"
+)
+
+// Policy statuses
+const (
+	StatusProposed = "proposed"
+	StatusPassed   = "passed"
+	StatusFailed   = "failed"
+)
+
+// Vote values
+const (
+	VoteYes       = "yes"
+	VoteNo        = "no"
+	VoteAbstain   = "abstain"
+	VoteNotVoting = "not_voting"
+)
+
+// Policy represents a government policy or legislation
+type Policy struct {
+	ID              primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	Title           string               `bson:"title" json:"title" validate:"required,max=300"`
+	Description     string               `bson:"description" json:"description"`
+	SimplifiedDesc  string               `bson:"simplified_desc" json:"simplified_desc"` // Humanified version, set by publishing a reviewed summary
+	Summary         *PublishedSummary    `bson:"summary,omitempty" json:"summary,omitempty"`
+	Readability     *Readability         `bson:"readability,omitempty" json:"readability,omitempty"` // Scores of SimplifiedDesc
+	OriginalText    string               `bson:"original_text" json:"original_text"`
+	Sections        []Provision          `bson:"sections,omitempty" json:"sections,omitempty"` // Parsed from OriginalText
+	TextVersion     int64                `bson:"text_version" json:"text_version,omitempty"`   // Incremented when OriginalText changes
+	Status          string               `bson:"status" json:"status" enum:"proposed,passed,failed" validate:"required"`
+	IntroducedDate  time.Time            `bson:"introduced_date" json:"introduced_date"`
+	LastUpdated     time.Time            `bson:"last_updated" json:"last_updated"`
+	Type            string               `bson:"type" json:"type" validate:"max=100"` // e.g., "bill", "executive order", "local ordinance"
+	Level           string               `bson:"level" json:"level" enum:"federal,state,local" validate:"required"`
+	Jurisdiction    Jurisdiction         `bson:"jurisdiction" json:"jurisdiction"`
+	Tags            []string             `bson:"tags" json:"tags"`
+	Sponsors        []Representative     `bson:"sponsors" json:"sponsors"`
+	VotingRecord    []Vote               `bson:"voting_record" json:"voting_record"`
+	Sources         []Source             `bson:"sources" json:"sources"`
+	RelatedPolicies []primitive.ObjectID `bson:"related_policies,omitempty" json:"related_policies,omitempty"`
+	Revision        int64                `bson:"revision" json:"revision,omitempty" validate:"min=0"` // Incremented on every write
+	DeletedAt       *time.Time           `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`    // Set while the policy is in the trash
+	DeletedBy       *primitive.ObjectID  `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+}
+
+// Jurisdiction represents the geographical jurisdiction of a policy
+type Jurisdiction struct {
+	Country string `bson:"country" json:"country" validate:"required"`
+	State   string `bson:"state,omitempty" json:"state,omitempty"`
+	County  string `bson:"county,omitempty" json:"county,omitempty"`
+	City    string `bson:"city,omitempty" json:"city,omitempty"`
+}
+
+// Vote represents a vote on a policy by a representative
+type Vote struct {
+	RepresentativeID primitive.ObjectID `bson:"representative_id" json:"representative_id" validate:"required"`
+	Vote             string             `bson:"vote" json:"vote" enum:"yes,no,abstain,not_voting" validate:"required"`
+	Date             time.Time          `bson:"date" json:"date"`
+	Comments         string             `bson:"comments,omitempty" json:"comments,omitempty"`
+}
+
+// Source represents a source of information about a policy
+type Source struct {
+	URL         string    `bson:"url" json:"url" validate:"required,url"`
+	Title       string    `bson:"title" json:"title" validate:"max=300"`
+	PublishedAt time.Time `bson:"published_at,omitempty" json:"published_at,omitempty"`
+	Publisher   string    `bson:"publisher,omitempty" json:"publisher,omitempty"`
+}
+
+// RepresentativeVote is a representative's vote on a policy, as listed in
+// their voting record
+type RepresentativeVote struct {
+	PolicyID primitive.ObjectID `bson:"policy_id" json:"policy_id"`
+	Title    string             `bson:"title" json:"title"`
+	Status   string             `bson:"status" json:"status" enum:"proposed,passed,failed"`
+	Vote     Vote               `bson:"vote" json:"vote"`
+}
+
+// Validate checks rules that span several policy fields
+func (p Policy) Validate() validation.Errors {
+	var errs validation.Errors
+
+	// State and local policies must say where they apply
+	if (p.Level == LevelState || p.Level == LevelLocal) && p.Jurisdiction.State == "" {
+		errs.Add("jurisdiction.state", "is required for "+p.Level+" policies")
+	}
+	if p.Level == LevelLocal && p.Jurisdiction.City == "" && p.Jurisdiction.County == "" {
+		errs.Add("jurisdiction.city", "is required for local policies unless jurisdiction.county is set")
+	}
+
+	// Each representative votes at most once
+	voted := map[primitive.ObjectID]bool{}
+	for i, vote := range p.VotingRecord {
+		if vote.RepresentativeID.IsZero() {
+			continue
+		}
+		if voted[vote.RepresentativeID] {
+			errs.Add(fmt.Sprintf("voting_record[%d].representative_id", i), "has already voted on this policy")
+		}
+		voted[vote.RepresentativeID] = true
+	}
+	return errs
+}
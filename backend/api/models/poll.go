@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ballot is a user's answer to the poll of a policy, asking how they would
+// vote on it. Each user has one ballot per policy. The state and
+// congressional district of the voter are copied from their location when
+// they vote, so that results can be compared with their representatives.
+type Ballot struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	PolicyID primitive.ObjectID `bson:"policy_id" json:"policy_id"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Choice   string             `bson:"choice" json:"choice" enum:"yes,no,abstain"`
+	State    string             `bson:"state,omitempty" json:"state,omitempty"`
+	District string             `bson:"district,omitempty" json:"district,omitempty"` // Congressional district within State
+	CastAt   time.Time          `bson:"cast_at" json:"cast_at"`
+}
+
+// BallotRequest is the body of requests answering a policy's poll
+type BallotRequest struct {
+	Choice string `json:"choice" validate:"required" enum:"yes,no,abstain"`
+}
+
+// BallotCount is the number of ballots with a choice on a policy from the
+// voters of a state and district
+type BallotCount struct {
+	PolicyID primitive.ObjectID `bson:"policy_id"`
+	State    string             `bson:"state"`
+	District string             `bson:"district"`
+	Choice   string             `bson:"choice"`
+	Count    int64              `bson:"count"`
+}
+
+// Tally counts the ballots of a poll
+type Tally struct {
+	Yes     int64 `json:"yes"`
+	No      int64 `json:"no"`
+	Abstain int64 `json:"abstain"`
+	Total   int64 `json:"total"`
+	// Majority is yes or no, whichever more voters chose. Ties have none, and
+	// abstentions never decide it.
+	Majority string `json:"majority,omitempty" enum:"yes,no"`
+}
+
+// Add counts n ballots with choice
+func (t *Tally) Add(choice string, n int64) {
+	switch choice {
+	case VoteYes:
+		t.Yes += n
+	case VoteNo:
+		t.No += n
+	case VoteAbstain:
+		t.Abstain += n
+	default:
+		return
+	}
+	t.Total += n
+
+	switch {
+	case t.Yes > t.No:
+		t.Majority = VoteYes
+	case t.No > t.Yes:
+		t.Majority = VoteNo
+	default:
+		t.Majority = ""
+	}
+}
+
+// DistrictTally is the tally of the voters of a state, or of one of its
+// congressional districts
+type DistrictTally struct {
+	State    string `json:"state"`
+	District string `json:"district,omitempty"`
+	Tally
+}
+
+// PollResults are the results of a policy's poll, overall and by the state
+// and congressional district of the voters. States and districts with fewer
+// than MinBallots ballots are left out, but counted overall.
+type PollResults struct {
+	PolicyID   primitive.ObjectID `json:"policy_id"`
+	Overall    Tally              `json:"overall"`
+	States     []DistrictTally    `json:"states"`
+	Districts  []DistrictTally    `json:"districts"`
+	MinBallots int                `json:"min_ballots"`
+	MyChoice   string             `json:"my_choice,omitempty"` // Set for signed-in voters
+}
+
+// ConstituentAlignment compares a representative's votes with how their
+// constituents answered the polls of the same policies. Constituents are the
+// voters of the representative's congressional district, or of their whole
+// state for senators and state and local officials.
+type ConstituentAlignment struct {
+	RepresentativeID primitive.ObjectID `json:"representative_id"`
+	State            string             `json:"state"`
+	District         string             `json:"district,omitempty"`
+	// Compared counts the policies the representative voted yes or no on
+	// where enough constituents answered to have a majority
+	Compared int `json:"compared"`
+	Aligned  int `json:"aligned"`
+	// AlignmentRate is the percentage of compared votes that matched the
+	// constituents' majority, if any were compared
+	AlignmentRate *float64          `json:"alignment_rate,omitempty"`
+	Policies      []PolicyAlignment `json:"policies"`
+}
+
+// PolicyAlignment compares a representative's vote on a policy with their
+// constituents' poll
+type PolicyAlignment struct {
+	PolicyID primitive.ObjectID `json:"policy_id"`
+	Title    string             `json:"title"`
+	Vote     string             `json:"vote" enum:"yes,no,abstain,not_voting"`
+	// Constituents is left out if too few constituents answered
+	Constituents *Tally `json:"constituents,omitempty"`
+	// Aligned is left out unless the vote was compared
+	Aligned *bool `json:"aligned,omitempty"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Kinds of provisions, from the outermost
+const (
+	ProvisionTitle        = "title"
+	ProvisionSection      = "section"
+	ProvisionSubsection   = "subsection"
+	ProvisionParagraph    = "paragraph"
+	ProvisionSubparagraph = "subparagraph"
+	ProvisionClause       = "clause"
+)
+
+// Provision is a title, section or smaller numbered part of a policy's
+// original text. Start and End are character offsets into the text.
+type Provision struct {
+	Anchor  string `bson:"anchor" json:"anchor"` // Stable ID derived from its numbering, e.g. "sec-3-b-2"
+	Kind    string `bson:"kind" json:"kind" enum:"title,section,subsection,paragraph,subparagraph,clause"`
+	Number  string `bson:"number" json:"number"` // e.g. "3", "b" or "II"
+	Heading string `bson:"heading,omitempty" json:"heading,omitempty"`
+	// Text is the provision's own text, without its label, heading or
+	// children
+	Text       string           `bson:"text" json:"text"`
+	Start      int              `bson:"start" json:"start"`
+	End        int              `bson:"end" json:"end"`
+	References []CrossReference `bson:"references,omitempty" json:"references,omitempty"`
+	Children   []Provision      `bson:"children,omitempty" json:"children,omitempty"`
+}
+
+// CrossReference is a mention in a provision's text of another provision,
+// such as "section 3(b)". Start and End are character offsets into the
+// policy's original text.
+type CrossReference struct {
+	Text string `bson:"text" json:"text"`
+	// Anchor is the provision referred to. It is empty for references to
+	// other laws or to provisions the text does not have.
+	Anchor string `bson:"anchor,omitempty" json:"anchor,omitempty"`
+	Start  int    `bson:"start" json:"start"`
+	End    int    `bson:"end" json:"end"`
+}
+
+// ProvisionRef identifies a provision containing another one
+type ProvisionRef struct {
+	Anchor  string `json:"anchor"`
+	Kind    string `json:"kind"`
+	Number  string `json:"number"`
+	Heading string `json:"heading,omitempty"`
+}
+
+// PolicySection is a provision of a policy with the provisions containing
+// it, from the outermost
+type PolicySection struct {
+	PolicyID primitive.ObjectID `json:"policy_id"`
+	Citation string             `json:"citation"` // e.g. "Clean Water Act § 3(b)(2)"
+	Path     []ProvisionRef     `json:"path"`
+	Provision
+}
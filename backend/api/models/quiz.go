@@ -1,67 +1,101 @@
-package models
-
-import (
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-)
-
-// PoliticalQuiz represents a quiz to determine political stances
-type PoliticalQuiz struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Title       string             `bson:"title" json:"title"`
-	Description string             `bson:"description" json:"description"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
-	Questions   []QuizQuestion     `bson:"questions" json:"questions"`
-	Categories  []string           `bson:"categories" json:"categories"`
-	Version     string             `bson:"version" json:"version"`
-}
-
-// QuizQuestion represents a question in a political quiz
-type QuizQuestion struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Text        string             `bson:"text" json:"text"`
-	Category    string             `bson:"category" json:"category"` // e.g., "economic", "social", "foreign policy"
-	Description string             `bson:"description,omitempty" json:"description,omitempty"`
-	Options     []QuizOption       `bson:"options,omitempty" json:"options,omitempty"`
-	// For Likert scale questions (strongly disagree to strongly agree)
-	IsLikertScale bool `bson:"is_likert_scale" json:"is_likert_scale"`
-	// Representative stances on this question
-	RepresentativeStances []RepresentativeStance `bson:"representative_stances,omitempty" json:"representative_stances,omitempty"`
-}
-
-// QuizOption represents an option for a quiz question
-type QuizOption struct {
-	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Text  string             `bson:"text" json:"text"`
-	Value int                `bson:"value" json:"value"` // Numerical value for scoring
-}
-
-// RepresentativeStance represents a representative's stance on a quiz question
-type RepresentativeStance struct {
-	RepresentativeID primitive.ObjectID `bson:"representative_id" json:"representative_id"`
-	Stance           int                `bson:"stance" json:"stance"` // Scale from 1-5 or similar
-	Source           string             `bson:"source,omitempty" json:"source,omitempty"`
-	Date             time.Time          `bson:"date,omitempty" json:"date,omitempty"`
-	Comments         string             `bson:"comments,omitempty" json:"comments,omitempty"`
-}
-
-// QuizResult represents a user's result on a political quiz
-type QuizResult struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
-	QuizID     primitive.ObjectID `bson:"quiz_id" json:"quiz_id"`
-	TakenAt    time.Time          `bson:"taken_at" json:"taken_at"`
-	Responses  []QuizResponse     `bson:"responses" json:"responses"`
-	Categories map[string]float64 `bson:"categories" json:"categories"` // Category scores
-	// Alignment with representatives
-	RepresentativeAlignment []RepresentativeAlignment `bson:"representative_alignment,omitempty" json:"representative_alignment,omitempty"`
-}
-
-// RepresentativeAlignment represents how closely a user's quiz results align with a representative
-type RepresentativeAlignment struct {
-	RepresentativeID primitive.ObjectID `bson:"representative_id" json:"representative_id"`
-	OverallScore     float64            `bson:"overall_score" json:"overall_score"` // 0-100% alignment
-	CategoryScores   map[string]float64 `bson:"category_scores,omitempty" json:"category_scores,omitempty"`
-} 
\ No newline at end of file
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/validation"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PoliticalQuiz represents a quiz to determine political stances
+type PoliticalQuiz struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	Title       string              `bson:"title" json:"title" validate:"required,max=300"`
+	Description string              `bson:"description" json:"description"`
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time           `bson:"updated_at" json:"updated_at"`
+	Questions   []QuizQuestion      `bson:"questions" json:"questions" validate:"required"`
+	Categories  []string            `bson:"categories" json:"categories"`
+	Version     string              `bson:"version" json:"version" validate:"max=50"`
+	Revision    int64               `bson:"revision" json:"revision,omitempty" validate:"min=0"` // Incremented on every write
+	DeletedAt   *time.Time          `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`    // Set while the quiz is in the trash
+	DeletedBy   *primitive.ObjectID `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+}
+
+// QuizQuestion represents a question in a political quiz
+type QuizQuestion struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Text        string             `bson:"text" json:"text" validate:"required,max=1000"`
+	Category    string             `bson:"category" json:"category" validate:"required"` // e.g., "economic", "social", "foreign policy"
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Options     []QuizOption       `bson:"options,omitempty" json:"options,omitempty"`
+	// For Likert scale questions (strongly disagree to strongly agree)
+	IsLikertScale bool `bson:"is_likert_scale" json:"is_likert_scale"`
+	// Representative stances on this question
+	RepresentativeStances []RepresentativeStance `bson:"representative_stances,omitempty" json:"representative_stances,omitempty"`
+}
+
+// QuizOption represents an option for a quiz question
+type QuizOption struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Text  string             `bson:"text" json:"text" validate:"required"`
+	Value int                `bson:"value" json:"value"` // Numerical value for scoring
+}
+
+// RepresentativeStance represents a representative's stance on a quiz question
+type RepresentativeStance struct {
+	RepresentativeID primitive.ObjectID `bson:"representative_id" json:"representative_id" validate:"required"`
+	Stance           int                `bson:"stance" json:"stance" validate:"min=1,max=5"` // Scale from 1-5 or similar
+	Source           string             `bson:"source,omitempty" json:"source,omitempty"`
+	Date             time.Time          `bson:"date,omitempty" json:"date,omitempty"`
+	Comments         string             `bson:"comments,omitempty" json:"comments,omitempty"`
+}
+
+// QuizResult represents a user's result on a political quiz
+type QuizResult struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	QuizID     primitive.ObjectID `bson:"quiz_id" json:"quiz_id"`
+	TakenAt    time.Time          `bson:"taken_at" json:"taken_at"`
+	Responses  []QuizResponse     `bson:"responses" json:"responses" validate:"required"`
+	Categories map[string]float64 `bson:"categories" json:"categories"` // Category scores
+	// Alignment with representatives
+	RepresentativeAlignment []RepresentativeAlignment `bson:"representative_alignment,omitempty" json:"representative_alignment,omitempty"`
+}
+
+// RepresentativeAlignment represents how closely a user's quiz results align with a representative
+type RepresentativeAlignment struct {
+	RepresentativeID primitive.ObjectID `bson:"representative_id" json:"representative_id"`
+	OverallScore     float64            `bson:"overall_score" json:"overall_score"` // 0-100% alignment
+	CategoryScores   map[string]float64 `bson:"category_scores,omitempty" json:"category_scores,omitempty"`
+}
+
+// Validate checks rules that span several quiz fields
+func (q PoliticalQuiz) Validate() validation.Errors {
+	var errs validation.Errors
+	if len(q.Categories) == 0 {
+		return errs
+	}
+
+	// Questions must belong to one of the quiz's categories
+	categories := map[string]bool{}
+	for _, category := range q.Categories {
+		categories[category] = true
+	}
+	for i, question := range q.Questions {
+		if question.Category != "" && !categories[question.Category] {
+			errs.Add(fmt.Sprintf("questions[%d].category", i), "must be one of the quiz categories")
+		}
+	}
+	return errs
+}
+
+// Validate checks that multiple choice questions offer a choice
+func (q QuizQuestion) Validate() validation.Errors {
+	var errs validation.Errors
+	if !q.IsLikertScale && len(q.Options) < 2 {
+		errs.Add("options", "must have at least 2 items unless is_likert_scale is set")
+	}
+	return errs
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Readability measures how plain and neutral a simplified description is
+type Readability struct {
+	Grade           float64 `bson:"grade" json:"grade"`               // Flesch-Kincaid grade level
+	ReadingEase     float64 `bson:"reading_ease" json:"reading_ease"` // Flesch reading ease, higher is easier
+	Words           int     `bson:"words" json:"words"`
+	Sentences       int     `bson:"sentences" json:"sentences"`
+	SentenceLength  float64 `bson:"sentence_length" json:"sentence_length"` // Average words per sentence
+	LongestSentence int     `bson:"longest_sentence" json:"longest_sentence"`
+	// JargonTerms are the legislative glossary terms used
+	JargonTerms []string `bson:"jargon_terms" json:"jargon_terms"`
+	// LoadedTerms are the loaded or partisan terms used
+	LoadedTerms []string `bson:"loaded_terms" json:"loaded_terms"`
+	// Problems lists the thresholds the text does not meet; only text
+	// without problems can be published
+	Problems   []string  `bson:"problems" json:"problems"`
+	AnalyzedAt time.Time `bson:"analyzed_at" json:"analyzed_at"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Summary statuses. A summary is written as a draft, submitted for review,
+// approved by editors of different affiliations and then published, which
+// supersedes the policy's previously published summary.
+const (
+	SummaryDraft      = "draft"
+	SummaryInReview   = "in_review"
+	SummaryApproved   = "approved"
+	SummaryPublished  = "published"
+	SummarySuperseded = "superseded"
+)
+
+// SummaryStatuses lists every summary status
+var SummaryStatuses = []string{SummaryDraft, SummaryInReview, SummaryApproved, SummaryPublished, SummarySuperseded}
+
+// Summary is a proposed plain-language explanation of a policy. Only
+// approved summaries are published as the policy's simplified description.
+type Summary struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	PolicyID primitive.ObjectID `bson:"policy_id" json:"policy_id"`
+	Text     string             `bson:"text" json:"text"`
+	Status   string             `bson:"status" json:"status" enum:"draft,in_review,approved,published,superseded"`
+	// Readability scores Text each time the summary is written
+	Readability *Readability `bson:"readability,omitempty" json:"readability,omitempty"`
+	// AuthorID is unset for generated summaries and for summaries moved
+	// from policies by a migration
+	AuthorID    *primitive.ObjectID  `bson:"author_id,omitempty" json:"author_id,omitempty"`
+	Generator   *SummaryGenerator    `bson:"generator,omitempty" json:"generator,omitempty"`
+	Reviewers   []primitive.ObjectID `bson:"reviewers" json:"reviewers"`
+	Comments    []SummaryComment     `bson:"comments" json:"comments"`
+	Approvals   []SummaryApproval    `bson:"approvals" json:"approvals"`
+	PublishedBy *primitive.ObjectID  `bson:"published_by,omitempty" json:"published_by,omitempty"`
+	PublishedAt *time.Time           `bson:"published_at,omitempty" json:"published_at,omitempty"`
+	CreatedAt   time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time            `bson:"updated_at" json:"updated_at"`
+	Revision    int64                `bson:"revision" json:"revision,omitempty"` // Incremented on every write
+}
+
+// SummaryComment is a remark on a summary made during its review
+type SummaryComment struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	AuthorID  primitive.ObjectID `bson:"author_id" json:"author_id"`
+	Body      string             `bson:"body" json:"body"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// SummaryApproval records a reviewer's approval and the affiliation they had
+// declared when they gave it
+type SummaryApproval struct {
+	ReviewerID  primitive.ObjectID `bson:"reviewer_id" json:"reviewer_id"`
+	Affiliation string             `bson:"affiliation" json:"affiliation"`
+	At          time.Time          `bson:"at" json:"at"`
+}
+
+// SummaryGenerator records the generator that drafted a summary
+type SummaryGenerator struct {
+	Name        string    `bson:"name" json:"name"`
+	Version     string    `bson:"version" json:"version"`
+	GeneratedAt time.Time `bson:"generated_at" json:"generated_at"`
+}
+
+// PublishedSummary identifies the reviewed summary a policy's simplified
+// description was published from
+type PublishedSummary struct {
+	ID          primitive.ObjectID   `bson:"id" json:"id"`
+	ApprovedBy  []primitive.ObjectID `bson:"approved_by" json:"approved_by"`
+	PublishedBy primitive.ObjectID   `bson:"published_by" json:"published_by"`
+	PublishedAt time.Time            `bson:"published_at" json:"published_at"`
+}
+
+// SummaryRequest is the body of requests creating or editing a summary
+type SummaryRequest struct {
+	PolicyID primitive.ObjectID `json:"policy_id"`
+	Text     string             `json:"text" validate:"required,max=5000"`
+}
+
+// GenerateSummaryRequest is the body of requests generating a draft summary
+type GenerateSummaryRequest struct {
+	PolicyID primitive.ObjectID `json:"policy_id"`
+}
+
+// ReviewersRequest is the body of requests assigning reviewers to a summary
+type ReviewersRequest struct {
+	Reviewers []primitive.ObjectID `json:"reviewers" validate:"required"`
+}
+
+// CommentRequest is the body of requests commenting on a summary
+type CommentRequest struct {
+	Body string `json:"body" validate:"max=2000"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// Trash lists the content that has been deleted but not yet purged, most
+// recently deleted first
+type Trash struct {
+	Policies        []Policy         `json:"policies"`
+	Representatives []Representative `json:"representatives"`
+	Quizzes         []PoliticalQuiz  `json:"quizzes"`
+}
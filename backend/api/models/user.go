@@ -1,57 +1,108 @@
-package models
-
-import (
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-)
-
-// User represents a user in the system
-type User struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Name          string             `bson:"name" json:"name"`
-	Email         string             `bson:"email" json:"email"`
-	Password      string             `bson:"password,omitempty" json:"password,omitempty"` // Password is omitted from JSON responses
-	Location      Location           `bson:"location" json:"location"`
-	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
-	UpdatedAt     time.Time          `bson:"updated_at" json:"updatedAt"`
-	PoliticalQuiz []QuizResponse     `bson:"political_quiz,omitempty" json:"political_quiz,omitempty"`
-}
-
-// Location represents a geographical location
-type Location struct {
-	Address     string `bson:"address,omitempty" json:"address,omitempty"`
-	City        string `bson:"city" json:"city"`
-	State       string `bson:"state" json:"state"`
-	ZipCode     string `bson:"zip_code" json:"zip_code"`
-	Coordinates struct {
-		Latitude  float64 `bson:"latitude" json:"latitude"`
-		Longitude float64 `bson:"longitude" json:"longitude"`
-	} `bson:"coordinates,omitempty" json:"coordinates,omitempty"`
-	CongressionalDistrict string `bson:"congressional_district,omitempty" json:"congressional_district,omitempty"`
-}
-
-// QuizResponse represents a user's response to a political quiz question
-type QuizResponse struct {
-	QuestionID primitive.ObjectID `bson:"question_id" json:"question_id"`
-	Answer     int                `bson:"answer" json:"answer"` // Scale from 1-5 or similar
-}
-
-// LoginRequest represents the login request body
-type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-// RegisterRequest represents the register request body
-type RegisterRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-// AuthResponse represents the authentication response
-type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
-}
\ No newline at end of file
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User roles. Roles are assigned by administrators in the database and
+// cannot be set through the API.
+const (
+	RoleUser      = "user"
+	RoleEditor    = "editor"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// User represents a user in the system
+type User struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name          string             `bson:"name" json:"name" validate:"required,max=200"`
+	Email         string             `bson:"email" json:"email" validate:"required,email"`
+	Password      string             `bson:"password,omitempty" json:"password,omitempty" validate:"min=8,max=72"` // Password is omitted from JSON responses
+	Location      Location           `bson:"location" json:"location"`
+	Role          string             `bson:"role,omitempty" json:"role,omitempty"`
+	Affiliation   string             `bson:"affiliation,omitempty" json:"affiliation,omitempty" validate:"max=100"` // Political affiliation for balanced review; set by administrators
+	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updatedAt"`
+	PoliticalQuiz []QuizResponse     `bson:"political_quiz,omitempty" json:"political_quiz,omitempty"`
+	CommentBan    *CommentBan        `bson:"comment_ban,omitempty" json:"comment_ban,omitempty"`  // Set by moderators; read-only
+	Revision      int64              `bson:"revision" json:"revision,omitempty" validate:"min=0"` // Incremented on every write
+}
+
+// CanEdit reports whether the user may create write-scoped API keys.
+// Accounts created before roles existed have no role and are regular users.
+func (u *User) CanEdit() bool {
+	return u.Role == RoleEditor || u.Role == RoleAdmin
+}
+
+// CanModerate reports whether the user may moderate comments
+func (u *User) CanModerate() bool {
+	return u.Role == RoleModerator || u.Role == RoleAdmin
+}
+
+// NormalizeEmail returns email in the form it is stored, looked up and
+// locked out by, so that case variants name the same account
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Location represents a geographical location
+type Location struct {
+	Address     string `bson:"address,omitempty" json:"address,omitempty"`
+	City        string `bson:"city" json:"city"`
+	State       string `bson:"state" json:"state"`
+	ZipCode     string `bson:"zip_code" json:"zip_code"`
+	Coordinates struct {
+		Latitude  float64 `bson:"latitude" json:"latitude" validate:"min=-90,max=90"`
+		Longitude float64 `bson:"longitude" json:"longitude" validate:"min=-180,max=180"`
+	} `bson:"coordinates,omitempty" json:"coordinates,omitempty"`
+	CongressionalDistrict string `bson:"congressional_district,omitempty" json:"congressional_district,omitempty"`
+	// Geo mirrors Coordinates as GeoJSON so it can be served by a 2dsphere index
+	Geo *GeoPoint `bson:"geo,omitempty" json:"-"`
+}
+
+// GeoPoint represents a GeoJSON point
+type GeoPoint struct {
+	Type        string    `bson:"type" json:"type"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"` // [longitude, latitude]
+}
+
+// SyncGeo updates Geo from Coordinates, clearing it when no coordinates are set
+func (l *Location) SyncGeo() {
+	if l.Coordinates.Latitude == 0 && l.Coordinates.Longitude == 0 {
+		l.Geo = nil
+		return
+	}
+	l.Geo = &GeoPoint{
+		Type:        "Point",
+		Coordinates: []float64{l.Coordinates.Longitude, l.Coordinates.Latitude},
+	}
+}
+
+// QuizResponse represents a user's response to a political quiz question
+type QuizResponse struct {
+	QuestionID primitive.ObjectID `bson:"question_id" json:"question_id" validate:"required"`
+	Answer     int                `bson:"answer" json:"answer" validate:"min=1,max=5"` // Scale from 1-5 or similar
+}
+
+// LoginRequest represents the login request body
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// RegisterRequest represents the register request body
+type RegisterRequest struct {
+	Name     string `json:"name" validate:"required,max=200"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// AuthResponse represents the authentication response
+type AuthResponse struct {
+	Token string `json:"token"`
+	User  User   `json:"user"`
+}
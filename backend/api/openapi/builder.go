@@ -0,0 +1,296 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/patch"
+	"github.com/gorilla/mux"
+)
+
+// Route documents one method on one path template. Routes are keyed by
+// "METHOD /path/{template}" exactly as registered with the router.
+type Route struct {
+	Summary     string
+	Description string
+	Tag         string
+
+	// Public routes do not require a bearer token
+	Public bool
+
+	// Admin routes respond 403 Forbidden to users who are not administrators
+	Admin bool
+
+	// Scope is the API key scope that grants access to the route, or empty
+	// if API keys are not accepted
+	Scope string
+
+	// Query lists the supported query string parameters
+	Query []Parameter
+
+	// Request is a value of the type the request body decodes into, or nil
+	Request interface{}
+
+	// Patch routes accept a JSON Merge Patch of Request or a JSON Patch
+	// instead of a JSON body
+	Patch bool
+
+	// Response is a value of the type of the success response body, or nil
+	Response interface{}
+
+	// Status is the success status code, 200 when zero
+	Status int
+
+	// ContentType is the success response content type, application/json when empty
+	ContentType string
+
+	// RateLimited routes may respond 429 Too Many Requests
+	RateLimited bool
+
+	// Conditional routes send an ETag and respond 304 Not Modified when it
+	// matches If-None-Match
+	Conditional bool
+
+	// Versioned routes write a document only at the revision named by
+	// If-Match or the request body, and respond 412 Precondition Failed with
+	// the current document otherwise
+	Versioned bool
+}
+
+// QueryParam is a convenience constructor for an optional query parameter
+func QueryParam(name, description string, enum ...string) Parameter {
+	return Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      &Schema{Type: "string", Enum: enum},
+	}
+}
+
+// pathParamPattern matches {name} and {name:regexp} segments in mux templates
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]+)?\}`)
+
+// Build walks the router and documents every route found in routes. It
+// returns the document together with the "METHOD /path" keys of registered
+// routes that have no documentation.
+func Build(router *mux.Router, info Info, tags []Tag, routes map[string]Route) (*Document, []string, error) {
+	schemas := newSchemaRegistry()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+		Tags:    tags,
+		Components: Components{
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "Token returned by /api/auth/login or /api/auth/register",
+				},
+				"apiKeyAuth": {
+					Type:        "apiKey",
+					Name:        "X-API-Key",
+					In:          "header",
+					Description: "Developer API key created with POST /api/keys. Each operation lists the scope it requires.",
+				},
+			},
+		},
+	}
+
+	var undocumented []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Path prefixes and subrouters have no methods of their own
+			return nil
+		}
+
+		path := pathParamPattern.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			key := method + " " + template
+			documented, ok := routes[key]
+			if !ok {
+				undocumented = append(undocumented, key)
+				continue
+			}
+
+			item, ok := doc.Paths[path]
+			if !ok {
+				item = &PathItem{}
+				doc.Paths[path] = item
+			}
+			(*item)[strings.ToLower(method)] = buildOperation(method, template, documented, schemas)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc.Components.Schemas = schemas.schemas
+	sort.Strings(undocumented)
+	return doc, undocumented, nil
+}
+
+// buildOperation converts a documented route into an operation
+func buildOperation(method, template string, route Route, schemas *schemaRegistry) *Operation {
+	op := &Operation{
+		OperationID: operationID(method, template),
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   map[string]*Response{},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(template, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   pathParamSchema(match[1]),
+		})
+	}
+	op.Parameters = append(op.Parameters, route.Query...)
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: schemas.schemaOf(route.Request)}},
+		}
+	}
+	if route.Patch {
+		op.RequestBody.Content = map[string]*MediaType{
+			patch.MergePatchType: {Schema: schemas.schemaOf(route.Request)},
+			patch.JSONPatchType:  {Schema: schemas.schemaOf([]patch.Operation{})},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if route.Response != nil {
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success.Content = map[string]*MediaType{contentType: {Schema: schemas.schemaOf(route.Response)}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+	if route.Conditional {
+		success.Headers = map[string]*Header{
+			"ETag":          {Description: "Strong entity tag of the response", Schema: &Schema{Type: "string"}},
+			"Cache-Control": {Description: "How long the response may be reused", Schema: &Schema{Type: "string"}},
+		}
+		op.Responses[strconv.Itoa(http.StatusNotModified)] = &Response{Description: "The client's copy, named by If-None-Match, is current"}
+	}
+	if route.Versioned {
+		success.Headers = map[string]*Header{
+			"ETag": {Description: "Strong entity tag of the new revision", Schema: &Schema{Type: "string"}},
+		}
+	}
+
+	// Every error is an apierror problem details body
+	problem := &MediaType{Schema: schemas.schemaOf(apierror.Problem{})}
+	errorResponse := func(code int) {
+		op.Responses[strconv.Itoa(code)] = &Response{
+			Description: http.StatusText(code),
+			Content:     map[string]*MediaType{apierror.ContentType: problem},
+		}
+	}
+	if route.Request != nil || len(op.Parameters) > 0 {
+		errorResponse(http.StatusBadRequest)
+	}
+	if route.Request != nil {
+		errorResponse(http.StatusUnprocessableEntity)
+	}
+	if !route.Public {
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+		errorResponse(http.StatusUnauthorized)
+	}
+	if route.Scope != "" {
+		if route.Public {
+			// An empty requirement makes authentication optional
+			op.Security = []map[string][]string{{}}
+			errorResponse(http.StatusUnauthorized)
+		}
+		op.Security = append(op.Security, map[string][]string{"apiKeyAuth": {route.Scope}})
+		errorResponse(http.StatusForbidden)
+	}
+	if route.Admin {
+		errorResponse(http.StatusForbidden)
+	}
+	if strings.Contains(template, "{") {
+		errorResponse(http.StatusNotFound)
+	}
+	if route.Versioned {
+		errorResponse(http.StatusPreconditionFailed)
+	}
+	if route.Patch {
+		errorResponse(http.StatusConflict)
+		errorResponse(http.StatusUnsupportedMediaType)
+	}
+	if route.RateLimited {
+		errorResponse(http.StatusTooManyRequests)
+	}
+	errorResponse(http.StatusInternalServerError)
+
+	if route.Conditional {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        "If-None-Match",
+			In:          "header",
+			Description: "ETag of a previously fetched response",
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	if route.Versioned {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        "If-Match",
+			In:          "header",
+			Description: "ETag of the revision the write replaces; the revision field of the body may be used instead",
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+
+	return op
+}
+
+// pathParamSchema returns the schema for a path parameter; parameters named
+// id or ending in _id are ObjectIDs
+func pathParamSchema(name string) *Schema {
+	if name == "id" || strings.HasSuffix(name, "_id") || strings.HasSuffix(name, "Id") {
+		return objectIDSchema()
+	}
+	return &Schema{Type: "string"}
+}
+
+// operationID derives a stable identifier such as getApiPoliciesById
+func operationID(method, template string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, segment := range strings.Split(template, "/") {
+		if segment == "" {
+			continue
+		}
+		if match := pathParamPattern.FindStringSubmatch(segment); match != nil {
+			b.WriteString("By")
+			segment = match[1]
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
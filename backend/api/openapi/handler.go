@@ -0,0 +1,63 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/gorilla/mux"
+)
+
+//go:embed viewer.html
+var viewerHTML []byte
+
+// Handler serves the OpenAPI document for a router and an HTML viewer for it.
+// The document is built on first request, once every route is registered.
+type Handler struct {
+	router *mux.Router
+	info   Info
+	tags   []Tag
+	routes map[string]Route
+
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+// NewHandler creates a Handler documenting router with routes
+func NewHandler(router *mux.Router, info Info, tags []Tag, routes map[string]Route) *Handler {
+	return &Handler{router: router, info: info, tags: tags, routes: routes}
+}
+
+func (h *Handler) build() {
+	doc, undocumented, err := Build(h.router, h.info, h.tags, h.routes)
+	if err != nil {
+		h.err = err
+		return
+	}
+	for _, key := range undocumented {
+		slog.Warn("Route is missing from the OpenAPI document", "route", key)
+	}
+	h.spec, h.err = json.MarshalIndent(doc, "", "  ")
+}
+
+// ServeSpec handles GET requests for the OpenAPI document
+func (h *Handler) ServeSpec(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(h.build)
+	if h.err != nil {
+		apierror.Write(w, r, apierror.Internal(h.err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.spec)
+}
+
+// ServeViewer handles GET requests for the API documentation viewer
+func (h *Handler) ServeViewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(viewerHTML)
+}
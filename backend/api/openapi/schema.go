@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// objectIDSchema describes a hex-encoded MongoDB ObjectID
+func objectIDSchema() *Schema {
+	return &Schema{Type: "string", Pattern: "^[0-9a-fA-F]{24}$"}
+}
+
+// schemaRegistry converts Go types to schemas, collecting named struct types
+// as reusable components
+type schemaRegistry struct {
+	schemas map[string]*Schema
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: map[string]*Schema{}}
+}
+
+// schemaOf returns the schema for the type of v, or nil if v is nil
+func (r *schemaRegistry) schemaOf(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return r.schemaFor(reflect.TypeOf(v))
+}
+
+// schemaFor returns the schema for t. Named structs are registered as
+// components and referenced with $ref.
+func (r *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return objectIDSchema()
+	case rawMessageType:
+		// Raw JSON can hold any value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return r.schemaFor(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := r.schemas[name]; !ok {
+			// Reserve the name first so recursive types terminate
+			r.schemas[name] = &Schema{}
+			*r.schemas[name] = *r.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// structSchema describes the JSON encoding of a struct type
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	r.addFields(schema, t)
+	return schema
+}
+
+func (r *schemaRegistry) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a JSON name are flattened, as encoding/json does
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			r.addFields(schema, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := r.schemaFor(field.Type)
+		if enum := field.Tag.Get("enum"); enum != "" {
+			property = &Schema{Type: "string", Enum: strings.Split(enum, ",")}
+		}
+		if rules := field.Tag.Get("validate"); rules != "" {
+			if applyRules(property, rules) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// applyRules describes the validation package's validate tag rules on a
+// property schema and reports whether the field is required. Referenced
+// component schemas are shared, so only inline schemas are annotated.
+func applyRules(property *Schema, rules string) bool {
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			required = true
+		}
+		if property.Ref != "" {
+			continue
+		}
+
+		switch name {
+		case "url":
+			property.Format = "uri"
+		case "email":
+			property.Format = "email"
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			count := int(limit)
+			switch {
+			case property.Type == "string" && name == "min":
+				property.MinLength = &count
+			case property.Type == "string":
+				property.MaxLength = &count
+			case property.Type == "array" && name == "min":
+				property.MinItems = &count
+			case property.Type == "array":
+				property.MaxItems = &count
+			case name == "min":
+				property.Minimum = &limit
+			default:
+				property.Maximum = &limit
+			}
+		}
+	}
+	if required && property.Type == "array" && property.MinItems == nil {
+		one := 1
+		property.MinItems = &one
+	}
+	return required
+}
+
+// jsonName returns the name a field is encoded under by encoding/json, or an
+// empty string if its tag does not set one
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
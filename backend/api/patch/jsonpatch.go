@@ -0,0 +1,356 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/benjamingetches/govtrack/api/validation"
+)
+
+// Operation is a single JSON Patch operation
+type Operation struct {
+	Op    string      `json:"op" enum:"add,remove,replace,move,copy,test" validate:"required"`
+	Path  string      `json:"path" validate:"required"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+
+	// hasValue distinguishes a null value from a missing one
+	hasValue bool
+}
+
+// parseOperations checks that ops is a list of valid operations
+func parseOperations(ops interface{}) ([]Operation, error) {
+	list, ok := ops.([]interface{})
+	if !ok {
+		return nil, &validation.MalformedError{Detail: "A JSON Patch must be an array of operations"}
+	}
+
+	var errs validation.Errors
+	operations := make([]Operation, len(list))
+	for i, item := range list {
+		field := "[" + strconv.Itoa(i) + "]"
+		members, ok := item.(map[string]interface{})
+		if !ok {
+			errs.Add(field, "must be an object")
+			continue
+		}
+
+		op := &operations[i]
+		op.Op, _ = members["op"].(string)
+		op.From, _ = members["from"].(string)
+		op.Value, op.hasValue = members["value"]
+		path, ok := members["path"].(string)
+		op.Path = path
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if !op.hasValue {
+				errs.Add(field+".value", "is required")
+			}
+		case "move", "copy":
+			if _, ok := members["from"].(string); !ok {
+				errs.Add(field+".from", "is required")
+			} else if !validPointer(op.From) {
+				errs.Add(field+".from", "must be a JSON Pointer")
+			}
+		case "remove":
+		case "":
+			errs.Add(field+".op", "is required")
+		default:
+			errs.Add(field+".op", "must be one of: add, remove, replace, move, copy, test")
+		}
+		if !ok {
+			errs.Add(field+".path", "is required")
+		} else if !validPointer(path) {
+			errs.Add(field+".path", "must be a JSON Pointer")
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return operations, nil
+}
+
+// applyOperations applies operations to doc in order. Failures leave doc
+// partly patched, so callers must discard it.
+func applyOperations(doc interface{}, operations []Operation) (interface{}, error) {
+	for i, op := range operations {
+		var err error
+		switch op.Op {
+		case "add":
+			doc, err = add(doc, op.Path, op.Value)
+		case "remove":
+			doc, _, err = remove(doc, op.Path)
+		case "replace":
+			if doc, _, err = remove(doc, op.Path); err == nil {
+				doc, err = add(doc, op.Path, op.Value)
+			}
+		case "move":
+			if op.Path == op.From {
+				break
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				err = fmt.Errorf("cannot move %s into itself", displayPath(op.From))
+				break
+			}
+			var value interface{}
+			if doc, value, err = remove(doc, op.From); err == nil {
+				doc, err = add(doc, op.Path, value)
+			}
+		case "copy":
+			var value interface{}
+			if value, err = get(doc, op.From); err == nil {
+				doc, err = add(doc, op.Path, deepCopy(value))
+			}
+		case "test":
+			var value interface{}
+			if value, err = get(doc, op.Path); err == nil && !equal(value, op.Value) {
+				err = fmt.Errorf("%s does not have the expected value", displayPath(op.Path))
+			}
+		}
+		if err != nil {
+			return nil, &FailedError{Index: i, Reason: err.Error()}
+		}
+	}
+	return doc, nil
+}
+
+// validPointer reports whether p is a JSON Pointer (RFC 6901)
+func validPointer(p string) bool {
+	if p != "" && !strings.HasPrefix(p, "/") {
+		return false
+	}
+	for i := 0; i < len(p); i++ {
+		if p[i] == '~' && (i+1 == len(p) || (p[i+1] != '0' && p[i+1] != '1')) {
+			return false
+		}
+	}
+	return true
+}
+
+// tokens splits a JSON Pointer into its unescaped reference tokens
+func tokens(p string) []string {
+	if p == "" {
+		return nil
+	}
+	parts := strings.Split(p[1:], "/")
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+	}
+	return parts
+}
+
+// displayPath names a pointer in error messages
+func displayPath(p string) string {
+	if p == "" {
+		return "the document"
+	}
+	return p
+}
+
+// arrayIndex parses an array index token. end allows "-" and len(array),
+// which address the position after the last element.
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	if index > length || (index == length && !end) {
+		return 0, fmt.Errorf("index %d is out of range", index)
+	}
+	return index, nil
+}
+
+// get returns the value at pointer p
+func get(doc interface{}, p string) (interface{}, error) {
+	value := doc
+	for _, token := range tokens(p) {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			member, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%s does not exist", p)
+			}
+			value = member
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, fmt.Errorf("%s does not exist: %v", p, err)
+			}
+			value = node[index]
+		default:
+			return nil, fmt.Errorf("%s does not exist", p)
+		}
+	}
+	return value, nil
+}
+
+// parent returns the container holding the value at p, which must not be
+// the root, and the last reference token of p
+func parent(doc interface{}, p string) (interface{}, string, error) {
+	container, err := get(doc, parentPath(p))
+	if err != nil {
+		return nil, "", err
+	}
+	parts := tokens(p)
+	return container, parts[len(parts)-1], nil
+}
+
+// parentPath returns the pointer to the container of the value at p.
+// Escaped tokens contain no slashes, so the last token follows the last one.
+func parentPath(p string) string {
+	return p[:strings.LastIndex(p, "/")]
+}
+
+// add inserts value at p, replacing an object member or shifting array
+// elements, and returns the updated document
+func add(doc interface{}, p string, value interface{}) (interface{}, error) {
+	if p == "" {
+		return value, nil
+	}
+	container, token, err := parent(doc, p)
+	if err != nil {
+		return nil, err
+	}
+	switch node := container.(type) {
+	case map[string]interface{}:
+		node[token] = value
+	case []interface{}:
+		index, err := arrayIndex(token, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node, nil)
+		copy(node[index+1:], node[index:])
+		node[index] = value
+		return replaceAt(doc, parentPath(p), node)
+	default:
+		return nil, fmt.Errorf("the parent of %s is not an object or array", p)
+	}
+	return doc, nil
+}
+
+// remove deletes the value at p and returns the updated document and the
+// removed value
+func remove(doc interface{}, p string) (interface{}, interface{}, error) {
+	if p == "" {
+		return nil, doc, nil
+	}
+	container, token, err := parent(doc, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch node := container.(type) {
+	case map[string]interface{}:
+		value, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s does not exist", p)
+		}
+		delete(node, token)
+		return doc, value, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s does not exist: %v", p, err)
+		}
+		value := node[index]
+		node = append(node[:index:index], node[index+1:]...)
+		doc, err = replaceAt(doc, parentPath(p), node)
+		return doc, value, err
+	default:
+		return nil, nil, fmt.Errorf("%s does not exist", p)
+	}
+}
+
+// replaceAt stores value at p, which must exist, and returns the updated
+// document. Arrays change length in place this way, since slices are values.
+func replaceAt(doc interface{}, p string, value interface{}) (interface{}, error) {
+	if p == "" {
+		return value, nil
+	}
+	container, token, err := parent(doc, p)
+	if err != nil {
+		return nil, err
+	}
+	switch node := container.(type) {
+	case map[string]interface{}:
+		node[token] = value
+	case []interface{}:
+		index, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = value
+	}
+	return doc, nil
+}
+
+// deepCopy copies a decoded JSON value so that later operations on the copy
+// do not change the original
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		members := make(map[string]interface{}, len(v))
+		for name, member := range v {
+			members[name] = deepCopy(member)
+		}
+		return members
+	case []interface{}:
+		elements := make([]interface{}, len(v))
+		for i, element := range v {
+			elements[i] = deepCopy(element)
+		}
+		return elements
+	default:
+		return v
+	}
+}
+
+// equal compares decoded JSON values as RFC 6902 requires for test: numbers
+// by value, objects regardless of member order
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, okx := new(big.Float).SetString(x.String())
+		fy, oky := new(big.Float).SetString(y.String())
+		return okx && oky && fx.Cmp(fy) == 0
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for name, member := range x {
+			other, ok := y[name]
+			if !ok || !equal(member, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,111 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to the JSON form of a stored document.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"strconv"
+
+	"github.com/benjamingetches/govtrack/api/validation"
+)
+
+// Patch media types
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// Accepted lists the supported media types, as sent in Accept-Patch
+const Accepted = MergePatchType + ", " + JSONPatchType
+
+// ErrUnsupportedType is returned for a patch of any other media type
+var ErrUnsupportedType = errors.New("unsupported patch media type")
+
+// FailedError reports a JSON Patch operation that cannot be applied to the
+// document, such as a failed test or a path that does not exist. Reason is
+// safe to show to clients.
+type FailedError struct {
+	Index  int
+	Reason string
+}
+
+// Error implements the error interface
+func (e *FailedError) Error() string {
+	return "operation " + strconv.Itoa(e.Index) + " failed: " + e.Reason
+}
+
+// Apply applies patch, a document of the given Content-Type, to the JSON
+// document doc and returns the result. A patch that is not valid JSON gives a
+// *validation.MalformedError and invalid operations give validation.Errors.
+func Apply(contentType string, doc, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	var target, ops interface{}
+	if err := decode(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := decode(patch, &ops); err != nil {
+		return nil, &validation.MalformedError{Detail: "The patch is not valid JSON"}
+	}
+
+	var result interface{}
+	switch mediaType {
+	case MergePatchType:
+		if _, ok := ops.(map[string]interface{}); !ok {
+			return nil, &validation.MalformedError{Detail: "A merge patch must be a JSON object"}
+		}
+		result = Merge(target, ops)
+	case JSONPatchType:
+		operations, err := parseOperations(ops)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = applyOperations(target, operations); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedType
+	}
+	return json.Marshal(result)
+}
+
+// decode parses a single JSON value, keeping numbers exact
+func decode(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("more than one JSON value")
+	}
+	return nil
+}
+
+// Merge returns target with a JSON Merge Patch applied. Objects are merged
+// recursively, null removes a member and any other value replaces it.
+func Merge(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	doc, ok := target.(map[string]interface{})
+	if !ok {
+		doc = make(map[string]interface{})
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(doc, name)
+			continue
+		}
+		doc[name] = Merge(doc[name], value)
+	}
+	return doc
+}
@@ -0,0 +1,144 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/validation"
+)
+
+// sameJSON reports whether two JSON documents are equal
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var x, y interface{}
+	if err := decode(a, &x); err != nil {
+		t.Fatalf("decode %s: %v", a, err)
+	}
+	if err := decode(b, &y); err != nil {
+		t.Fatalf("decode %s: %v", b, err)
+	}
+	return equal(x, y)
+}
+
+func TestMergePatch(t *testing.T) {
+	cases := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		got, err := Apply(MergePatchType, []byte(c.doc), []byte(c.patch))
+		if err != nil {
+			t.Errorf("merge %s into %s: %v", c.patch, c.doc, err)
+			continue
+		}
+		if !sameJSON(t, got, []byte(c.want)) {
+			t.Errorf("merge %s into %s = %s, want %s", c.patch, c.doc, got, c.want)
+		}
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	cases := []struct{ doc, patch, want string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"foo":{"bar":[1]}}`, `[{"op":"copy","from":"/foo/bar","path":"/baz"},{"op":"add","path":"/baz/-","value":2}]`, `{"foo":{"bar":[1]},"baz":[1,2]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`, `{"~1":10}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":null}]`, `{"foo":"bar","baz":null}`},
+	}
+	for _, c := range cases {
+		got, err := Apply(JSONPatchType, []byte(c.doc), []byte(c.patch))
+		if err != nil {
+			t.Errorf("patch %s with %s: %v", c.doc, c.patch, err)
+			continue
+		}
+		if !sameJSON(t, got, []byte(c.want)) {
+			t.Errorf("patch %s with %s = %s, want %s", c.doc, c.patch, got, c.want)
+		}
+	}
+}
+
+func TestJSONPatchFailures(t *testing.T) {
+	cases := []struct{ doc, patch string }{
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`},
+		{`{"foo":[1]}`, `[{"op":"add","path":"/foo/2","value":2}]`},
+		{`{"foo":[1]}`, `[{"op":"remove","path":"/foo/01"}]`},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`},
+	}
+	for _, c := range cases {
+		_, err := Apply(JSONPatchType, []byte(c.doc), []byte(c.patch))
+		var failed *FailedError
+		if !errors.As(err, &failed) || failed.Index != 0 {
+			t.Errorf("patch %s with %s: error = %v, want operation 0 to fail", c.doc, c.patch, err)
+		}
+	}
+}
+
+func TestInvalidPatches(t *testing.T) {
+	doc := []byte(`{"foo":"bar"}`)
+
+	_, err := Apply(JSONPatchType, doc, []byte(`[{"op":"add","path":"/a","value":1},{"op":"frobnicate","path":"a"},{"path":"/b"},{"op":"copy","path":"/c"}]`))
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error = %v, want field errors", err)
+	}
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{"[1].op", "[1].path", "[2].op", "[3].from"} {
+		if !fields[field] {
+			t.Errorf("no error for %s in %v", field, errs)
+		}
+	}
+
+	for _, c := range []struct{ contentType, patch string }{
+		{JSONPatchType, `{"op":"add"}`},
+		{MergePatchType, `["a"]`},
+		{MergePatchType, `{"a":`},
+	} {
+		var malformed *validation.MalformedError
+		if _, err := Apply(c.contentType, doc, []byte(c.patch)); !errors.As(err, &malformed) {
+			t.Errorf("%s patch %s: error = %v, want a malformed body", c.contentType, c.patch, err)
+		}
+	}
+
+	if _, err := Apply("application/json", doc, []byte(`{}`)); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("application/json patch: error = %v, want ErrUnsupportedType", err)
+	}
+	if _, err := Apply(MergePatchType+"; charset=utf-8", doc, []byte(`{}`)); err != nil {
+		t.Errorf("media type parameters should be ignored: %v", err)
+	}
+}
+
+func TestNumbersKeepTheirPrecision(t *testing.T) {
+	got, err := Apply(MergePatchType, []byte(`{"big":9007199254740993}`), []byte(`{"other":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]json.Number
+	if err := json.Unmarshal(got, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["big"] != "9007199254740993" {
+		t.Errorf("big = %s, want 9007199254740993", doc["big"])
+	}
+}
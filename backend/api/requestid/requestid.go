@@ -0,0 +1,43 @@
+// Package requestid carries the identifier of the current request through its
+// context so that responses and logs can be correlated.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+)
+
+// Header is the request and response header carrying the request ID
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// validID matches request IDs accepted from clients and upstream proxies
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// New generates a random request ID
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether id is safe to adopt from an incoming request
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// NewContext returns a copy of ctx carrying id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or an empty string
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
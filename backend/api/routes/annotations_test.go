@@ -0,0 +1,185 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/models"
+)
+
+// annotate creates an annotation with the server's token, expecting 201
+func annotate(t *testing.T, s *testServer, body map[string]interface{}) models.Annotation {
+	t.Helper()
+	resp := s.do(t, "POST", "/api/annotations", body)
+	expectStatus(t, resp, http.StatusCreated)
+	var annotation models.Annotation
+	resp.decode(t, &annotation)
+	return annotation
+}
+
+// annotations lists the annotations at path, expecting 200
+func annotations(t *testing.T, s *testServer, path string) []models.Annotation {
+	t.Helper()
+	resp := s.do(t, "GET", path, nil)
+	expectStatus(t, resp, http.StatusOK)
+	var list []models.Annotation
+	resp.decode(t, &list)
+	return list
+}
+
+// rangeOf returns the character offsets of quote in billText
+func rangeOf(quote string) (int, int) {
+	start := len([]rune(billText[:strings.Index(billText, quote)]))
+	return start, start + len([]rune(quote))
+}
+
+func TestAnnotations(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Grace Hopper", "grace@example.com")
+	grace := s.token
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	ada := s.token
+	bill := samplePolicy("Safe School Water Act", "2024-01-01T00:00:00Z")
+	bill["original_text"] = billText
+	policy := createResource(t, s, "/api/policies", bill)
+	policyID := policy["id"].(string)
+	list := "/api/annotations?policy_id=" + policyID
+	public := "/api/public/policies/" + policyID + "/annotations"
+
+	start, end := rangeOf("every year")
+	resp := s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID, "start": start, "end": end, "body": "Too rare?"})
+	golden(t, "annotations/create", resp)
+	expectStatus(t, resp, http.StatusCreated)
+	var note models.Annotation
+	resp.decode(t, &note)
+	if note.Visibility != models.AnnotationPrivate || note.Target.Quote != "every year" || note.Target.Anchor != "sec-2-a-1" {
+		t.Fatalf("annotation = %+v, want a private note on sec-2-a-1", note)
+	}
+	highlight := annotate(t, s, map[string]interface{}{"policy_id": policyID, "anchor": "sec-2-b", "visibility": "public"})
+	if !strings.HasPrefix(highlight.Target.Quote, "(b) Repairs.") {
+		t.Errorf("quote = %q, want the whole subsection", highlight.Target.Quote)
+	}
+
+	t.Run("targets are validated", func(t *testing.T) {
+		inTitle, _ := rangeOf("Safe School")
+		expectFieldErrors(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID}), "anchor")
+		expectFieldErrors(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID, "anchor": "sec-9"}), "anchor")
+		expectFieldErrors(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID, "anchor": "sec-2-b", "start": inTitle, "end": inTitle + 4}), "start")
+		expectFieldErrors(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID, "start": 10, "end": 100000}), "end")
+		expectFieldErrors(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID, "start": 10, "end": 10}), "end")
+		expectFieldErrors(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID, "start": 10, "end": 20, "visibility": "friends"}), "visibility")
+		decodeError(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": "64b7f0f0f0f0f0f0f0f0f0f0", "anchor": "sec-1"}), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "GET", "/api/annotations", nil), http.StatusBadRequest, "invalid_parameter")
+	})
+
+	t.Run("private annotations are only visible to their author", func(t *testing.T) {
+		if got := annotations(t, s, list); len(got) != 2 {
+			t.Errorf("author sees %d annotations, want 2", len(got))
+		}
+		s.token = grace
+		defer func() { s.token = ada }()
+		if got := annotations(t, s, list); len(got) != 1 || got[0].ID != highlight.ID {
+			t.Errorf("others see %+v, want the public highlight", got)
+		}
+		if got := annotations(t, s, list+"&mine=true"); len(got) != 0 {
+			t.Errorf("mine = %+v, want none", got)
+		}
+		decodeError(t, s.do(t, "GET", "/api/annotations/"+note.ID.Hex(), nil), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "PUT", "/api/annotations/"+highlight.ID.Hex(), map[string]interface{}{"body": "Mine now"}), http.StatusForbidden, "forbidden")
+		decodeError(t, s.do(t, "DELETE", "/api/annotations/"+highlight.ID.Hex(), nil), http.StatusForbidden, "forbidden")
+
+		s.token = ""
+		if got := annotations(t, s, public); len(got) != 1 || got[0].ID != highlight.ID {
+			t.Errorf("public annotations = %+v, want the highlight", got)
+		}
+		if got := annotations(t, s, public+"?anchor=sec-2-a-1"); len(got) != 0 {
+			t.Errorf("public annotations of sec-2-a-1 = %+v, want none", got)
+		}
+		decodeError(t, s.do(t, "GET", "/api/public/policies/64b7f0f0f0f0f0f0f0f0f0f0/annotations", nil), http.StatusNotFound, "not_found")
+	})
+
+	t.Run("replies share their thread's visibility", func(t *testing.T) {
+		s.token = grace
+		defer func() { s.token = ada }()
+		decodeError(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID, "parent_id": note.ID.Hex(), "body": "Agreed"}), http.StatusNotFound, "not_found")
+		expectFieldErrors(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID, "parent_id": highlight.ID.Hex()}), "body")
+		expectFieldErrors(t, s.do(t, "POST", "/api/annotations", map[string]interface{}{"policy_id": policyID, "parent_id": highlight.ID.Hex(), "body": "Hm", "visibility": "private"}), "visibility")
+		reply := annotate(t, s, map[string]interface{}{"policy_id": policyID, "parent_id": highlight.ID.Hex(), "body": "Which fixtures?"})
+		if reply.Visibility != models.AnnotationPublic || reply.Target != nil || *reply.ParentID != highlight.ID {
+			t.Errorf("reply = %+v, want a public reply without a target", reply)
+		}
+
+		s.token = ada
+		expectFieldErrors(t, s.do(t, "PUT", "/api/annotations/"+highlight.ID.Hex(), map[string]interface{}{"visibility": "private"}), "visibility")
+	})
+
+	t.Run("authors change their annotations", func(t *testing.T) {
+		path := "/api/annotations/" + note.ID.Hex()
+		resp := s.do(t, "PUT", path, map[string]interface{}{"body": "Should be twice a year.", "visibility": "public"})
+		expectStatus(t, resp, http.StatusOK)
+		var updated models.Annotation
+		resp.decode(t, &updated)
+		if updated.Body != "Should be twice a year." || updated.Visibility != models.AnnotationPublic || updated.Target.Quote != "every year" {
+			t.Errorf("updated = %+v", updated)
+		}
+
+		header := http.Header{"Authorization": {"Bearer " + ada}, "If-Match": {`"` + note.ID.Hex() + `-1"`}}
+		expectStatus(t, s.doWithHeader(t, "PUT", path, header, map[string]interface{}{"body": "Stale"}), http.StatusPreconditionFailed)
+	})
+
+	t.Run("annotations follow amendments", func(t *testing.T) {
+		amended := strings.Replace(billText, "SEC. 1. SHORT TITLE.\nThis Act may be cited as the Safe School Water Act.\n", "", 1)
+		amended = strings.Replace(amended, "SEC. 2. TESTING.", "SEC. 1. TESTING.", 1)
+		amended = strings.Replace(amended, "shall replace its fixtures", "shall replace its pipes", 1)
+		amended = strings.Replace(amended, "(1) every year; and", "(1) each spring; and", 1)
+		resp := s.mergePatch(t, "/api/policies/"+policyID, map[string]interface{}{"original_text": amended})
+		expectStatus(t, resp, http.StatusOK)
+		var patched models.Policy
+		resp.decode(t, &patched)
+		if patched.TextVersion != 2 {
+			t.Errorf("text_version = %d, want 2", patched.TextVersion)
+		}
+
+		byID := map[string]models.Annotation{}
+		for _, annotation := range annotations(t, s, list) {
+			byID[annotation.ID.Hex()] = annotation
+		}
+		moved := byID[highlight.ID.Hex()].Target
+		if moved.Anchor != "sec-1-b" || moved.TextVersion != 2 || !strings.HasSuffix(moved.Quote, "replace its pipes.") {
+			t.Errorf("highlight = %+v, want it to follow subsection (b) to sec-1-b", moved)
+		}
+		amendedNote := byID[note.ID.Hex()].Target
+		if amendedNote.Status != models.AnnotationAnchored || amendedNote.Quote != "each spring" || amendedNote.Anchor != "sec-1-a-1" {
+			t.Errorf("note = %+v, want it on the amended words", amendedNote)
+		}
+
+		// Text that is struck orphans its annotations
+		resp = s.mergePatch(t, "/api/policies/"+policyID, map[string]interface{}{"original_text": "SEC. 1. TESTING.\nEach school shall test its water."})
+		expectStatus(t, resp, http.StatusOK)
+		resp = s.do(t, "GET", "/api/annotations/"+highlight.ID.Hex(), nil)
+		var orphaned models.Annotation
+		resp.decode(t, &orphaned)
+		if orphaned.Target.Status != models.AnnotationOrphaned || orphaned.Target.TextVersion != 2 {
+			t.Errorf("target = %+v, want it orphaned on version 2", orphaned.Target)
+		}
+	})
+
+	t.Run("deleting an annotation deletes its replies", func(t *testing.T) {
+		resp := s.do(t, "DELETE", "/api/annotations/"+highlight.ID.Hex(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := annotations(t, s, list); len(got) != 1 || got[0].ID != note.ID {
+			t.Errorf("annotations = %+v, want only the note", got)
+		}
+		decodeError(t, s.do(t, "DELETE", "/api/annotations/"+highlight.ID.Hex(), nil), http.StatusNotFound, "not_found")
+
+		// Editors can delete others' annotations
+		stored, _ := s.store.Users.FindByEmail(context.Background(), "grace@example.com")
+		stored.Role = models.RoleEditor
+		if err := s.store.Users.Update(context.Background(), stored); err != nil {
+			t.Fatal(err)
+		}
+		expectStatus(t, s.doWithToken(t, "DELETE", "/api/annotations/"+note.ID.Hex(), grace, nil), http.StatusOK)
+	})
+}
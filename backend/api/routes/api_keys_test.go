@@ -0,0 +1,256 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+// apiKey is an API key as returned by the key management routes
+type apiKey struct {
+	ID         string   `json:"id"`
+	Key        string   `json:"key"`
+	Prefix     string   `json:"prefix"`
+	Hash       string   `json:"hash"`
+	Scopes     []string `json:"scopes"`
+	DailyQuota int64    `json:"daily_quota"`
+	UsageCount int64    `json:"usage_count"`
+	UsageToday int64    `json:"usage_today"`
+	LastUsedAt string   `json:"last_used_at"`
+	RevokedAt  string   `json:"revoked_at"`
+}
+
+// createKey creates an API key for the logged-in user
+func createKey(t *testing.T, s *testServer, body map[string]interface{}) apiKey {
+	t.Helper()
+	resp := s.do(t, "POST", "/api/keys", body)
+	expectStatus(t, resp, http.StatusCreated)
+
+	var key apiKey
+	resp.decode(t, &key)
+	return key
+}
+
+// doWithKey sends a request authenticated only with an API key
+func (s *testServer) doWithKey(t *testing.T, method, path, key string, body interface{}) *response {
+	t.Helper()
+	return s.doWithHeader(t, method, path, http.Header{"X-Api-Key": {key}}, body)
+}
+
+// listKeys returns the logged-in user's keys
+func listKeys(t *testing.T, s *testServer) []apiKey {
+	t.Helper()
+	resp := s.do(t, "GET", "/api/keys", nil)
+	expectStatus(t, resp, http.StatusOK)
+
+	var keys []apiKey
+	resp.decode(t, &keys)
+	return keys
+}
+
+// promote gives the user with email the role in the store
+func promote(t *testing.T, s *testServer, email, role string) {
+	t.Helper()
+	user, err := s.store.Users.FindByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	user.Role = role
+	if err := s.store.Users.Update(context.Background(), user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	rep := createResource(t, s, "/api/representatives", sampleRepresentative())
+	repID := rep["id"].(string)
+
+	key := createKey(t, s, map[string]interface{}{"name": "Civic app", "scopes": []string{"public:read"}})
+	if !strings.HasPrefix(key.Key, "gt_") || !strings.HasPrefix(key.Key, key.Prefix) {
+		t.Fatalf("key = %q with prefix %q, want a gt_ key starting with its prefix", key.Key, key.Prefix)
+	}
+	if key.Hash != "" {
+		t.Error("the key hash must not be returned")
+	}
+	if key.DailyQuota != 1000 {
+		t.Errorf("daily_quota = %d, want the default of 1000", key.DailyQuota)
+	}
+
+	t.Run("keys authenticate reads in scope", func(t *testing.T) {
+		resp := s.doWithKey(t, "GET", "/api/policies", key.Key, nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := resp.Header.Get("X-API-Quota-Limit"); got != "1000" {
+			t.Errorf("X-API-Quota-Limit = %q, want 1000", got)
+		}
+		if got := resp.Header.Get("X-API-Quota-Remaining"); got != "999" {
+			t.Errorf("X-API-Quota-Remaining = %q, want 999", got)
+		}
+
+		// Public routes count the request against the key too
+		resp = s.doWithKey(t, "GET", "/api/public/representatives/"+repID, key.Key, nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := resp.Header.Get("X-API-Quota-Remaining"); got != "998" {
+			t.Errorf("X-API-Quota-Remaining = %q, want 998", got)
+		}
+	})
+
+	t.Run("keys are refused outside their scopes", func(t *testing.T) {
+		resp := s.doWithKey(t, "GET", "/api/public/representatives/"+repID+"/votes", key.Key, nil)
+		decodeError(t, resp, http.StatusForbidden, "insufficient_scope")
+		resp = s.doWithKey(t, "POST", "/api/policies", key.Key, samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+		decodeError(t, resp, http.StatusForbidden, "insufficient_scope")
+	})
+
+	t.Run("keys cannot be used on account routes", func(t *testing.T) {
+		for _, path := range []string{"/api/users", "/api/keys"} {
+			decodeError(t, s.doWithKey(t, "GET", path, key.Key, nil), http.StatusForbidden, "forbidden")
+		}
+	})
+
+	t.Run("unknown keys are rejected", func(t *testing.T) {
+		decodeError(t, s.doWithKey(t, "GET", "/api/policies", "gt_not-a-real-key", nil), http.StatusUnauthorized, "invalid_api_key")
+		decodeError(t, s.doWithKey(t, "GET", "/api/public/policies", key.Key+"x", nil), http.StatusUnauthorized, "invalid_api_key")
+	})
+
+	t.Run("usage is listed without the key", func(t *testing.T) {
+		keys := listKeys(t, s)
+		if len(keys) != 1 {
+			t.Fatalf("got %d keys, want 1", len(keys))
+		}
+		if keys[0].Key != "" || keys[0].Hash != "" {
+			t.Error("listed keys must not include the key or its hash")
+		}
+		// Refused requests are not counted
+		if keys[0].UsageCount != 2 || keys[0].UsageToday != 2 || keys[0].LastUsedAt == "" {
+			t.Errorf("usage = %d total, %d today, last used %q; want 2, 2 and a time", keys[0].UsageCount, keys[0].UsageToday, keys[0].LastUsedAt)
+		}
+	})
+
+	t.Run("only the owner can revoke a key", func(t *testing.T) {
+		ada := s.token
+		s.login(t, "Grace Hopper", "grace@example.com")
+		decodeError(t, s.do(t, "DELETE", "/api/keys/"+key.ID, nil), http.StatusNotFound, "not_found")
+		if keys := listKeys(t, s); len(keys) != 0 {
+			t.Errorf("Grace sees %d keys, want 0", len(keys))
+		}
+		s.token = ada
+	})
+
+	t.Run("revoked keys are rejected", func(t *testing.T) {
+		expectStatus(t, s.do(t, "DELETE", "/api/keys/"+key.ID, nil), http.StatusOK)
+		body := decodeError(t, s.doWithKey(t, "GET", "/api/policies", key.Key, nil), http.StatusUnauthorized, "invalid_api_key")
+		if !strings.Contains(body.Detail, "revoked") {
+			t.Errorf("detail = %q, want it to mention revocation", body.Detail)
+		}
+		if keys := listKeys(t, s); keys[0].RevokedAt == "" {
+			t.Error("revoked_at is not set")
+		}
+	})
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	t.Run("scopes and quotas are validated", func(t *testing.T) {
+		expectFieldErrors(t, s.do(t, "POST", "/api/keys", map[string]interface{}{}), "name", "scopes")
+		resp := s.do(t, "POST", "/api/keys", map[string]interface{}{"name": "App", "scopes": []string{"public:read", "admin", "public:read"}})
+		expectFieldErrors(t, resp, "scopes[1]", "scopes[2]")
+		resp = s.do(t, "POST", "/api/keys", map[string]interface{}{"name": "App", "scopes": []string{"public:read"}, "daily_quota": 1000000})
+		expectFieldErrors(t, resp, "daily_quota")
+	})
+
+	t.Run("only editors can create write keys", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/keys", map[string]interface{}{"name": "Importer", "scopes": []string{"write"}})
+		decodeError(t, resp, http.StatusForbidden, "forbidden")
+
+		promote(t, s, "ada@example.com", models.RoleEditor)
+		key := createKey(t, s, map[string]interface{}{"name": "Importer", "scopes": []string{"public:read", "write"}})
+		resp = s.doWithKey(t, "POST", "/api/policies", key.Key, samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+		expectStatus(t, resp, http.StatusCreated)
+	})
+
+	t.Run("write keys stop working when their owner is demoted", func(t *testing.T) {
+		promote(t, s, "ada@example.com", models.RoleEditor)
+		key := createKey(t, s, map[string]interface{}{"name": "Importer", "scopes": []string{"public:read", "write"}})
+
+		promote(t, s, "ada@example.com", models.RoleUser)
+		resp := s.doWithKey(t, "POST", "/api/policies", key.Key, samplePolicy("Clean Water Act", "2024-01-01T00:00:00Z"))
+		decodeError(t, resp, http.StatusForbidden, "forbidden")
+		expectStatus(t, s.doWithKey(t, "GET", "/api/public/policies", key.Key, nil), http.StatusOK)
+
+		promote(t, s, "ada@example.com", models.RoleEditor)
+		resp = s.doWithKey(t, "POST", "/api/policies", key.Key, samplePolicy("Clean Water Act", "2024-01-01T00:00:00Z"))
+		expectStatus(t, resp, http.StatusCreated)
+	})
+
+	t.Run("votes need the votes scope", func(t *testing.T) {
+		rep := createResource(t, s, "/api/representatives", sampleRepresentative())
+		key := createKey(t, s, map[string]interface{}{"name": "Scorecard", "scopes": []string{"votes:read"}})
+		expectStatus(t, s.doWithKey(t, "GET", "/api/representatives/"+rep["id"].(string)+"/votes", key.Key, nil), http.StatusOK)
+		decodeError(t, s.doWithKey(t, "GET", "/api/representatives", key.Key, nil), http.StatusForbidden, "insufficient_scope")
+	})
+
+	t.Run("roles cannot be set through the API", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/users", map[string]interface{}{
+			"name": "Mallory", "email": "mallory@example.com", "password": "correct horse battery staple", "role": "admin",
+		})
+		expectFieldErrors(t, resp, "role")
+
+		user := createResource(t, s, "/api/users", map[string]interface{}{
+			"name": "Mallory", "email": "mallory@example.com", "password": "correct horse battery staple",
+		})
+		if user["role"] != models.RoleUser {
+			t.Errorf("role = %v, want %s", user["role"], models.RoleUser)
+		}
+
+		// Not even administrators can change roles
+		promote(t, s, "ada@example.com", models.RoleAdmin)
+		resp = s.do(t, "PUT", "/api/users/"+user["id"].(string), map[string]interface{}{
+			"name": "Mallory", "email": "mallory@example.com", "role": "editor",
+		})
+		expectFieldErrors(t, resp, "role")
+	})
+}
+
+func TestAPIKeyQuotas(t *testing.T) {
+	cfg := testConfig()
+	cfg.APIKeys.MaxPerUser = 2
+	s := newTestServerWithConfig(t, cfg, repository.NewMemoryStore())
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	t.Run("requests over the daily quota are refused", func(t *testing.T) {
+		key := createKey(t, s, map[string]interface{}{"name": "Small", "scopes": []string{"public:read"}, "daily_quota": 2})
+		expectStatus(t, s.doWithKey(t, "GET", "/api/public/policies", key.Key, nil), http.StatusOK)
+		expectStatus(t, s.doWithKey(t, "GET", "/api/policies", key.Key, nil), http.StatusOK)
+
+		resp := s.doWithKey(t, "GET", "/api/policies", key.Key, nil)
+		decodeError(t, resp, http.StatusTooManyRequests, "quota_exceeded")
+		if retry, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retry < 1 || retry > 86400 {
+			t.Errorf("Retry-After = %q, want the seconds until midnight UTC", resp.Header.Get("Retry-After"))
+		}
+		if got := resp.Header.Get("X-API-Quota-Remaining"); got != "0" {
+			t.Errorf("X-API-Quota-Remaining = %q, want 0", got)
+		}
+
+		// The user's own token is not affected
+		expectStatus(t, s.do(t, "GET", "/api/policies", nil), http.StatusOK)
+	})
+
+	t.Run("active keys per user are limited", func(t *testing.T) {
+		second := createKey(t, s, map[string]interface{}{"name": "Second", "scopes": []string{"public:read"}})
+		resp := s.do(t, "POST", "/api/keys", map[string]interface{}{"name": "Third", "scopes": []string{"public:read"}})
+		decodeError(t, resp, http.StatusConflict, "conflict")
+
+		// Revoked keys do not count
+		expectStatus(t, s.do(t, "DELETE", "/api/keys/"+second.ID, nil), http.StatusOK)
+		createKey(t, s, map[string]interface{}{"name": "Third", "scopes": []string{"public:read"}})
+	})
+}
@@ -0,0 +1,203 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/trash"
+)
+
+// auditEntries lists audit log entries as an administrator
+func auditEntries(t *testing.T, s *testServer, query string) []models.AuditEntry {
+	t.Helper()
+	resp := s.do(t, "GET", "/api/admin/audit"+query, nil)
+	expectStatus(t, resp, http.StatusOK)
+
+	var entries []models.AuditEntry
+	resp.decode(t, &entries)
+	return entries
+}
+
+// change returns the change of field in entry, failing the test if there is none
+func change(t *testing.T, entry models.AuditEntry, field string) models.FieldChange {
+	t.Helper()
+	for _, c := range entry.Changes {
+		if c.Field == field {
+			return c
+		}
+	}
+	t.Fatalf("%s entry has no change of %s: %+v", entry.Action, field, entry.Changes)
+	return models.FieldChange{}
+}
+
+func TestAuditLog(t *testing.T) {
+	s := newTestServer(t)
+	user := s.login(t, "Ada Lovelace", "ada@example.com")
+
+	resp := s.do(t, "POST", "/api/policies", samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+	expectStatus(t, resp, http.StatusCreated)
+	var policy map[string]interface{}
+	resp.decode(t, &policy)
+	createRequestID := resp.Header.Get("X-Request-ID")
+	path := "/api/policies/" + policy["id"].(string)
+
+	expectStatus(t, s.do(t, "PUT", path, samplePolicy("Clean Air Act of 2024", "2024-01-01T00:00:00Z")), http.StatusOK)
+	expectStatus(t, s.mergePatch(t, path, map[string]interface{}{"status": "passed"}), http.StatusOK)
+	expectStatus(t, s.do(t, "DELETE", path, nil), http.StatusOK)
+	expectStatus(t, s.do(t, "POST", "/api/users", map[string]interface{}{"name": "Grace Hopper", "email": "grace@example.com", "password": "password123"}), http.StatusCreated)
+
+	t.Run("only administrators read the audit log", func(t *testing.T) {
+		decodeError(t, s.do(t, "GET", "/api/admin/audit", nil), http.StatusForbidden, "forbidden")
+		decodeError(t, s.do(t, "GET", "/api/admin/audit/verify", nil), http.StatusForbidden, "forbidden")
+		promote(t, s, "ada@example.com", "admin")
+	})
+
+	t.Run("every change is recorded newest first", func(t *testing.T) {
+		entries := auditEntries(t, s, "?resource_id="+policy["id"].(string))
+		var actions []string
+		for _, entry := range entries {
+			actions = append(actions, entry.Action)
+		}
+		if len(actions) != 4 || actions[0] != "delete" || actions[1] != "update" || actions[2] != "update" || actions[3] != "create" {
+			t.Fatalf("actions = %v, want delete, update, update, create", actions)
+		}
+
+		created := entries[3]
+		if created.ActorID == nil || created.ActorID.Hex() != user["id"] || created.ResourceType != "policy" {
+			t.Errorf("create entry = %+v, want the policy created by %v", created, user["id"])
+		}
+		if created.RequestID != createRequestID || created.IP != "127.0.0.1" {
+			t.Errorf("request ID and IP = %q, %q; want %q from 127.0.0.1", created.RequestID, created.IP, createRequestID)
+		}
+		if title := change(t, created, "title"); title.Before != nil || string(title.After) != `"Clean Air Act"` {
+			t.Errorf("created title = %s -> %s, want it set", title.Before, title.After)
+		}
+
+		updated := entries[2]
+		title := change(t, updated, "title")
+		if string(title.Before) != `"Clean Air Act"` || string(title.After) != `"Clean Air Act of 2024"` {
+			t.Errorf("updated title = %s -> %s, want the old and new titles", title.Before, title.After)
+		}
+		if revision := change(t, updated, "revision"); string(revision.Before) != "1" || string(revision.After) != "2" {
+			t.Errorf("updated revision = %s -> %s, want 1 -> 2", revision.Before, revision.After)
+		}
+
+		// Stored times keep milliseconds, so the update time may not change
+		patched := entries[1]
+		for _, c := range patched.Changes {
+			if c.Field != "status" && c.Field != "revision" && c.Field != "last_updated" {
+				t.Errorf("patch changed %s, want only status, revision and last update time", c.Field)
+			}
+		}
+		if status := change(t, patched, "status"); string(status.After) != `"passed"` {
+			t.Errorf("patched status = %s, want passed", status.After)
+		}
+
+		deleted := entries[0]
+		if title := change(t, deleted, "title"); string(title.Before) != `"Clean Air Act of 2024"` || title.After != nil {
+			t.Errorf("deleted title = %s -> %s, want the deleted policy's title", title.Before, title.After)
+		}
+	})
+
+	t.Run("secrets are redacted", func(t *testing.T) {
+		entries := auditEntries(t, s, "?resource_type=user")
+		if len(entries) != 1 {
+			t.Fatalf("user entries = %d, want 1", len(entries))
+		}
+		if password := change(t, entries[0], "password"); string(password.After) != `"[redacted]"` {
+			t.Errorf("password = %s, want it redacted", password.After)
+		}
+	})
+
+	t.Run("changes made with API keys name the key", func(t *testing.T) {
+		key := createKey(t, s, map[string]interface{}{"name": "Importer", "scopes": []string{"write"}})
+		expectStatus(t, s.doWithKey(t, "POST", "/api/representatives", key.Key, sampleRepresentative()), http.StatusCreated)
+
+		entries := auditEntries(t, s, "?resource_type=representative")
+		if len(entries) != 1 || entries[0].APIKeyID == nil || entries[0].APIKeyID.Hex() != key.ID {
+			t.Fatalf("representative entries = %+v, want one made with key %s", entries, key.ID)
+		}
+		if entries[0].ActorID == nil || entries[0].ActorID.Hex() != user["id"] {
+			t.Errorf("actor = %v, want the key's owner", entries[0].ActorID)
+		}
+
+		keys := auditEntries(t, s, "?resource_type=api_key")
+		if len(keys) != 1 || keys[0].Action != "create" {
+			t.Errorf("API key entries = %+v, want its creation", keys)
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		if entries := auditEntries(t, s, "?action=delete"); len(entries) != 1 {
+			t.Errorf("delete entries = %d, want 1", len(entries))
+		}
+		if entries := auditEntries(t, s, "?actor="+user["id"].(string)+"&limit=2"); len(entries) != 2 || entries[0].Sequence <= entries[1].Sequence {
+			t.Errorf("entries = %+v, want the two newest", entries)
+		}
+		future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		if entries := auditEntries(t, s, "?from="+future); len(entries) != 0 {
+			t.Errorf("future entries = %d, want none", len(entries))
+		}
+		if entries := auditEntries(t, s, "?to="+future); len(entries) != 7 {
+			t.Errorf("past entries = %d, want 7", len(entries))
+		}
+
+		for _, query := range []string{"?action=rename", "?resource_type=vote", "?actor=ada", "?from=yesterday", "?limit=0", "?limit=501", "?skip=-1"} {
+			decodeError(t, s.do(t, "GET", "/api/admin/audit"+query, nil), http.StatusBadRequest, "invalid_parameter")
+		}
+	})
+}
+
+func TestAuditChain(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	promote(t, s, "ada@example.com", "admin")
+
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+	expectStatus(t, s.do(t, "DELETE", "/api/policies/"+policy["id"].(string), nil), http.StatusOK)
+	expectStatus(t, s.do(t, "POST", "/api/admin/trash/policies/"+policy["id"].(string)+"/restore", nil), http.StatusOK)
+	expectStatus(t, s.do(t, "DELETE", "/api/policies/"+policy["id"].(string), nil), http.StatusOK)
+	if _, err := trash.NewPurger(s.store, 0, s.cache).Purge(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := auditEntries(t, s, "")
+	if len(entries) != 5 || entries[0].Action != "purge" || entries[2].Action != "restore" {
+		t.Fatalf("entries = %+v, want create, delete, restore, delete and purge", entries)
+	}
+	if entries[0].ActorID != nil {
+		t.Errorf("purge actor = %v, want none", entries[0].ActorID)
+	}
+	for i, entry := range entries[:len(entries)-1] {
+		if entry.PrevHash != entries[i+1].Hash || entry.Sequence != entries[i+1].Sequence+1 {
+			t.Errorf("entry %d does not follow entry %d", entry.Sequence, entries[i+1].Sequence)
+		}
+	}
+
+	verify := func() models.AuditVerification {
+		t.Helper()
+		resp := s.do(t, "GET", "/api/admin/audit/verify", nil)
+		expectStatus(t, resp, http.StatusOK)
+		var result models.AuditVerification
+		resp.decode(t, &result)
+		return result
+	}
+	if result := verify(); !result.Valid || result.Entries != 5 || result.LastHash != entries[0].Hash {
+		t.Errorf("verification = %+v, want 5 valid entries ending with %s", result, entries[0].Hash)
+	}
+
+	// An entry appended without following the chain breaks it
+	forged := entries[0]
+	forged.ID, forged.Sequence = [12]byte{}, 6
+	forged.Changes = []models.FieldChange{{Field: "title", Before: json.RawMessage(`"Forged"`)}}
+	if err := s.store.Audit.Append(context.Background(), &forged); err != nil {
+		t.Fatal(err)
+	}
+	if result := verify(); result.Valid || result.BrokenAt != 6 || result.Entries != 5 {
+		t.Errorf("verification = %+v, want entry 6 broken", result)
+	}
+}
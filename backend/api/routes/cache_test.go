@@ -0,0 +1,147 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+// ifNoneMatch returns headers for a conditional GET
+func ifNoneMatch(etag string) http.Header {
+	return http.Header{"If-None-Match": {etag}}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z"))
+	path := "/api/public/policies/" + policy["id"].(string)
+
+	first := s.doWithHeader(t, "GET", path, nil, nil)
+	expectStatus(t, first, http.StatusOK)
+	etag := first.Header.Get("ETag")
+	if etag == "" || etag[0] != '"' {
+		t.Fatalf("ETag = %q, want a strong entity tag", etag)
+	}
+	if got := first.Header.Get("Cache-Control"); got != "public, max-age=60" {
+		t.Errorf("Cache-Control = %q, want public, max-age=60", got)
+	}
+
+	t.Run("public responses are served from the cache", func(t *testing.T) {
+		if got := first.Header.Get("X-Cache"); got != "miss" {
+			t.Errorf("first X-Cache = %q, want miss", got)
+		}
+		resp := s.doWithHeader(t, "GET", path, nil, nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := resp.Header.Get("X-Cache"); got != "hit" {
+			t.Errorf("second X-Cache = %q, want hit", got)
+		}
+		if resp.Header.Get("ETag") != etag || string(resp.Body) != string(first.Body) {
+			t.Error("the cached response differs from the original")
+		}
+	})
+
+	t.Run("matching If-None-Match gets 304", func(t *testing.T) {
+		for _, header := range []string{etag, "W/" + etag, `"stale", ` + etag} {
+			resp := s.doWithHeader(t, "GET", path, ifNoneMatch(header), nil)
+			expectStatus(t, resp, http.StatusNotModified)
+			if len(resp.Body) != 0 {
+				t.Errorf("304 response has a body: %s", resp.Body)
+			}
+			if resp.Header.Get("ETag") != etag || resp.Header.Get("Cache-Control") != "public, max-age=60" {
+				t.Errorf("304 headers = %v, want the ETag and Cache-Control", resp.Header)
+			}
+		}
+	})
+
+	t.Run("protected routes share ETags and must revalidate", func(t *testing.T) {
+		resp := s.doWithToken(t, "GET", "/api/policies/"+policy["id"].(string), s.token, nil)
+		expectStatus(t, resp, http.StatusOK)
+		if resp.Header.Get("ETag") != etag {
+			t.Errorf("ETag = %q, want %q", resp.Header.Get("ETag"), etag)
+		}
+		if got := resp.Header.Get("Cache-Control"); got != "private, no-cache" {
+			t.Errorf("Cache-Control = %q, want private, no-cache", got)
+		}
+		if resp.Header.Get("X-Cache") != "" {
+			t.Error("protected responses should not use the response cache")
+		}
+
+		header := ifNoneMatch(etag)
+		header.Set("Authorization", "Bearer "+s.token)
+		expectStatus(t, s.doWithHeader(t, "GET", "/api/policies/"+policy["id"].(string), header, nil), http.StatusNotModified)
+	})
+
+	t.Run("updates invalidate cached responses", func(t *testing.T) {
+		list := s.doWithHeader(t, "GET", "/api/public/policies", nil, nil)
+		expectStatus(t, list, http.StatusOK)
+		listTag := list.Header.Get("ETag")
+
+		update := samplePolicy("Clean Water Act of 2024", "2024-03-01T00:00:00Z")
+		expectStatus(t, s.do(t, "PUT", "/api/policies/"+policy["id"].(string), update), http.StatusOK)
+
+		resp := s.doWithHeader(t, "GET", path, ifNoneMatch(etag), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := resp.Header.Get("X-Cache"); got != "miss" {
+			t.Errorf("X-Cache after update = %q, want miss", got)
+		}
+		if resp.Header.Get("ETag") == etag {
+			t.Error("the ETag should change when the policy is updated")
+		}
+		var updated map[string]interface{}
+		resp.decode(t, &updated)
+		if updated["title"] != "Clean Water Act of 2024" {
+			t.Errorf("title = %v, want the updated title", updated["title"])
+		}
+
+		list = s.doWithHeader(t, "GET", "/api/public/policies", ifNoneMatch(listTag), nil)
+		expectStatus(t, list, http.StatusOK)
+		if list.Header.Get("ETag") == listTag {
+			t.Error("the list ETag should change when a listed policy is updated")
+		}
+	})
+
+	t.Run("policy writes invalidate voting records", func(t *testing.T) {
+		rep := createResource(t, s, "/api/representatives", sampleRepresentative())
+		votesPath := "/api/public/representatives/" + rep["id"].(string) + "/votes"
+		expectStatus(t, s.doWithHeader(t, "GET", votesPath, nil, nil), http.StatusOK)
+		if got := s.doWithHeader(t, "GET", votesPath, nil, nil).Header.Get("X-Cache"); got != "hit" {
+			t.Fatalf("X-Cache = %q, want hit", got)
+		}
+
+		createResource(t, s, "/api/policies", samplePolicy("Clean Air Act", "2024-04-01T00:00:00Z"))
+		if got := s.doWithHeader(t, "GET", votesPath, nil, nil).Header.Get("X-Cache"); got != "miss" {
+			t.Errorf("X-Cache after a policy write = %q, want miss", got)
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		resp := s.doWithHeader(t, "GET", "/api/public/policies/000000000000000000000000", nil, nil)
+		expectStatus(t, resp, http.StatusNotFound)
+		if got := resp.Header.Get("Cache-Control"); got != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", got)
+		}
+		resp = s.doWithHeader(t, "GET", "/api/public/policies/000000000000000000000000", nil, nil)
+		if got := resp.Header.Get("X-Cache"); got != "miss" {
+			t.Errorf("X-Cache = %q, want miss", got)
+		}
+	})
+}
+
+func TestResponseCacheDisabled(t *testing.T) {
+	cfg := testConfig()
+	cfg.HTTPCache.Entries = 0
+	s := newTestServerWithConfig(t, cfg, repository.NewMemoryStore())
+
+	resp := s.doWithHeader(t, "GET", "/api/public/representatives", nil, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("X-Cache") != "" {
+		t.Error("the response cache should be disabled")
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("ETags should be sent without the response cache")
+	}
+	expectStatus(t, s.doWithHeader(t, "GET", "/api/public/representatives", ifNoneMatch(etag), nil), http.StatusNotModified)
+}
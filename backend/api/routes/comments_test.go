@@ -0,0 +1,277 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/ratelimit"
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+// comment posts a comment with the server's token, expecting 201
+func comment(t *testing.T, s *testServer, body map[string]interface{}) models.Comment {
+	t.Helper()
+	resp := s.do(t, "POST", "/api/comments", body)
+	expectStatus(t, resp, http.StatusCreated)
+	var created models.Comment
+	resp.decode(t, &created)
+	return created
+}
+
+// comments lists the comments at path, expecting 200
+func comments(t *testing.T, s *testServer, path string) []models.Comment {
+	t.Helper()
+	resp := s.do(t, "GET", path, nil)
+	expectStatus(t, resp, http.StatusOK)
+	var list []models.Comment
+	resp.decode(t, &list)
+	return list
+}
+
+// commentIDs returns the IDs of comments in order
+func commentIDs(comments []models.Comment) []string {
+	ids := make([]string, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID.Hex()
+	}
+	return ids
+}
+
+func TestComments(t *testing.T) {
+	cfg := testConfig()
+	cfg.Comments.Limit = ratelimit.Limit{Requests: 100, Per: time.Minute}
+	cfg.Comments.ReportThreshold = 2
+	s := newTestServerWithConfig(t, cfg, repository.NewMemoryStore())
+	s.login(t, "Grace Hopper", "grace@example.com")
+	grace := s.token
+	s.login(t, "Alan Turing", "alan@example.com")
+	alan := s.token
+	s.login(t, "Margaret Hamilton", "margaret@example.com")
+	margaret := s.token
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	ada := s.token
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z"))
+	policyID := policy["id"].(string)
+	list := "/api/comments?policy_id=" + policyID
+	public := "/api/public/policies/" + policyID + "/comments"
+
+	resp := s.do(t, "POST", "/api/comments", map[string]interface{}{"policy_id": policyID, "body": "Testing every year seems too rare."})
+	golden(t, "comments/create", resp)
+	expectStatus(t, resp, http.StatusCreated)
+	var first models.Comment
+	resp.decode(t, &first)
+	if first.Status != models.CommentPublished || first.AuthorID.IsZero() {
+		t.Fatalf("comment = %+v, want it published", first)
+	}
+
+	t.Run("comments are validated", func(t *testing.T) {
+		expectFieldErrors(t, s.do(t, "POST", "/api/comments", map[string]interface{}{"policy_id": policyID}), "body")
+		expectFieldErrors(t, s.do(t, "POST", "/api/comments", map[string]interface{}{"body": "Hi"}), "policy_id")
+		decodeError(t, s.do(t, "POST", "/api/comments", map[string]interface{}{"policy_id": "64b7f0f0f0f0f0f0f0f0f0f0", "body": "Hi"}), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "POST", "/api/comments", map[string]interface{}{"policy_id": policyID, "parent_id": "64b7f0f0f0f0f0f0f0f0f0f0", "body": "Hi"}), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "GET", "/api/comments", nil), http.StatusBadRequest, "invalid_parameter")
+		decodeError(t, s.do(t, "GET", list+"&sort=loudest", nil), http.StatusBadRequest, "invalid_parameter")
+	})
+
+	var reply models.Comment
+	t.Run("replies and votes", func(t *testing.T) {
+		s.token = grace
+		defer func() { s.token = ada }()
+		reply = comment(t, s, map[string]interface{}{"policy_id": policyID, "parent_id": first.ID.Hex(), "body": "Twice a year would be better."})
+		if reply.ParentID == nil || *reply.ParentID != first.ID {
+			t.Errorf("reply = %+v, want a reply to the first comment", reply)
+		}
+
+		resp := s.do(t, "POST", "/api/comments/"+first.ID.Hex()+"/vote", map[string]interface{}{"value": 1})
+		expectStatus(t, resp, http.StatusOK)
+		var voted models.Comment
+		resp.decode(t, &voted)
+		if voted.Upvotes != 1 || voted.MyVote != 1 {
+			t.Errorf("voted = %+v, want one up vote by the caller", voted)
+		}
+		expectStatus(t, s.doWithToken(t, "POST", "/api/comments/"+first.ID.Hex()+"/vote", alan, map[string]interface{}{"value": 1}), http.StatusOK)
+		resp = s.do(t, "POST", "/api/comments/"+first.ID.Hex()+"/vote", map[string]interface{}{"value": -1})
+		resp.decode(t, &voted)
+		if voted.Upvotes != 1 || voted.Downvotes != 1 || voted.MyVote != -1 {
+			t.Errorf("changed vote = %+v, want one vote each way", voted)
+		}
+		expectFieldErrors(t, s.do(t, "POST", "/api/comments/"+first.ID.Hex()+"/vote", map[string]interface{}{"value": 2}), "value")
+		decodeError(t, s.do(t, "POST", "/api/comments/"+reply.ID.Hex()+"/vote", map[string]interface{}{"value": 1}), http.StatusForbidden, "forbidden")
+
+		// Reset to a single up vote so that the first comment scores highest
+		expectStatus(t, s.do(t, "POST", "/api/comments/"+first.ID.Hex()+"/vote", map[string]interface{}{"value": 0}), http.StatusOK)
+		s.token = ""
+		got := comments(t, s, public+"?sort=top")
+		if len(got) != 2 || got[0].ID != first.ID || got[0].Upvotes != 1 || got[0].MyVote != 0 {
+			t.Errorf("top comments = %+v, want the voted comment first", got)
+		}
+		if ids := commentIDs(comments(t, s, public+"?sort=newest")); ids[0] != reply.ID.Hex() {
+			t.Errorf("newest comments = %v, want the reply first", ids)
+		}
+	})
+
+	t.Run("authors edit their comments", func(t *testing.T) {
+		path := "/api/comments/" + first.ID.Hex()
+		resp := s.do(t, "PUT", path, map[string]interface{}{"body": "Testing every year is too rare."})
+		expectStatus(t, resp, http.StatusOK)
+		var edited models.Comment
+		resp.decode(t, &edited)
+		if edited.Body != "Testing every year is too rare." || len(edited.Edits) != 1 || edited.Edits[0].Body != first.Body {
+			t.Errorf("edited = %+v, want the earlier body in edits", edited)
+		}
+		decodeError(t, s.doWithToken(t, "PUT", path, grace, map[string]interface{}{"body": "Mine now"}), http.StatusForbidden, "forbidden")
+		decodeError(t, s.doWithToken(t, "DELETE", path, grace, nil), http.StatusForbidden, "forbidden")
+
+		header := http.Header{"Authorization": {"Bearer " + ada}, "If-Match": {`"` + first.ID.Hex() + `-1"`}}
+		expectStatus(t, s.doWithHeader(t, "PUT", path, header, map[string]interface{}{"body": "Stale"}), http.StatusPreconditionFailed)
+	})
+
+	var held models.Comment
+	t.Run("the content filter holds suspicious comments", func(t *testing.T) {
+		s.token = alan
+		defer func() { s.token = ada }()
+		held = comment(t, s, map[string]interface{}{"policy_id": policyID, "body": "Click here for free money!"})
+		if held.Status != models.CommentHeld || held.Moderation == nil || len(held.Moderation.Reasons) != 2 {
+			t.Fatalf("comment = %+v, want it held for two phrases", held)
+		}
+		if got := comments(t, s, list); len(got) != 3 {
+			t.Errorf("author sees %d comments, want 3", len(got))
+		}
+
+		s.token = grace
+		if got := comments(t, s, list); len(got) != 2 {
+			t.Errorf("others see %d comments, want 2", len(got))
+		}
+		decodeError(t, s.do(t, "GET", "/api/comments/"+held.ID.Hex(), nil), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "POST", "/api/comments", map[string]interface{}{"policy_id": policyID, "parent_id": held.ID.Hex(), "body": "Spam"}), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "POST", "/api/comments/"+held.ID.Hex()+"/vote", map[string]interface{}{"value": -1}), http.StatusNotFound, "not_found")
+	})
+
+	t.Run("enough reports hold a comment", func(t *testing.T) {
+		path := "/api/comments/" + reply.ID.Hex() + "/report"
+		expectFieldErrors(t, s.do(t, "POST", path, map[string]interface{}{"reason": "boring"}), "reason")
+		decodeError(t, s.doWithToken(t, "POST", path, grace, map[string]interface{}{"reason": "spam"}), http.StatusForbidden, "forbidden")
+
+		// Reporting twice counts once
+		expectStatus(t, s.do(t, "POST", path, map[string]interface{}{"reason": "spam"}), http.StatusOK)
+		expectStatus(t, s.do(t, "POST", path, map[string]interface{}{"reason": "off_topic", "details": "Not about the act"}), http.StatusOK)
+		got := comments(t, s, list)
+		if len(got) != 2 || got[1].Status != models.CommentPublished || got[1].Reports != nil {
+			t.Errorf("comments = %+v, want the reply published without its reports", got)
+		}
+
+		expectStatus(t, s.doWithToken(t, "POST", path, alan, map[string]interface{}{"reason": "off_topic"}), http.StatusOK)
+		if got := comments(t, s, list); len(got) != 1 {
+			t.Errorf("comments = %+v, want the reply held", got)
+		}
+		decodeError(t, s.do(t, "POST", path, map[string]interface{}{"reason": "spam"}), http.StatusNotFound, "not_found")
+	})
+
+	t.Run("moderators work through the queue", func(t *testing.T) {
+		decodeError(t, s.do(t, "GET", "/api/moderation/comments", nil), http.StatusForbidden, "forbidden")
+		decodeError(t, s.do(t, "POST", "/api/moderation/comments/"+held.ID.Hex(), map[string]interface{}{"action": "approve"}), http.StatusNotFound, "not_found")
+
+		promote(t, s, "margaret@example.com", models.RoleModerator)
+		s.token = margaret
+		defer func() { s.token = ada }()
+		queue := comments(t, s, "/api/moderation/comments")
+		if ids := commentIDs(queue); len(ids) != 2 || ids[0] != reply.ID.Hex() || ids[1] != held.ID.Hex() {
+			t.Fatalf("queue = %v, want the reply and the held comment", ids)
+		}
+		if len(queue[0].Reports) != 2 || queue[0].Moderation.Reasons[0] != "reported as off_topic" {
+			t.Errorf("reported = %+v, want its reports and reasons", queue[0])
+		}
+		decodeError(t, s.do(t, "GET", "/api/moderation/comments?status=lost", nil), http.StatusBadRequest, "invalid_parameter")
+		expectFieldErrors(t, s.do(t, "POST", "/api/moderation/comments/"+reply.ID.Hex(), map[string]interface{}{"action": "shrug"}), "action")
+
+		resp := s.do(t, "POST", "/api/moderation/comments/"+reply.ID.Hex(), map[string]interface{}{"action": "approve", "note": "Fair point"})
+		expectStatus(t, resp, http.StatusOK)
+		var approved models.Comment
+		resp.decode(t, &approved)
+		if approved.Status != models.CommentPublished || approved.Reports != nil || approved.Moderation.Action != models.ModerationApprove || approved.Moderation.ModeratorID == nil {
+			t.Errorf("approved = %+v", approved)
+		}
+
+		expectStatus(t, s.do(t, "POST", "/api/moderation/comments/"+first.ID.Hex(), map[string]interface{}{"action": "remove"}), http.StatusOK)
+		if got := comments(t, s, "/api/moderation/comments?status=removed"); len(got) != 1 || got[0].Body == "" {
+			t.Errorf("removed = %+v, want the removed comment with its body", got)
+		}
+		s.token = ""
+		got := comments(t, s, public)
+		if len(got) != 2 || got[0].Status != models.CommentRemoved || got[0].Body != "" || got[0].Moderation.ModeratorID != nil {
+			t.Errorf("public comments = %+v, want the removed comment without its body or moderator", got)
+		}
+	})
+
+	t.Run("banned users cannot comment", func(t *testing.T) {
+		s.token = margaret
+		defer func() { s.token = ada }()
+
+		resp := s.do(t, "POST", "/api/moderation/comments/"+held.ID.Hex(), map[string]interface{}{"action": "ban", "note": "Spam", "ban_days": 7})
+		expectStatus(t, resp, http.StatusOK)
+		var banned models.Comment
+		resp.decode(t, &banned)
+		if banned.Status != models.CommentRemoved || banned.Moderation.Action != models.ModerationBan || len(banned.Moderation.Reasons) != 2 {
+			t.Errorf("banned = %+v, want it removed with the filter's reasons", banned)
+		}
+
+		s.token = alan
+		decodeError(t, s.do(t, "POST", "/api/comments", map[string]interface{}{"policy_id": policyID, "body": "Sorry"}), http.StatusForbidden, "banned")
+		decodeError(t, s.do(t, "POST", "/api/comments/"+reply.ID.Hex()+"/vote", map[string]interface{}{"value": 1}), http.StatusForbidden, "banned")
+		resp = s.do(t, "GET", "/api/users/"+held.AuthorID.Hex(), nil)
+		var user map[string]interface{}
+		resp.decode(t, &user)
+		if ban, ok := user["comment_ban"].(map[string]interface{}); !ok || ban["until"] == nil {
+			t.Errorf("user = %v, want a ban until a week from now", user)
+		}
+
+		// Moderators cannot be banned
+		s.token = margaret
+		mine := comment(t, s, map[string]interface{}{"policy_id": policyID, "body": "Please stay on topic."})
+		decodeError(t, s.do(t, "POST", "/api/moderation/comments/"+mine.ID.Hex(), map[string]interface{}{"action": "ban"}), http.StatusForbidden, "forbidden")
+
+		decodeError(t, s.do(t, "DELETE", "/api/moderation/bans/"+first.AuthorID.Hex(), nil), http.StatusNotFound, "not_found")
+		resp = s.do(t, "DELETE", "/api/moderation/bans/"+held.AuthorID.Hex(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		s.token = alan
+		comment(t, s, map[string]interface{}{"policy_id": policyID, "body": "Thanks for having me back."})
+	})
+
+	t.Run("deleted comments keep their place", func(t *testing.T) {
+		s.token = grace
+		defer func() { s.token = ada }()
+		resp := s.do(t, "DELETE", "/api/comments/"+reply.ID.Hex(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		decodeError(t, s.do(t, "DELETE", "/api/comments/"+reply.ID.Hex(), nil), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "PUT", "/api/comments/"+reply.ID.Hex(), map[string]interface{}{"body": "Back"}), http.StatusForbidden, "forbidden")
+
+		got, _ := s.store.Comments.FindByID(context.Background(), reply.ID)
+		if got.Status != models.CommentDeleted || got.Body != "" {
+			t.Errorf("deleted = %+v, want it without a body", got)
+		}
+	})
+}
+
+func TestCommentRateLimit(t *testing.T) {
+	cfg := testConfig()
+	cfg.Comments.Limit = ratelimit.Limit{Requests: 2, Per: time.Hour}
+	s := newTestServerWithConfig(t, cfg, repository.NewMemoryStore())
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z"))
+	body := map[string]interface{}{"policy_id": policy["id"], "body": "First!"}
+
+	created := comment(t, s, body)
+	expectStatus(t, s.do(t, "PUT", "/api/comments/"+created.ID.Hex(), map[string]interface{}{"body": "Second!"}), http.StatusOK)
+	resp := s.do(t, "POST", "/api/comments", body)
+	decodeError(t, resp, http.StatusTooManyRequests, "rate_limited")
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("Retry-After is missing")
+	}
+
+	// Each user has their own limit
+	s.login(t, "Grace Hopper", "grace@example.com")
+	comment(t, s, body)
+}
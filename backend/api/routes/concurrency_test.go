@@ -0,0 +1,168 @@
+package routes_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// doIfMatch sends an authenticated request expecting the revision named by etag
+func (s *testServer) doIfMatch(t *testing.T, method, path, etag string, body interface{}) *response {
+	t.Helper()
+	header := http.Header{"If-Match": {etag}}
+	header.Set("Authorization", "Bearer "+s.token)
+	return s.doWithHeader(t, method, path, header, body)
+}
+
+// expectConflict checks for a 412 response carrying the stored document at
+// the given revision
+func expectConflict(t *testing.T, resp *response, revision float64) {
+	t.Helper()
+	decodeError(t, resp, http.StatusPreconditionFailed, "precondition_failed")
+
+	var body struct {
+		Current map[string]interface{} `json:"current"`
+	}
+	resp.decode(t, &body)
+	if body.Current["revision"] != revision {
+		t.Errorf("current revision = %v, want %v", body.Current["revision"], revision)
+	}
+	if resp.Header.Get("ETag") == "" {
+		t.Error("the current document's ETag is not set")
+	}
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+	path := "/api/policies/" + policy["id"].(string)
+	if policy["revision"] != float64(1) {
+		t.Fatalf("revision = %v, want 1 on create", policy["revision"])
+	}
+
+	resp := s.do(t, "GET", path, nil)
+	expectStatus(t, resp, http.StatusOK)
+	first := resp.Header.Get("ETag")
+
+	t.Run("writes at the expected revision succeed", func(t *testing.T) {
+		resp := s.doIfMatch(t, "PUT", path, first, samplePolicy("Clean Air Act of 2024", "2024-01-01T00:00:00Z"))
+		expectStatus(t, resp, http.StatusOK)
+		var updated map[string]interface{}
+		resp.decode(t, &updated)
+		if updated["revision"] != float64(2) {
+			t.Errorf("revision = %v, want 2", updated["revision"])
+		}
+		if etag := resp.Header.Get("ETag"); etag == "" || etag == first {
+			t.Errorf("ETag = %q, want the new revision's tag", etag)
+		}
+	})
+
+	t.Run("stale If-Match is refused with the current document", func(t *testing.T) {
+		resp := s.doIfMatch(t, "PUT", path, first, samplePolicy("Lost update", "2024-01-01T00:00:00Z"))
+		expectConflict(t, resp, 2)
+
+		resp = s.do(t, "GET", path, nil)
+		var stored map[string]interface{}
+		resp.decode(t, &stored)
+		if stored["title"] != "Clean Air Act of 2024" {
+			t.Errorf("title = %v, the refused write must not be stored", stored["title"])
+		}
+	})
+
+	t.Run("a stale revision in the body is refused", func(t *testing.T) {
+		body := samplePolicy("Lost update", "2024-01-01T00:00:00Z")
+		body["revision"] = 1
+		expectConflict(t, s.do(t, "PUT", path, body), 2)
+
+		body["revision"] = 2
+		expectStatus(t, s.do(t, "PUT", path, body), http.StatusOK)
+	})
+
+	t.Run("unusable preconditions are refused", func(t *testing.T) {
+		resp := s.do(t, "GET", path, nil)
+		current := resp.Header.Get("ETag")
+		body := samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z")
+
+		// Weak tags never match, nor do tags of other documents
+		expectConflict(t, s.doIfMatch(t, "PUT", path, "W/"+current, body), 3)
+		expectConflict(t, s.doIfMatch(t, "PUT", path, `"000000000000000000000000-3"`, body), 3)
+
+		// The header and the body must agree
+		body["revision"] = 2
+		expectConflict(t, s.doIfMatch(t, "PUT", path, current, body), 3)
+	})
+
+	t.Run("unconditional writes still increment the revision", func(t *testing.T) {
+		resp := s.do(t, "PUT", path, samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+		expectStatus(t, resp, http.StatusOK)
+		var updated map[string]interface{}
+		resp.decode(t, &updated)
+		if updated["revision"] != float64(4) {
+			t.Errorf("revision = %v, want 4", updated["revision"])
+		}
+	})
+
+	t.Run("preconditions on missing documents are not found", func(t *testing.T) {
+		missing := "/api/policies/000000000000000000000000"
+		resp := s.doIfMatch(t, "PUT", missing, `"000000000000000000000000-1"`, samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+		decodeError(t, resp, http.StatusNotFound, "not_found")
+	})
+
+	t.Run("only one of concurrent writes at a revision succeeds", func(t *testing.T) {
+		etag := s.do(t, "GET", path, nil).Header.Get("ETag")
+
+		var wg sync.WaitGroup
+		statuses := make(chan int, 10)
+		for i := 0; i < cap(statuses); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				statuses <- s.doIfMatch(t, "PUT", path, etag, samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z")).Status
+			}()
+		}
+		wg.Wait()
+		close(statuses)
+
+		counts := make(map[int]int)
+		for status := range statuses {
+			counts[status]++
+		}
+		if counts[http.StatusOK] != 1 || counts[http.StatusPreconditionFailed] != cap(statuses)-1 {
+			t.Errorf("statuses = %v, want exactly one 200 and the rest 412", counts)
+		}
+	})
+}
+
+func TestRevisionsOnEveryResource(t *testing.T) {
+	s := newTestServer(t)
+	user := s.login(t, "Ada Lovelace", "ada@example.com")
+	rep := createResource(t, s, "/api/representatives", sampleRepresentative())
+	quiz := createResource(t, s, "/api/quizzes", sampleQuiz(nil))
+
+	cases := []struct {
+		name string
+		path string
+		body map[string]interface{}
+	}{
+		{"users", "/api/users/" + user["id"].(string), map[string]interface{}{"name": "Ada King", "email": "ada@example.com"}},
+		{"representatives", "/api/representatives/" + rep["id"].(string), sampleRepresentative()},
+		{"quizzes", "/api/quizzes/" + quiz["id"].(string), sampleQuiz(nil)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := s.do(t, "GET", c.path, nil)
+			expectStatus(t, resp, http.StatusOK)
+			etag := resp.Header.Get("ETag")
+			if etag == "" {
+				t.Fatal("GET does not set an ETag")
+			}
+			header := ifNoneMatch(etag)
+			header.Set("Authorization", "Bearer "+s.token)
+			expectStatus(t, s.doWithHeader(t, "GET", c.path, header, nil), http.StatusNotModified)
+
+			expectStatus(t, s.doIfMatch(t, "PUT", c.path, etag, c.body), http.StatusOK)
+			expectConflict(t, s.doIfMatch(t, "PUT", c.path, etag, c.body), 2)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// apiError is an error response body
+type apiError struct {
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail"`
+	RequestID string `json:"request_id"`
+}
+
+func decodeError(t *testing.T, resp *response, status int, code string) apiError {
+	t.Helper()
+	expectStatus(t, resp, status)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+
+	var body apiError
+	resp.decode(t, &body)
+	if body.Code != code {
+		t.Errorf("code = %q, want %q", body.Code, code)
+	}
+	if body.Status != status {
+		t.Errorf("status field = %d, want %d", body.Status, status)
+	}
+	if body.RequestID == "" || body.RequestID != resp.Header.Get("X-Request-ID") {
+		t.Errorf("request_id = %q, want it to match X-Request-ID %q", body.RequestID, resp.Header.Get("X-Request-ID"))
+	}
+	return body
+}
+
+func TestRequestID(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := s.do(t, "GET", "/api/health", nil)
+	if id := resp.Header.Get("X-Request-ID"); len(id) != 32 {
+		t.Errorf("generated X-Request-ID = %q, want 32 hex characters", id)
+	}
+
+	req, _ := http.NewRequest("GET", s.server.URL+"/api/public/policies/nope", nil)
+	req.Header.Set("X-Request-ID", "client-trace-42")
+	raw, err := s.server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Body.Close()
+	if id := raw.Header.Get("X-Request-ID"); id != "client-trace-42" {
+		t.Errorf("X-Request-ID = %q, want the client's ID echoed", id)
+	}
+
+	req.Header.Set("X-Request-ID", "<script>alert(1)</script>")
+	raw, err = s.server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Body.Close()
+	if id := raw.Header.Get("X-Request-ID"); len(id) != 32 {
+		t.Errorf("X-Request-ID = %q, want an invalid client ID replaced", id)
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	s := newTestServer(t)
+
+	decodeError(t, s.do(t, "GET", "/api/nowhere", nil), http.StatusNotFound, "route_not_found")
+	decodeError(t, s.do(t, "PATCH", "/api/health", nil), http.StatusMethodNotAllowed, "method_not_allowed")
+	decodeError(t, s.do(t, "GET", "/api/users", nil), http.StatusUnauthorized, "unauthorized")
+	decodeError(t, s.doWithToken(t, "GET", "/api/users", "nope", nil), http.StatusUnauthorized, "invalid_token")
+	decodeError(t, s.do(t, "GET", "/api/public/policies/123", nil), http.StatusBadRequest, "invalid_id")
+	decodeError(t, s.do(t, "GET", "/api/public/policies/5f8d0d55b54764421b7156c9", nil), http.StatusNotFound, "not_found")
+	decodeError(t, s.do(t, "GET", "/api/public/policies/location/here", nil), http.StatusBadRequest, "invalid_parameter")
+
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	decodeError(t, s.do(t, "POST", "/api/policies", "{"), http.StatusBadRequest, "malformed_body")
+	decodeError(t, s.do(t, "POST", "/api/policies", map[string]string{}), http.StatusUnprocessableEntity, "validation_failed")
+	decodeError(t, s.doWithToken(t, "POST", "/api/auth/register", "", map[string]string{
+		"name": "Ada", "email": "ada@example.com", "password": "analytical engine",
+	}), http.StatusConflict, "email_taken")
+	decodeError(t, s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{
+		"email": "ada@example.com", "password": "wrong password",
+	}), http.StatusUnauthorized, "invalid_credentials")
+}
+
+// failingPolicies is a policy repository whose database is unreachable
+type failingPolicies struct {
+	repository.PolicyRepository
+}
+
+var errDatabase = errors.New("connection(localhost:27017[-3]) socket was unexpectedly closed: EOF")
+
+func (failingPolicies) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Policy, error) {
+	return nil, errDatabase
+}
+
+func (failingPolicies) List(ctx context.Context, filter repository.PolicyFilter) ([]models.Policy, error) {
+	return nil, errDatabase
+}
+
+func TestInternalErrorsAreNotLeaked(t *testing.T) {
+	store := repository.NewMemoryStore()
+	store.Policies = failingPolicies{store.Policies}
+	s := newTestServerWithStore(t, store)
+
+	for _, path := range []string{"/api/public/policies", "/api/public/policies/5f8d0d55b54764421b7156c9"} {
+		resp := s.do(t, "GET", path, nil)
+		body := decodeError(t, resp, http.StatusInternalServerError, "internal_error")
+		if strings.Contains(string(resp.Body), "socket") || body.Detail != "An internal error occurred" {
+			t.Errorf("GET %s leaked internal details: %s", path, resp.Body)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benjamingetches/govtrack/health"
+)
+
+// readiness fetches /readyz and checks its status code
+func readiness(t *testing.T, s *testServer, status int) health.Report {
+	t.Helper()
+	resp := s.do(t, "GET", "/readyz", nil)
+	expectStatus(t, resp, status)
+	var report health.Report
+	resp.decode(t, &report)
+	return report
+}
+
+func TestHealthProbes(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := s.do(t, "GET", "/healthz", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+
+	s.checker.Add("mongodb", func(context.Context) error { return nil })
+	report := readiness(t, s, http.StatusOK)
+	if report.Status != health.StatusReady || report.Checks["mongodb"].Status != health.StatusOK {
+		t.Errorf("report = %+v, want ready with mongodb ok", report)
+	}
+
+	t.Run("failing dependency", func(t *testing.T) {
+		s.checker.Add("migrations", func(context.Context) error { return errors.New("2 pending migrations") })
+		report := readiness(t, s, http.StatusServiceUnavailable)
+		if report.Status != health.StatusNotReady {
+			t.Errorf("status = %q, want not_ready", report.Status)
+		}
+		if got := report.Checks["migrations"]; got.Status != health.StatusFail || got.Error != "2 pending migrations" {
+			t.Errorf("migrations = %+v, want the failure reported", got)
+		}
+		if report.Checks["mongodb"].Status != health.StatusOK {
+			t.Errorf("mongodb = %+v, want ok", report.Checks["mongodb"])
+		}
+		s.checker.Add("migrations", func(context.Context) error { return nil })
+	})
+
+	t.Run("slow dependency times out", func(t *testing.T) {
+		checker := health.NewChecker(10 * time.Millisecond)
+		checker.Add("mongodb", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		report := checker.Ready(context.Background())
+		if report.Status != health.StatusNotReady || report.Checks["mongodb"].Error != context.DeadlineExceeded.Error() {
+			t.Errorf("report = %+v, want mongodb to time out", report)
+		}
+	})
+
+	t.Run("stale worker", func(t *testing.T) {
+		heartbeat := s.checker.Heartbeat("purge", time.Hour)
+		readiness(t, s, http.StatusOK)
+
+		stale := s.checker.Heartbeat("digest", -time.Second)
+		report := readiness(t, s, http.StatusServiceUnavailable)
+		if report.Checks["worker:digest"].Status != health.StatusFail || report.Checks["worker:purge"].Status != health.StatusOK {
+			t.Errorf("checks = %+v, want only the stale worker failing", report.Checks)
+		}
+		heartbeat.Beat()
+		stale.Beat()
+		s.checker.Add("worker:digest", func(context.Context) error { return nil })
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		readiness(t, s, http.StatusOK)
+		s.checker.Drain()
+		if report := readiness(t, s, http.StatusServiceUnavailable); report.Status != health.StatusShuttingDown {
+			t.Errorf("status = %q, want shutting_down", report.Status)
+		}
+		expectStatus(t, s.do(t, "GET", "/healthz", nil), http.StatusOK)
+	})
+}
@@ -0,0 +1,132 @@
+package routes_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/logging"
+)
+
+func TestMain(m *testing.M) {
+	// Keep request logs out of test output
+	slog.SetDefault(logging.New(io.Discard, slog.LevelInfo))
+	os.Exit(m.Run())
+}
+
+// logBuffer is a concurrency-safe buffer of JSON log lines
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// records decodes every log line
+func (b *logBuffer) records(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(b.String()))
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("log line is not JSON: %s", scanner.Bytes())
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// captureLogs sends the default logger to a buffer for the rest of the test
+func captureLogs(t *testing.T) *logBuffer {
+	t.Helper()
+	buf := &logBuffer{}
+	previous := slog.Default()
+	slog.SetDefault(logging.New(buf, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return buf
+}
+
+func TestRequestLogging(t *testing.T) {
+	logs := captureLogs(t)
+	s := newTestServer(t)
+	user := s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z"))
+
+	resp := s.do(t, "GET", "/api/policies/"+policy["id"].(string), nil)
+	requestID := resp.Header.Get("X-Request-ID")
+	s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": "ada@example.com", "password": "wrong password"})
+
+	var found bool
+	for _, record := range logs.records(t) {
+		if record["request_id"] != requestID || record["msg"] != "request" {
+			continue
+		}
+		found = true
+		want := map[string]interface{}{
+			"level":   "INFO",
+			"method":  "GET",
+			"route":   "/api/policies/{id}",
+			"status":  float64(200),
+			"user_id": user["id"],
+		}
+		for key, value := range want {
+			if record[key] != value {
+				t.Errorf("%s = %v, want %v", key, record[key], value)
+			}
+		}
+		if _, ok := record["latency_ms"].(float64); !ok {
+			t.Errorf("latency_ms missing from %v", record)
+		}
+	}
+	if !found {
+		t.Fatalf("no request record with request_id %s in:\n%s", requestID, logs)
+	}
+
+	output := logs.String()
+	for _, secret := range []string{"ada@example.com", "wrong password", s.token} {
+		if strings.Contains(output, secret) {
+			t.Errorf("logs contain %q:\n%s", secret, output)
+		}
+	}
+	if !strings.Contains(output, `"error_code":"invalid_credentials"`) {
+		t.Errorf("failed login was not logged with its error code:\n%s", output)
+	}
+}
+
+func TestLogRedaction(t *testing.T) {
+	logs := captureLogs(t)
+
+	slog.Info("Contact ada@example.com",
+		"email", "ada@example.com",
+		"password", "analytical engine",
+		"header", "Bearer abc.def.ghi",
+		slog.Group("user", "token", "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln"),
+	)
+
+	output := logs.String()
+	for _, secret := range []string{"ada@example.com", "analytical engine", "abc.def.ghi", "eyJhbGciOiJIUzI1NiJ9"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("log contains %q: %s", secret, output)
+		}
+	}
+	if !strings.Contains(output, "***@example.com") {
+		t.Errorf("email domain should be kept: %s", output)
+	}
+}
@@ -0,0 +1,130 @@
+package routes_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamingetches/govtrack/metrics"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// scrape returns the value of every series served by /metrics, keyed by the
+// series name and labels as written in the exposition format
+func scrape(t *testing.T, s *testServer) map[string]float64 {
+	t.Helper()
+	resp := s.do(t, "GET", "/metrics", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+
+	series := map[string]float64{}
+	scanner := bufio.NewScanner(strings.NewReader(string(resp.Body)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("unparseable metric line %q", line)
+		}
+		series[line[:i]] = value
+	}
+	return series
+}
+
+// expectIncrease checks that each series grew by exactly the given amount
+func expectIncrease(t *testing.T, before, after map[string]float64, want map[string]float64) {
+	t.Helper()
+	for series, delta := range want {
+		if got := after[series] - before[series]; got != delta {
+			t.Errorf("%s increased by %v, want %v", series, got, delta)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t)
+	before := scrape(t, s)
+
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z"))
+	id := policy["id"].(string)
+	s.do(t, "GET", "/api/policies/"+id, nil)
+	s.do(t, "GET", "/api/public/policies/"+id, nil)
+	s.do(t, "GET", "/api/public/policies/nope", nil)
+	s.do(t, "GET", "/api/nowhere", nil)
+	s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": "ada@example.com", "password": "wrong password"})
+	s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": "ada@example.com", "password": "correct horse battery staple"})
+
+	quiz := createResource(t, s, "/api/quizzes", sampleQuiz(nil))
+	question := quiz["questions"].([]interface{})[0].(map[string]interface{})
+	expectStatus(t, s.do(t, "POST", "/api/quizzes/"+quiz["id"].(string)+"/submit", map[string]interface{}{
+		"responses": []map[string]interface{}{{"question_id": question["id"], "answer": 4}},
+	}), http.StatusCreated)
+
+	after := scrape(t, s)
+	expectIncrease(t, before, after, map[string]float64{
+		`govtrack_http_requests_total{method="GET",route="/api/policies/{id}",status="200"}`:        1,
+		`govtrack_http_requests_total{method="GET",route="/api/public/policies/{id}",status="200"}`: 1,
+		`govtrack_http_requests_total{method="GET",route="/api/public/policies/{id}",status="400"}`: 1,
+		`govtrack_http_requests_total{method="GET",route="unmatched",status="404"}`:                 1,
+		`govtrack_http_request_duration_seconds_count{method="GET",route="/api/policies/{id}"}`:     1,
+		`govtrack_logins_total{result="success"}`:                                                   1,
+		`govtrack_logins_total{result="failure"}`:                                                   1,
+		`govtrack_quiz_submissions_total`:                                                           1,
+	})
+	if after["govtrack_active_sessions"] < 1 {
+		t.Errorf("govtrack_active_sessions = %v, want the logged in user counted", after["govtrack_active_sessions"])
+	}
+	for series := range after {
+		if strings.Contains(series, id) {
+			t.Errorf("series %s contains a raw path instead of a route template", series)
+		}
+	}
+}
+
+func TestMongoCommandMetrics(t *testing.T) {
+	s := newTestServer(t)
+	before := scrape(t, s)
+
+	monitor := metrics.CommandMonitor()
+	command := func(requestID int64, name string, doc bson.D) {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		monitor.Started(context.Background(), &event.CommandStartedEvent{
+			Command: raw, CommandName: name, RequestID: requestID,
+		})
+	}
+
+	command(1, "find", bson.D{{Key: "find", Value: "policies"}})
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1, Duration: 3 * time.Millisecond},
+	})
+	command(2, "getMore", bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "policies"}})
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "getMore", RequestID: 2, Duration: time.Millisecond},
+	})
+	command(3, "insert", bson.D{{Key: "insert", Value: "users"}})
+	monitor.Failed(context.Background(), &event.CommandFailedEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "insert", RequestID: 3, Duration: time.Millisecond},
+	})
+
+	expectIncrease(t, before, scrape(t, s), map[string]float64{
+		`govtrack_mongo_command_duration_seconds_count{collection="policies",command="find"}`:    1,
+		`govtrack_mongo_command_duration_seconds_count{collection="policies",command="getMore"}`: 1,
+		`govtrack_mongo_command_duration_seconds_count{collection="users",command="insert"}`:     1,
+		`govtrack_mongo_command_errors_total{collection="users",command="insert"}`:               1,
+		`govtrack_mongo_command_errors_total{collection="policies",command="find"}`:              0,
+	})
+}
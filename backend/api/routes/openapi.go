@@ -0,0 +1,247 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/benjamingetches/govtrack/api/handlers"
+	"github.com/benjamingetches/govtrack/api/middleware"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/openapi"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/health"
+)
+
+// MessageResponse is the body returned by endpoints that only confirm an action
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+var apiInfo = openapi.Info{
+	Title:       "GovTrack API",
+	Version:     "1.0.0",
+	Description: "Policies, representatives, voting records and political quizzes. Routes under /api/public are readable without authentication. Third-party applications can authenticate with developer API keys.",
+}
+
+var apiTags = []openapi.Tag{
+	{Name: "Auth", Description: "Registration and login"},
+	{Name: "Users", Description: "User accounts and profiles"},
+	{Name: "Policies", Description: "Legislation and other policies"},
+	{Name: "Representatives", Description: "Elected officials and their voting records"},
+	{Name: "Quizzes", Description: "Political quizzes and results"},
+	{Name: "Summaries", Description: "Editorial review of policies' simplified descriptions"},
+	{Name: "Annotations", Description: "Users' highlights of and notes on policies' text"},
+	{Name: "Comments", Description: "Public discussion of policies"},
+	{Name: "Moderation", Description: "Review of held and reported comments"},
+	{Name: "Polls", Description: "How users would vote on policies, compared with their representatives' votes"},
+	{Name: "API Keys", Description: "Developer API keys for third-party access"},
+	{Name: "Admin", Description: "Administration, such as restoring deleted content and reviewing the audit log"},
+	{Name: "System", Description: "Health and API documentation"},
+}
+
+var (
+	policyFilters = []openapi.Parameter{
+		openapi.QueryParam("level", "Filter by government level", models.LevelFederal, models.LevelState, models.LevelLocal),
+		openapi.QueryParam("state", "Filter by jurisdiction state"),
+		openapi.QueryParam("city", "Filter by jurisdiction city"),
+		openapi.QueryParam("status", "Filter by status", models.StatusProposed, models.StatusPassed, models.StatusFailed),
+		openapi.QueryParam("type", "Filter by policy type, such as bill or executive order"),
+	}
+	locationFilters = []openapi.Parameter{
+		{Name: "state", In: "query", Required: true, Description: "Jurisdiction state", Schema: &openapi.Schema{Type: "string"}},
+		openapi.QueryParam("city", "Jurisdiction city"),
+	}
+	representativeFilters = []openapi.Parameter{
+		openapi.QueryParam("state", "Filter by state"),
+		openapi.QueryParam("party", "Filter by party"),
+		openapi.QueryParam("title", "Filter by title, such as Senator"),
+		openapi.QueryParam("limit", "Maximum number of results"),
+	}
+	quizFilters = []openapi.Parameter{
+		openapi.QueryParam("category", "Filter by category"),
+		openapi.QueryParam("limit", "Maximum number of results"),
+	}
+	summaryFilters = []openapi.Parameter{
+		openapi.QueryParam("policy_id", "Filter by the ID of the summarized policy"),
+		openapi.QueryParam("status", "Filter by review status", models.SummaryStatuses...),
+	}
+	annotationFilters = []openapi.Parameter{
+		{Name: "policy_id", In: "query", Required: true, Description: "ID of the annotated policy", Schema: &openapi.Schema{Type: "string"}},
+		openapi.QueryParam("anchor", "Only annotations of this section, such as sec-3-b"),
+		openapi.QueryParam("mine", "Only the caller's annotations", "true"),
+	}
+	publicAnnotationFilters = []openapi.Parameter{
+		openapi.QueryParam("anchor", "Only annotations of this section, such as sec-3-b"),
+	}
+	commentOrder   = openapi.QueryParam("sort", "Order of the comments, oldest first by default", handlers.CommentsOldest, handlers.CommentsNewest, handlers.CommentsTop)
+	commentFilters = []openapi.Parameter{
+		{Name: "policy_id", In: "query", Required: true, Description: "ID of the discussed policy", Schema: &openapi.Schema{Type: "string"}},
+		commentOrder,
+	}
+	moderationFilters = []openapi.Parameter{
+		openapi.QueryParam("policy_id", "Filter by the ID of the discussed policy"),
+		openapi.QueryParam("status", "List the comments with this status instead of the queue", models.CommentPublished, models.CommentHeld, models.CommentHidden, models.CommentRemoved, models.CommentDeleted),
+		openapi.QueryParam("limit", "Maximum number of results"),
+	}
+	trashFilters = []openapi.Parameter{
+		openapi.QueryParam("type", "List only one type of content", handlers.TrashPolicies, handlers.TrashRepresentatives, handlers.TrashQuizzes),
+	}
+	auditFilters = []openapi.Parameter{
+		openapi.QueryParam("actor", "Filter by the ID of the user who made the change"),
+		openapi.QueryParam("action", "Filter by action", audit.Actions...),
+		openapi.QueryParam("resource_type", "Filter by the type of the changed document", audit.ResourceTypes...),
+		openapi.QueryParam("resource_id", "Filter by the ID of the changed document"),
+		openapi.QueryParam("from", "Only changes made at or after this RFC 3339 time"),
+		openapi.QueryParam("to", "Only changes made before this RFC 3339 time"),
+		openapi.QueryParam("limit", "Maximum number of results, 50 by default and at most 500"),
+		openapi.QueryParam("skip", "Number of results to skip"),
+	}
+)
+
+const (
+	patchDescription = "Applies a JSON Merge Patch (application/merge-patch+json) or a JSON Patch (application/json-patch+json) to the stored document. The result is validated like a full replacement, and only the fields that changed are written. id and the update time cannot be patched."
+
+	userOwnerDescription = "Only the account's owner and administrators can use this route; other users get 403."
+
+	userPatchDescription = patchDescription + " The password hash is not part of the document; setting password replaces it, role is read-only and only administrators can change affiliation. " + userOwnerDescription
+
+	summaryDescription = "Summaries move from draft to in_review when submitted, to approved once assigned reviewers who are editors of enough different declared affiliations have approved them, and to published when an editor publishes them. Any step can be made conditional with If-Match."
+
+	sectionsDescription = "Sections are parsed from the policy's original text whenever it is written. Anchors are derived from the numbering, such as sec-3-b-2 for section 3(b)(2), and references such as \"subsection (a)\" carry the anchor of the provision they name."
+
+	annotationDescription = "Annotations target a section by anchor, a range of characters of the original text, or a range within a section, and keep the quoted text with some context around it. When the text is amended they follow the quote to its new place; if it can no longer be found their target's status becomes orphaned. Replies set parent_id instead and share the visibility of the annotation they reply to."
+
+	commentDescription = "Comments are flat lists; replies set parent_id. Removed and deleted comments keep their place without their body, and voters and reports are never shown to other users."
+
+	pollDescription = "Results count the ballots overall and by the voters' state and congressional district, as set in their location when they voted. States and districts with fewer than min_ballots ballots are left out, but counted overall. The majority is yes or no, whichever more voters chose; ties have none."
+
+	alignmentDescription = "Compares each of the representative's votes with how their constituents answered the policy's poll: the voters of their congressional district, or of their whole state for senators and state and local officials. Votes are compared when they are yes or no and at least min_ballots constituents answered with a majority."
+
+	trashDescription = "Deleted content is hidden from every listing and lookup and can be restored by an administrator until it is purged, along with references to it, after the trash retention period."
+)
+
+// apiRoutes documents every route registered by SetupRoutes. A test fails if
+// a registered route is missing from this table.
+var apiRoutes = map[string]openapi.Route{
+	"GET /api/health":       {Tag: "System", Summary: "Health check", Public: true, Response: "", ContentType: "text/plain"},
+	"GET /api/openapi.json": {Tag: "System", Summary: "OpenAPI document", Public: true, Response: map[string]interface{}{}},
+	"GET /api/docs":         {Tag: "System", Summary: "API documentation viewer", Public: true, Response: "", ContentType: "text/html"},
+	"GET /healthz":          {Tag: "System", Summary: "Liveness probe", Description: "Succeeds while the process is serving requests.", Public: true, Response: health.Report{}},
+	"GET /readyz":           {Tag: "System", Summary: "Readiness probe", Description: "Checks MongoDB, migrations and background workers. Responds 503 when any check fails or the server is shutting down.", Public: true, Response: health.Report{}},
+	"GET /metrics":          {Tag: "System", Summary: "Prometheus metrics", Description: "Request, database, session, login and quiz metrics in the Prometheus text format.", Public: true, Response: "", ContentType: "text/plain"},
+
+	"POST /api/auth/register": {Tag: "Auth", Summary: "Register a new account", Public: true, Request: models.RegisterRequest{}, Response: models.AuthResponse{}, Status: http.StatusCreated},
+	"POST /api/auth/login":    {Tag: "Auth", Summary: "Log in and receive a token", Public: true, Request: models.LoginRequest{}, Response: models.AuthResponse{}},
+
+	"POST /api/keys":        {Tag: "API Keys", Summary: "Create an API key", Description: "Returns the key once; only its hash is stored. Keys with the write scope can only be created by editors.", Request: models.CreateAPIKeyRequest{}, Response: models.CreateAPIKeyResponse{}, Status: http.StatusCreated},
+	"GET /api/keys":         {Tag: "API Keys", Summary: "List your API keys with their usage", Response: []models.APIKey{}},
+	"DELETE /api/keys/{id}": {Tag: "API Keys", Summary: "Revoke an API key", Response: MessageResponse{}},
+
+	"GET /api/users":             {Tag: "Users", Summary: "List users", Response: []models.User{}},
+	"POST /api/users":            {Tag: "Users", Summary: "Create a user", Request: models.User{}, Response: models.User{}, Status: http.StatusCreated},
+	"GET /api/users/{id}":        {Tag: "Users", Summary: "Get a user", Response: models.User{}},
+	"PUT /api/users/{id}":        {Tag: "Users", Summary: "Replace a user", Description: userOwnerDescription, Request: models.User{}, Response: models.User{}},
+	"PATCH /api/users/{id}":      {Tag: "Users", Summary: "Change some fields of a user", Description: userPatchDescription, Request: models.User{}, Patch: true, Response: models.User{}},
+	"DELETE /api/users/{id}":     {Tag: "Users", Summary: "Delete a user", Description: userOwnerDescription, Response: MessageResponse{}},
+	"GET /api/public/users/{id}": {Tag: "Users", Summary: "Get a user's public profile", Public: true, Response: models.User{}},
+
+	"GET /api/policies":                               {Tag: "Policies", Summary: "List policies, newest first", Query: policyFilters, Response: []models.Policy{}},
+	"POST /api/policies":                              {Tag: "Policies", Summary: "Create a policy", Request: models.Policy{}, Response: models.Policy{}, Status: http.StatusCreated},
+	"GET /api/policies/{id}":                          {Tag: "Policies", Summary: "Get a policy", Response: models.Policy{}},
+	"PUT /api/policies/{id}":                          {Tag: "Policies", Summary: "Replace a policy", Request: models.Policy{}, Response: models.Policy{}},
+	"PATCH /api/policies/{id}":                        {Tag: "Policies", Summary: "Change some fields of a policy", Description: patchDescription, Request: models.Policy{}, Patch: true, Response: models.Policy{}},
+	"DELETE /api/policies/{id}":                       {Tag: "Policies", Summary: "Move a policy to the trash", Description: trashDescription, Response: MessageResponse{}},
+	"GET /api/policies/{id}/sections":                 {Tag: "Policies", Summary: "Get the structure of a policy's text", Description: sectionsDescription, Response: []models.Provision{}},
+	"GET /api/policies/{id}/sections/{anchor}":        {Tag: "Policies", Summary: "Get a section of a policy's text", Description: sectionsDescription, Response: models.PolicySection{}},
+	"GET /api/policies/location/{location}":           {Tag: "Policies", Summary: "List policies for a location", Query: locationFilters, Response: []models.Policy{}},
+	"GET /api/public/policies":                        {Tag: "Policies", Summary: "List policies, newest first", Public: true, Query: policyFilters, Response: []models.Policy{}},
+	"GET /api/public/policies/{id}":                   {Tag: "Policies", Summary: "Get a policy", Public: true, Response: models.Policy{}},
+	"GET /api/public/policies/{id}/sections":          {Tag: "Policies", Summary: "Get the structure of a policy's text", Description: sectionsDescription, Public: true, Response: []models.Provision{}},
+	"GET /api/public/policies/{id}/sections/{anchor}": {Tag: "Policies", Summary: "Get a section of a policy's text", Description: sectionsDescription, Public: true, Response: models.PolicySection{}},
+	"GET /api/public/policies/{id}/comments":          {Tag: "Comments", Summary: "List a policy's comments", Description: commentDescription, Public: true, Query: []openapi.Parameter{commentOrder}, Response: []models.Comment{}},
+	"GET /api/public/policies/{id}/poll":              {Tag: "Polls", Summary: "Get the results of a policy's poll", Description: pollDescription, Public: true, Response: models.PollResults{}},
+	"GET /api/public/policies/{id}/annotations":       {Tag: "Annotations", Summary: "List a policy's public annotations, oldest first", Description: annotationDescription, Public: true, Query: publicAnnotationFilters, Response: []models.Annotation{}},
+	"GET /api/public/policies/location/{location}":    {Tag: "Policies", Summary: "List policies for a location", Public: true, Query: locationFilters, Response: []models.Policy{}},
+	"GET /api/representatives":                        {Tag: "Representatives", Summary: "List representatives", Query: representativeFilters, Response: []models.Representative{}},
+	"POST /api/representatives":                       {Tag: "Representatives", Summary: "Create a representative", Request: models.Representative{}, Response: models.Representative{}, Status: http.StatusCreated},
+	"GET /api/representatives/{id}":                   {Tag: "Representatives", Summary: "Get a representative", Response: models.Representative{}},
+	"PUT /api/representatives/{id}":                   {Tag: "Representatives", Summary: "Replace a representative", Request: models.Representative{}, Response: models.Representative{}},
+	"PATCH /api/representatives/{id}":                 {Tag: "Representatives", Summary: "Change some fields of a representative", Description: patchDescription, Request: models.Representative{}, Patch: true, Response: models.Representative{}},
+	"DELETE /api/representatives/{id}":                {Tag: "Representatives", Summary: "Move a representative to the trash", Description: trashDescription, Status: http.StatusNoContent},
+	"GET /api/representatives/{id}/votes":             {Tag: "Representatives", Summary: "Get a representative's voting record", Response: []models.RepresentativeVote{}},
+	"GET /api/representatives/{id}/alignment":         {Tag: "Polls", Summary: "Compare a representative's votes with their constituents' polls", Description: alignmentDescription, Response: models.ConstituentAlignment{}},
+	"GET /api/public/representatives":                 {Tag: "Representatives", Summary: "List representatives", Public: true, Query: representativeFilters, Response: []models.Representative{}},
+	"GET /api/public/representatives/{id}":            {Tag: "Representatives", Summary: "Get a representative", Public: true, Response: models.Representative{}},
+	"GET /api/public/representatives/{id}/votes":      {Tag: "Representatives", Summary: "Get a representative's voting record", Public: true, Response: []models.RepresentativeVote{}},
+	"GET /api/public/representatives/{id}/alignment":  {Tag: "Polls", Summary: "Compare a representative's votes with their constituents' polls", Description: alignmentDescription, Public: true, Response: models.ConstituentAlignment{}},
+	"GET /api/quizzes":                                {Tag: "Quizzes", Summary: "List quizzes", Query: quizFilters, Response: []models.PoliticalQuiz{}},
+	"POST /api/quizzes":                               {Tag: "Quizzes", Summary: "Create a quiz", Request: models.PoliticalQuiz{}, Response: models.PoliticalQuiz{}, Status: http.StatusCreated},
+	"GET /api/quizzes/{id}":                           {Tag: "Quizzes", Summary: "Get a quiz", Response: models.PoliticalQuiz{}},
+	"PUT /api/quizzes/{id}":                           {Tag: "Quizzes", Summary: "Replace a quiz", Request: models.PoliticalQuiz{}, Response: models.PoliticalQuiz{}},
+	"PATCH /api/quizzes/{id}":                         {Tag: "Quizzes", Summary: "Change some fields of a quiz", Description: patchDescription, Request: models.PoliticalQuiz{}, Patch: true, Response: models.PoliticalQuiz{}},
+	"DELETE /api/quizzes/{id}":                        {Tag: "Quizzes", Summary: "Move a quiz to the trash", Description: trashDescription, Status: http.StatusNoContent},
+	"POST /api/quizzes/{id}/submit":                   {Tag: "Quizzes", Summary: "Submit answers and score them", Description: "Scores the responses by category and computes alignment with representatives who have recorded stances. The result is saved for the authenticated user unless user_id is given.", Request: models.QuizResult{}, Response: models.QuizResult{}, Status: http.StatusCreated},
+	"GET /api/quizzes/results/{result_id}":            {Tag: "Quizzes", Summary: "Get a quiz result", Response: models.QuizResult{}},
+	"GET /api/quizzes/user/{user_id}/results":         {Tag: "Quizzes", Summary: "List a user's quiz results, newest first", Response: []models.QuizResult{}},
+	"GET /api/public/quizzes":                         {Tag: "Quizzes", Summary: "List quizzes", Public: true, Query: quizFilters, Response: []models.PoliticalQuiz{}},
+	"GET /api/public/quizzes/{id}":                    {Tag: "Quizzes", Summary: "Get a quiz", Public: true, Response: models.PoliticalQuiz{}},
+
+	"POST /api/summaries":                      {Tag: "Summaries", Summary: "Draft a summary of a policy", Description: "Policies' simplified descriptions can only be set by publishing a reviewed summary.", Request: models.SummaryRequest{}, Response: models.Summary{}, Status: http.StatusCreated},
+	"POST /api/summaries/generate":             {Tag: "Summaries", Summary: "Generate a draft summary of a policy", Description: "Editors only. Summarizes the policy's original text with the configured generator and records the generator's name and version on the draft. Responds 502 if the generator fails.", Request: models.GenerateSummaryRequest{}, Response: models.Summary{}, Status: http.StatusCreated},
+	"GET /api/summaries":                       {Tag: "Summaries", Summary: "List summaries, most recently updated first", Query: summaryFilters, Response: []models.Summary{}},
+	"GET /api/summaries/{id}":                  {Tag: "Summaries", Summary: "Get a summary with its review", Response: models.Summary{}},
+	"PUT /api/summaries/{id}":                  {Tag: "Summaries", Summary: "Change the text of a draft", Description: "Only the author and editors can change a draft.", Request: models.SummaryRequest{}, Response: models.Summary{}},
+	"POST /api/summaries/{id}/submit":          {Tag: "Summaries", Summary: "Submit a draft for review", Description: summaryDescription, Response: models.Summary{}},
+	"POST /api/summaries/{id}/reviewers":       {Tag: "Summaries", Summary: "Assign reviewers", Description: "Editors only. Reviewers must be editors who have declared an affiliation, other than the author.", Request: models.ReviewersRequest{}, Response: models.Summary{}},
+	"POST /api/summaries/{id}/comments":        {Tag: "Summaries", Summary: "Comment on a summary", Description: "The author, reviewers and editors can comment until the summary is published.", Request: models.CommentRequest{}, Response: models.Summary{}},
+	"POST /api/summaries/{id}/approve":         {Tag: "Summaries", Summary: "Approve a summary under review", Description: summaryDescription, Response: models.Summary{}},
+	"POST /api/summaries/{id}/request-changes": {Tag: "Summaries", Summary: "Return a summary to its author", Description: "Makes the summary a draft again and clears its approvals. The optional comment explains why.", Request: models.CommentRequest{}, Response: models.Summary{}},
+	"POST /api/summaries/{id}/publish":         {Tag: "Summaries", Summary: "Publish an approved summary", Description: "Editors only. Sets the policy's simplified description, naming the approvers and publisher, and supersedes the summary published before.", Response: models.Summary{}},
+
+	"POST /api/annotations":        {Tag: "Annotations", Summary: "Annotate a policy's text or reply to an annotation", Description: annotationDescription + " Annotations are private unless visibility is public.", Request: models.AnnotationRequest{}, Response: models.Annotation{}, Status: http.StatusCreated},
+	"GET /api/annotations":         {Tag: "Annotations", Summary: "List a policy's public annotations and the caller's, oldest first", Query: annotationFilters, Response: []models.Annotation{}},
+	"GET /api/annotations/{id}":    {Tag: "Annotations", Summary: "Get an annotation", Description: "Others' private annotations are not found.", Response: models.Annotation{}},
+	"PUT /api/annotations/{id}":    {Tag: "Annotations", Summary: "Change an annotation's note or visibility", Description: "Only the author can change an annotation. The visibility of replies, and of annotations that have replies, cannot change.", Request: models.AnnotationUpdate{}, Response: models.Annotation{}},
+	"DELETE /api/annotations/{id}": {Tag: "Annotations", Summary: "Delete an annotation and its replies", Description: "The author and editors can delete an annotation.", Response: MessageResponse{}},
+
+	"POST /api/comments":             {Tag: "Comments", Summary: "Comment on a policy or reply to a comment", Description: "Comments are checked by the content filter, which holds suspicious ones for a moderator. Each user can post a limited number of comments and edits at a time; banned users get 403 banned.", Request: models.PolicyCommentRequest{}, Response: models.Comment{}, Status: http.StatusCreated},
+	"GET /api/comments":              {Tag: "Comments", Summary: "List a policy's comments with the caller's held and hidden ones", Description: commentDescription, Query: commentFilters, Response: []models.Comment{}},
+	"GET /api/comments/{id}":         {Tag: "Comments", Summary: "Get a comment", Description: "Others' held and hidden comments are not found.", Response: models.Comment{}},
+	"PUT /api/comments/{id}":         {Tag: "Comments", Summary: "Edit a comment", Description: "Only the author can edit a published or held comment. The earlier body is kept in edits, and the new one is checked by the content filter.", Request: models.CommentUpdate{}, Response: models.Comment{}},
+	"DELETE /api/comments/{id}":      {Tag: "Comments", Summary: "Delete a comment", Description: "Only the author can delete a comment. It keeps its place in the thread without its body.", Response: MessageResponse{}},
+	"POST /api/comments/{id}/vote":   {Tag: "Comments", Summary: "Vote on a comment", Description: "A value of 1 votes up, -1 down and 0 withdraws the caller's vote. Authors cannot vote on their own comments.", Request: models.CommentVoteRequest{}, Response: models.Comment{}},
+	"POST /api/comments/{id}/report": {Tag: "Comments", Summary: "Report a comment to the moderators", Description: "Each user's latest report counts. A comment reported by enough users is held for moderation.", Request: models.CommentReportRequest{}, Response: MessageResponse{}},
+
+	"GET /api/moderation/comments":          {Tag: "Moderation", Summary: "List comments awaiting moderation, oldest first", Description: "Moderators only. The queue holds the comments held by the content filter or by reports, and published comments that were reported.", Query: moderationFilters, Response: []models.Comment{}},
+	"POST /api/moderation/comments/{id}":    {Tag: "Moderation", Summary: "Moderate a comment", Description: "Moderators only. approve publishes the comment and clears its reports, hide shows it only to its author, remove keeps its place without its body, and ban removes it and bars its author from commenting, for ban_days or indefinitely.", Request: models.ModerationRequest{}, Response: models.Comment{}},
+	"DELETE /api/moderation/bans/{user_id}": {Tag: "Moderation", Summary: "Lift a user's comment ban", Description: "Moderators only.", Response: MessageResponse{}},
+
+	"GET /api/polls/{id}":           {Tag: "Polls", Summary: "Get the results of a policy's poll with the caller's choice", Description: pollDescription, Response: models.PollResults{}},
+	"PUT /api/polls/{id}/ballot":    {Tag: "Polls", Summary: "Answer a policy's poll or change the answer", Description: "Each user has one ballot per policy; answering again replaces it. The ballot records the state and congressional district of the caller's location.", Request: models.BallotRequest{}, Response: models.Ballot{}},
+	"DELETE /api/polls/{id}/ballot": {Tag: "Polls", Summary: "Withdraw the caller's answer to a policy's poll", Response: MessageResponse{}},
+
+	"GET /api/admin/trash":                               {Tag: "Admin", Summary: "List deleted content", Admin: true, Query: trashFilters, Response: models.Trash{}},
+	"POST /api/admin/trash/policies/{id}/restore":        {Tag: "Admin", Summary: "Restore a deleted policy", Admin: true, Response: models.Policy{}},
+	"POST /api/admin/trash/representatives/{id}/restore": {Tag: "Admin", Summary: "Restore a deleted representative", Admin: true, Response: models.Representative{}},
+	"POST /api/admin/trash/quizzes/{id}/restore":         {Tag: "Admin", Summary: "Restore a deleted quiz", Admin: true, Response: models.PoliticalQuiz{}},
+	"GET /api/admin/audit":                               {Tag: "Admin", Summary: "List audit log entries, newest first", Description: "Every change made through the API is recorded with the user, API key, IP address and request ID that made it and the fields it changed.", Admin: true, Query: auditFilters, Response: []models.AuditEntry{}},
+	"GET /api/admin/audit/verify":                        {Tag: "Admin", Summary: "Verify the audit log's hash chain", Description: "Checks that every entry's hash matches its content and the previous entry's hash, and reports the first entry that does not.", Admin: true, Response: models.AuditVerification{}},
+}
+
+// Every route outside the System tag is rate limited
+func init() {
+	for key, route := range apiRoutes {
+		if route.Tag != "System" {
+			route.RateLimited = true
+			method, template, _ := strings.Cut(key, " ")
+			route.Scope = middleware.ScopeFor(method, template)
+			// Policy and representative reads carry ETags, as do single users
+			// and quizzes so that their revision can be named in If-Match
+			route.Conditional = method == http.MethodGet &&
+				(route.Tag == "Policies" || route.Tag == "Representatives" || strings.HasSuffix(template, "/{id}"))
+			// Replacing a document is conditional on its revision
+			route.Versioned = method == http.MethodPut || method == http.MethodPatch
+			apiRoutes[key] = route
+		}
+	}
+}
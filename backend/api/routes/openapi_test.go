@@ -0,0 +1,133 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/httpcache"
+	"github.com/benjamingetches/govtrack/api/routes"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/health"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/gorilla/mux"
+)
+
+type openAPISpec struct {
+	OpenAPI    string                                       `json:"openapi"`
+	Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]struct {
+				Enum []string `json:"enum"`
+			} `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func fetchSpec(t *testing.T, s *testServer) openAPISpec {
+	t.Helper()
+	resp := s.do(t, "GET", "/api/openapi.json", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+
+	var spec openAPISpec
+	resp.decode(t, &spec)
+	return spec
+}
+
+// TestOpenAPIDocumentsEveryRoute fails when a route is registered without an
+// entry in the route documentation table
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	s := newTestServer(t)
+	spec := fetchSpec(t, s)
+
+	if spec.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q, want 3.1.0", spec.OpenAPI)
+	}
+
+	router := mux.NewRouter()
+	routes.SetupRoutes(router, config.Default(), repository.NewMemoryStore(), health.NewChecker(health.DefaultTimeout), httpcache.New(0, 0))
+
+	paramPattern := regexp.MustCompile(`\{([^}:]+)(:[^}]+)?\}`)
+	count := 0
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		path := paramPattern.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			count++
+			if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+				t.Errorf("%s %s is registered but missing from the OpenAPI document", method, template)
+			}
+		}
+		return nil
+	})
+	if count == 0 {
+		t.Fatal("no routes found on the router")
+	}
+}
+
+func TestOpenAPISecurityAndEnums(t *testing.T) {
+	s := newTestServer(t)
+	spec := fetchSpec(t, s)
+
+	security := func(path, method string) string {
+		b, _ := json.Marshal(spec.Paths[path][method]["security"])
+		return string(b)
+	}
+	if got, want := security("/api/policies", "get"), `[{"bearerAuth":[]},{"apiKeyAuth":["public:read"]}]`; got != want {
+		t.Errorf("GET /api/policies security = %s, want %s", got, want)
+	}
+	if got, want := security("/api/public/policies", "get"), `[{},{"apiKeyAuth":["public:read"]}]`; got != want {
+		t.Errorf("GET /api/public/policies should be public with an optional API key, security = %s, want %s", got, want)
+	}
+	if got, want := security("/api/public/representatives/{id}/votes", "get"), `[{},{"apiKeyAuth":["votes:read"]}]`; got != want {
+		t.Errorf("GET /api/public/representatives/{id}/votes security = %s, want %s", got, want)
+	}
+	if got, want := security("/api/keys", "post"), `[{"bearerAuth":[]}]`; got != want {
+		t.Errorf("POST /api/keys should only accept bearerAuth, security = %s, want %s", got, want)
+	}
+	if got := security("/api/public/users/{id}", "get"); got != "null" {
+		t.Errorf("GET /api/public/users/{id} should be public, security = %s", got)
+	}
+
+	enums := []struct {
+		schema, property string
+		want             []string
+	}{
+		{"Policy", "level", []string{"federal", "state", "local"}},
+		{"Policy", "status", []string{"proposed", "passed", "failed"}},
+		{"Representative", "level", []string{"federal", "state", "local"}},
+		{"Vote", "vote", []string{"yes", "no", "abstain", "not_voting"}},
+	}
+	for _, e := range enums {
+		got := spec.Components.Schemas[e.schema].Properties[e.property].Enum
+		if strings.Join(got, ",") != strings.Join(e.want, ",") {
+			t.Errorf("%s.%s enum = %v, want %v", e.schema, e.property, got, e.want)
+		}
+	}
+}
+
+func TestAPIDocsViewer(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := s.do(t, "GET", "/api/docs", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", ct)
+	}
+	if !strings.Contains(string(resp.Body), "openapi.json") {
+		t.Error("viewer does not load openapi.json")
+	}
+}
@@ -0,0 +1,241 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// doPatch sends an authenticated patch of the given media type
+func (s *testServer) doPatch(t *testing.T, path, contentType string, body interface{}, extra http.Header) *response {
+	t.Helper()
+	header := http.Header{"Content-Type": {contentType}}
+	header.Set("Authorization", "Bearer "+s.token)
+	for name, values := range extra {
+		header[name] = values
+	}
+	return s.doWithHeader(t, "PATCH", path, header, body)
+}
+
+// mergePatch sends an authenticated JSON Merge Patch
+func (s *testServer) mergePatch(t *testing.T, path string, body interface{}) *response {
+	t.Helper()
+	return s.doPatch(t, path, "application/merge-patch+json", body, nil)
+}
+
+// jsonPatch sends an authenticated JSON Patch
+func (s *testServer) jsonPatch(t *testing.T, path string, ops ...map[string]interface{}) *response {
+	t.Helper()
+	return s.doPatch(t, path, "application/json-patch+json", ops, nil)
+}
+
+func TestPatchUser(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	adminToken := s.token
+	promote(t, s, "ada@example.com", models.RoleAdmin)
+	s.login(t, "Mallory", "mallory@example.com")
+	malloryToken := s.token
+	user := s.login(t, "Grace Hopper", "grace@example.com")
+	path := "/api/users/" + user["id"].(string)
+	expectStatus(t, s.mergePatch(t, path, map[string]interface{}{"location": map[string]interface{}{"city": "Arlington", "state": "VA"}}), http.StatusOK)
+	s.do(t, "GET", path, nil).decode(t, &user)
+
+	t.Run("a location edit keeps every other field", func(t *testing.T) {
+		resp := s.mergePatch(t, path, map[string]interface{}{"location": map[string]interface{}{"city": "New York", "state": "NY"}})
+		expectStatus(t, resp, http.StatusOK)
+		var patched map[string]interface{}
+		resp.decode(t, &patched)
+
+		location := patched["location"].(map[string]interface{})
+		if patched["name"] != "Grace Hopper" || patched["email"] != "grace@example.com" || location["city"] != "New York" {
+			t.Errorf("patched user = %v, want the name and email kept and the city changed", patched)
+		}
+		if patched["createdAt"] != user["createdAt"] || patched["revision"] != float64(3) {
+			t.Errorf("createdAt = %v and revision = %v, want %v and 3", patched["createdAt"], patched["revision"], user["createdAt"])
+		}
+		if _, ok := patched["password"]; ok {
+			t.Error("the password hash must not be returned")
+		}
+
+		resp = s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": "grace@example.com", "password": "correct horse battery staple"})
+		expectStatus(t, resp, http.StatusOK)
+	})
+
+	t.Run("setting the password replaces it", func(t *testing.T) {
+		expectStatus(t, s.mergePatch(t, path, map[string]interface{}{"password": "a new long passphrase"}), http.StatusOK)
+		resp := s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": "grace@example.com", "password": "a new long passphrase"})
+		expectStatus(t, resp, http.StatusOK)
+	})
+
+	t.Run("patched documents are validated", func(t *testing.T) {
+		expectFieldErrors(t, s.mergePatch(t, path, map[string]interface{}{"email": "not an email"}), "email")
+		expectFieldErrors(t, s.mergePatch(t, path, map[string]interface{}{"role": "admin"}), "role")
+		expectFieldErrors(t, s.jsonPatch(t, path, map[string]interface{}{"op": "remove", "path": "/name"}), "name")
+	})
+
+	t.Run("emails stay unique", func(t *testing.T) {
+		resp := s.mergePatch(t, path, map[string]interface{}{"email": "ada@example.com"})
+		decodeError(t, resp, http.StatusConflict, "email_taken")
+	})
+
+	t.Run("users cannot patch other accounts", func(t *testing.T) {
+		header := http.Header{"Content-Type": {"application/merge-patch+json"}, "Authorization": {"Bearer " + malloryToken}}
+		resp := s.doWithHeader(t, "PATCH", path, header, map[string]interface{}{"email": "mallory@evil.example", "password": "stolen account"})
+		decodeError(t, resp, http.StatusForbidden, "forbidden")
+		resp = s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": "grace@example.com", "password": "a new long passphrase"})
+		expectStatus(t, resp, http.StatusOK)
+	})
+
+	t.Run("administrators patch any account", func(t *testing.T) {
+		header := http.Header{"Content-Type": {"application/merge-patch+json"}, "Authorization": {"Bearer " + adminToken}}
+		resp := s.doWithHeader(t, "PATCH", path, header, map[string]interface{}{"name": "Grace Brewster Hopper"})
+		expectStatus(t, resp, http.StatusOK)
+		var patched models.User
+		resp.decode(t, &patched)
+		if patched.Name != "Grace Brewster Hopper" {
+			t.Errorf("name = %q, want the administrator's change", patched.Name)
+		}
+	})
+}
+
+func TestPatchPolicy(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+	path := "/api/policies/" + policy["id"].(string)
+
+	t.Run("JSON Patch operations apply in order", func(t *testing.T) {
+		resp := s.jsonPatch(t, path,
+			map[string]interface{}{"op": "test", "path": "/title", "value": "Clean Air Act"},
+			map[string]interface{}{"op": "replace", "path": "/title", "value": "Clean Air Act of 2024"},
+			map[string]interface{}{"op": "add", "path": "/tags/-", "value": "health"},
+		)
+		expectStatus(t, resp, http.StatusOK)
+		var patched map[string]interface{}
+		resp.decode(t, &patched)
+		tags := patched["tags"].([]interface{})
+		if patched["title"] != "Clean Air Act of 2024" || len(tags) != 2 || tags[1] != "health" {
+			t.Errorf("title = %v and tags = %v, want the new title and an added tag", patched["title"], tags)
+		}
+		if patched["description"] != policy["description"] {
+			t.Errorf("description = %v, want it unchanged", patched["description"])
+		}
+		if resp.Header.Get("ETag") == "" {
+			t.Error("the new revision's ETag is not set")
+		}
+	})
+
+	t.Run("failed operations change nothing", func(t *testing.T) {
+		resp := s.jsonPatch(t, path,
+			map[string]interface{}{"op": "replace", "path": "/title", "value": "Lost update"},
+			map[string]interface{}{"op": "test", "path": "/status", "value": "passed"},
+		)
+		body := decodeError(t, resp, http.StatusConflict, "patch_failed")
+		if body.Detail != "Operation 1 failed: /status does not have the expected value" {
+			t.Errorf("detail = %q", body.Detail)
+		}
+		resp = s.do(t, "GET", path, nil)
+		var stored map[string]interface{}
+		resp.decode(t, &stored)
+		if stored["title"] != "Clean Air Act of 2024" || stored["revision"] != float64(2) {
+			t.Errorf("title = %v at revision %v, want the policy unchanged", stored["title"], stored["revision"])
+		}
+	})
+
+	t.Run("invalid patches are rejected", func(t *testing.T) {
+		expectFieldErrors(t, s.jsonPatch(t, path, map[string]interface{}{"op": "frobnicate", "path": "/title"}), "[0].op")
+		expectFieldErrors(t, s.mergePatch(t, path, map[string]interface{}{"status": "vetoed"}), "status")
+		decodeError(t, s.doPatch(t, path, "application/merge-patch+json", `["title"]`, nil), http.StatusBadRequest, "malformed_body")
+	})
+
+	t.Run("only patch media types are accepted", func(t *testing.T) {
+		resp := s.doPatch(t, path, "application/json", map[string]interface{}{"title": "Clean Air Act"}, nil)
+		decodeError(t, resp, http.StatusUnsupportedMediaType, "unsupported_media_type")
+		if got := resp.Header.Get("Accept-Patch"); got != "application/merge-patch+json, application/json-patch+json" {
+			t.Errorf("Accept-Patch = %q", got)
+		}
+	})
+
+	t.Run("patches that change nothing are not written", func(t *testing.T) {
+		resp := s.mergePatch(t, path, map[string]interface{}{"title": "Clean Air Act of 2024", "id": "000000000000000000000000"})
+		expectStatus(t, resp, http.StatusOK)
+		var patched map[string]interface{}
+		resp.decode(t, &patched)
+		if patched["revision"] != float64(2) || patched["id"] != policy["id"] {
+			t.Errorf("revision = %v and id = %v, want 2 and the policy's ID", patched["revision"], patched["id"])
+		}
+	})
+
+	t.Run("patches honour revision preconditions", func(t *testing.T) {
+		stale := `"` + policy["id"].(string) + `-1"`
+		resp := s.doPatch(t, path, "application/merge-patch+json", map[string]interface{}{"title": "Lost update"}, http.Header{"If-Match": {stale}})
+		expectConflict(t, resp, 2)
+		expectConflict(t, s.mergePatch(t, path, map[string]interface{}{"title": "Lost update", "revision": 1}), 2)
+
+		resp = s.mergePatch(t, path, map[string]interface{}{"title": "Clean Air Act", "revision": 2})
+		expectStatus(t, resp, http.StatusOK)
+	})
+
+	t.Run("missing documents are not found", func(t *testing.T) {
+		resp := s.mergePatch(t, "/api/policies/000000000000000000000000", map[string]interface{}{"title": "Clean Air Act"})
+		decodeError(t, resp, http.StatusNotFound, "not_found")
+	})
+}
+
+func TestPatchWritesOnlyChangedFields(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+	id, _ := primitive.ObjectIDFromHex(policy["id"].(string))
+
+	ctx := context.Background()
+	doc, err := s.store.Policies.FindByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Title = "Clean Air Act of 2024"
+	doc.Description = "Not written"
+	if err := s.store.Policies.Patch(ctx, doc, []string{"title"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.store.Policies.FindByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "Clean Air Act of 2024" || stored.Description != policy["description"] || stored.Revision != 2 {
+		t.Errorf("stored title %q, description %q at revision %d; want only the title written", stored.Title, stored.Description, stored.Revision)
+	}
+}
+
+func TestPatchRepresentativeAndQuiz(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	rep := createResource(t, s, "/api/representatives", sampleRepresentative())
+	quiz := createResource(t, s, "/api/quizzes", sampleQuiz(nil))
+
+	resp := s.mergePatch(t, "/api/representatives/"+rep["id"].(string), map[string]interface{}{"party": "Independent"})
+	expectStatus(t, resp, http.StatusOK)
+	var patchedRep map[string]interface{}
+	resp.decode(t, &patchedRep)
+	if patchedRep["party"] != "Independent" || patchedRep["name"] != rep["name"] || patchedRep["last_updated"] == rep["last_updated"] {
+		t.Errorf("patched representative = %v, want the party changed and last_updated bumped", patchedRep)
+	}
+
+	resp = s.jsonPatch(t, "/api/quizzes/"+quiz["id"].(string), map[string]interface{}{
+		"op": "add", "path": "/questions/-",
+		"value": map[string]interface{}{"text": "Healthcare should be public.", "category": "economic", "is_likert_scale": true},
+	})
+	expectStatus(t, resp, http.StatusOK)
+	var patchedQuiz struct {
+		Questions []map[string]interface{} `json:"questions"`
+	}
+	resp.decode(t, &patchedQuiz)
+	if len(patchedQuiz.Questions) != 3 || patchedQuiz.Questions[2]["id"] == nil {
+		t.Errorf("questions = %v, want a third question with an ID", patchedQuiz.Questions)
+	}
+}
@@ -0,0 +1,173 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+// pollResults gets the results of a poll at path, expecting 200
+func pollResults(t *testing.T, s *testServer, path string) models.PollResults {
+	t.Helper()
+	resp := s.do(t, "GET", path, nil)
+	expectStatus(t, resp, http.StatusOK)
+	var results models.PollResults
+	resp.decode(t, &results)
+	return results
+}
+
+// constituentAlignment gets the alignment of the representative with id,
+// expecting 200
+func constituentAlignment(t *testing.T, s *testServer, id string) models.ConstituentAlignment {
+	t.Helper()
+	resp := s.do(t, "GET", "/api/public/representatives/"+id+"/alignment", nil)
+	expectStatus(t, resp, http.StatusOK)
+	var alignment models.ConstituentAlignment
+	resp.decode(t, &alignment)
+	return alignment
+}
+
+// voter registers a user living in state and district and returns their token
+func voter(t *testing.T, s *testServer, name, email, state, district string) string {
+	t.Helper()
+	user := s.login(t, name, email)
+	if state != "" {
+		location := map[string]interface{}{"state": state, "congressional_district": district}
+		expectStatus(t, s.mergePatch(t, "/api/users/"+user["id"].(string), map[string]interface{}{"location": location}), http.StatusOK)
+	}
+	return s.token
+}
+
+func TestPolls(t *testing.T) {
+	cfg := testConfig()
+	cfg.Polls.MinBallots = 2
+	s := newTestServerWithConfig(t, cfg, repository.NewMemoryStore())
+	voters := map[string]string{
+		"ada":      voter(t, s, "Ada Lovelace", "ada@example.com", "TX", "05"),
+		"grace":    voter(t, s, "Grace Hopper", "grace@example.com", "tx", "TX-5"),
+		"alan":     voter(t, s, "Alan Turing", "alan@example.com", "TX", "7"),
+		"margaret": voter(t, s, "Margaret Hamilton", "margaret@example.com", "", ""),
+	}
+	s.token = voters["ada"]
+
+	house := sampleRepresentative()
+	house["title"], house["level"], house["state"], house["district"] = "Representative", "federal", "TX", "5"
+	houseID := createResource(t, s, "/api/representatives", house)["id"].(string)
+	senate := sampleRepresentative()
+	senate["level"], senate["state"], senate["district"] = "federal", "TX", ""
+	senateID := createResource(t, s, "/api/representatives", senate)["id"].(string)
+
+	water := samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z")
+	water["voting_record"] = []map[string]interface{}{
+		{"representative_id": houseID, "vote": "yes", "date": "2024-04-01T00:00:00Z"},
+		{"representative_id": senateID, "vote": "no", "date": "2024-04-02T00:00:00Z"},
+	}
+	waterID := createResource(t, s, "/api/policies", water)["id"].(string)
+	roads := samplePolicy("Safe Roads Act", "2024-02-01T00:00:00Z")
+	roads["voting_record"] = []map[string]interface{}{
+		{"representative_id": houseID, "vote": "no", "date": "2024-03-01T00:00:00Z"},
+	}
+	roadsID := createResource(t, s, "/api/policies", roads)["id"].(string)
+
+	cast := func(name, policyID, choice string) *response {
+		return s.doWithToken(t, "PUT", "/api/polls/"+policyID+"/ballot", voters[name], map[string]interface{}{"choice": choice})
+	}
+
+	t.Run("ballots are validated", func(t *testing.T) {
+		expectFieldErrors(t, cast("ada", waterID, "maybe"), "choice")
+		decodeError(t, cast("ada", "64b7f0f0f0f0f0f0f0f0f0f0", "yes"), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "GET", "/api/public/policies/64b7f0f0f0f0f0f0f0f0f0f0/poll", nil), http.StatusNotFound, "not_found")
+	})
+
+	t.Run("each user has one ballot per policy", func(t *testing.T) {
+		expectStatus(t, cast("ada", waterID, "no"), http.StatusOK)
+		resp := cast("ada", waterID, "yes")
+		expectStatus(t, resp, http.StatusOK)
+		var ballot models.Ballot
+		resp.decode(t, &ballot)
+		if ballot.State != "TX" || ballot.District != "5" || ballot.Choice != models.VoteYes {
+			t.Errorf("ballot = %+v, want a yes from TX-5", ballot)
+		}
+		expectStatus(t, cast("grace", waterID, "yes"), http.StatusOK)
+		expectStatus(t, cast("alan", waterID, "no"), http.StatusOK)
+		expectStatus(t, cast("margaret", waterID, "yes"), http.StatusOK)
+		expectStatus(t, cast("ada", roadsID, "yes"), http.StatusOK)
+		expectStatus(t, cast("grace", roadsID, "no"), http.StatusOK)
+
+		if results := pollResults(t, s, "/api/polls/"+waterID); results.MyChoice != models.VoteYes || results.Overall.Total != 4 {
+			t.Errorf("results = %+v, want four ballots and the caller's yes", results)
+		}
+	})
+
+	t.Run("results are shown by state and district", func(t *testing.T) {
+		s.token = ""
+		defer func() { s.token = voters["ada"] }()
+		resp := s.do(t, "GET", "/api/public/policies/"+waterID+"/poll", nil)
+		golden(t, "polls/results", resp)
+		expectStatus(t, resp, http.StatusOK)
+		var results models.PollResults
+		resp.decode(t, &results)
+		if results.Overall.Yes != 3 || results.Overall.No != 1 || results.Overall.Majority != models.VoteYes {
+			t.Errorf("overall = %+v, want 3 to 1 for yes", results.Overall)
+		}
+		if len(results.States) != 1 || results.States[0].State != "TX" || results.States[0].Total != 3 {
+			t.Errorf("states = %+v, want the three Texans", results.States)
+		}
+		// TX-7 has a single ballot, which is not shown
+		if len(results.Districts) != 1 || results.Districts[0].District != "5" || results.Districts[0].Yes != 2 {
+			t.Errorf("districts = %+v, want only TX-5", results.Districts)
+		}
+
+		roads := pollResults(t, s, "/api/public/policies/"+roadsID+"/poll")
+		if roads.Overall.Total != 2 || roads.Overall.Majority != "" {
+			t.Errorf("tied results = %+v, want no majority", roads.Overall)
+		}
+	})
+
+	t.Run("representatives are compared with their constituents", func(t *testing.T) {
+		alignment := constituentAlignment(t, s, houseID)
+		if alignment.State != "TX" || alignment.District != "5" || alignment.Compared != 1 || alignment.Aligned != 1 || *alignment.AlignmentRate != 100 {
+			t.Errorf("house alignment = %+v, want its one compared vote aligned", alignment)
+		}
+		for _, policy := range alignment.Policies {
+			switch policy.PolicyID.Hex() {
+			case waterID:
+				if policy.Aligned == nil || !*policy.Aligned || policy.Constituents.Yes != 2 {
+					t.Errorf("water = %+v, want the yes vote aligned with TX-5", policy)
+				}
+			case roadsID:
+				if policy.Aligned != nil || policy.Constituents == nil || policy.Constituents.Total != 2 {
+					t.Errorf("roads = %+v, want the tie not compared", policy)
+				}
+			}
+		}
+
+		// Senators answer to their whole state
+		alignment = constituentAlignment(t, s, senateID)
+		if alignment.District != "" || alignment.Compared != 1 || alignment.Aligned != 0 || *alignment.AlignmentRate != 0 {
+			t.Errorf("senate alignment = %+v, want its no vote against the state's yes", alignment)
+		}
+		if got := alignment.Policies[0].Constituents; got == nil || got.Total != 3 {
+			t.Errorf("constituents = %+v, want the three Texans", got)
+		}
+		decodeError(t, s.do(t, "GET", "/api/public/representatives/64b7f0f0f0f0f0f0f0f0f0f0/alignment", nil), http.StatusNotFound, "not_found")
+	})
+
+	t.Run("ballots can be withdrawn", func(t *testing.T) {
+		path := "/api/polls/" + roadsID + "/ballot"
+		expectStatus(t, s.doWithToken(t, "DELETE", path, voters["grace"], nil), http.StatusOK)
+		decodeError(t, s.doWithToken(t, "DELETE", path, voters["grace"], nil), http.StatusNotFound, "not_found")
+
+		// Too few constituents remain to compare the vote
+		for _, policy := range constituentAlignment(t, s, houseID).Policies {
+			if policy.PolicyID.Hex() == roadsID && policy.Constituents != nil {
+				t.Errorf("roads = %+v, want its single ballot left out", policy)
+			}
+		}
+		if results := pollResults(t, s, "/api/polls/"+roadsID); results.MyChoice != models.VoteYes || results.Overall.Yes != 1 {
+			t.Errorf("results = %+v, want the caller's remaining yes", results)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package routes_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/ratelimit"
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+func TestRateLimits(t *testing.T) {
+	cfg := testConfig()
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Auth = ratelimit.Limit{Requests: 3, Per: time.Hour}
+	cfg.RateLimit.Public = ratelimit.Limit{Requests: 2, Per: time.Hour}
+	cfg.RateLimit.API = ratelimit.Limit{Requests: 2, Per: time.Hour}
+	s := newTestServerWithConfig(t, cfg, repository.NewMemoryStore())
+
+	t.Run("public routes are limited per IP", func(t *testing.T) {
+		resp := s.doWithToken(t, "GET", "/api/public/policies", "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := resp.Header.Get("RateLimit-Remaining"); got != "1" {
+			t.Errorf("RateLimit-Remaining = %q, want 1", got)
+		}
+		if got := resp.Header.Get("RateLimit-Policy"); got != "2;w=3600" {
+			t.Errorf("RateLimit-Policy = %q, want 2;w=3600", got)
+		}
+
+		// Every public group shares the same bucket
+		expectStatus(t, s.doWithToken(t, "GET", "/api/public/quizzes", "", nil), http.StatusOK)
+		resp = s.doWithToken(t, "GET", "/api/public/representatives", "", nil)
+		decodeError(t, resp, http.StatusTooManyRequests, "rate_limited")
+		if retry, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retry < 1 || retry > 1800 {
+			t.Errorf("Retry-After = %q, want the seconds until a token refills", resp.Header.Get("Retry-After"))
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != "0" {
+			t.Errorf("RateLimit-Remaining = %q, want 0", got)
+		}
+	})
+
+	t.Run("protected routes are limited per user", func(t *testing.T) {
+		s.login(t, "Ada Lovelace", "ada@example.com")
+		ada := s.token
+		s.login(t, "Grace Hopper", "grace@example.com")
+		grace := s.token
+
+		expectStatus(t, s.doWithToken(t, "GET", "/api/policies", ada, nil), http.StatusOK)
+		expectStatus(t, s.doWithToken(t, "GET", "/api/quizzes", ada, nil), http.StatusOK)
+		decodeError(t, s.doWithToken(t, "GET", "/api/policies", ada, nil), http.StatusTooManyRequests, "rate_limited")
+		expectStatus(t, s.doWithToken(t, "GET", "/api/policies", grace, nil), http.StatusOK)
+	})
+
+	t.Run("auth routes are limited per IP", func(t *testing.T) {
+		// The two registrations above used two of the three requests
+		resp := s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": "ada@example.com", "password": "correct horse battery staple"})
+		expectStatus(t, resp, http.StatusOK)
+		resp = s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": "ada@example.com", "password": "correct horse battery staple"})
+		decodeError(t, resp, http.StatusTooManyRequests, "rate_limited")
+	})
+
+	t.Run("system routes are not limited", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			expectStatus(t, s.doWithToken(t, "GET", "/healthz", "", nil), http.StatusOK)
+		}
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.Lockout = ratelimit.LockoutPolicy{Threshold: 3, Base: time.Minute, Max: time.Hour}
+	s := newTestServerWithConfig(t, cfg, repository.NewMemoryStore())
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	login := func(email, password string) *response {
+		return s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": email, "password": password})
+	}
+
+	for i := 0; i < 3; i++ {
+		decodeError(t, login("ada@example.com", "wrong password"), http.StatusUnauthorized, "invalid_credentials")
+	}
+
+	// Locked accounts are refused even with the right password
+	resp := login("ADA@example.com", "correct horse battery staple")
+	decodeError(t, resp, http.StatusTooManyRequests, "account_locked")
+	if retry, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retry < 1 || retry > 60 {
+		t.Errorf("Retry-After = %q, want up to one minute", resp.Header.Get("Retry-After"))
+	}
+
+	// Other accounts are unaffected, and unknown emails lock out the same way
+	expectStatus(t, s.doWithToken(t, "POST", "/api/auth/register", "", map[string]string{
+		"name": "Grace Hopper", "email": "grace@example.com", "password": "correct horse battery staple",
+	}), http.StatusCreated)
+	expectStatus(t, login("grace@example.com", "correct horse battery staple"), http.StatusOK)
+	for i := 0; i < 3; i++ {
+		decodeError(t, login("nobody@example.com", "guess"), http.StatusUnauthorized, "invalid_credentials")
+	}
+	decodeError(t, login("nobody@example.com", "guess"), http.StatusTooManyRequests, "account_locked")
+}
+
+func TestEmailsAreNormalized(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.Lockout = ratelimit.LockoutPolicy{Threshold: 2, Base: time.Minute, Max: time.Hour}
+	s := newTestServerWithConfig(t, cfg, repository.NewMemoryStore())
+	user := s.login(t, "Ada Lovelace", "Ada@Example.com")
+	if user["email"] != "ada@example.com" {
+		t.Errorf("email = %v, want it stored in lower case", user["email"])
+	}
+
+	login := func(email, password string) *response {
+		return s.doWithToken(t, "POST", "/api/auth/login", "", map[string]string{"email": email, "password": password})
+	}
+
+	// Case variants name the same account
+	decodeError(t, s.doWithToken(t, "POST", "/api/auth/register", "", map[string]string{
+		"name": "Impostor", "email": "ADA@example.com", "password": "correct horse battery staple",
+	}), http.StatusConflict, "email_taken")
+	resp := login("ADA@EXAMPLE.COM", "correct horse battery staple")
+	expectStatus(t, resp, http.StatusOK)
+	var auth models.AuthResponse
+	resp.decode(t, &auth)
+	if auth.User.ID.Hex() != user["id"] {
+		t.Errorf("logged in as %s, want %v", auth.User.ID.Hex(), user["id"])
+	}
+
+	// and share one lockout
+	decodeError(t, login("ada@example.com", "wrong password"), http.StatusUnauthorized, "invalid_credentials")
+	decodeError(t, login("Ada@Example.com", "wrong password"), http.StatusUnauthorized, "invalid_credentials")
+	decodeError(t, login("ADA@example.com", "correct horse battery staple"), http.StatusTooManyRequests, "account_locked")
+}
@@ -1,93 +1,277 @@
-package routes
-
-import (
-	"net/http"
-
-	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/mongo"
-
-	"github.com/benjamingetches/govtrack/api/handlers"
-	"github.com/benjamingetches/govtrack/api/middleware"
-)
-
-// SetupRoutes configures all API routes
-func SetupRoutes(router *mux.Router, client *mongo.Client) {
-	// Health check route
-	router.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("OK"))
-	}).Methods("GET")
-
-	// Create handlers
-	userHandler := handlers.NewUserHandler(client)
-	policyHandler := handlers.NewPolicyHandler(client)
-	representativeHandler := handlers.NewRepresentativeHandler(client)
-	quizHandler := handlers.NewQuizHandler(client)
-	authHandler := handlers.NewAuthHandler(client)
-
-	// Auth routes - no authentication required
-	authRouter := router.PathPrefix("/api/auth").Subrouter()
-	authRouter.HandleFunc("/register", authHandler.Register).Methods("POST")
-	authRouter.HandleFunc("/login", authHandler.Login).Methods("POST")
-
-	// User routes - protected with JWT
-	userRouter := router.PathPrefix("/api/users").Subrouter()
-	userRouter.Use(middleware.VerifyJWT)
-	userRouter.HandleFunc("", userHandler.GetUsers).Methods("GET")
-	userRouter.HandleFunc("/{id}", userHandler.GetUser).Methods("GET")
-	userRouter.HandleFunc("", userHandler.CreateUser).Methods("POST")
-	userRouter.HandleFunc("/{id}", userHandler.UpdateUser).Methods("PUT")
-	userRouter.HandleFunc("/{id}", userHandler.DeleteUser).Methods("DELETE")
-
-	// Public user routes - no authentication required
-	publicUserRouter := router.PathPrefix("/api/public/users").Subrouter()
-	publicUserRouter.HandleFunc("/{id}", userHandler.GetUser).Methods("GET")
-
-	// Policy routes - protected with JWT
-	policyRouter := router.PathPrefix("/api/policies").Subrouter()
-	policyRouter.Use(middleware.VerifyJWT)
-	policyRouter.HandleFunc("", policyHandler.CreatePolicy).Methods("POST")
-	policyRouter.HandleFunc("", policyHandler.GetPolicies).Methods("GET")
-	policyRouter.HandleFunc("/{id}", policyHandler.GetPolicy).Methods("GET")
-	policyRouter.HandleFunc("/{id}", policyHandler.UpdatePolicy).Methods("PUT")
-	policyRouter.HandleFunc("/{id}", policyHandler.DeletePolicy).Methods("DELETE")
-	policyRouter.HandleFunc("/location/{location}", policyHandler.GetPoliciesByLocation).Methods("GET")
-
-	// Public policy routes - no authentication required
-	publicPolicyRouter := router.PathPrefix("/api/public/policies").Subrouter()
-	publicPolicyRouter.HandleFunc("", policyHandler.GetPolicies).Methods("GET")
-	publicPolicyRouter.HandleFunc("/{id}", policyHandler.GetPolicy).Methods("GET")
-	publicPolicyRouter.HandleFunc("/location/{location}", policyHandler.GetPoliciesByLocation).Methods("GET")
-
-	// Representative routes - protected with JWT
-	repRouter := router.PathPrefix("/api/representatives").Subrouter()
-	repRouter.Use(middleware.VerifyJWT)
-	repRouter.HandleFunc("", representativeHandler.CreateRepresentative).Methods("POST")
-	repRouter.HandleFunc("", representativeHandler.GetRepresentatives).Methods("GET")
-	repRouter.HandleFunc("/{id}", representativeHandler.GetRepresentative).Methods("GET")
-	repRouter.HandleFunc("/{id}", representativeHandler.UpdateRepresentative).Methods("PUT")
-	repRouter.HandleFunc("/{id}", representativeHandler.DeleteRepresentative).Methods("DELETE")
-	repRouter.HandleFunc("/{id}/votes", representativeHandler.GetRepresentativeVotes).Methods("GET")
-
-	// Public representative routes - no authentication required
-	publicRepRouter := router.PathPrefix("/api/public/representatives").Subrouter()
-	publicRepRouter.HandleFunc("", representativeHandler.GetRepresentatives).Methods("GET")
-	publicRepRouter.HandleFunc("/{id}", representativeHandler.GetRepresentative).Methods("GET")
-	publicRepRouter.HandleFunc("/{id}/votes", representativeHandler.GetRepresentativeVotes).Methods("GET")
-
-	// Quiz routes - protected with JWT
-	quizRouter := router.PathPrefix("/api/quizzes").Subrouter()
-	quizRouter.Use(middleware.VerifyJWT)
-	quizRouter.HandleFunc("", quizHandler.CreateQuiz).Methods("POST")
-	quizRouter.HandleFunc("", quizHandler.GetQuizzes).Methods("GET")
-	quizRouter.HandleFunc("/{id}", quizHandler.GetQuiz).Methods("GET")
-	quizRouter.HandleFunc("/{id}", quizHandler.UpdateQuiz).Methods("PUT")
-	quizRouter.HandleFunc("/{id}", quizHandler.DeleteQuiz).Methods("DELETE")
-	quizRouter.HandleFunc("/submit", quizHandler.SubmitQuizResults).Methods("POST")
-	quizRouter.HandleFunc("/results", quizHandler.GetQuizResults).Methods("GET")
-	quizRouter.HandleFunc("/user/{userId}/results", quizHandler.GetUserQuizResults).Methods("GET")
-
-	// Public quiz routes - no authentication required
-	publicQuizRouter := router.PathPrefix("/api/public/quizzes").Subrouter()
-	publicQuizRouter.HandleFunc("", quizHandler.GetQuizzes).Methods("GET")
-	publicQuizRouter.HandleFunc("/{id}", quizHandler.GetQuiz).Methods("GET")
-}
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+
+	"github.com/benjamingetches/govtrack/annotate"
+	"github.com/benjamingetches/govtrack/api/apierror"
+	"github.com/benjamingetches/govtrack/api/handlers"
+	"github.com/benjamingetches/govtrack/api/httpcache"
+	"github.com/benjamingetches/govtrack/api/middleware"
+	"github.com/benjamingetches/govtrack/api/openapi"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/health"
+	"github.com/benjamingetches/govtrack/metrics"
+	"github.com/benjamingetches/govtrack/moderation"
+	"github.com/benjamingetches/govtrack/ratelimit"
+	"github.com/benjamingetches/govtrack/readability"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/benjamingetches/govtrack/summarize"
+	"github.com/benjamingetches/govtrack/tracing"
+)
+
+// SetupRoutes configures all API routes. checker reports the readiness of
+// the server's dependencies, and cache keeps public responses; it is shared
+// with the background workers that change content.
+func SetupRoutes(router *mux.Router, cfg *config.Config, store *repository.Store, checker *health.Checker, cache *httpcache.Cache) {
+	// Every request is traced, gets an ID echoed in responses and error
+	// bodies, and is logged and counted
+	router.Use(otelmux.Middleware(tracing.ServiceName), middleware.RequestID, middleware.Logging, middleware.Metrics)
+	router.NotFoundHandler = instrument(http.HandlerFunc(apierror.NotFoundHandler))
+	router.MethodNotAllowedHandler = instrument(http.HandlerFunc(apierror.MethodNotAllowedHandler))
+
+	// Health check route, kept for existing monitors
+	router.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}).Methods("GET")
+
+	// Liveness and readiness probes
+	router.HandleFunc("/healthz", checker.ServeLiveness).Methods("GET")
+	router.HandleFunc("/readyz", checker.ServeReadiness).Methods("GET")
+
+	// Prometheus metrics
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// API documentation - built from the router and apiRoutes on first request
+	docsHandler := openapi.NewHandler(router, apiInfo, apiTags, apiRoutes)
+	router.HandleFunc("/api/openapi.json", docsHandler.ServeSpec).Methods("GET")
+	router.HandleFunc("/api/docs", docsHandler.ServeViewer).Methods("GET")
+
+	// Create handlers. Changes made through them are recorded in the audit
+	// log along with the client's IP address.
+	auditor := handlers.NewAuditor(audit.NewLog(store.Audit), cfg.RateLimit.TrustForwardedFor)
+	userHandler := handlers.NewUserHandler(store.Users, auditor)
+	policyHandler := handlers.NewPolicyHandler(store.Policies, annotate.NewReanchorer(store), auditor)
+	representativeHandler := handlers.NewRepresentativeHandler(store.Representatives, store.Policies, auditor)
+	quizHandler := handlers.NewQuizHandler(store, auditor)
+	authHandler := handlers.NewAuthHandler(store.Users, cfg.Auth)
+	apiKeyHandler := handlers.NewAPIKeyHandler(store.APIKeys, store.Users, cfg.APIKeys, auditor)
+	trashHandler := handlers.NewTrashHandler(store, auditor)
+	analyzer := readability.FromConfig(cfg.Readability)
+	drafter := summarize.NewDrafter(store, summarize.FromConfig(cfg.Summaries.Generator), analyzer, cfg.Summaries.Generator.Batch)
+	summaryHandler := handlers.NewSummaryHandler(store, cfg.Summaries, analyzer, drafter, auditor)
+	annotationHandler := handlers.NewAnnotationHandler(store, auditor)
+	commentHandler := handlers.NewCommentHandler(store, cfg.Comments, moderation.FromConfig(cfg.Comments.Filter), auditor)
+	pollHandler := handlers.NewPollHandler(store, cfg.Polls)
+	auditHandler := handlers.NewAuditHandler(store.Audit)
+
+	// Protected routes accept a user's token or a developer API key; public
+	// routes accept an optional API key so that its scope and quota apply
+	verifyJWT := middleware.VerifyJWT(cfg.Auth.JWTSecret)
+	keyAuth := middleware.NewAPIKeyAuth(store.APIKeys, store.Users)
+	authenticate := keyAuth.OrJWT(verifyJWT)
+
+	// Rate limits - each group has its own buckets. Clients are identified by
+	// API key or user where possible, so the limiters must run after
+	// authentication.
+	trustProxy := cfg.RateLimit.TrustForwardedFor
+	authLimit := rateLimit(cfg.RateLimit, "auth", cfg.RateLimit.Auth, middleware.ByIP(trustProxy))
+	publicLimit := rateLimit(cfg.RateLimit, "public", cfg.RateLimit.Public, middleware.ByClient(trustProxy))
+	apiLimit := rateLimit(cfg.RateLimit, "api", cfg.RateLimit.API, middleware.ByClient(trustProxy))
+
+	// HTTP caching - public responses may be reused by clients and proxies
+	// for MaxAge and are kept in an in-process LRU cache until a write through
+	// the protected routes, or a purge of the trash, invalidates them.
+	// Protected responses must be revalidated with their ETag on every use, as
+	// must users and quizzes, which are not cached.
+	publicCache := middleware.CacheControl("public, max-age=" + strconv.Itoa(int(cfg.HTTPCache.MaxAge.Seconds())))
+	privateCache := middleware.CacheControl("private, no-cache")
+
+	// Auth routes - no authentication required
+	authRouter := router.PathPrefix("/api/auth").Subrouter()
+	authRouter.Use(authLimit)
+	authRouter.HandleFunc("/register", authHandler.Register).Methods("POST")
+	authRouter.HandleFunc("/login", authHandler.Login).Methods("POST")
+
+	// API key routes - protected with JWT; keys cannot manage keys
+	keyRouter := router.PathPrefix("/api/keys").Subrouter()
+	keyRouter.Use(authenticate, apiLimit)
+	keyRouter.HandleFunc("", apiKeyHandler.CreateAPIKey).Methods("POST")
+	keyRouter.HandleFunc("", apiKeyHandler.GetAPIKeys).Methods("GET")
+	keyRouter.HandleFunc("/{id}", apiKeyHandler.RevokeAPIKey).Methods("DELETE")
+
+	// User routes - protected with JWT
+	userRouter := router.PathPrefix("/api/users").Subrouter()
+	userRouter.Use(authenticate, apiLimit, privateCache)
+	userRouter.HandleFunc("", userHandler.GetUsers).Methods("GET")
+	userRouter.HandleFunc("/{id}", userHandler.GetUser).Methods("GET")
+	userRouter.HandleFunc("", userHandler.CreateUser).Methods("POST")
+	userRouter.HandleFunc("/{id}", userHandler.UpdateUser).Methods("PUT")
+	userRouter.HandleFunc("/{id}", userHandler.PatchUser).Methods("PATCH")
+	userRouter.HandleFunc("/{id}", userHandler.DeleteUser).Methods("DELETE")
+
+	// Public user routes - no authentication required
+	publicUserRouter := router.PathPrefix("/api/public/users").Subrouter()
+	publicUserRouter.Use(publicLimit, privateCache)
+	publicUserRouter.HandleFunc("/{id}", userHandler.GetUser).Methods("GET")
+
+	// Policy routes - protected with JWT or API key
+	policyRouter := router.PathPrefix("/api/policies").Subrouter()
+	policyRouter.Use(authenticate, apiLimit, privateCache, middleware.InvalidateCache(cache, "policies"))
+	policyRouter.HandleFunc("", policyHandler.CreatePolicy).Methods("POST")
+	policyRouter.HandleFunc("", policyHandler.GetPolicies).Methods("GET")
+	policyRouter.HandleFunc("/{id}", policyHandler.GetPolicy).Methods("GET")
+	policyRouter.HandleFunc("/{id}/sections", policyHandler.GetPolicySections).Methods("GET")
+	policyRouter.HandleFunc("/{id}/sections/{anchor}", policyHandler.GetPolicySection).Methods("GET")
+	policyRouter.HandleFunc("/{id}", policyHandler.UpdatePolicy).Methods("PUT")
+	policyRouter.HandleFunc("/{id}", policyHandler.PatchPolicy).Methods("PATCH")
+	policyRouter.HandleFunc("/{id}", policyHandler.DeletePolicy).Methods("DELETE")
+	policyRouter.HandleFunc("/location/{location}", policyHandler.GetPoliciesByLocation).Methods("GET")
+
+	// Public policy routes - no authentication required
+	publicPolicyRouter := router.PathPrefix("/api/public/policies").Subrouter()
+	publicPolicyRouter.Use(keyAuth.Optional, publicLimit, publicCache, responseCache(cfg.HTTPCache, cache, "policies"))
+	publicPolicyRouter.HandleFunc("", policyHandler.GetPolicies).Methods("GET")
+	publicPolicyRouter.HandleFunc("/{id}", policyHandler.GetPolicy).Methods("GET")
+	publicPolicyRouter.HandleFunc("/{id}/sections", policyHandler.GetPolicySections).Methods("GET")
+	publicPolicyRouter.HandleFunc("/{id}/sections/{anchor}", policyHandler.GetPolicySection).Methods("GET")
+	publicPolicyRouter.HandleFunc("/{id}/annotations", annotationHandler.GetPublicAnnotations).Methods("GET")
+	publicPolicyRouter.HandleFunc("/{id}/comments", commentHandler.GetPublicComments).Methods("GET")
+	publicPolicyRouter.HandleFunc("/{id}/poll", pollHandler.GetPublicPoll).Methods("GET")
+	publicPolicyRouter.HandleFunc("/location/{location}", policyHandler.GetPoliciesByLocation).Methods("GET")
+
+	// Representative routes - protected with JWT or API key
+	repRouter := router.PathPrefix("/api/representatives").Subrouter()
+	repRouter.Use(authenticate, apiLimit, privateCache, middleware.InvalidateCache(cache, "representatives"))
+	repRouter.HandleFunc("", representativeHandler.CreateRepresentative).Methods("POST")
+	repRouter.HandleFunc("", representativeHandler.GetRepresentatives).Methods("GET")
+	repRouter.HandleFunc("/{id}", representativeHandler.GetRepresentative).Methods("GET")
+	repRouter.HandleFunc("/{id}", representativeHandler.UpdateRepresentative).Methods("PUT")
+	repRouter.HandleFunc("/{id}", representativeHandler.PatchRepresentative).Methods("PATCH")
+	repRouter.HandleFunc("/{id}", representativeHandler.DeleteRepresentative).Methods("DELETE")
+	repRouter.HandleFunc("/{id}/votes", representativeHandler.GetRepresentativeVotes).Methods("GET")
+	repRouter.HandleFunc("/{id}/alignment", pollHandler.GetConstituentAlignment).Methods("GET")
+
+	// Public representative routes - no authentication required. Voting
+	// records are read from policies, so policy writes invalidate these too.
+	publicRepRouter := router.PathPrefix("/api/public/representatives").Subrouter()
+	publicRepRouter.Use(keyAuth.Optional, publicLimit, publicCache, responseCache(cfg.HTTPCache, cache, "representatives", "policies"))
+	publicRepRouter.HandleFunc("", representativeHandler.GetRepresentatives).Methods("GET")
+	publicRepRouter.HandleFunc("/{id}", representativeHandler.GetRepresentative).Methods("GET")
+	publicRepRouter.HandleFunc("/{id}/votes", representativeHandler.GetRepresentativeVotes).Methods("GET")
+	publicRepRouter.HandleFunc("/{id}/alignment", pollHandler.GetConstituentAlignment).Methods("GET")
+
+	// Quiz routes - protected with JWT or API key
+	quizRouter := router.PathPrefix("/api/quizzes").Subrouter()
+	quizRouter.Use(authenticate, apiLimit, privateCache)
+	quizRouter.HandleFunc("", quizHandler.CreateQuiz).Methods("POST")
+	quizRouter.HandleFunc("", quizHandler.GetQuizzes).Methods("GET")
+	quizRouter.HandleFunc("/{id}", quizHandler.GetQuiz).Methods("GET")
+	quizRouter.HandleFunc("/{id}", quizHandler.UpdateQuiz).Methods("PUT")
+	quizRouter.HandleFunc("/{id}", quizHandler.PatchQuiz).Methods("PATCH")
+	quizRouter.HandleFunc("/{id}", quizHandler.DeleteQuiz).Methods("DELETE")
+	quizRouter.HandleFunc("/{id}/submit", quizHandler.SubmitQuizResults).Methods("POST")
+	quizRouter.HandleFunc("/results/{result_id}", quizHandler.GetQuizResults).Methods("GET")
+	quizRouter.HandleFunc("/user/{user_id}/results", quizHandler.GetUserQuizResults).Methods("GET")
+
+	// Public quiz routes - no authentication required
+	publicQuizRouter := router.PathPrefix("/api/public/quizzes").Subrouter()
+	publicQuizRouter.Use(keyAuth.Optional, publicLimit, privateCache)
+	publicQuizRouter.HandleFunc("", quizHandler.GetQuizzes).Methods("GET")
+	publicQuizRouter.HandleFunc("/{id}", quizHandler.GetQuiz).Methods("GET")
+
+	// Summary review routes - protected with JWT; API keys cannot review.
+	// Publishing a summary changes its policy's public responses.
+	summaryRouter := router.PathPrefix("/api/summaries").Subrouter()
+	summaryRouter.Use(authenticate, apiLimit, privateCache, middleware.InvalidateCache(cache, "policies"))
+	summaryRouter.HandleFunc("", summaryHandler.CreateSummary).Methods("POST")
+	summaryRouter.HandleFunc("/generate", summaryHandler.GenerateSummary).Methods("POST")
+	summaryRouter.HandleFunc("", summaryHandler.GetSummaries).Methods("GET")
+	summaryRouter.HandleFunc("/{id}", summaryHandler.GetSummary).Methods("GET")
+	summaryRouter.HandleFunc("/{id}", summaryHandler.UpdateSummary).Methods("PUT")
+	summaryRouter.HandleFunc("/{id}/submit", summaryHandler.SubmitSummary).Methods("POST")
+	summaryRouter.HandleFunc("/{id}/reviewers", summaryHandler.AssignReviewers).Methods("POST")
+	summaryRouter.HandleFunc("/{id}/comments", summaryHandler.CommentOnSummary).Methods("POST")
+	summaryRouter.HandleFunc("/{id}/approve", summaryHandler.ApproveSummary).Methods("POST")
+	summaryRouter.HandleFunc("/{id}/request-changes", summaryHandler.RequestSummaryChanges).Methods("POST")
+	summaryRouter.HandleFunc("/{id}/publish", summaryHandler.PublishSummary).Methods("POST")
+
+	// Annotation routes - protected with JWT; annotations belong to users.
+	// Public annotations are part of their policy's public responses.
+	annotationRouter := router.PathPrefix("/api/annotations").Subrouter()
+	annotationRouter.Use(authenticate, apiLimit, privateCache, middleware.InvalidateCache(cache, "policies"))
+	annotationRouter.HandleFunc("", annotationHandler.CreateAnnotation).Methods("POST")
+	annotationRouter.HandleFunc("", annotationHandler.GetAnnotations).Methods("GET")
+	annotationRouter.HandleFunc("/{id}", annotationHandler.GetAnnotation).Methods("GET")
+	annotationRouter.HandleFunc("/{id}", annotationHandler.UpdateAnnotation).Methods("PUT")
+	annotationRouter.HandleFunc("/{id}", annotationHandler.DeleteAnnotation).Methods("DELETE")
+
+	// Comment routes - protected with JWT; comments are posted by users.
+	// Published comments are part of their policy's public responses.
+	commentRouter := router.PathPrefix("/api/comments").Subrouter()
+	commentRouter.Use(authenticate, apiLimit, privateCache, middleware.InvalidateCache(cache, "policies"))
+	commentRouter.HandleFunc("", commentHandler.CreateComment).Methods("POST")
+	commentRouter.HandleFunc("", commentHandler.GetComments).Methods("GET")
+	commentRouter.HandleFunc("/{id}", commentHandler.GetComment).Methods("GET")
+	commentRouter.HandleFunc("/{id}", commentHandler.UpdateComment).Methods("PUT")
+	commentRouter.HandleFunc("/{id}", commentHandler.DeleteComment).Methods("DELETE")
+	commentRouter.HandleFunc("/{id}/vote", commentHandler.VoteOnComment).Methods("POST")
+	commentRouter.HandleFunc("/{id}/report", commentHandler.ReportComment).Methods("POST")
+
+	// Moderation routes - protected with JWT; moderators only
+	moderationRouter := router.PathPrefix("/api/moderation").Subrouter()
+	moderationRouter.Use(authenticate, apiLimit, privateCache, middleware.InvalidateCache(cache, "policies"))
+	moderationRouter.HandleFunc("/comments", commentHandler.GetModerationQueue).Methods("GET")
+	moderationRouter.HandleFunc("/comments/{id}", commentHandler.ModerateComment).Methods("POST")
+	moderationRouter.HandleFunc("/bans/{user_id}", commentHandler.LiftBan).Methods("DELETE")
+
+	// Poll routes - protected with JWT; each user answers a policy's poll
+	// once. Results are part of policies' and representatives' public
+	// responses, which are invalidated with policies.
+	pollRouter := router.PathPrefix("/api/polls").Subrouter()
+	pollRouter.Use(authenticate, apiLimit, privateCache, middleware.InvalidateCache(cache, "policies"))
+	pollRouter.HandleFunc("/{id}", pollHandler.GetPoll).Methods("GET")
+	pollRouter.HandleFunc("/{id}/ballot", pollHandler.CastBallot).Methods("PUT")
+	pollRouter.HandleFunc("/{id}/ballot", pollHandler.WithdrawBallot).Methods("DELETE")
+
+	// Admin routes - administrators only, with a user's token. Restoring
+	// content invalidates the public responses it appears in.
+	adminRouter := router.PathPrefix("/api/admin").Subrouter()
+	adminRouter.Use(authenticate, apiLimit, middleware.RequireAdmin(store.Users), privateCache, middleware.InvalidateCache(cache, "policies", "representatives"))
+	adminRouter.HandleFunc("/trash", trashHandler.GetTrash).Methods("GET")
+	adminRouter.HandleFunc("/trash/policies/{id}/restore", trashHandler.RestorePolicy).Methods("POST")
+	adminRouter.HandleFunc("/trash/representatives/{id}/restore", trashHandler.RestoreRepresentative).Methods("POST")
+	adminRouter.HandleFunc("/trash/quizzes/{id}/restore", trashHandler.RestoreQuiz).Methods("POST")
+	adminRouter.HandleFunc("/audit", auditHandler.GetAudit).Methods("GET")
+	adminRouter.HandleFunc("/audit/verify", auditHandler.VerifyAudit).Methods("GET")
+}
+
+// rateLimit returns a middleware enforcing limit per client, or one that does
+// nothing if rate limiting is disabled
+func rateLimit(cfg config.RateLimitConfig, group string, limit ratelimit.Limit, key middleware.RateLimitKey) mux.MiddlewareFunc {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middleware.RateLimit(ratelimit.NewLimiter(limit), group, key)
+}
+
+// responseCache returns a middleware serving GET requests from cache, or one
+// that does nothing if the response cache is disabled
+func responseCache(cfg config.HTTPCacheConfig, cache *httpcache.Cache, groups ...string) mux.MiddlewareFunc {
+	if cfg.Entries <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middleware.ResponseCache(cache, groups...)
+}
+
+// instrument applies the router's middleware to handlers that mux calls
+// without matching a route
+func instrument(h http.Handler) http.Handler {
+	traced := otelmux.Middleware(tracing.ServiceName)
+	return traced(middleware.RequestID(middleware.Logging(middleware.Metrics(h))))
+}
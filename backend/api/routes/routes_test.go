@@ -0,0 +1,889 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/httpcache"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/api/routes"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/health"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+)
+
+var update = flag.Bool("update", false, "rewrite golden response snapshots in testdata/")
+
+// testServer is an API server backed by an in-memory store
+type testServer struct {
+	server  *httptest.Server
+	store   *repository.Store
+	checker *health.Checker
+	cache   *httpcache.Cache
+	token   string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWithStore(t, repository.NewMemoryStore())
+}
+
+func newTestServerWithStore(t *testing.T, store *repository.Store) *testServer {
+	t.Helper()
+	return newTestServerWithConfig(t, testConfig(), store)
+}
+
+// testConfig returns the configuration of test servers. Rate limiting is off
+// so that tests can make as many requests as they need.
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.RateLimit.Enabled = false
+	return cfg
+}
+
+func newTestServerWithConfig(t *testing.T, cfg *config.Config, store *repository.Store) *testServer {
+	t.Helper()
+
+	router := mux.NewRouter()
+	checker := health.NewChecker(health.DefaultTimeout)
+	cache := httpcache.New(cfg.HTTPCache.Entries, cfg.HTTPCache.TTL)
+	routes.SetupRoutes(router, cfg, store, checker, cache)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &testServer{server: server, store: store, checker: checker, cache: cache}
+}
+
+// response is a captured HTTP response
+type response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// do sends a request, authenticated with the server's token if one is set
+func (s *testServer) do(t *testing.T, method, path string, body interface{}) *response {
+	t.Helper()
+	return s.doWithToken(t, method, path, s.token, body)
+}
+
+func (s *testServer) doWithToken(t *testing.T, method, path, token string, body interface{}) *response {
+	t.Helper()
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return s.doWithHeader(t, method, path, header, body)
+}
+
+// doWithHeader sends a request with the given headers and no other credentials.
+// JSON bodies are sent as application/json unless header sets a Content-Type.
+func (s *testServer) doWithHeader(t *testing.T, method, path string, header http.Header, body interface{}) *response {
+	t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, s.server.URL+path, reader)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read response body: %v", err)
+	}
+	return &response{Status: resp.StatusCode, Header: resp.Header, Body: raw}
+}
+
+// login registers a user and authenticates subsequent requests as them
+func (s *testServer) login(t *testing.T, name, email string) map[string]interface{} {
+	t.Helper()
+
+	resp := s.doWithToken(t, "POST", "/api/auth/register", "", map[string]string{
+		"name": name, "email": email, "password": "correct horse battery staple",
+	})
+	expectStatus(t, resp, http.StatusCreated)
+
+	var auth struct {
+		Token string                 `json:"token"`
+		User  map[string]interface{} `json:"user"`
+	}
+	resp.decode(t, &auth)
+	s.token = auth.Token
+	return auth.User
+}
+
+func (r *response) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("decode response %q: %v", r.Body, err)
+	}
+}
+
+func expectStatus(t *testing.T, r *response, status int) {
+	t.Helper()
+	if r.Status != status {
+		t.Fatalf("status = %d, want %d; body: %s", r.Status, status, r.Body)
+	}
+}
+
+var (
+	objectIDPattern  = regexp.MustCompile(`^[0-9a-f]{24}$`)
+	timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+	jwtPattern       = regexp.MustCompile(`^[\w-]+\.[\w-]+\.[\w-]+$`)
+)
+
+// normalize replaces values that change between runs, such as generated IDs,
+// timestamps and tokens, with stable placeholders
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if key == "request_id" {
+				value[key] = "<request_id>"
+				continue
+			}
+			value[key] = normalize(child)
+		}
+		return value
+	case []interface{}:
+		for i, child := range value {
+			value[i] = normalize(child)
+		}
+		return value
+	case string:
+		switch {
+		case objectIDPattern.MatchString(value):
+			return "<id>"
+		case timestampPattern.MatchString(value):
+			if _, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return "<time>"
+			}
+		case jwtPattern.MatchString(value) && strings.HasPrefix(value, "eyJ"):
+			return "<token>"
+		}
+	}
+	return v
+}
+
+// snapshot renders a response as its status line, relevant headers and
+// normalized body
+func (r *response) snapshot() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP %d\n", r.Status)
+
+	var headers []string
+	for _, name := range []string{"Content-Type"} {
+		if value := r.Header.Get(name); value != "" {
+			headers = append(headers, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+	sort.Strings(headers)
+	for _, header := range headers {
+		buf.WriteString(header + "\n")
+	}
+	buf.WriteString("\n")
+
+	var body interface{}
+	if len(bytes.TrimSpace(r.Body)) > 0 && json.Unmarshal(r.Body, &body) == nil {
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(normalize(body))
+	} else {
+		buf.Write(r.Body)
+	}
+	return buf.Bytes()
+}
+
+// golden compares a response with testdata/<name>.golden, rewriting the file
+// instead when the -update flag is set
+func golden(t *testing.T, name string, r *response) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	got := r.snapshot()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response for %s does not match %s (run with -update if the change is intended)\n--- got ---\n%s\n--- want ---\n%s", name, path, got, want)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := s.do(t, "GET", "/api/health", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if string(resp.Body) != "OK" {
+		t.Errorf("body = %q, want OK", resp.Body)
+	}
+}
+
+func TestAuthFlow(t *testing.T) {
+	s := newTestServer(t)
+	register := map[string]string{"name": "Ada Lovelace", "email": "ada@example.com", "password": "analytical engine"}
+
+	resp := s.do(t, "POST", "/api/auth/register", register)
+	golden(t, "auth/register", resp)
+	expectStatus(t, resp, http.StatusCreated)
+
+	var auth struct {
+		Token string `json:"token"`
+		User  struct {
+			Password string `json:"password"`
+		} `json:"user"`
+	}
+	resp.decode(t, &auth)
+	if auth.Token == "" {
+		t.Fatal("register returned no token")
+	}
+	if auth.User.Password != "" {
+		t.Error("register response leaked the password hash")
+	}
+
+	stored, err := s.store.Users.FindByEmail(context.Background(), "ada@example.com")
+	if err != nil {
+		t.Fatalf("registered user not stored: %v", err)
+	}
+	if stored.Password == register["password"] {
+		t.Error("password stored in plain text")
+	}
+
+	t.Run("duplicate email", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/auth/register", register)
+		golden(t, "auth/register_duplicate", resp)
+		expectStatus(t, resp, http.StatusConflict)
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/auth/register", map[string]string{"email": "bob@example.com"})
+		golden(t, "auth/register_missing_fields", resp)
+		expectStatus(t, resp, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/auth/register", "{not json")
+		golden(t, "auth/register_malformed", resp)
+		expectStatus(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("login", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/auth/login", map[string]string{"email": "ada@example.com", "password": "analytical engine"})
+		golden(t, "auth/login", resp)
+		expectStatus(t, resp, http.StatusOK)
+
+		var login struct {
+			Token string `json:"token"`
+		}
+		resp.decode(t, &login)
+
+		// The issued token grants access to protected routes
+		resp = s.doWithToken(t, "GET", "/api/users", login.Token, nil)
+		expectStatus(t, resp, http.StatusOK)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/auth/login", map[string]string{"email": "ada@example.com", "password": "difference engine"})
+		golden(t, "auth/login_wrong_password", resp)
+		expectStatus(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/auth/login", map[string]string{"email": "nobody@example.com", "password": "analytical engine"})
+		golden(t, "auth/login_unknown_email", resp)
+		expectStatus(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/auth/login", "[]")
+		golden(t, "auth/login_malformed", resp)
+		expectStatus(t, resp, http.StatusBadRequest)
+	})
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	s := newTestServer(t)
+	const id = "5f8d0d55b54764421b7156c9"
+
+	protected := []struct{ method, path string }{
+		{"GET", "/api/users"},
+		{"POST", "/api/users"},
+		{"GET", "/api/users/" + id},
+		{"PUT", "/api/users/" + id},
+		{"DELETE", "/api/users/" + id},
+		{"GET", "/api/policies"},
+		{"POST", "/api/policies"},
+		{"GET", "/api/policies/" + id},
+		{"GET", "/api/policies/" + id + "/sections"},
+		{"GET", "/api/policies/" + id + "/sections/sec-1"},
+		{"PUT", "/api/policies/" + id},
+		{"DELETE", "/api/policies/" + id},
+		{"GET", "/api/policies/location/here?state=CA"},
+		{"GET", "/api/representatives"},
+		{"POST", "/api/representatives"},
+		{"GET", "/api/representatives/" + id},
+		{"PUT", "/api/representatives/" + id},
+		{"DELETE", "/api/representatives/" + id},
+		{"GET", "/api/representatives/" + id + "/votes"},
+		{"GET", "/api/quizzes"},
+		{"POST", "/api/quizzes"},
+		{"GET", "/api/quizzes/" + id},
+		{"PUT", "/api/quizzes/" + id},
+		{"DELETE", "/api/quizzes/" + id},
+		{"POST", "/api/quizzes/" + id + "/submit"},
+		{"GET", "/api/quizzes/results/" + id},
+		{"GET", "/api/quizzes/user/" + id + "/results"},
+		{"GET", "/api/summaries"},
+		{"POST", "/api/summaries"},
+		{"POST", "/api/summaries/generate"},
+		{"POST", "/api/summaries/" + id + "/approve"},
+		{"GET", "/api/annotations?policy_id=" + id},
+		{"POST", "/api/annotations"},
+		{"GET", "/api/annotations/" + id},
+		{"PUT", "/api/annotations/" + id},
+		{"DELETE", "/api/annotations/" + id},
+		{"GET", "/api/comments?policy_id=" + id},
+		{"POST", "/api/comments"},
+		{"GET", "/api/comments/" + id},
+		{"PUT", "/api/comments/" + id},
+		{"DELETE", "/api/comments/" + id},
+		{"POST", "/api/comments/" + id + "/vote"},
+		{"POST", "/api/comments/" + id + "/report"},
+		{"GET", "/api/moderation/comments"},
+		{"POST", "/api/moderation/comments/" + id},
+		{"DELETE", "/api/moderation/bans/" + id},
+		{"GET", "/api/polls/" + id},
+		{"PUT", "/api/polls/" + id + "/ballot"},
+		{"DELETE", "/api/polls/" + id + "/ballot"},
+		{"GET", "/api/representatives/" + id + "/alignment"},
+	}
+
+	for _, route := range protected {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			resp := s.do(t, route.method, route.path, nil)
+			expectStatus(t, resp, http.StatusUnauthorized)
+		})
+	}
+
+	t.Run("missing header", func(t *testing.T) {
+		golden(t, "auth/missing_header", s.do(t, "GET", "/api/users", nil))
+	})
+
+	t.Run("not a bearer token", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", s.server.URL+"/api/users", nil)
+		req.Header.Set("Authorization", "Basic YWRhOnNlY3JldA==")
+		resp, err := s.server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		resp := s.doWithToken(t, "GET", "/api/users", "eyJhbGciOiJIUzI1NiJ9.e30.invalid", nil)
+		golden(t, "auth/invalid_token", resp)
+		expectStatus(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("token signed with another secret", func(t *testing.T) {
+		user := s.login(t, "Grace Hopper", "grace@example.com")
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"userId": user["id"],
+			"exp":    time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("another-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp := s.doWithToken(t, "GET", "/api/users", token, nil)
+		expectStatus(t, resp, http.StatusUnauthorized)
+	})
+}
+
+func TestPublicRoutesDoNotRequireToken(t *testing.T) {
+	s := newTestServer(t)
+	user := s.login(t, "Ada Lovelace", "ada@example.com")
+
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z"))
+	rep := createResource(t, s, "/api/representatives", sampleRepresentative())
+	quiz := createResource(t, s, "/api/quizzes", sampleQuiz(nil))
+	s.token = ""
+
+	public := []string{
+		"/api/public/users/" + user["id"].(string),
+		"/api/public/policies",
+		"/api/public/policies/" + policy["id"].(string),
+		"/api/public/policies/" + policy["id"].(string) + "/sections",
+		"/api/public/policies/" + policy["id"].(string) + "/sections/sec-1",
+		"/api/public/policies/" + policy["id"].(string) + "/annotations",
+		"/api/public/policies/" + policy["id"].(string) + "/comments",
+		"/api/public/policies/" + policy["id"].(string) + "/poll",
+		"/api/public/policies/location/here?state=CA",
+		"/api/public/representatives",
+		"/api/public/representatives/" + rep["id"].(string),
+		"/api/public/representatives/" + rep["id"].(string) + "/votes",
+		"/api/public/representatives/" + rep["id"].(string) + "/alignment",
+		"/api/public/quizzes",
+		"/api/public/quizzes/" + quiz["id"].(string),
+	}
+	for _, path := range public {
+		t.Run(path, func(t *testing.T) {
+			expectStatus(t, s.do(t, "GET", path, nil), http.StatusOK)
+		})
+	}
+
+	t.Run("public routes are read-only", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/public/policies", samplePolicy("Sneaky", "2024-01-01T00:00:00Z"))
+		if resp.Status < http.StatusBadRequest {
+			t.Errorf("status = %d, want an error status", resp.Status)
+		}
+	})
+}
+
+// createResource POSTs body to path, expecting 201, and returns the decoded result
+func createResource(t *testing.T, s *testServer, path string, body interface{}) map[string]interface{} {
+	t.Helper()
+	resp := s.do(t, "POST", path, body)
+	expectStatus(t, resp, http.StatusCreated)
+
+	var created map[string]interface{}
+	resp.decode(t, &created)
+	if id, _ := created["id"].(string); id == "" {
+		t.Fatalf("created resource has no id: %s", resp.Body)
+	}
+	return created
+}
+
+func samplePolicy(title, introduced string) map[string]interface{} {
+	return map[string]interface{}{
+		"title":           title,
+		"description":     "An act to protect drinking water sources.",
+		"original_text":   "Section 1. Short title.",
+		"status":          "proposed",
+		"introduced_date": introduced,
+		"type":            "bill",
+		"level":           "state",
+		"jurisdiction":    map[string]string{"country": "US", "state": "CA", "city": "Sacramento"},
+		"tags":            []string{"environment"},
+		"sources":         []map[string]string{{"url": "https://leginfo.example.gov/bill/1", "title": "Bill text"}},
+	}
+}
+
+func sampleRepresentative() map[string]interface{} {
+	return map[string]interface{}{
+		"name":       "Jordan Rivera",
+		"title":      "Senator",
+		"party":      "Independent",
+		"state":      "CA",
+		"district":   "12",
+		"level":      "state",
+		"term_start": "2023-01-03T00:00:00Z",
+		"term_end":   "2027-01-03T00:00:00Z",
+		"contact_info": map[string]string{
+			"email":   "rivera@senate.example.gov",
+			"website": "https://senate.example.gov/rivera",
+		},
+	}
+}
+
+func sampleQuiz(stances []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"title":       "Where do you stand?",
+		"description": "A short quiz on current issues.",
+		"categories":  []string{"economic", "environment"},
+		"version":     "1",
+		"questions": []map[string]interface{}{
+			{
+				"text":                   "The minimum wage should be raised.",
+				"category":               "economic",
+				"is_likert_scale":        true,
+				"representative_stances": stances,
+			},
+			{
+				"text":            "Carbon emissions should be taxed.",
+				"category":        "environment",
+				"is_likert_scale": true,
+			},
+		},
+	}
+}
+
+func TestUserCRUD(t *testing.T) {
+	s := newTestServer(t)
+	self := s.login(t, "Ada Lovelace", "ada@example.com")
+	selfID := self["id"].(string)
+
+	resp := s.do(t, "POST", "/api/users", map[string]interface{}{
+		"name":     "Charles Babbage",
+		"email":    "charles@example.com",
+		"password": "difference engine",
+		"location": map[string]interface{}{"city": "London", "state": "LDN", "zip_code": "SW1A"},
+	})
+	golden(t, "users/create", resp)
+	expectStatus(t, resp, http.StatusCreated)
+	var created map[string]interface{}
+	resp.decode(t, &created)
+	id := created["id"].(string)
+
+	golden(t, "users/list", s.do(t, "GET", "/api/users", nil))
+	golden(t, "users/get", s.do(t, "GET", "/api/users/"+id, nil))
+	golden(t, "users/get_public", s.do(t, "GET", "/api/public/users/"+selfID, nil))
+
+	// Administrators manage other users' accounts
+	promote(t, s, "ada@example.com", models.RoleAdmin)
+	resp = s.do(t, "PUT", "/api/users/"+id, map[string]interface{}{
+		"name":     "Charles Babbage",
+		"email":    "charles@example.com",
+		"location": map[string]interface{}{"city": "Cambridge", "state": "CAM", "zip_code": "CB2"},
+	})
+	golden(t, "users/update", resp)
+	expectStatus(t, resp, http.StatusOK)
+
+	t.Run("duplicate email", func(t *testing.T) {
+		resp := s.do(t, "PUT", "/api/users/"+id, map[string]interface{}{"name": "Charles", "email": "ada@example.com"})
+		golden(t, "users/update_duplicate_email", resp)
+		expectStatus(t, resp, http.StatusConflict)
+	})
+
+	resp = s.do(t, "DELETE", "/api/users/"+id, nil)
+	golden(t, "users/delete", resp)
+	expectStatus(t, resp, http.StatusOK)
+
+	t.Run("errors", func(t *testing.T) {
+		golden(t, "users/get_invalid_id", s.do(t, "GET", "/api/users/not-an-id", nil))
+		golden(t, "users/get_not_found", s.do(t, "GET", "/api/users/"+id, nil))
+		golden(t, "users/update_not_found", s.do(t, "PUT", "/api/users/"+id, map[string]string{"name": "Ghost", "email": "ghost@example.com"}))
+		golden(t, "users/delete_not_found", s.do(t, "DELETE", "/api/users/"+id, nil))
+		expectStatus(t, s.do(t, "POST", "/api/users", "{"), http.StatusBadRequest)
+		expectStatus(t, s.do(t, "PUT", "/api/users/"+selfID, "{"), http.StatusBadRequest)
+	})
+}
+
+func TestUserOwnership(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login(t, "Ada Lovelace", "ada@example.com")
+	adminToken := s.token
+	promote(t, s, "ada@example.com", models.RoleAdmin)
+	grace := s.login(t, "Grace Hopper", "grace@example.com")
+	graceToken := s.token
+	mallory := s.login(t, "Mallory", "mallory@example.com")
+	graceID := grace["id"].(string)
+
+	t.Run("users cannot change other accounts", func(t *testing.T) {
+		resp := s.do(t, "PUT", "/api/users/"+graceID, map[string]interface{}{
+			"name": "Grace Hopper", "email": "mallory@evil.example", "password": "stolen account",
+		})
+		decodeError(t, resp, http.StatusForbidden, "forbidden")
+		decodeError(t, s.do(t, "PUT", "/api/users/"+admin["id"].(string), map[string]interface{}{
+			"name": "Ada Lovelace", "email": "ada@example.com", "password": "stolen account",
+		}), http.StatusForbidden, "forbidden")
+		decodeError(t, s.do(t, "DELETE", "/api/users/"+graceID, nil), http.StatusForbidden, "forbidden")
+		expectStatus(t, s.doWithToken(t, "GET", "/api/users/"+graceID, graceToken, nil), http.StatusOK)
+	})
+
+	t.Run("owners change their own account", func(t *testing.T) {
+		resp := s.doWithToken(t, "PUT", "/api/users/"+graceID, graceToken, map[string]interface{}{
+			"name": "Grace Brewster Hopper", "email": "grace@example.com",
+		})
+		expectStatus(t, resp, http.StatusOK)
+	})
+
+	t.Run("administrators change any account", func(t *testing.T) {
+		resp := s.doWithToken(t, "PUT", "/api/users/"+graceID, adminToken, map[string]interface{}{
+			"name": "Grace Hopper", "email": "grace@example.com",
+		})
+		expectStatus(t, resp, http.StatusOK)
+		expectStatus(t, s.doWithToken(t, "DELETE", "/api/users/"+mallory["id"].(string), adminToken, nil), http.StatusOK)
+	})
+}
+
+func TestPolicyCRUD(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	resp := s.do(t, "POST", "/api/policies", samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z"))
+	golden(t, "policies/create", resp)
+	expectStatus(t, resp, http.StatusCreated)
+	var created map[string]interface{}
+	resp.decode(t, &created)
+	id := created["id"].(string)
+
+	older := samplePolicy("Transit Funding Act", "2023-06-15T00:00:00Z")
+	older["level"] = "local"
+	older["jurisdiction"] = map[string]string{"country": "US", "state": "NY", "city": "New York"}
+	createResource(t, s, "/api/policies", older)
+
+	t.Run("list newest first", func(t *testing.T) {
+		resp := s.do(t, "GET", "/api/policies", nil)
+		golden(t, "policies/list", resp)
+
+		var policies []struct {
+			Title string `json:"title"`
+		}
+		resp.decode(t, &policies)
+		if len(policies) != 2 || policies[0].Title != "Clean Water Act" {
+			t.Errorf("policies = %+v, want Clean Water Act first", policies)
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		for query, want := range map[string]int{
+			"level=state":                   1,
+			"level=federal":                 0,
+			"state=NY":                      1,
+			"state=CA&city=Sacramento":      1,
+			"status=proposed&type=bill":     2,
+			"status=passed":                 0,
+			"level=local&state=NY&type=law": 0,
+		} {
+			var policies []interface{}
+			s.do(t, "GET", "/api/public/policies?"+query, nil).decode(t, &policies)
+			if len(policies) != want {
+				t.Errorf("GET ?%s returned %d policies, want %d", query, len(policies), want)
+			}
+		}
+	})
+
+	t.Run("by location", func(t *testing.T) {
+		golden(t, "policies/by_location", s.do(t, "GET", "/api/policies/location/here?state=NY", nil))
+		golden(t, "policies/by_location_missing_state", s.do(t, "GET", "/api/public/policies/location/here", nil))
+	})
+
+	update := samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z")
+	update["status"] = "passed"
+	resp = s.do(t, "PUT", "/api/policies/"+id, update)
+	golden(t, "policies/update", resp)
+	expectStatus(t, resp, http.StatusOK)
+
+	golden(t, "policies/get", s.do(t, "GET", "/api/public/policies/"+id, nil))
+
+	resp = s.do(t, "DELETE", "/api/policies/"+id, nil)
+	golden(t, "policies/delete", resp)
+	expectStatus(t, resp, http.StatusOK)
+
+	t.Run("errors", func(t *testing.T) {
+		golden(t, "policies/get_invalid_id", s.do(t, "GET", "/api/policies/123", nil))
+		golden(t, "policies/get_not_found", s.do(t, "GET", "/api/policies/"+id, nil))
+		golden(t, "policies/update_not_found", s.do(t, "PUT", "/api/policies/"+id, update))
+		golden(t, "policies/delete_not_found", s.do(t, "DELETE", "/api/policies/"+id, nil))
+		expectStatus(t, s.do(t, "POST", "/api/policies", "nope"), http.StatusBadRequest)
+		expectStatus(t, s.do(t, "PUT", "/api/policies/nope", update), http.StatusBadRequest)
+		expectStatus(t, s.do(t, "DELETE", "/api/policies/nope", nil), http.StatusBadRequest)
+	})
+}
+
+func TestRepresentativeCRUD(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	resp := s.do(t, "POST", "/api/representatives", sampleRepresentative())
+	golden(t, "representatives/create", resp)
+	expectStatus(t, resp, http.StatusCreated)
+	var created map[string]interface{}
+	resp.decode(t, &created)
+	id := created["id"].(string)
+
+	other := sampleRepresentative()
+	other["name"] = "Sam Lee"
+	other["party"] = "Green"
+	other["state"] = "OR"
+	createResource(t, s, "/api/representatives", other)
+
+	golden(t, "representatives/list", s.do(t, "GET", "/api/representatives", nil))
+	golden(t, "representatives/list_filtered", s.do(t, "GET", "/api/public/representatives?state=OR&party=Green", nil))
+
+	var limited []interface{}
+	s.do(t, "GET", "/api/representatives?limit=1", nil).decode(t, &limited)
+	if len(limited) != 1 {
+		t.Errorf("limit=1 returned %d representatives", len(limited))
+	}
+
+	t.Run("votes", func(t *testing.T) {
+		policy := samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z")
+		policy["voting_record"] = []map[string]interface{}{
+			{"representative_id": id, "vote": "yes", "date": "2024-04-01T00:00:00Z"},
+			{"representative_id": "5f8d0d55b54764421b7156c9", "vote": "no", "date": "2024-04-01T00:00:00Z"},
+		}
+		createResource(t, s, "/api/policies", policy)
+		createResource(t, s, "/api/policies", samplePolicy("Unrelated Act", "2024-02-01T00:00:00Z"))
+
+		resp := s.do(t, "GET", "/api/public/representatives/"+id+"/votes", nil)
+		golden(t, "representatives/votes", resp)
+
+		var votes []struct {
+			Title string `json:"title"`
+			Vote  struct {
+				Vote string `json:"vote"`
+			} `json:"vote"`
+		}
+		resp.decode(t, &votes)
+		if len(votes) != 1 || votes[0].Title != "Clean Water Act" || votes[0].Vote.Vote != "yes" {
+			t.Errorf("votes = %+v, want one yes vote on Clean Water Act", votes)
+		}
+	})
+
+	update := sampleRepresentative()
+	update["party"] = "Democratic"
+	resp = s.do(t, "PUT", "/api/representatives/"+id, update)
+	golden(t, "representatives/update", resp)
+	expectStatus(t, resp, http.StatusOK)
+
+	golden(t, "representatives/get", s.do(t, "GET", "/api/public/representatives/"+id, nil))
+
+	resp = s.do(t, "DELETE", "/api/representatives/"+id, nil)
+	golden(t, "representatives/delete", resp)
+	expectStatus(t, resp, http.StatusNoContent)
+
+	t.Run("errors", func(t *testing.T) {
+		golden(t, "representatives/get_invalid_id", s.do(t, "GET", "/api/representatives/xyz", nil))
+		golden(t, "representatives/get_not_found", s.do(t, "GET", "/api/representatives/"+id, nil))
+		golden(t, "representatives/votes_not_found", s.do(t, "GET", "/api/representatives/"+id+"/votes", nil))
+		golden(t, "representatives/update_not_found", s.do(t, "PUT", "/api/representatives/"+id, update))
+		golden(t, "representatives/delete_not_found", s.do(t, "DELETE", "/api/representatives/"+id, nil))
+		expectStatus(t, s.do(t, "POST", "/api/representatives", "{]"), http.StatusBadRequest)
+	})
+}
+
+func TestQuizCRUDAndResults(t *testing.T) {
+	s := newTestServer(t)
+	user := s.login(t, "Ada Lovelace", "ada@example.com")
+	userID := user["id"].(string)
+	rep := createResource(t, s, "/api/representatives", sampleRepresentative())
+
+	resp := s.do(t, "POST", "/api/quizzes", sampleQuiz([]map[string]interface{}{
+		{"representative_id": rep["id"], "stance": 5, "source": "Voting record"},
+	}))
+	golden(t, "quizzes/create", resp)
+	expectStatus(t, resp, http.StatusCreated)
+
+	var quiz struct {
+		ID        string `json:"id"`
+		Questions []struct {
+			ID string `json:"id"`
+		} `json:"questions"`
+	}
+	resp.decode(t, &quiz)
+	if len(quiz.Questions) != 2 || quiz.Questions[0].ID == "" {
+		t.Fatalf("questions were not assigned IDs: %s", resp.Body)
+	}
+
+	golden(t, "quizzes/list", s.do(t, "GET", "/api/quizzes", nil))
+	golden(t, "quizzes/list_by_category", s.do(t, "GET", "/api/public/quizzes?category=foreign", nil))
+	golden(t, "quizzes/get", s.do(t, "GET", "/api/public/quizzes/"+quiz.ID, nil))
+
+	t.Run("submit", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/quizzes/"+quiz.ID+"/submit", map[string]interface{}{
+			"responses": []map[string]interface{}{
+				{"question_id": quiz.Questions[0].ID, "answer": 4},
+				{"question_id": quiz.Questions[1].ID, "answer": 2},
+			},
+		})
+		golden(t, "quizzes/submit", resp)
+		expectStatus(t, resp, http.StatusCreated)
+
+		var result struct {
+			ID         string             `json:"id"`
+			UserID     string             `json:"user_id"`
+			Categories map[string]float64 `json:"categories"`
+			Alignment  []struct {
+				OverallScore float64 `json:"overall_score"`
+			} `json:"representative_alignment"`
+		}
+		resp.decode(t, &result)
+		if result.UserID != userID {
+			t.Errorf("user_id = %q, want the authenticated user %q", result.UserID, userID)
+		}
+		if result.Categories["economic"] != 4 || result.Categories["environment"] != 2 {
+			t.Errorf("categories = %v", result.Categories)
+		}
+		if len(result.Alignment) != 1 || result.Alignment[0].OverallScore != 75 {
+			t.Errorf("alignment = %+v, want a single 75%% match", result.Alignment)
+		}
+
+		golden(t, "quizzes/result", s.do(t, "GET", "/api/quizzes/results/"+result.ID, nil))
+		golden(t, "quizzes/user_results", s.do(t, "GET", "/api/quizzes/user/"+userID+"/results", nil))
+	})
+
+	update := sampleQuiz(nil)
+	update["title"] = "Where do you stand now?"
+	resp = s.do(t, "PUT", "/api/quizzes/"+quiz.ID, update)
+	golden(t, "quizzes/update", resp)
+	expectStatus(t, resp, http.StatusOK)
+
+	resp = s.do(t, "DELETE", "/api/quizzes/"+quiz.ID, nil)
+	golden(t, "quizzes/delete", resp)
+	expectStatus(t, resp, http.StatusNoContent)
+
+	t.Run("errors", func(t *testing.T) {
+		const missing = "5f8d0d55b54764421b7156c9"
+		golden(t, "quizzes/get_invalid_id", s.do(t, "GET", "/api/quizzes/abc", nil))
+		golden(t, "quizzes/get_not_found", s.do(t, "GET", "/api/quizzes/"+quiz.ID, nil))
+		golden(t, "quizzes/update_not_found", s.do(t, "PUT", "/api/quizzes/"+quiz.ID, update))
+		golden(t, "quizzes/delete_not_found", s.do(t, "DELETE", "/api/quizzes/"+quiz.ID, nil))
+		golden(t, "quizzes/submit_not_found", s.do(t, "POST", "/api/quizzes/"+quiz.ID+"/submit", map[string]interface{}{
+			"responses": []map[string]interface{}{{"question_id": quiz.Questions[0].ID, "answer": 3}},
+		}))
+		golden(t, "quizzes/result_not_found", s.do(t, "GET", "/api/quizzes/results/"+missing, nil))
+		golden(t, "quizzes/user_results_not_found", s.do(t, "GET", "/api/quizzes/user/"+missing+"/results", nil))
+		expectStatus(t, s.do(t, "POST", "/api/quizzes/"+quiz.ID+"/submit", "oops"), http.StatusBadRequest)
+		expectStatus(t, s.do(t, "GET", "/api/quizzes/results/abc", nil), http.StatusBadRequest)
+		expectStatus(t, s.do(t, "GET", "/api/quizzes/user/abc/results", nil), http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,63 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/models"
+)
+
+const billText = `A BILL To test the drinking water of schools.
+
+SEC. 1. SHORT TITLE.
+This Act may be cited as the Safe School Water Act.
+SEC. 2. TESTING.
+(a) In General.—Each school shall test its water—
+  (1) every year; and
+  (2) after any repair described in subsection (b).
+(b) Repairs.—A school that fails a test under subsection (a) shall replace its fixtures.`
+
+func TestPolicySections(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	bill := samplePolicy("Safe School Water Act", "2024-01-01T00:00:00Z")
+	bill["original_text"] = billText
+	policy := createResource(t, s, "/api/policies", bill)
+	base := "/api/public/policies/" + policy["id"].(string) + "/sections"
+
+	resp := s.do(t, "GET", base+"/sec-2-a-2", nil)
+	golden(t, "policies/section", resp)
+	expectStatus(t, resp, http.StatusOK)
+	var section models.PolicySection
+	resp.decode(t, &section)
+	if section.Citation != "Safe School Water Act § 2(a)(2)" || len(section.Path) != 2 || section.Path[1].Anchor != "sec-2-a" {
+		t.Errorf("section = %+v, want paragraph (2) of section 2(a)", section)
+	}
+	if len(section.References) != 1 || section.References[0].Anchor != "sec-2-b" {
+		t.Errorf("references = %+v, want subsection (b) resolved", section.References)
+	}
+
+	var sections []models.Provision
+	resp = s.do(t, "GET", base, nil)
+	expectStatus(t, resp, http.StatusOK)
+	resp.decode(t, &sections)
+	if len(sections) != 2 || sections[1].Heading != "TESTING" || len(sections[1].Children) != 2 {
+		t.Errorf("sections = %+v, want the two sections of the bill", sections)
+	}
+
+	decodeError(t, s.do(t, "GET", base+"/sec-9", nil), http.StatusNotFound, "not_found")
+	decodeError(t, s.do(t, "GET", "/api/public/policies/64b7f0f0f0f0f0f0f0f0f0f0/sections/sec-1", nil), http.StatusNotFound, "not_found")
+
+	t.Run("sections follow the original text", func(t *testing.T) {
+		// Sections sent by clients are ignored
+		resp := s.mergePatch(t, "/api/policies/"+policy["id"].(string),
+			map[string]interface{}{"original_text": "SEC. 1. TESTING.\nEach school shall test its water.", "sections": []interface{}{}})
+		expectStatus(t, resp, http.StatusOK)
+		var patched models.Policy
+		resp.decode(t, &patched)
+		if len(patched.Sections) != 1 || patched.Sections[0].Text != "Each school shall test its water." {
+			t.Errorf("sections = %+v, want them parsed from the new text", patched.Sections)
+		}
+		decodeError(t, s.do(t, "GET", base+"/sec-2-a-2", nil), http.StatusNotFound, "not_found")
+	})
+}
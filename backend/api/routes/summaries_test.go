@@ -0,0 +1,376 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+// reviewer registers an editor with a declared affiliation and returns their
+// ID and token, leaving the server's token unchanged
+func reviewer(t *testing.T, s *testServer, name, email, affiliation string) (string, string) {
+	t.Helper()
+	token := s.token
+	user := s.login(t, name, email)
+	editorToken := s.token
+	s.token = token
+
+	stored, err := s.store.Users.FindByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	stored.Role, stored.Affiliation = models.RoleEditor, affiliation
+	if err := s.store.Users.Update(context.Background(), stored); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	return user["id"].(string), editorToken
+}
+
+// reviewStep posts to a summary's review endpoint with token, expecting status
+func reviewStep(t *testing.T, s *testServer, token, path string, body interface{}, status int) models.Summary {
+	t.Helper()
+	resp := s.doWithToken(t, "POST", path, token, body)
+	expectStatus(t, resp, status)
+
+	var summary models.Summary
+	if status == http.StatusOK {
+		resp.decode(t, &summary)
+	}
+	return summary
+}
+
+func TestSummaryReview(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	author := s.token
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-01-01T00:00:00Z"))
+	policyID := policy["id"].(string)
+
+	demID, dem := reviewer(t, s, "Grace Hopper", "grace@example.com", "Democratic")
+	repID, rep := reviewer(t, s, "Alan Turing", "alan@example.com", "Republican")
+	_, dem2 := reviewer(t, s, "Edsger Dijkstra", "edsger@example.com", " democratic ")
+
+	resp := s.do(t, "POST", "/api/summaries", map[string]interface{}{"policy_id": policyID, "text": "Keeps tap water safe."})
+	expectStatus(t, resp, http.StatusCreated)
+	var summary models.Summary
+	resp.decode(t, &summary)
+	path := "/api/summaries/" + summary.ID.Hex()
+	if summary.Status != models.SummaryDraft || summary.AuthorID == nil {
+		t.Fatalf("summary = %+v, want a draft with an author", summary)
+	}
+
+	t.Run("drafts are edited by their author", func(t *testing.T) {
+		resp := s.do(t, "PUT", path, map[string]interface{}{"text": "Protects the sources of tap water."})
+		expectStatus(t, resp, http.StatusOK)
+		s.login(t, "Linus Torvalds", "linus@example.com")
+		decodeError(t, s.do(t, "PUT", path, map[string]interface{}{"text": "Bad for business."}), http.StatusForbidden, "forbidden")
+		s.token = author
+	})
+
+	t.Run("reviewers are editors with an affiliation", func(t *testing.T) {
+		ada, _ := s.store.Users.FindByEmail(context.Background(), "ada@example.com")
+		decodeError(t, s.do(t, "POST", path+"/reviewers", map[string]interface{}{"reviewers": []string{demID}}), http.StatusForbidden, "forbidden")
+		resp := s.doWithToken(t, "POST", path+"/reviewers", dem, map[string]interface{}{"reviewers": []string{ada.ID.Hex()}})
+		expectFieldErrors(t, resp, "reviewers[0]")
+
+		summary := reviewStep(t, s, dem, path+"/reviewers", map[string]interface{}{"reviewers": []string{demID, repID}}, http.StatusOK)
+		if len(summary.Reviewers) != 2 {
+			t.Errorf("reviewers = %v, want 2", summary.Reviewers)
+		}
+	})
+
+	t.Run("only summaries under review are approved", func(t *testing.T) {
+		decodeError(t, s.doWithToken(t, "POST", path+"/approve", dem, nil), http.StatusConflict, "conflict")
+		reviewStep(t, s, author, path+"/submit", nil, http.StatusOK)
+		decodeError(t, s.do(t, "PUT", path, map[string]interface{}{"text": "Changed under review."}), http.StatusConflict, "conflict")
+	})
+
+	t.Run("approvals need different affiliations", func(t *testing.T) {
+		decodeError(t, s.doWithToken(t, "POST", path+"/approve", dem2, nil), http.StatusForbidden, "forbidden")
+		summary := reviewStep(t, s, dem, path+"/approve", nil, http.StatusOK)
+		if summary.Status != models.SummaryInReview {
+			t.Fatalf("status = %s after one approval, want in_review", summary.Status)
+		}
+		decodeError(t, s.doWithToken(t, "POST", path+"/approve", dem, nil), http.StatusConflict, "conflict")
+		decodeError(t, s.doWithToken(t, "POST", path+"/publish", dem, nil), http.StatusConflict, "conflict")
+	})
+
+	t.Run("requesting changes clears approvals", func(t *testing.T) {
+		summary := reviewStep(t, s, rep, path+"/request-changes", map[string]interface{}{"body": "Mention the cost to water utilities."}, http.StatusOK)
+		if summary.Status != models.SummaryDraft || len(summary.Approvals) != 0 || len(summary.Comments) != 1 {
+			t.Fatalf("summary = %+v, want a draft with no approvals and a comment", summary)
+		}
+		resp := s.do(t, "PUT", path, map[string]interface{}{"text": "Protects the sources of tap water, at a cost to utilities."})
+		expectStatus(t, resp, http.StatusOK)
+		reviewStep(t, s, author, path+"/comments", map[string]interface{}{"body": "Added the cost."}, http.StatusOK)
+		reviewStep(t, s, author, path+"/submit", nil, http.StatusOK)
+	})
+
+	t.Run("publishing sets the policy's description", func(t *testing.T) {
+		reviewStep(t, s, dem, path+"/approve", nil, http.StatusOK)
+		summary := reviewStep(t, s, rep, path+"/approve", nil, http.StatusOK)
+		if summary.Status != models.SummaryApproved {
+			t.Fatalf("status = %s after approvals from two affiliations, want approved", summary.Status)
+		}
+
+		decodeError(t, s.do(t, "POST", path+"/publish", nil), http.StatusForbidden, "forbidden")
+		summary = reviewStep(t, s, dem2, path+"/publish", nil, http.StatusOK)
+		if summary.Status != models.SummaryPublished || summary.PublishedBy == nil {
+			t.Fatalf("summary = %+v, want it published with its publisher", summary)
+		}
+
+		var published models.Policy
+		resp := s.doWithToken(t, "GET", "/api/public/policies/"+policyID, "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		resp.decode(t, &published)
+		if published.SimplifiedDesc != summary.Text || published.Summary == nil || published.Summary.ID != summary.ID {
+			t.Fatalf("policy = %q with %+v, want the published summary", published.SimplifiedDesc, published.Summary)
+		}
+		if len(published.Summary.ApprovedBy) != 2 || published.Summary.PublishedBy != *summary.PublishedBy {
+			t.Errorf("published summary = %+v, want two approvers and the publisher", published.Summary)
+		}
+	})
+
+	t.Run("a new summary supersedes the published one", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/summaries", map[string]interface{}{"policy_id": policyID, "text": "Makes tap water cleaner."})
+		expectStatus(t, resp, http.StatusCreated)
+		var next models.Summary
+		resp.decode(t, &next)
+		nextPath := "/api/summaries/" + next.ID.Hex()
+
+		reviewStep(t, s, dem, nextPath+"/reviewers", map[string]interface{}{"reviewers": []string{demID, repID}}, http.StatusOK)
+		reviewStep(t, s, author, nextPath+"/submit", nil, http.StatusOK)
+		reviewStep(t, s, dem, nextPath+"/approve", nil, http.StatusOK)
+		reviewStep(t, s, rep, nextPath+"/approve", nil, http.StatusOK)
+		reviewStep(t, s, rep, nextPath+"/publish", nil, http.StatusOK)
+
+		var summaries []models.Summary
+		resp = s.do(t, "GET", "/api/summaries?policy_id="+policyID+"&status=superseded", nil)
+		expectStatus(t, resp, http.StatusOK)
+		resp.decode(t, &summaries)
+		if len(summaries) != 1 || summaries[0].ID != summary.ID {
+			t.Errorf("superseded summaries = %+v, want the first one", summaries)
+		}
+		decodeError(t, s.do(t, "GET", "/api/summaries?status=rejected", nil), http.StatusBadRequest, "invalid_parameter")
+	})
+}
+
+func TestSimplifiedDescIsReadOnly(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	described := samplePolicy("Clean Water Act", "2024-01-01T00:00:00Z")
+	described["simplified_desc"] = "Keeps tap water safe."
+	expectFieldErrors(t, s.do(t, "POST", "/api/policies", described), "simplified_desc")
+
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-01-01T00:00:00Z"))
+	path := "/api/policies/" + policy["id"].(string)
+	expectFieldErrors(t, s.do(t, "PUT", path, described), "simplified_desc")
+	expectFieldErrors(t, s.mergePatch(t, path, map[string]interface{}{"simplified_desc": "Keeps tap water safe."}), "simplified_desc")
+
+	t.Run("API keys cannot review summaries", func(t *testing.T) {
+		promote(t, s, "ada@example.com", "editor")
+		key := createKey(t, s, map[string]interface{}{"name": "Importer", "scopes": []string{"write"}})
+		resp := s.doWithKey(t, "POST", "/api/summaries", key.Key, map[string]interface{}{"policy_id": policy["id"], "text": "Keeps tap water safe."})
+		decodeError(t, resp, http.StatusForbidden, "forbidden")
+	})
+}
+
+func TestSummaryReadability(t *testing.T) {
+	cfg := testConfig()
+	cfg.Summaries.RequiredApprovals = 1
+	s := newTestServerWithConfig(t, cfg, repository.NewMemoryStore())
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-01-01T00:00:00Z"))
+	policyID := policy["id"].(string)
+	editorID, editor := reviewer(t, s, "Grace Hopper", "grace@example.com", "Democratic")
+
+	resp := s.do(t, "POST", "/api/summaries", map[string]interface{}{"policy_id": policyID, "text": "A radical plan to keep tap water safe."})
+	expectStatus(t, resp, http.StatusCreated)
+	var summary models.Summary
+	resp.decode(t, &summary)
+	path := "/api/summaries/" + summary.ID.Hex()
+	if summary.Readability == nil || len(summary.Readability.LoadedTerms) != 1 || len(summary.Readability.Problems) != 1 {
+		t.Fatalf("readability = %+v, want the loaded term flagged", summary.Readability)
+	}
+
+	reviewStep(t, s, editor, path+"/reviewers", map[string]interface{}{"reviewers": []string{editorID}}, http.StatusOK)
+	reviewStep(t, s, s.token, path+"/submit", nil, http.StatusOK)
+	reviewStep(t, s, editor, path+"/approve", nil, http.StatusOK)
+	expectFieldErrors(t, s.doWithToken(t, "POST", path+"/publish", editor, nil), "text")
+
+	// The text is rescored when it changes
+	reviewStep(t, s, editor, path+"/request-changes", map[string]interface{}{"body": "Drop \"radical\"."}, http.StatusOK)
+	resp = s.do(t, "PUT", path, map[string]interface{}{"text": "A plan to keep tap water safe."})
+	expectStatus(t, resp, http.StatusOK)
+	resp.decode(t, &summary)
+	if len(summary.Readability.Problems) != 0 {
+		t.Fatalf("problems = %v, want none", summary.Readability.Problems)
+	}
+	reviewStep(t, s, s.token, path+"/submit", nil, http.StatusOK)
+	reviewStep(t, s, editor, path+"/approve", nil, http.StatusOK)
+	reviewStep(t, s, editor, path+"/publish", nil, http.StatusOK)
+
+	var published models.Policy
+	resp = s.do(t, "GET", "/api/policies/"+policyID, nil)
+	expectStatus(t, resp, http.StatusOK)
+	resp.decode(t, &published)
+	if published.Readability == nil || published.Readability.Words != 7 {
+		t.Errorf("policy readability = %+v, want the published summary's scores", published.Readability)
+	}
+}
+
+func TestGenerateSummary(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	bill := samplePolicy("Clean Water Act", "2024-01-01T00:00:00Z")
+	bill["original_text"] = "SEC. 1. WATER TESTING.\nEvery city must test its drinking water for lead each year.\n\nSEC. 2. FUNDING.\nThe state gives cities grants to replace lead pipes."
+	policy := createResource(t, s, "/api/policies", bill)
+	body := map[string]interface{}{"policy_id": policy["id"]}
+
+	decodeError(t, s.do(t, "POST", "/api/summaries/generate", body), http.StatusForbidden, "forbidden")
+	promote(t, s, "ada@example.com", "editor")
+
+	resp := s.do(t, "POST", "/api/summaries/generate", body)
+	expectStatus(t, resp, http.StatusCreated)
+	var summary models.Summary
+	resp.decode(t, &summary)
+	if summary.Status != models.SummaryDraft || summary.AuthorID != nil || summary.Readability == nil {
+		t.Errorf("summary = %+v, want a scored draft without an author", summary)
+	}
+	if g := summary.Generator; g == nil || g.Name != "extractive" || g.Version == "" {
+		t.Errorf("generator = %+v, want the extractive generator and its version", summary.Generator)
+	}
+	if summary.Text != "Every city must test its drinking water for lead each year. The state gives cities grants to replace lead pipes." {
+		t.Errorf("text = %q, want a sentence of each section", summary.Text)
+	}
+
+	t.Run("policies need original text", func(t *testing.T) {
+		empty := samplePolicy("Empty Act", "2024-01-01T00:00:00Z")
+		delete(empty, "original_text")
+		policy := createResource(t, s, "/api/policies", empty)
+		expectFieldErrors(t, s.do(t, "POST", "/api/summaries/generate", map[string]interface{}{"policy_id": policy["id"]}), "policy_id")
+		decodeError(t, s.do(t, "POST", "/api/summaries/generate", map[string]interface{}{"policy_id": "64b7f0f0f0f0f0f0f0f0f0f0"}), http.StatusNotFound, "not_found")
+	})
+
+	t.Run("remote generator failures are reported", func(t *testing.T) {
+		stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "model unavailable", http.StatusServiceUnavailable)
+		}))
+		defer stub.Close()
+
+		cfg := testConfig()
+		cfg.Summaries.Generator.Kind, cfg.Summaries.Generator.URL = config.GeneratorRemote, stub.URL
+		remote := newTestServerWithConfig(t, cfg, s.store)
+		remote.token = s.token
+		decodeError(t, remote.do(t, "POST", "/api/summaries/generate", body), http.StatusBadGateway, "generator_failed")
+	})
+}
+
+func TestAffiliationsAreSetByAdministrators(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	adminToken := s.token
+	promote(t, s, "ada@example.com", models.RoleAdmin)
+	id, token := reviewer(t, s, "Grace Hopper", "grace@example.com", "Democratic")
+	s.token = token
+	path := "/api/users/" + id
+
+	t.Run("users cannot change their own", func(t *testing.T) {
+		expectFieldErrors(t, s.mergePatch(t, path, map[string]interface{}{"affiliation": "Republican"}), "affiliation")
+		expectFieldErrors(t, s.mergePatch(t, path, map[string]interface{}{"affiliation": nil}), "affiliation")
+		expectFieldErrors(t, s.do(t, "PUT", path, map[string]interface{}{
+			"name": "Grace Hopper", "email": "grace@example.com", "affiliation": "Republican",
+		}), "affiliation")
+		expectFieldErrors(t, s.do(t, "POST", "/api/users", map[string]interface{}{
+			"name": "Alan Turing", "email": "alan@example.com", "password": "correct horse battery staple", "affiliation": "Republican",
+		}), "affiliation")
+
+		// Leaving it out of a replacement keeps it
+		resp := s.do(t, "PUT", path, map[string]interface{}{"name": "Grace Brewster Hopper", "email": "grace@example.com"})
+		expectStatus(t, resp, http.StatusOK)
+		var user models.User
+		resp.decode(t, &user)
+		if user.Affiliation != "Democratic" {
+			t.Errorf("affiliation = %q, want it kept", user.Affiliation)
+		}
+	})
+
+	t.Run("administrators change any", func(t *testing.T) {
+		s.token = adminToken
+		resp := s.mergePatch(t, path, map[string]interface{}{"affiliation": "Independent"})
+		expectStatus(t, resp, http.StatusOK)
+		var user models.User
+		resp.decode(t, &user)
+		if user.Affiliation != "Independent" {
+			t.Errorf("affiliation = %q, want the administrator's change", user.Affiliation)
+		}
+	})
+}
+
+// unpatchablePolicies fails every policy patch with err, if it is set
+type unpatchablePolicies struct {
+	repository.PolicyRepository
+	err error
+}
+
+func (f *unpatchablePolicies) Patch(ctx context.Context, policy *models.Policy, fields []string) error {
+	if f.err != nil {
+		return f.err
+	}
+	return f.PolicyRepository.Patch(ctx, policy, fields)
+}
+
+func TestPublishingNeedsThePolicyWritten(t *testing.T) {
+	cfg := testConfig()
+	cfg.Summaries.RequiredApprovals = 1
+	store := repository.NewMemoryStore()
+	policies := &unpatchablePolicies{PolicyRepository: store.Policies}
+	store.Policies = policies
+	s := newTestServerWithConfig(t, cfg, store)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-01-01T00:00:00Z"))
+	editorID, editor := reviewer(t, s, "Grace Hopper", "grace@example.com", "Democratic")
+
+	resp := s.do(t, "POST", "/api/summaries", map[string]interface{}{"policy_id": policy["id"], "text": "Keeps tap water safe."})
+	expectStatus(t, resp, http.StatusCreated)
+	var summary models.Summary
+	resp.decode(t, &summary)
+	path := "/api/summaries/" + summary.ID.Hex()
+	reviewStep(t, s, editor, path+"/reviewers", map[string]interface{}{"reviewers": []string{editorID}}, http.StatusOK)
+	reviewStep(t, s, s.token, path+"/submit", nil, http.StatusOK)
+	reviewStep(t, s, editor, path+"/approve", nil, http.StatusOK)
+
+	for _, c := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{errDatabase, http.StatusInternalServerError, "internal_error"},
+		{repository.ErrRevisionConflict, http.StatusConflict, "conflict"},
+		{repository.ErrNotFound, http.StatusNotFound, "not_found"},
+	} {
+		policies.err = c.err
+		decodeError(t, s.doWithToken(t, "POST", path+"/publish", editor, nil), c.status, c.code)
+
+		// The summary stays approved, so publishing can be retried
+		var stored models.Summary
+		s.do(t, "GET", path, nil).decode(t, &stored)
+		if stored.Status != models.SummaryApproved || stored.PublishedBy != nil {
+			t.Errorf("after %v the summary = %+v, want it still approved", c.err, stored)
+		}
+	}
+
+	policies.err = nil
+	published := reviewStep(t, s, editor, path+"/publish", nil, http.StatusOK)
+	var stored models.Policy
+	s.do(t, "GET", "/api/policies/"+policy["id"].(string), nil).decode(t, &stored)
+	if published.Status != models.SummaryPublished || stored.Summary == nil || stored.Summary.ID != summary.ID {
+		t.Errorf("policy summary = %+v, want the summary published on retry", stored.Summary)
+	}
+}
@@ -0,0 +1,64 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracePropagation(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", tracing.ExporterNone)
+	if _, err := tracing.Setup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The global provider can only be replaced once per process, so this is
+	// the only test that records spans
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+
+	logs := captureLogs(t)
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z"))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest("GET", s.server.URL+"/api/public/policies/"+policy["id"].(string), nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := s.server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var found bool
+	for _, span := range spans.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			continue
+		}
+		found = true
+		if span.Name() != "/api/public/policies/{id}" {
+			t.Errorf("span name = %q, want the route template", span.Name())
+		}
+		if !span.Parent().IsRemote() || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+			t.Errorf("span parent = %v, want the caller's span", span.Parent())
+		}
+	}
+	if !found {
+		t.Fatalf("no span continued trace %s", traceID)
+	}
+
+	var logged bool
+	for _, record := range logs.records(t) {
+		if record["msg"] == "request" && record["trace_id"] == traceID {
+			logged = true
+		}
+	}
+	if !logged {
+		t.Errorf("request log has no trace_id %s:\n%s", traceID, logs)
+	}
+}
@@ -0,0 +1,176 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benjamingetches/govtrack/trash"
+)
+
+func TestTrash(t *testing.T) {
+	s := newTestServer(t)
+	user := s.login(t, "Ada Lovelace", "ada@example.com")
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+	related := samplePolicy("Clean Air Funding Act", "2024-02-01T00:00:00Z")
+	related["related_policies"] = []string{policy["id"].(string)}
+	related = createResource(t, s, "/api/policies", related)
+	quiz := createResource(t, s, "/api/quizzes", sampleQuiz(nil))
+	path := "/api/policies/" + policy["id"].(string)
+
+	expectStatus(t, s.do(t, "DELETE", path, nil), http.StatusOK)
+	expectStatus(t, s.do(t, "DELETE", "/api/quizzes/"+quiz["id"].(string), nil), http.StatusNoContent)
+
+	t.Run("deleted content is hidden", func(t *testing.T) {
+		decodeError(t, s.do(t, "GET", path, nil), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "GET", "/api/public/policies/"+policy["id"].(string), nil), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "PUT", path, samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z")), http.StatusNotFound, "not_found")
+		decodeError(t, s.mergePatch(t, path, map[string]interface{}{"title": "Clean Air Act of 2024"}), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "DELETE", path, nil), http.StatusNotFound, "not_found")
+		decodeError(t, s.do(t, "GET", "/api/quizzes/"+quiz["id"].(string), nil), http.StatusNotFound, "not_found")
+
+		var policies []map[string]interface{}
+		s.do(t, "GET", "/api/public/policies", nil).decode(t, &policies)
+		if len(policies) != 1 || policies[0]["id"] != related["id"] {
+			t.Errorf("policies = %v, want only the policy that was not deleted", policies)
+		}
+		var quizzes []interface{}
+		s.do(t, "GET", "/api/quizzes", nil).decode(t, &quizzes)
+		if len(quizzes) != 0 {
+			t.Errorf("quizzes = %v, want none", quizzes)
+		}
+	})
+
+	t.Run("only administrators see the trash", func(t *testing.T) {
+		decodeError(t, s.do(t, "GET", "/api/admin/trash", nil), http.StatusForbidden, "forbidden")
+		decodeError(t, s.do(t, "POST", "/api/admin/trash/policies/"+policy["id"].(string)+"/restore", nil), http.StatusForbidden, "forbidden")
+		promote(t, s, "ada@example.com", "admin")
+	})
+
+	t.Run("the trash records who deleted what and when", func(t *testing.T) {
+		resp := s.do(t, "GET", "/api/admin/trash", nil)
+		expectStatus(t, resp, http.StatusOK)
+		var listed struct {
+			Policies        []map[string]interface{} `json:"policies"`
+			Representatives []map[string]interface{} `json:"representatives"`
+			Quizzes         []map[string]interface{} `json:"quizzes"`
+		}
+		resp.decode(t, &listed)
+		if len(listed.Policies) != 1 || len(listed.Quizzes) != 1 || len(listed.Representatives) != 0 {
+			t.Fatalf("trash = %+v, want one policy and one quiz", listed)
+		}
+		trashed := listed.Policies[0]
+		if trashed["id"] != policy["id"] || trashed["deleted_by"] != user["id"] || trashed["deleted_at"] == nil {
+			t.Errorf("trashed policy = %v, want it deleted by %v", trashed, user["id"])
+		}
+
+		s.do(t, "GET", "/api/admin/trash?type=representatives", nil).decode(t, &listed)
+		if len(listed.Policies) != 0 || len(listed.Quizzes) != 0 {
+			t.Errorf("trash = %+v, want only representatives", listed)
+		}
+		decodeError(t, s.do(t, "GET", "/api/admin/trash?type=users", nil), http.StatusBadRequest, "invalid_parameter")
+	})
+
+	t.Run("restoring brings content back with its references", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/admin/trash/policies/"+policy["id"].(string)+"/restore", nil)
+		expectStatus(t, resp, http.StatusOK)
+		var restored map[string]interface{}
+		resp.decode(t, &restored)
+		if restored["title"] != "Clean Air Act" || restored["revision"] != float64(3) || restored["deleted_at"] != nil {
+			t.Errorf("restored policy = %v, want it out of the trash at revision 3", restored)
+		}
+		expectStatus(t, s.do(t, "GET", path, nil), http.StatusOK)
+
+		var stored map[string]interface{}
+		s.do(t, "GET", "/api/policies/"+related["id"].(string), nil).decode(t, &stored)
+		if ids, _ := stored["related_policies"].([]interface{}); len(ids) != 1 || ids[0] != policy["id"] {
+			t.Errorf("related_policies = %v, want the restored policy kept", stored["related_policies"])
+		}
+
+		resp = s.do(t, "POST", "/api/admin/trash/policies/"+policy["id"].(string)+"/restore", nil)
+		decodeError(t, resp, http.StatusNotFound, "not_found")
+		expectStatus(t, s.do(t, "POST", "/api/admin/trash/quizzes/"+quiz["id"].(string)+"/restore", nil), http.StatusOK)
+	})
+}
+
+func TestTrashPurge(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+	rep := createResource(t, s, "/api/representatives", sampleRepresentative())
+	repID := rep["id"].(string)
+	policy := createResource(t, s, "/api/policies", samplePolicy("Clean Air Act", "2024-01-01T00:00:00Z"))
+	policyID := policy["id"].(string)
+
+	referrer := samplePolicy("Clean Air Funding Act", "2024-02-01T00:00:00Z")
+	referrer["related_policies"] = []string{policyID}
+	referrer["sponsors"] = []map[string]interface{}{rep}
+	referrer["voting_record"] = []map[string]interface{}{{"representative_id": repID, "vote": "yes", "date": "2024-02-10T00:00:00Z"}}
+	referrer = createResource(t, s, "/api/policies", referrer)
+	voter := sampleRepresentative()
+	voter["name"] = "Sam Ortiz"
+	voter["voting_history"] = []string{policyID}
+	voter = createResource(t, s, "/api/representatives", voter)
+	quiz := createResource(t, s, "/api/quizzes", sampleQuiz([]map[string]interface{}{{"representative_id": repID, "stance": 4}}))
+
+	summary := createResource(t, s, "/api/summaries", map[string]interface{}{"policy_id": policyID, "text": "Keeps the air clean."})
+
+	expectStatus(t, s.do(t, "DELETE", "/api/policies/"+policyID, nil), http.StatusOK)
+	expectStatus(t, s.do(t, "DELETE", "/api/representatives/"+repID, nil), http.StatusNoContent)
+
+	ctx := context.Background()
+	result, err := trash.NewPurger(s.store, time.Hour, s.cache).Purge(ctx)
+	if err != nil || result != (trash.Result{}) {
+		t.Fatalf("purge within the retention period = %+v, %v; want nothing purged", result, err)
+	}
+
+	// Cache the public view of the referring policy before the purge
+	publicPath := "/api/public/policies/" + referrer["id"].(string)
+	expectStatus(t, s.doWithHeader(t, "GET", publicPath, nil, nil), http.StatusOK)
+	if got := s.doWithHeader(t, "GET", publicPath, nil, nil).Header.Get("X-Cache"); got != "hit" {
+		t.Fatalf("X-Cache = %q before the purge, want hit", got)
+	}
+
+	result, err = trash.NewPurger(s.store, 0, s.cache).Purge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (trash.Result{Policies: 1, Representatives: 1}) {
+		t.Errorf("purged %+v, want one policy and one representative", result)
+	}
+
+	var stored map[string]interface{}
+	s.do(t, "GET", "/api/policies/"+referrer["id"].(string), nil).decode(t, &stored)
+	if stored["related_policies"] != nil || len(stored["sponsors"].([]interface{})) != 0 || len(stored["voting_record"].([]interface{})) != 0 {
+		t.Errorf("referring policy = %v, want the related policy, sponsor and vote removed", stored)
+	}
+	if stored["revision"] != float64(3) {
+		t.Errorf("revision = %v, want 3 after removing references to the policy and the representative", stored["revision"])
+	}
+
+	decodeError(t, s.do(t, "GET", "/api/summaries/"+summary["id"].(string), nil), http.StatusNotFound, "not_found")
+
+	// The purge invalidates cached public responses
+	resp := s.doWithHeader(t, "GET", publicPath, nil, nil)
+	var public map[string]interface{}
+	resp.decode(t, &public)
+	if got := resp.Header.Get("X-Cache"); got != "miss" || public["related_policies"] != nil {
+		t.Errorf("X-Cache = %q and related_policies = %v after the purge, want a miss without the purged policy", got, public["related_policies"])
+	}
+	var storedRep map[string]interface{}
+	s.do(t, "GET", "/api/representatives/"+voter["id"].(string), nil).decode(t, &storedRep)
+	if storedRep["voting_history"] != nil {
+		t.Errorf("voting_history = %v, want the purged policy removed", storedRep["voting_history"])
+	}
+	var storedQuiz struct {
+		Questions []map[string]interface{} `json:"questions"`
+	}
+	s.do(t, "GET", "/api/quizzes/"+quiz["id"].(string), nil).decode(t, &storedQuiz)
+	if stances := storedQuiz.Questions[0]["representative_stances"]; stances != nil {
+		t.Errorf("representative_stances = %v, want the purged representative's stance removed", stances)
+	}
+
+	promote(t, s, "ada@example.com", "admin")
+	resp = s.do(t, "POST", "/api/admin/trash/policies/"+policyID+"/restore", nil)
+	decodeError(t, resp, http.StatusNotFound, "not_found")
+}
@@ -0,0 +1,184 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// problem is an application/problem+json response body
+type problem struct {
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	Errors []struct {
+		Field  string `json:"field"`
+		Reason string `json:"reason"`
+	} `json:"errors"`
+}
+
+// expectFieldErrors checks that resp is a 422 problem listing exactly fields
+func expectFieldErrors(t *testing.T, resp *response, fields ...string) {
+	t.Helper()
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+
+	var p problem
+	resp.decode(t, &p)
+	got := map[string]bool{}
+	for _, fe := range p.Errors {
+		got[fe.Field] = true
+	}
+	for _, field := range fields {
+		if !got[field] {
+			t.Errorf("no error reported for %s; errors: %+v", field, p.Errors)
+		}
+	}
+	if len(p.Errors) != len(fields) {
+		t.Errorf("got %d field errors, want %d: %+v", len(p.Errors), len(fields), p.Errors)
+	}
+}
+
+func TestPolicyValidation(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	invalid := samplePolicy("", "2024-03-01T00:00:00Z")
+	invalid["level"] = "galactic"
+	invalid["status"] = "vetoed"
+	invalid["sponsor"] = "Jordan Rivera"
+	invalid["sources"] = []map[string]string{{"url": "leginfo/bill/1"}, {"title": "Missing URL"}}
+	invalid["voting_record"] = []map[string]interface{}{
+		{"representative_id": "5f8d0d55b54764421b7156c9", "vote": "yes", "date": "2024-04-01T00:00:00Z"},
+		{"representative_id": "5f8d0d55b54764421b7156c9", "vote": "maybe", "date": "2024-04-01T00:00:00Z", "weight": 2},
+	}
+
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/policies", invalid)
+		golden(t, "validation/policy_unknown_fields", resp)
+		expectFieldErrors(t, resp, "sponsor", "voting_record[1].weight")
+	})
+
+	delete(invalid, "sponsor")
+	delete(invalid["voting_record"].([]map[string]interface{})[1], "weight")
+
+	t.Run("every invalid field is reported", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/policies", invalid)
+		golden(t, "validation/policy_invalid", resp)
+		expectFieldErrors(t, resp,
+			"title", "status", "level",
+			"sources[0].url", "sources[1].url",
+			"voting_record[1].vote", "voting_record[1].representative_id",
+		)
+	})
+
+	t.Run("jurisdiction must match level", func(t *testing.T) {
+		policy := samplePolicy("Local Parks Act", "2024-03-01T00:00:00Z")
+		policy["level"] = "local"
+		policy["jurisdiction"] = map[string]string{"country": "US"}
+		expectFieldErrors(t, s.do(t, "POST", "/api/policies", policy), "jurisdiction.state", "jurisdiction.city")
+	})
+
+	t.Run("wrong types", func(t *testing.T) {
+		policy := samplePolicy("Typed Act", "2024-03-01T00:00:00Z")
+		policy["tags"] = "environment"
+		expectFieldErrors(t, s.do(t, "POST", "/api/policies", policy), "tags")
+	})
+
+	t.Run("updates are validated", func(t *testing.T) {
+		created := createResource(t, s, "/api/policies", samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z"))
+		update := samplePolicy("Clean Water Act", "2024-03-01T00:00:00Z")
+		update["status"] = "enacted"
+		expectFieldErrors(t, s.do(t, "PUT", "/api/policies/"+created["id"].(string), update), "status")
+	})
+}
+
+func TestRepresentativeValidation(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	rep := sampleRepresentative()
+	rep["term_end"] = "2021-01-03T00:00:00Z"
+	rep["photo_url"] = "not a url"
+	rep["contact_info"] = map[string]string{"email": "rivera at senate", "website": "ftp://senate.example.gov"}
+	rep["political_stances"] = []map[string]interface{}{{"issue": "Healthcare", "stance": 7}}
+
+	resp := s.do(t, "POST", "/api/representatives", rep)
+	golden(t, "validation/representative_invalid", resp)
+	expectFieldErrors(t, resp,
+		"term_end", "photo_url", "contact_info.email", "contact_info.website", "political_stances[0].stance",
+	)
+}
+
+func TestUserValidation(t *testing.T) {
+	s := newTestServer(t)
+	self := s.login(t, "Ada Lovelace", "ada@example.com")
+
+	t.Run("create requires a password", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/users", map[string]interface{}{"name": "Charles Babbage", "email": "charles"})
+		expectFieldErrors(t, resp, "email", "password")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		resp := s.do(t, "PUT", "/api/users/"+self["id"].(string), map[string]interface{}{
+			"name":     " ",
+			"email":    "ada@example.com",
+			"password": "short",
+			"location": map[string]interface{}{"coordinates": map[string]float64{"latitude": 91, "longitude": -181}},
+		})
+		expectFieldErrors(t, resp, "name", "password", "location.coordinates.latitude", "location.coordinates.longitude")
+	})
+
+	t.Run("register", func(t *testing.T) {
+		resp := s.doWithToken(t, "POST", "/api/auth/register", "", map[string]string{
+			"name": "Bob", "email": "bob@example.com", "password": "hunter2", "role": "admin",
+		})
+		expectFieldErrors(t, resp, "role")
+	})
+}
+
+func TestQuizValidation(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	quiz := sampleQuiz([]map[string]interface{}{{"representative_id": "5f8d0d55b54764421b7156c9", "stance": 0}})
+	questions := quiz["questions"].([]map[string]interface{})
+	questions[1]["category"] = "foreign"
+	questions[1]["is_likert_scale"] = false
+
+	resp := s.do(t, "POST", "/api/quizzes", quiz)
+	golden(t, "validation/quiz_invalid", resp)
+	expectFieldErrors(t, resp,
+		"questions[0].representative_stances[0].stance", "questions[1].options", "questions[1].category",
+	)
+
+	created := createResource(t, s, "/api/quizzes", sampleQuiz(nil))
+	t.Run("submit", func(t *testing.T) {
+		resp := s.do(t, "POST", "/api/quizzes/"+created["id"].(string)+"/submit", map[string]interface{}{
+			"responses": []map[string]interface{}{{"answer": 6}},
+		})
+		expectFieldErrors(t, resp, "responses[0].question_id", "responses[0].answer")
+	})
+}
+
+func TestMalformedBodies(t *testing.T) {
+	s := newTestServer(t)
+	s.login(t, "Ada Lovelace", "ada@example.com")
+
+	for name, body := range map[string]string{
+		"empty":         "",
+		"not json":      "{title:",
+		"not an object": `["title"]`,
+		"trailing data": `{"title": "A"} {"title": "B"}`,
+		"bad timestamp": `{"title": "A", "introduced_date": "yesterday"}`,
+		"bad object id": `{"title": "A", "related_policies": ["nope"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := s.do(t, "POST", "/api/policies", body)
+			expectStatus(t, resp, http.StatusBadRequest)
+			if ct := resp.Header.Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", ct)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MaxBodyBytes is the largest request body Decode accepts
+const MaxBodyBytes = 1 << 20
+
+// Decode reads the JSON request body into v and validates it. It returns
+// Errors listing every unknown, mistyped or invalid field, or a
+// *MalformedError if the body cannot be read as a JSON object at all.
+func Decode(r *http.Request, v interface{}) error {
+	body, err := ReadBody(r)
+	if err != nil {
+		return err
+	}
+	return DecodeJSON(body, v)
+}
+
+// ReadBody reads a request body of at most MaxBodyBytes
+func ReadBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxBodyBytes+1))
+	if err != nil {
+		return nil, malformed("The request body could not be read")
+	}
+	if len(body) > MaxBodyBytes {
+		return nil, malformed("The request body is too large")
+	}
+	return body, nil
+}
+
+// DecodeJSON decodes the JSON object body into v and validates it, as Decode
+// does for request bodies
+func DecodeJSON(body []byte, v interface{}) error {
+	// Decode into a generic value first so that every unknown field can be
+	// reported, rather than only the first as DisallowUnknownFields would
+	var raw interface{}
+	if err := decodeSingle(body, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw.(map[string]interface{}); !ok {
+		return malformed("The request body must be a JSON object")
+	}
+
+	var errs Errors
+	unknownFields(reflect.TypeOf(v), raw, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return Errors{{Field: typeErr.Field, Reason: "must be " + jsonKind(typeErr.Type)}}
+		}
+		// Values such as timestamps and IDs report parse errors without a field
+		detail := strings.TrimPrefix(err.Error(), "json: ")
+		return malformed(strings.ToUpper(detail[:1]) + detail[1:])
+	}
+
+	if errs := Struct(v); errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// decodeSingle decodes exactly one JSON value from body
+func decodeSingle(body []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return malformed("The request body is empty")
+		}
+		return malformed("The request body is not valid JSON")
+	}
+	if decoder.More() {
+		return malformed("The request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// unknownFields records every object key in data that does not map to a
+// field of t
+func unknownFields(t reflect.Type, data interface{}, path string, errs *Errors) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := data.(map[string]interface{})
+		if !ok || t == timeType || t == objectIDType {
+			return
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field, ok := lookupField(fields, key)
+			if !ok {
+				errs.Add(join(path, key), "is not a recognized field")
+				continue
+			}
+			unknownFields(field, obj[key], join(path, key), errs)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := data.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			unknownFields(t.Elem(), item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, value := range obj {
+			unknownFields(t.Elem(), value, join(path, key), errs)
+		}
+	}
+}
+
+// jsonFields maps the JSON names of t's fields to their types
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embedded, typ := range jsonFields(field.Type) {
+				if _, ok := fields[embedded]; !ok {
+					fields[embedded] = typ
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// lookupField finds a field by JSON key, falling back to the case-insensitive
+// match encoding/json accepts
+func lookupField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if t, ok := fields[key]; ok {
+		return t, true
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// jsonKind describes the JSON type expected for a Go type
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// MalformedError reports a request body that is not a single JSON object of
+// the expected shape. Detail is safe to show to clients.
+type MalformedError struct {
+	Detail string
+}
+
+// Error implements the error interface
+func (e *MalformedError) Error() string {
+	return "malformed request body: " + e.Detail
+}
+
+func malformed(detail string) error {
+	return &MalformedError{Detail: detail}
+}
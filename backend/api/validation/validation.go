@@ -0,0 +1,289 @@
+// Package validation checks decoded request models against declarative rules
+// and reports every invalid field at once.
+//
+// Rules are declared with struct tags:
+//
+//	Title string `json:"title" validate:"required,max=300"`
+//	Level string `json:"level" enum:"federal,state,local"`
+//
+// The validate tag supports required, min=N, max=N, url and email. min and
+// max bound the length of strings and slices and the value of numbers. The
+// enum tag lists the allowed values of a string; an empty string is allowed
+// unless the field is also required. Rules that span several fields are
+// implemented by the Validator interface.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// FieldError describes why a single request field is invalid. Field is the
+// JSON path of the field, such as sources[0].url.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// Errors is a list of field errors
+type Errors []FieldError
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + " " + fe.Reason
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Add records that field is invalid for reason
+func (e *Errors) Add(field, reason string) {
+	*e = append(*e, FieldError{Field: field, Reason: reason})
+}
+
+// Validator is implemented by models with rules that span several fields.
+// Field names in the returned errors are relative to the model.
+type Validator interface {
+	Validate() Errors
+}
+
+// Append adds a field error to err if err is nil or a list of field errors,
+// so handlers can report extra rules alongside those found by Decode. Other
+// errors, such as a malformed body, are returned unchanged.
+func Append(err error, field, reason string) error {
+	if err == nil {
+		return Errors{{Field: field, Reason: reason}}
+	}
+	if errs, ok := err.(Errors); ok {
+		errs.Add(field, reason)
+		return errs
+	}
+	return err
+}
+
+// Struct validates v, which must be a struct or a pointer to one, and returns
+// every field error found or nil if v is valid
+func Struct(v interface{}) Errors {
+	var errs Errors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateValue walks nested structs, slices and maps, applying their field
+// rules and Validator implementations
+func validateValue(v reflect.Value, path string, errs *Errors) {
+	if !v.IsValid() || v.Type() == timeType || v.Type() == objectIDType {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			validateValue(v.Elem(), path, errs)
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+		return
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			validateValue(v.MapIndex(key), join(path, fmt.Sprint(key.Interface())), errs)
+		}
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a JSON name are flattened, as encoding/json does
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			validateValue(v.Field(i), path, errs)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldPath := join(path, name)
+		if validateField(v.Field(i), field, fieldPath, errs) {
+			validateValue(v.Field(i), fieldPath, errs)
+		}
+	}
+
+	if validator, ok := asValidator(v); ok {
+		for _, fe := range validator.Validate() {
+			errs.Add(join(path, fe.Field), fe.Reason)
+		}
+	}
+}
+
+// asValidator returns v as a Validator if it or a pointer to it implements one
+func asValidator(v reflect.Value) (Validator, bool) {
+	if validator, ok := v.Interface().(Validator); ok {
+		return validator, true
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	validator, ok := ptr.Interface().(Validator)
+	return validator, ok
+}
+
+// validateField applies the validate and enum tags of a single field. It
+// reports whether the field's own contents should be validated too, which is
+// skipped once a field is known to be missing.
+func validateField(v reflect.Value, field reflect.StructField, path string, errs *Errors) bool {
+	rules := field.Tag.Get("validate")
+	enum := field.Tag.Get("enum")
+	if rules == "" && enum == "" {
+		return true
+	}
+
+	empty := isEmpty(v)
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "":
+		case "required":
+			if empty {
+				errs.Add(path, "is required")
+				return false
+			}
+		case "min", "max":
+			// Numbers are always bounded; zero is a value, not a missing one
+			if !empty || isNumber(v) {
+				checkBound(v, name, arg, path, errs)
+			}
+		case "url":
+			if !empty && !isURL(v.String()) {
+				errs.Add(path, "must be an absolute http or https URL")
+			}
+		case "email":
+			if !empty && !isEmail(v.String()) {
+				errs.Add(path, "must be a valid email address")
+			}
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q on field %s", name, field.Name))
+		}
+	}
+
+	if enum != "" && v.Kind() == reflect.String && v.String() != "" {
+		allowed := strings.Split(enum, ",")
+		if !slices.Contains(allowed, v.String()) {
+			errs.Add(path, "must be one of: "+strings.Join(allowed, ", "))
+		}
+	}
+	return true
+}
+
+// checkBound applies a min or max rule to a string length, collection length
+// or number
+func checkBound(v reflect.Value, rule, arg, path string, errs *Errors) {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid %s=%q on %s", rule, arg, path))
+	}
+
+	var actual float64
+	var unit string
+	switch v.Kind() {
+	case reflect.String:
+		actual, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = v.Float()
+	default:
+		return
+	}
+
+	switch {
+	case rule == "min" && actual < limit:
+		errs.Add(path, "must be at least "+arg+unit)
+	case rule == "max" && actual > limit:
+		errs.Add(path, "must be at most "+arg+unit)
+	}
+}
+
+// isEmpty reports whether v holds no value. Strings made only of whitespace
+// count as empty.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// join appends a field name to a JSON path
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// jsonName returns the name a field is encoded under by encoding/json, or an
+// empty string if its tag does not set one
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
@@ -0,0 +1,228 @@
+// Package audit keeps a tamper-evident log of the changes made through the
+// API. Every entry records who changed which document, how, and from where,
+// and carries a SHA-256 hash of its content chained to the previous entry's
+// hash, so that editing, inserting or removing entries can be detected.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actions recorded in the log
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionRestore  = "restore"
+	ActionPurge    = "purge"
+	ActionRevoke   = "revoke"
+	ActionModerate = "moderate"
+)
+
+// Actions lists every action
+var Actions = []string{ActionCreate, ActionUpdate, ActionDelete, ActionRestore, ActionPurge, ActionRevoke, ActionModerate}
+
+// Types of the audited resources
+const (
+	ResourceUser           = "user"
+	ResourcePolicy         = "policy"
+	ResourceRepresentative = "representative"
+	ResourceQuiz           = "quiz"
+	ResourceAPIKey         = "api_key"
+	ResourceSummary        = "summary"
+	ResourceAnnotation     = "annotation"
+	ResourceComment        = "comment"
+)
+
+// ResourceTypes lists every audited resource type
+var ResourceTypes = []string{ResourceUser, ResourcePolicy, ResourceRepresentative, ResourceQuiz, ResourceAPIKey, ResourceSummary, ResourceAnnotation, ResourceComment}
+
+// Redacted replaces the values of redacted fields in changes
+const Redacted = `"[redacted]"`
+
+// maxAppendAttempts bounds how often an entry is rechained after another
+// process appended one first
+const maxAppendAttempts = 10
+
+// verifyBatch is the number of entries read at a time by Verify
+const verifyBatch = 500
+
+// Log appends entries to the audit log
+type Log struct {
+	entries repository.AuditRepository
+	now     func() time.Time
+
+	// mu serializes the appends of this process; appends from other
+	// processes are detected by the unique sequence and retried
+	mu sync.Mutex
+}
+
+// NewLog creates a Log writing to entries
+func NewLog(entries repository.AuditRepository) *Log {
+	return &Log{entries: entries, now: time.Now}
+}
+
+// Record sets entry's time, sequence and hashes and appends it to the log
+func (l *Log) Record(ctx context.Context, entry *models.AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Stored times keep milliseconds, so hashes are computed from those
+	entry.At = l.now().UTC().Truncate(time.Millisecond)
+	for attempt := 1; ; attempt++ {
+		last, err := l.entries.Last(ctx)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			entry.Sequence, entry.PrevHash = 1, ""
+		case err != nil:
+			return err
+		default:
+			entry.Sequence, entry.PrevHash = last.Sequence+1, last.Hash
+		}
+
+		entry.ID = primitive.NilObjectID
+		if entry.Hash, err = Hash(entry); err != nil {
+			return err
+		}
+		err = l.entries.Append(ctx, entry)
+		if errors.Is(err, repository.ErrDuplicate) && attempt < maxAppendAttempts {
+			continue
+		}
+		return err
+	}
+}
+
+// Hash returns the hex-encoded SHA-256 of the JSON form of entry without its
+// ID and hash. The previous entry's hash is part of the content.
+func Hash(entry *models.AuditEntry) (string, error) {
+	content := *entry
+	content.ID = primitive.NilObjectID
+	content.Hash = ""
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Diff returns the changes between the JSON forms of before and after, one
+// per top-level field that differs, in field order. Either may be nil, for
+// documents that were created or deleted. The values of the redacted fields
+// are replaced by Redacted.
+func Diff(before, after interface{}, redacted ...string) ([]models.FieldChange, error) {
+	old, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(old)+len(changed))
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range changed {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]models.FieldChange, 0)
+	for _, name := range names {
+		change := models.FieldChange{Field: name, Before: old[name], After: changed[name]}
+		if bytes.Equal(change.Before, change.After) {
+			continue
+		}
+		if slices.Contains(redacted, name) {
+			change.Before, change.After = redact(change.Before), redact(change.After)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// fields returns the top-level fields of the JSON form of doc
+func fields(doc interface{}) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if doc == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// redact replaces a value that is present by Redacted
+func redact(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	return json.RawMessage(Redacted)
+}
+
+// Verify checks the whole hash chain, from the first entry to the last. It
+// stops at the first entry that is missing, out of order, or whose hash does
+// not match its content.
+func Verify(ctx context.Context, entries repository.AuditRepository) (models.AuditVerification, error) {
+	result := models.AuditVerification{Valid: true}
+	for {
+		batch, err := entries.Range(ctx, result.Entries, verifyBatch)
+		if err != nil {
+			return models.AuditVerification{}, err
+		}
+		for i := range batch {
+			entry := &batch[i]
+			expected := result.Entries + 1
+			if reason := check(entry, expected, result.LastHash); reason != "" {
+				result.Valid = false
+				result.BrokenAt = expected
+				result.Reason = reason
+				return result, nil
+			}
+			result.Entries = expected
+			result.LastHash = entry.Hash
+		}
+		if len(batch) < verifyBatch {
+			return result, nil
+		}
+	}
+}
+
+// check returns why entry cannot follow the entry with prevHash at sequence,
+// or "" if it can
+func check(entry *models.AuditEntry, sequence int64, prevHash string) string {
+	if entry.Sequence != sequence {
+		return "entry " + strconv.FormatInt(sequence, 10) + " is missing"
+	}
+	if entry.PrevHash != prevHash {
+		return "previous hash does not match the previous entry"
+	}
+	hash, err := Hash(entry)
+	if err != nil || hash != entry.Hash {
+		return "hash does not match the entry's content"
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+// tampered returns the entries of a log with change applied to them as they
+// are read, as if they had been edited in the database
+type tampered struct {
+	repository.AuditRepository
+	change func(entries []models.AuditEntry) []models.AuditEntry
+}
+
+func (t tampered) Range(ctx context.Context, after, limit int64) ([]models.AuditEntry, error) {
+	entries, err := t.AuditRepository.Range(ctx, after, limit)
+	return t.change(entries), err
+}
+
+func TestDiff(t *testing.T) {
+	type doc struct {
+		Title    string   `json:"title"`
+		Tags     []string `json:"tags,omitempty"`
+		Password string   `json:"password,omitempty"`
+	}
+
+	changes, err := Diff(&doc{Title: "Clean Air Act", Password: "a"}, &doc{Title: "Clean Air Act", Tags: []string{"air"}, Password: "b"}, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.FieldChange{
+		{Field: "password", Before: json.RawMessage(Redacted), After: json.RawMessage(Redacted)},
+		{Field: "tags", After: json.RawMessage(`["air"]`)},
+	}
+	if got, _ := json.Marshal(changes); string(got) != string(mustMarshal(t, want)) {
+		t.Errorf("changes = %s, want %s", got, mustMarshal(t, want))
+	}
+
+	changes, err = Diff(nil, &doc{Title: "Clean Air Act"})
+	if err != nil || len(changes) != 1 || changes[0].Field != "title" || changes[0].Before != nil {
+		t.Errorf("created changes = %+v, %v; want the title set", changes, err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	entries := repository.NewMemoryStore().Audit
+	log := NewLog(entries)
+	for _, title := range []string{"Clean Air Act", "Clean Water Act", "Safe Roads Act"} {
+		entry := models.AuditEntry{Action: ActionCreate, ResourceType: ResourcePolicy, Changes: []models.FieldChange{{Field: "title", After: json.RawMessage(`"` + title + `"`)}}}
+		if err := log.Record(ctx, &entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Verify(ctx, entries)
+	if err != nil || !result.Valid || result.Entries != 3 {
+		t.Fatalf("verification = %+v, %v; want 3 valid entries", result, err)
+	}
+
+	cases := []struct {
+		name   string
+		change func([]models.AuditEntry) []models.AuditEntry
+		broken int64
+	}{
+		{"edited content", func(e []models.AuditEntry) []models.AuditEntry {
+			e[1].Changes[0].After = json.RawMessage(`"Dirty Water Act"`)
+			return e
+		}, 2},
+		{"edited content with a new hash", func(e []models.AuditEntry) []models.AuditEntry {
+			e[1].Action = ActionDelete
+			e[1].Hash, _ = Hash(&e[1])
+			return e
+		}, 3},
+		{"removed entry", func(e []models.AuditEntry) []models.AuditEntry {
+			return append(e[:1], e[2:]...)
+		}, 2},
+		{"reordered entries", func(e []models.AuditEntry) []models.AuditEntry {
+			e[0], e[1] = e[1], e[0]
+			return e
+		}, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := Verify(ctx, tampered{entries, c.change})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Valid || result.BrokenAt != c.broken || result.Reason == "" {
+				t.Errorf("verification = %+v, want entry %d broken", result, c.broken)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,121 @@
+// Command migrate applies, rolls back and reports database migrations.
+//
+// Usage:
+//
+//	go run ./cmd/migrate [-dry-run] status
+//	go run ./cmd/migrate [-dry-run] up [version]
+//	go run ./cmd/migrate [-dry-run] down [steps]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/migrations"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the migrations that would run without applying them")
+	timeout := flag.Duration("timeout", 10*time.Minute, "maximum time to spend applying migrations")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: migrate [-dry-run] [-timeout d] status | up [version] | down [steps]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command := flag.Arg(0)
+	if command == "" {
+		command = "status"
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.Mongo.URI))
+	if err != nil {
+		log.Fatalf("Error connecting to MongoDB: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	migrator, err := migrations.New(client.Database(cfg.Mongo.Database), migrations.All())
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator.DryRun = *dryRun
+
+	verb := "Applied"
+	if *dryRun {
+		verb = "Would apply"
+	}
+
+	switch command {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-45s %s\n", s.Version, s.Description, state)
+		}
+
+	case "up":
+		target := intArg(1, 0)
+		applied, err := migrator.Up(ctx, target)
+		for _, m := range applied {
+			fmt.Printf("%s migration %d: %s\n", verb, m.Version, m.Description)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+
+	case "down":
+		verb = "Rolled back"
+		if *dryRun {
+			verb = "Would roll back"
+		}
+		rolledBack, err := migrator.Down(ctx, intArg(1, 1))
+		for _, m := range rolledBack {
+			fmt.Printf("%s migration %d: %s\n", verb, m.Version, m.Description)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// intArg parses the positional argument at i, returning def when it is absent
+func intArg(i int, def int) int {
+	arg := flag.Arg(i)
+	if arg == "" {
+		return def
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid number %q", arg)
+	}
+	return n
+}
@@ -0,0 +1,600 @@
+// Package config loads and validates the server configuration and manages
+// the database connection.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjamingetches/govtrack/ratelimit"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+	"gopkg.in/yaml.v3"
+)
+
+// Environments the server can run in
+const (
+	Development = "development"
+	Production  = "production"
+)
+
+// DevelopmentJWTSecret signs tokens in development when no secret is set.
+// It is public, so it is refused in production.
+const DevelopmentJWTSecret = "govtrack_jwt_secret_key_for_local_authentication"
+
+// MinJWTSecretLength is the shortest JWT secret accepted in production
+const MinJWTSecretLength = 32
+
+// Config is the complete server configuration
+type Config struct {
+	Environment string            `yaml:"environment"`
+	Server      ServerConfig      `yaml:"server"`
+	Mongo       MongoConfig       `yaml:"mongo"`
+	Auth        AuthConfig        `yaml:"auth"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	APIKeys     APIKeysConfig     `yaml:"api_keys"`
+	HTTPCache   HTTPCacheConfig   `yaml:"http_cache"`
+	Trash       TrashConfig       `yaml:"trash"`
+	Summaries   SummaryConfig     `yaml:"summaries"`
+	Readability ReadabilityConfig `yaml:"readability"`
+	Comments    CommentConfig     `yaml:"comments"`
+	Polls       PollConfig        `yaml:"polls"`
+	Log         LogConfig         `yaml:"log"`
+
+	// Warnings lists settings that are allowed but unsafe, to be logged at startup
+	Warnings []string `yaml:"-"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Port          int           `yaml:"port"`
+	ReadTimeout   time.Duration `yaml:"read_timeout"`
+	WriteTimeout  time.Duration `yaml:"write_timeout"`
+	IdleTimeout   time.Duration `yaml:"idle_timeout"`
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	CORSOrigins   []string      `yaml:"cors_origins"`
+}
+
+// MongoConfig configures the database connection
+type MongoConfig struct {
+	URI            string        `yaml:"uri"`
+	Database       string        `yaml:"database"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	MigrateOnStart bool          `yaml:"migrate_on_start"`
+}
+
+// AuthConfig configures token authentication
+type AuthConfig struct {
+	JWTSecret string        `yaml:"jwt_secret"`
+	TokenTTL  time.Duration `yaml:"token_ttl"`
+
+	// Lockout locks an account after repeated failed logins
+	Lockout ratelimit.LockoutPolicy `yaml:"lockout"`
+}
+
+// RateLimitConfig configures per-client request limits for each route group
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// TrustForwardedFor identifies clients by X-Forwarded-For. Enable it
+	// only behind a proxy that sets the header.
+	TrustForwardedFor bool `yaml:"trust_forwarded_for"`
+
+	// Auth limits login and registration per IP address
+	Auth ratelimit.Limit `yaml:"auth"`
+
+	// Public limits unauthenticated public routes per IP address
+	Public ratelimit.Limit `yaml:"public"`
+
+	// API limits authenticated routes per user
+	API ratelimit.Limit `yaml:"api"`
+}
+
+// APIKeysConfig configures developer API keys
+type APIKeysConfig struct {
+	// DefaultDailyQuota applies to keys created without a quota
+	DefaultDailyQuota int64 `yaml:"default_daily_quota"`
+
+	// MaxDailyQuota is the largest quota a user can request
+	MaxDailyQuota int64 `yaml:"max_daily_quota"`
+
+	// MaxPerUser limits the active keys each user can hold
+	MaxPerUser int `yaml:"max_per_user"`
+}
+
+// HTTPCacheConfig configures caching of public responses
+type HTTPCacheConfig struct {
+	// Entries is the number of responses kept in memory; zero disables the
+	// response cache. ETags and Cache-Control headers are always sent.
+	Entries int `yaml:"entries"`
+
+	// TTL bounds how long a response is served from memory, so that writes
+	// made through other server instances are picked up
+	TTL time.Duration `yaml:"ttl"`
+
+	// MaxAge is how long clients and proxies may reuse public responses
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// TrashConfig configures how long deleted content can be restored
+type TrashConfig struct {
+	// Retention is how long deleted content stays in the trash before it is
+	// purged along with references to it
+	Retention time.Duration `yaml:"retention"`
+
+	// PurgeInterval is how often the trash is checked for expired content
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+// SummaryConfig configures the editorial review of policy summaries
+type SummaryConfig struct {
+	// RequiredApprovals is the number of editors of different affiliations
+	// who must approve a summary before it can be published
+	RequiredApprovals int `yaml:"required_approvals"`
+
+	// Generator drafts summaries of policies for review
+	Generator GeneratorConfig `yaml:"generator"`
+}
+
+// Summary generators
+const (
+	GeneratorExtractive = "extractive" // Ranks the sentences of the policy's sections in process
+	GeneratorRemote     = "remote"     // Calls an external model endpoint
+)
+
+// GeneratorConfig configures the generation of draft summaries. Every
+// interval, up to batch policies with original text and no summary are
+// drafted; an interval of 0 only drafts summaries on request.
+type GeneratorConfig struct {
+	Kind         string        `yaml:"kind"`
+	MaxSentences int           `yaml:"max_sentences"`
+	URL          string        `yaml:"url"`   // Endpoint of the remote generator
+	Token        string        `yaml:"token"` // Bearer token sent to the remote generator
+	Timeout      time.Duration `yaml:"timeout"`
+	Interval     time.Duration `yaml:"interval"`
+	Batch        int           `yaml:"batch"`
+}
+
+// ReadabilityConfig sets the plain-language and neutrality thresholds a
+// summary must meet to be published
+type ReadabilityConfig struct {
+	MaxGrade          float64 `yaml:"max_grade"`           // Flesch-Kincaid grade level
+	MaxSentenceLength float64 `yaml:"max_sentence_length"` // Average words per sentence
+	MaxJargon         int     `yaml:"max_jargon"`          // Distinct legislative glossary terms
+	MaxLoadedTerms    int     `yaml:"max_loaded_terms"`    // Distinct loaded or partisan terms
+
+	// Glossary and Lexicon replace the built-in lists of legislative jargon
+	// and loaded wording if set
+	Glossary []string `yaml:"glossary"`
+	Lexicon  []string `yaml:"lexicon"`
+}
+
+// Comment filters
+const (
+	FilterWordList = "wordlist" // Holds comments using words or phrases of a list
+	FilterNone     = "none"     // Publishes every comment
+)
+
+// CommentConfig configures public comments on policies
+type CommentConfig struct {
+	// Limit is how many comments and edits each user can post
+	Limit ratelimit.Limit `yaml:"limit"`
+
+	// ReportThreshold is the number of reports that hold a published
+	// comment for moderation
+	ReportThreshold int `yaml:"report_threshold"`
+
+	// Filter checks new and edited comments, holding suspicious ones for
+	// moderation
+	Filter FilterConfig `yaml:"filter"`
+}
+
+// PollConfig configures the polls of policies
+type PollConfig struct {
+	// MinBallots is the fewest ballots a state or district needs before its
+	// results are shown and its majority is compared with representatives'
+	// votes, so that few voters' choices are never revealed
+	MinBallots int `yaml:"min_ballots"`
+}
+
+// FilterConfig configures the content filter of comments
+type FilterConfig struct {
+	Kind string `yaml:"kind"`
+
+	// Words replaces the built-in list of the wordlist filter if set
+	Words []string `yaml:"words"`
+}
+
+// LogConfig configures logging
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+// Default returns the configuration used when nothing is set
+func Default() *Config {
+	return &Config{
+		Environment: Development,
+		Server: ServerConfig{
+			Port:          8080,
+			ReadTimeout:   15 * time.Second,
+			WriteTimeout:  15 * time.Second,
+			IdleTimeout:   60 * time.Second,
+			ShutdownDelay: 5 * time.Second,
+			CORSOrigins:   []string{"*"},
+		},
+		Mongo: MongoConfig{
+			URI:            "mongodb://localhost:27017",
+			ConnectTimeout: 10 * time.Second,
+			MigrateOnStart: true,
+		},
+		Auth: AuthConfig{
+			TokenTTL: 7 * 24 * time.Hour,
+			Lockout: ratelimit.LockoutPolicy{
+				Threshold: 5,
+				Base:      time.Minute,
+				Max:       time.Hour,
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Auth:    ratelimit.Limit{Requests: 10, Per: time.Minute},
+			Public:  ratelimit.Limit{Requests: 120, Per: time.Minute},
+			API:     ratelimit.Limit{Requests: 300, Per: time.Minute},
+		},
+		APIKeys: APIKeysConfig{
+			DefaultDailyQuota: 1000,
+			MaxDailyQuota:     100000,
+			MaxPerUser:        10,
+		},
+		HTTPCache: HTTPCacheConfig{
+			Entries: 1000,
+			TTL:     5 * time.Minute,
+			MaxAge:  time.Minute,
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Summaries: SummaryConfig{
+			RequiredApprovals: 2,
+			Generator: GeneratorConfig{
+				Kind:         GeneratorExtractive,
+				MaxSentences: 3,
+				Timeout:      30 * time.Second,
+				Interval:     10 * time.Minute,
+				Batch:        50,
+			},
+		},
+		Readability: ReadabilityConfig{
+			MaxGrade:          10,
+			MaxSentenceLength: 25,
+			MaxJargon:         3,
+			MaxLoadedTerms:    0,
+		},
+		Comments: CommentConfig{
+			Limit:           ratelimit.Limit{Requests: 5, Per: time.Minute},
+			ReportThreshold: 3,
+			Filter: FilterConfig{
+				Kind: FilterWordList,
+			},
+		},
+		Polls: PollConfig{
+			MinBallots: 5,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, then the YAML file named
+// by CONFIG_FILE if set, then environment variables, and validates it
+func Load() (*Config, error) {
+	cfg := Default()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile overlays the settings in a YAML file. Unknown keys are rejected so
+// that typos are not silently ignored.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %v", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// envVar overrides one setting from an environment variable
+type envVar struct {
+	name  string
+	apply func(value string) error
+}
+
+// envVars lists the environment variables read by loadEnv
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		{"APP_ENV", setString(&c.Environment)},
+		{"PORT", setInt(&c.Server.Port)},
+		{"READ_TIMEOUT", setDuration(&c.Server.ReadTimeout)},
+		{"WRITE_TIMEOUT", setDuration(&c.Server.WriteTimeout)},
+		{"IDLE_TIMEOUT", setDuration(&c.Server.IdleTimeout)},
+		{"SHUTDOWN_DELAY", setDuration(&c.Server.ShutdownDelay)},
+		{"CORS_ALLOWED_ORIGINS", setList(&c.Server.CORSOrigins)},
+		{"MONGO_URI", setString(&c.Mongo.URI)},
+		{"MONGO_DATABASE", setString(&c.Mongo.Database)},
+		{"MONGO_CONNECT_TIMEOUT", setDuration(&c.Mongo.ConnectTimeout)},
+		{"MIGRATE_ON_START", setBool(&c.Mongo.MigrateOnStart)},
+		{"JWT_SECRET", setString(&c.Auth.JWTSecret)},
+		{"JWT_TTL", setDuration(&c.Auth.TokenTTL)},
+		{"LOGIN_LOCKOUT_THRESHOLD", setInt(&c.Auth.Lockout.Threshold)},
+		{"LOGIN_LOCKOUT_BASE", setDuration(&c.Auth.Lockout.Base)},
+		{"LOGIN_LOCKOUT_MAX", setDuration(&c.Auth.Lockout.Max)},
+		{"RATE_LIMIT_ENABLED", setBool(&c.RateLimit.Enabled)},
+		{"TRUST_FORWARDED_FOR", setBool(&c.RateLimit.TrustForwardedFor)},
+		{"RATE_LIMIT_AUTH", setLimit(&c.RateLimit.Auth)},
+		{"RATE_LIMIT_PUBLIC", setLimit(&c.RateLimit.Public)},
+		{"RATE_LIMIT_API", setLimit(&c.RateLimit.API)},
+		{"API_KEY_DEFAULT_DAILY_QUOTA", setInt64(&c.APIKeys.DefaultDailyQuota)},
+		{"API_KEY_MAX_DAILY_QUOTA", setInt64(&c.APIKeys.MaxDailyQuota)},
+		{"API_KEY_MAX_PER_USER", setInt(&c.APIKeys.MaxPerUser)},
+		{"HTTP_CACHE_ENTRIES", setInt(&c.HTTPCache.Entries)},
+		{"HTTP_CACHE_TTL", setDuration(&c.HTTPCache.TTL)},
+		{"HTTP_CACHE_MAX_AGE", setDuration(&c.HTTPCache.MaxAge)},
+		{"TRASH_RETENTION", setDuration(&c.Trash.Retention)},
+		{"TRASH_PURGE_INTERVAL", setDuration(&c.Trash.PurgeInterval)},
+		{"SUMMARY_REQUIRED_APPROVALS", setInt(&c.Summaries.RequiredApprovals)},
+		{"SUMMARY_GENERATOR", setString(&c.Summaries.Generator.Kind)},
+		{"SUMMARY_GENERATOR_MAX_SENTENCES", setInt(&c.Summaries.Generator.MaxSentences)},
+		{"SUMMARY_GENERATOR_URL", setString(&c.Summaries.Generator.URL)},
+		{"SUMMARY_GENERATOR_TOKEN", setString(&c.Summaries.Generator.Token)},
+		{"SUMMARY_GENERATOR_TIMEOUT", setDuration(&c.Summaries.Generator.Timeout)},
+		{"SUMMARY_GENERATOR_INTERVAL", setDuration(&c.Summaries.Generator.Interval)},
+		{"SUMMARY_GENERATOR_BATCH", setInt(&c.Summaries.Generator.Batch)},
+		{"READABILITY_MAX_GRADE", setFloat(&c.Readability.MaxGrade)},
+		{"READABILITY_MAX_SENTENCE_LENGTH", setFloat(&c.Readability.MaxSentenceLength)},
+		{"READABILITY_MAX_JARGON", setInt(&c.Readability.MaxJargon)},
+		{"READABILITY_MAX_LOADED_TERMS", setInt(&c.Readability.MaxLoadedTerms)},
+		{"READABILITY_GLOSSARY", setList(&c.Readability.Glossary)},
+		{"READABILITY_LEXICON", setList(&c.Readability.Lexicon)},
+		{"COMMENT_RATE_LIMIT", setLimit(&c.Comments.Limit)},
+		{"COMMENT_REPORT_THRESHOLD", setInt(&c.Comments.ReportThreshold)},
+		{"COMMENT_FILTER", setString(&c.Comments.Filter.Kind)},
+		{"COMMENT_FILTER_WORDS", setList(&c.Comments.Filter.Words)},
+		{"POLL_MIN_BALLOTS", setInt(&c.Polls.MinBallots)},
+		{"LOG_LEVEL", setString(&c.Log.Level)},
+	}
+}
+
+// loadEnv overlays the environment variables that are set
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	for _, v := range c.envVars() {
+		value, ok := lookup(v.name)
+		if !ok {
+			continue
+		}
+		if err := v.apply(strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", v.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setString(dst *string) func(string) error {
+	return func(value string) error {
+		*dst = value
+		return nil
+	}
+}
+
+func setInt(dst *int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*dst = n
+		return nil
+	}
+}
+
+func setInt64(dst *int64) func(string) error {
+	return func(value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*dst = n
+		return nil
+	}
+}
+
+func setFloat(dst *float64) func(string) error {
+	return func(value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*dst = f
+		return nil
+	}
+}
+
+func setBool(dst *bool) func(string) error {
+	return func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*dst = b
+		return nil
+	}
+}
+
+func setDuration(dst *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 15s or 5m", value)
+		}
+		*dst = d
+		return nil
+	}
+}
+
+func setLimit(dst *ratelimit.Limit) func(string) error {
+	return func(value string) error {
+		return dst.UnmarshalText([]byte(value))
+	}
+}
+
+func setList(dst *[]string) func(string) error {
+	return func(value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+		return nil
+	}
+}
+
+// Validate checks every setting, fills in derived defaults and reports all
+// problems at once. In production a strong JWT secret is required.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	c.Environment = strings.ToLower(c.Environment)
+	if c.Environment != Development && c.Environment != Production {
+		invalid("environment must be %s or %s, not %q", Development, Production, c.Environment)
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		invalid("server port %d is out of range", c.Server.Port)
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		invalid("server read_timeout, write_timeout and idle_timeout must be positive")
+	}
+	if c.Server.ShutdownDelay < 0 {
+		invalid("server shutdown_delay must not be negative")
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		invalid("server cors_origins must list at least one origin")
+	}
+
+	if _, err := connstring.Parse(c.Mongo.URI); err != nil {
+		invalid("mongo uri is invalid: %v", err)
+	} else if c.Mongo.Database == "" {
+		c.Mongo.Database = DatabaseNameFromURI(c.Mongo.URI)
+	}
+	if c.Mongo.ConnectTimeout <= 0 {
+		invalid("mongo connect_timeout must be positive")
+	}
+
+	if c.Auth.TokenTTL <= 0 {
+		invalid("auth token_ttl must be positive")
+	}
+	if lockout := c.Auth.Lockout; lockout.Threshold < 1 || lockout.Base <= 0 || lockout.Max < lockout.Base {
+		invalid("auth lockout needs a positive threshold and base, and a max of at least base")
+	}
+	if c.RateLimit.Enabled {
+		for _, limit := range []ratelimit.Limit{c.RateLimit.Auth, c.RateLimit.Public, c.RateLimit.API} {
+			if limit.Requests < 1 || limit.Per <= 0 {
+				invalid("rate_limit limits must allow a positive number of requests per positive period, not %q", limit)
+				break
+			}
+		}
+	}
+	if keys := c.APIKeys; keys.DefaultDailyQuota < 1 || keys.MaxDailyQuota < keys.DefaultDailyQuota || keys.MaxPerUser < 1 {
+		invalid("api_keys needs a positive default_daily_quota and max_per_user, and a max_daily_quota of at least the default")
+	}
+	if cache := c.HTTPCache; cache.Entries < 0 || cache.MaxAge < 0 || (cache.Entries > 0 && cache.TTL <= 0) {
+		invalid("http_cache needs a non-negative entries and max_age, and a positive ttl when entries is set")
+	}
+	if c.Trash.Retention <= 0 || c.Trash.PurgeInterval <= 0 {
+		invalid("trash needs a positive retention and purge_interval")
+	}
+	if c.Summaries.RequiredApprovals < 1 {
+		invalid("summaries required_approvals must be at least 1")
+	}
+	switch g := c.Summaries.Generator; {
+	case g.Kind != GeneratorExtractive && g.Kind != GeneratorRemote:
+		invalid("summaries generator kind must be %s or %s, not %q", GeneratorExtractive, GeneratorRemote, g.Kind)
+	case g.Kind == GeneratorRemote && g.URL == "":
+		invalid("summaries generator url (SUMMARY_GENERATOR_URL) is required for the %s generator", GeneratorRemote)
+	case g.MaxSentences < 1 || g.Timeout <= 0 || g.Interval < 0 || g.Batch < 1:
+		invalid("summaries generator needs a positive max_sentences, timeout and batch, and a non-negative interval")
+	}
+	if r := c.Readability; r.MaxGrade <= 0 || r.MaxSentenceLength <= 0 || r.MaxJargon < 0 || r.MaxLoadedTerms < 0 {
+		invalid("readability needs a positive max_grade and max_sentence_length, and a non-negative max_jargon and max_loaded_terms")
+	}
+	if cm := c.Comments; cm.Limit.Requests < 1 || cm.Limit.Per <= 0 || cm.ReportThreshold < 1 {
+		invalid("comments needs a limit of a positive number of requests per positive period, and a positive report_threshold")
+	}
+	if kind := c.Comments.Filter.Kind; kind != FilterWordList && kind != FilterNone {
+		invalid("comments filter kind must be %s or %s, not %q", FilterWordList, FilterNone, kind)
+	}
+	if c.Polls.MinBallots < 1 {
+		invalid("polls min_ballots must be positive, not %d", c.Polls.MinBallots)
+	}
+	if c.Environment == Production {
+		switch {
+		case c.Auth.JWTSecret == "":
+			invalid("auth jwt_secret (JWT_SECRET) is required in production")
+		case c.Auth.JWTSecret == DevelopmentJWTSecret:
+			invalid("auth jwt_secret must not be the development secret in production")
+		case len(c.Auth.JWTSecret) < MinJWTSecretLength:
+			invalid("auth jwt_secret must be at least %d characters in production", MinJWTSecretLength)
+		}
+		for _, origin := range c.Server.CORSOrigins {
+			if origin == "*" {
+				c.Warnings = append(c.Warnings, "server cors_origins allows every origin in production")
+			}
+		}
+	} else if c.Auth.JWTSecret == "" {
+		c.Auth.JWTSecret = DevelopmentJWTSecret
+		c.Warnings = append(c.Warnings, "using the development JWT secret; set JWT_SECRET outside local development")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		invalid("log level must be debug, info, warn or error, not %q", c.Log.Level)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Addr returns the address the HTTP server listens on
+func (c *Config) Addr() string {
+	return ":" + strconv.Itoa(c.Server.Port)
+}
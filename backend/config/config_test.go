@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a lookup function over vars, standing in for os.LookupEnv
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func TestDefaultsAreValidForDevelopment(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.JWTSecret != DevelopmentJWTSecret || len(cfg.Warnings) != 1 {
+		t.Errorf("secret = %q, warnings = %v; want the development secret with a warning", cfg.Auth.JWTSecret, cfg.Warnings)
+	}
+	if cfg.Mongo.Database != DatabaseName {
+		t.Errorf("database = %q, want %q", cfg.Mongo.Database, DatabaseName)
+	}
+}
+
+func TestFileThenEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+server:
+  port: 9000
+  read_timeout: 5s
+  cors_origins: [https://govtrack.example]
+mongo:
+  uri: mongodb://db.internal:27017/civic
+auth:
+  token_ttl: 24h
+  lockout:
+    threshold: 3
+rate_limit:
+  auth: 5/30s
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Default()
+	if err := cfg.loadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.loadEnv(env(map[string]string{
+		"PORT":                 "9100",
+		"CORS_ALLOWED_ORIGINS": "https://a.example, https://b.example",
+		"MIGRATE_ON_START":     "false",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.Port != 9100 || cfg.Server.ReadTimeout != 5*time.Second || cfg.Server.WriteTimeout != 15*time.Second {
+		t.Errorf("server = %+v, want the port from the environment and timeouts from the file and defaults", cfg.Server)
+	}
+	if strings.Join(cfg.Server.CORSOrigins, " ") != "https://a.example https://b.example" {
+		t.Errorf("cors origins = %v", cfg.Server.CORSOrigins)
+	}
+	if cfg.Mongo.Database != "civic" || cfg.Mongo.MigrateOnStart {
+		t.Errorf("mongo = %+v, want database civic without migrations", cfg.Mongo)
+	}
+	if cfg.Auth.TokenTTL != 24*time.Hour || cfg.Auth.Lockout.Threshold != 3 || cfg.Auth.Lockout.Max != time.Hour {
+		t.Errorf("auth = %+v, want the file's token ttl and lockout threshold", cfg.Auth)
+	}
+	if cfg.RateLimit.Auth.String() != "5/30s" || cfg.RateLimit.API.String() != "300/1m0s" {
+		t.Errorf("rate limits = %+v, want the file's auth limit and the default API limit", cfg.RateLimit)
+	}
+}
+
+func TestUnknownFileKeysAreRejected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  prot: 9000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Default().loadFile(path); err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Errorf("err = %v, want the unknown key reported", err)
+	}
+}
+
+func TestInvalidEnvironmentValues(t *testing.T) {
+	err := Default().loadEnv(env(map[string]string{"PORT": "eighty", "JWT_TTL": "7", "READABILITY_MAX_GRADE": "eighth"}))
+	for _, want := range []string{"PORT", "JWT_TTL", "READABILITY_MAX_GRADE"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want %s reported", err, want)
+		}
+	}
+}
+
+func TestProductionRequiresStrongSecret(t *testing.T) {
+	for secret, want := range map[string]string{
+		"":                   "required in production",
+		DevelopmentJWTSecret: "development secret",
+		"hunter2":            "at least 32 characters",
+	} {
+		cfg := Default()
+		cfg.Environment = Production
+		cfg.Auth.JWTSecret = secret
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("secret %q: err = %v, want %q", secret, err, want)
+		}
+	}
+
+	cfg := Default()
+	cfg.Environment = Production
+	cfg.Auth.JWTSecret = strings.Repeat("s", MinJWTSecretLength)
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Warnings) != 1 || !strings.Contains(cfg.Warnings[0], "every origin") {
+		t.Errorf("warnings = %v, want the wildcard CORS origin flagged", cfg.Warnings)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.Environment = "staging"
+	cfg.Server.Port = 70000
+	cfg.Log.Level = "loud"
+	cfg.Comments.Filter.Kind = "ai"
+
+	err := cfg.Validate()
+	for _, want := range []string{"environment", "port", "log level", "comments filter"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want %s reported", err, want)
+		}
+	}
+}
@@ -1,107 +1,98 @@
-package config
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"os"
-	"strings"
-	"time"
-
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-// Database names
-const (
-	DatabaseName = "govtrack"
-)
-
-// Collection names
-const (
-	UsersCollection      = "users"
-	PoliciesCollection   = "policies"
-	RepresentativesCollection = "representatives"
-	VotingRecordsCollection  = "voting_records"
-	PoliticalQuizzesCollection = "political_quizzes"
-)
-
-var (
-	Client     *mongo.Client
-	DB         *mongo.Database
-	err        error
-)
-
-// ConnectDB connects to MongoDB
-func ConnectDB() error {
-	// Get MongoDB URI from environment variable
-	mongoURI := os.Getenv("MONGO_URI")
-	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017"
-	}
-	
-	// Extract database name from URI or use default
-	dbName := "govtrack"
-	if strings.Contains(mongoURI, "/") {
-		parts := strings.Split(mongoURI, "/")
-		if len(parts) > 2 {
-			dbNameParts := strings.Split(parts[len(parts)-1], "?")
-			if dbNameParts[0] != "" {
-				dbName = dbNameParts[0]
-			}
-		}
-	}
-	
-	log.Printf("Connecting to MongoDB at %s", mongoURI)
-	
-	// Set client options
-	clientOptions := options.Client().ApplyURI(mongoURI)
-	
-	// Connect to MongoDB
-	Client, err = mongo.Connect(context.TODO(), clientOptions)
-	if err != nil {
-		return fmt.Errorf("failed to connect to MongoDB: %v", err)
-	}
-	
-	// Check the connection
-	err = Client.Ping(context.TODO(), nil)
-	if err != nil {
-		return fmt.Errorf("failed to ping MongoDB: %v", err)
-	}
-	
-	log.Println("Successfully connected to MongoDB")
-	
-	// Initialize the database
-	DB = Client.Database(dbName)
-	if DB == nil {
-		return fmt.Errorf("failed to get database: %s", dbName)
-	}
-	
-	log.Printf("Using database: %s", dbName)
-	
-	return nil
-}
-
-// GetCollection returns a MongoDB collection
-func GetCollection(collectionName string) *mongo.Collection {
-	if DB == nil {
-		log.Println("Warning: Database not initialized, attempting to connect")
-		err := ConnectDB()
-		if err != nil {
-			log.Fatalf("Failed to connect to database: %v", err)
-		}
-	}
-	
-	return DB.Collection(collectionName)
-}
-
-// DisconnectDB closes the connection to the MongoDB database
-func DisconnectDB(client *mongo.Client) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := client.Disconnect(ctx); err != nil {
-		log.Printf("Error disconnecting from MongoDB: %v", err)
-	}
-	log.Println("Disconnected from MongoDB")
-} 
\ No newline at end of file
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/benjamingetches/govtrack/metrics"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+)
+
+// Database names
+const (
+	// DatabaseName is used when MONGO_URI does not name a database
+	DatabaseName = "govtrack"
+)
+
+// Collection names
+const (
+	UsersCollection           = "users"
+	PoliciesCollection        = "policies"
+	RepresentativesCollection = "representatives"
+	VotingRecordsCollection   = "voting_records"
+	QuizzesCollection         = "quizzes"
+	QuizResultsCollection     = "quiz_results"
+	APIKeysCollection         = "api_keys"
+	AuditCollection           = "audit_log"
+	SummariesCollection       = "summaries"
+	AnnotationsCollection     = "annotations"
+	CommentsCollection        = "comments"
+	BallotsCollection         = "ballots"
+)
+
+// DatabaseNameFromURI returns the database named in a MongoDB connection
+// string, or DatabaseName if the URI does not name one
+func DatabaseNameFromURI(mongoURI string) string {
+	cs, err := connstring.Parse(mongoURI)
+	if err != nil || cs.Database == "" {
+		return DatabaseName
+	}
+	return cs.Database
+}
+
+// ConnectDB connects to MongoDB, verifies the connection and returns the
+// client together with the configured database
+func ConnectDB(ctx context.Context, cfg MongoConfig) (*mongo.Client, *mongo.Database, error) {
+	monitor := commandMonitors(metrics.CommandMonitor(), otelmongo.NewMonitor())
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI).SetMonitor(monitor))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to MongoDB: %v", err)
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, nil, fmt.Errorf("failed to ping MongoDB: %v", err)
+	}
+
+	slog.Info("Using database", "database", cfg.Database)
+
+	return client, client.Database(cfg.Database), nil
+}
+
+// commandMonitors combines command monitors, since the driver accepts only one
+func commandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				m.Started(ctx, evt)
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				m.Succeeded(ctx, evt)
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				m.Failed(ctx, evt)
+			}
+		},
+	}
+}
+
+// DisconnectDB closes the connection to the MongoDB database
+func DisconnectDB(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := client.Disconnect(ctx); err != nil {
+		slog.Error("Error disconnecting from MongoDB", "error", err)
+	}
+	slog.Info("Disconnected from MongoDB")
+}
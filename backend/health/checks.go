@@ -0,0 +1,32 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/benjamingetches/govtrack/migrations"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// MongoPing checks that the primary MongoDB server responds to a ping
+func MongoPing(client *mongo.Client) Check {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	}
+}
+
+// Migrations checks that every known migration has been applied, so that
+// the server never serves traffic against an outdated schema
+func Migrations(migrator *migrations.Migrator) Check {
+	return func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, starting with version %d", len(pending), pending[0].Version)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,149 @@
+// Package health reports whether the server is alive and ready to receive
+// traffic. Liveness only says the process is serving requests; readiness runs
+// a check for every dependency, such as the database and background workers,
+// and fails while the server is shutting down so that load balancers drain it.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds how long a single readiness check may run
+const DefaultTimeout = 2 * time.Second
+
+// Statuses reported for the server and for each check
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusReady        = "ready"
+	StatusNotReady     = "not_ready"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check reports whether a dependency is usable. It should respect ctx.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one readiness check
+type CheckResult struct {
+	Status    string  `json:"status" enum:"ok,fail"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the body of liveness and readiness responses
+type Report struct {
+	Status string                 `json:"status" enum:"ok,ready,not_ready,shutting_down"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Checker runs the registered readiness checks
+type Checker struct {
+	timeout  time.Duration
+	draining atomic.Bool
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// NewChecker creates a Checker that gives each check timeout to complete
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: map[string]Check{}}
+}
+
+// Add registers a readiness check under name, replacing any existing one
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Drain marks the server as shutting down. Readiness fails from then on while
+// liveness keeps succeeding, so in-flight requests can finish.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs every check concurrently and reports the overall readiness
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusReady, Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusNotReady
+		}
+	}
+	if c.draining.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+// run runs one check within the checker's timeout
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ServeLiveness responds 200 while the process can serve requests
+func (c *Checker) ServeLiveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// ServeReadiness responds 200 when every check passes and 503 otherwise,
+// with the result of each check
+func (c *Checker) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Ready(r.Context())
+	status := http.StatusOK
+	if report.Status != StatusReady {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,40 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat tracks the liveness of a background worker. The worker calls Beat
+// on every iteration; the heartbeat's check fails when no beat has been seen
+// for longer than maxAge.
+type Heartbeat struct {
+	maxAge time.Duration
+	last   atomic.Int64
+}
+
+// Heartbeat registers a readiness check named "worker:<name>" for a
+// background worker and returns the heartbeat it should beat. The worker
+// counts as healthy until maxAge has passed without a beat.
+func (c *Checker) Heartbeat(name string, maxAge time.Duration) *Heartbeat {
+	h := &Heartbeat{maxAge: maxAge}
+	h.Beat()
+	c.Add("worker:"+name, h.Check)
+	return h
+}
+
+// Beat records that the worker is making progress
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Check fails if the worker has not beaten within maxAge
+func (h *Heartbeat) Check(context.Context) error {
+	age := time.Since(time.Unix(0, h.last.Load()))
+	if age > h.maxAge {
+		return fmt.Errorf("no heartbeat for %s", age.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,463 @@
+// Package legislation parses the text of bills into titles, sections,
+// subsections, paragraphs, subparagraphs and clauses, and detects the cross
+// references between them, such as "section 3(b)". Every provision has an
+// anchor derived from its numbering, such as "sec-3-b-2" for section
+// 3(b)(2), so anchors stay the same when text elsewhere changes.
+package legislation
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/benjamingetches/govtrack/api/models"
+)
+
+// levels orders the kinds of provisions from the outermost
+var levels = map[string]int{
+	models.ProvisionTitle:        0,
+	models.ProvisionSection:      1,
+	models.ProvisionSubsection:   2,
+	models.ProvisionParagraph:    3,
+	models.ProvisionSubparagraph: 4,
+	models.ProvisionClause:       5,
+}
+
+var (
+	// titleLine matches the start of a title, such as "TITLE II—FUNDING"
+	titleLine = regexp.MustCompile(`^(?:TITLE|Title)\s+([IVXLC]+|\d+)\b(?:\s*(?:—|–|--|-|\.)\s*|\s*$)`)
+
+	// sectionLine matches the start of a section, such as "SEC. 2." or
+	// "Section 101". Prose such as "Section 3 of the Act" does not match.
+	sectionLine = regexp.MustCompile(`^(?:SEC\.|SECTION|Sec\.|Section)\s+(\d+[A-Za-z]?)(?:\.\s*|\s*$)`)
+
+	// enumerator matches the label of a subsection, paragraph, subparagraph
+	// or clause, such as "(b)", "(2)", "(A)" or "(iv)"
+	enumerator = regexp.MustCompile(`^\(([a-z]{1,4}|[A-Z]{1,4}|\d{1,3})\)\s*`)
+
+	// dashHeading matches a heading ended by a period and a dash, as in
+	// "IN GENERAL.—"
+	dashHeading = regexp.MustCompile(`^([A-Z][^.—\n]{0,99})\.\s*(?:—|--)\s*`)
+
+	// reference matches mentions of other provisions: a section and the
+	// labels of provisions within it, as in "section 3(b)(2)", a provision
+	// of the same section, as in "paragraph (2)", optionally of another
+	// provision, as in "paragraph (2) of subsection (a)", or a title
+	reference = regexp.MustCompile(`\b(?:` + section + `|` + relative + `(?:\s+of\s+(?:` + relative + `|` + section + `))?|[Tt]itle\s+([IVXLC]+)\b)`)
+
+	// external matches the words after a reference to another law, as in
+	// "section 3 of the Clean Air Act" or "section 1401 of title 42"
+	external = regexp.MustCompile(`^,?\s+of\s+(?:the\s+[A-Z]|[A-Z]|title\s)`)
+
+	// label matches each label of a reference, such as "(b)"
+	label = regexp.MustCompile(`\(([A-Za-z0-9]{1,4})\)`)
+
+	// roman matches lowercase Roman numerals, which label clauses
+	roman = regexp.MustCompile(`^(?:x{0,3})(?:ix|iv|v?i{0,3})$`)
+)
+
+// Parts of reference. Each captures a number or kind and labels.
+const (
+	section  = `(?:[Ss]ection|[Ss]ec\.)\s+(\d+[A-Za-z]?)((?:\([A-Za-z0-9]{1,4}\))*)`
+	relative = `([Ss]ubsection|[Pp]aragraph|[Ss]ubparagraph|[Cc]lause)\s+((?:\([A-Za-z0-9]{1,4}\))+)`
+)
+
+// node is a provision being parsed. Offsets are in bytes.
+type node struct {
+	provision models.Provision
+	parent    *node
+	children  []*node
+	start     int // Where the provision's label starts
+	ownStart  int // Where its own text starts, after its label and heading
+	ownEnd    int // Where its first child starts, if it has any
+	end       int
+}
+
+// parser builds the tree of provisions of a text
+type parser struct {
+	text    string
+	roots   []*node
+	stack   []*node
+	anchors map[string]*node
+}
+
+// Parse splits text into its provisions. Text before the first provision,
+// such as a bill's long title, belongs to none. Offsets count characters
+// (Unicode code points) from the start of text.
+func Parse(text string) []models.Provision {
+	p := &parser{text: text, anchors: map[string]*node{}}
+	for offset := 0; offset < len(text); {
+		lineEnd := strings.IndexByte(text[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += offset
+		}
+		line := text[offset:lineEnd]
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		p.line(offset+indent, line[indent:])
+		offset = lineEnd + 1
+	}
+	p.closeTo(-1, len(text))
+
+	p.resolveReferences()
+	return p.provisions()
+}
+
+// line parses a line starting at pos. Lines that start no provision
+// continue the text of the current one.
+func (p *parser) line(pos int, line string) {
+	if m := titleLine.FindStringSubmatchIndex(line); m != nil {
+		heading, consumed := sectionHeading(line[m[1]:])
+		p.open(models.ProvisionTitle, line[m[2]:m[3]], heading, pos, pos+m[1]+consumed)
+		return
+	}
+	if m := sectionLine.FindStringSubmatchIndex(line); m != nil {
+		heading, consumed := sectionHeading(line[m[1]:])
+		own := m[1] + consumed
+		p.open(models.ProvisionSection, line[m[2]:m[3]], heading, pos, pos+own)
+		pos, line = pos+own, line[own:]
+	}
+	p.enumerators(pos, line)
+}
+
+// enumerators opens the provisions labelled at the start of line, which
+// starts at pos. Labels can follow each other, as in "(a)(1)" or
+// "(a) IN GENERAL.—(1)".
+func (p *parser) enumerators(pos int, line string) {
+	for {
+		m := enumerator.FindStringSubmatchIndex(line)
+		if m == nil {
+			return
+		}
+		label := line[m[2]:m[3]]
+		heading, consumed := enumeratorHeading(line[m[1]:])
+		own := m[1] + consumed
+		p.open(p.classify(label), label, heading, pos, pos+own)
+		pos, line = pos+own, line[own:]
+	}
+}
+
+// classify returns the kind of provision labelled label. Digits label
+// paragraphs and capitals subparagraphs. Lowercase Roman numerals label
+// clauses within subparagraphs; other lowercase letters label subsections.
+func (p *parser) classify(label string) string {
+	switch {
+	case unicode.IsDigit(rune(label[0])):
+		return models.ProvisionParagraph
+	case unicode.IsUpper(rune(label[0])):
+		return models.ProvisionSubparagraph
+	case roman.MatchString(label) && p.within(models.ProvisionSubparagraph, models.ProvisionClause):
+		return models.ProvisionClause
+	}
+	return models.ProvisionSubsection
+}
+
+// within reports whether the innermost open provision is of one of kinds
+func (p *parser) within(kinds ...string) bool {
+	if len(p.stack) == 0 {
+		return false
+	}
+	kind := p.stack[len(p.stack)-1].provision.Kind
+	for _, k := range kinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+// open starts a provision at start whose own text starts at ownStart,
+// closing the open provisions it does not belong to
+func (p *parser) open(kind, number, heading string, start, ownStart int) {
+	p.closeTo(levels[kind], start)
+
+	n := &node{
+		provision: models.Provision{Kind: kind, Number: number, Heading: heading},
+		start:     start,
+		ownStart:  ownStart,
+		ownEnd:    -1,
+	}
+	if len(p.stack) > 0 {
+		n.parent = p.stack[len(p.stack)-1]
+		if len(n.parent.children) == 0 {
+			n.parent.ownEnd = start
+		}
+		n.parent.children = append(n.parent.children, n)
+	} else {
+		p.roots = append(p.roots, n)
+	}
+	n.provision.Anchor = p.anchor(n)
+	p.anchors[n.provision.Anchor] = n
+	p.stack = append(p.stack, n)
+}
+
+// closeTo closes the open provisions at level or deeper where the next one
+// starts
+func (p *parser) closeTo(level, start int) {
+	for len(p.stack) > 0 {
+		n := p.stack[len(p.stack)-1]
+		if levels[n.provision.Kind] < level {
+			return
+		}
+		n.end = start
+		if n.ownEnd < 0 {
+			n.ownEnd = start
+		}
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+}
+
+// anchor returns a unique anchor for n. Sections and titles are numbered
+// throughout a bill; other provisions are numbered within their parent.
+func (p *parser) anchor(n *node) string {
+	number := strings.ToLower(n.provision.Number)
+	var anchor string
+	switch {
+	case n.provision.Kind == models.ProvisionTitle:
+		anchor = "title-" + number
+	case n.provision.Kind == models.ProvisionSection:
+		anchor = "sec-" + number
+	case n.parent != nil:
+		anchor = n.parent.provision.Anchor + "-" + number
+	default:
+		anchor = number
+	}
+
+	// Malformed text can repeat a number
+	unique := anchor
+	for i := 2; p.anchors[unique] != nil; i++ {
+		unique = anchor + "-" + strconv.Itoa(i)
+	}
+	return unique
+}
+
+// sectionHeading splits the heading of a title or section from the rest of
+// its first line: the words before a dash, a short line of their own, or
+// words in capitals ending with a period. It returns the heading and the
+// number of bytes it takes up.
+func sectionHeading(rest string) (string, int) {
+	if enumerator.MatchString(rest) {
+		return "", 0
+	}
+	if heading, consumed := enumeratorHeading(rest); consumed > 0 {
+		return heading, consumed
+	}
+	trimmed := strings.TrimSpace(rest)
+	if trimmed != "" && !strings.Contains(trimmed, ". ") && len(strings.Fields(trimmed)) <= 12 {
+		return strings.TrimRight(trimmed, "."), len(rest)
+	}
+	if i := strings.Index(rest, ". "); i > 0 && !strings.ContainsFunc(rest[:i], unicode.IsLower) && len(strings.Fields(rest[:i])) <= 12 {
+		return strings.TrimSpace(rest[:i]), i + 2
+	}
+	return "", 0
+}
+
+// enumeratorHeading splits the heading of a subsection, paragraph,
+// subparagraph or clause from the rest of its first line: the words before
+// a dash, or a line in capitals
+func enumeratorHeading(rest string) (string, int) {
+	if m := dashHeading.FindStringSubmatchIndex(rest); m != nil {
+		return strings.TrimSpace(rest[m[2]:m[3]]), m[1]
+	}
+	trimmed := strings.TrimSpace(rest)
+	if strings.ContainsFunc(trimmed, unicode.IsLetter) && !strings.ContainsFunc(trimmed, unicode.IsLower) {
+		return strings.TrimRight(trimmed, "."), len(rest)
+	}
+	return "", 0
+}
+
+// resolveReferences finds the references in the own text of every
+// provision and the anchors of those made to provisions of the same text
+func (p *parser) resolveReferences() {
+	for _, n := range p.anchors {
+		own := p.text[n.ownStart:n.ownEnd]
+		for _, m := range reference.FindAllStringSubmatchIndex(own, -1) {
+			ref := models.CrossReference{
+				Text:  strings.Join(strings.Fields(own[m[0]:m[1]]), " "),
+				Start: n.ownStart + m[0],
+				End:   n.ownStart + m[1],
+			}
+			if !external.MatchString(own[m[1]:]) {
+				if anchor := p.resolve(n, own, m); p.anchors[anchor] != nil {
+					ref.Anchor = anchor
+				}
+			}
+			n.provision.References = append(n.provision.References, ref)
+		}
+	}
+}
+
+// resolve returns the anchor of the provision that the reference matched by
+// m in own, the own text of n, refers to. References to a subsection are
+// within n's section, to a paragraph within its subsection or section, and
+// so on.
+func (p *parser) resolve(n *node, own string, m []int) string {
+	group := func(i int) string {
+		if m[2*i] < 0 {
+			return ""
+		}
+		return own[m[2*i]:m[2*i+1]]
+	}
+
+	// relativeTo returns the anchor of the provision containing n that
+	// provisions of kind are numbered within
+	relativeTo := func(kind string) string {
+		for within := n; within != nil; within = within.parent {
+			if levels[within.provision.Kind] < levels[strings.ToLower(kind)] {
+				return within.provision.Anchor
+			}
+		}
+		return ""
+	}
+
+	var base, labels string
+	switch {
+	case group(1) != "":
+		base, labels = "sec-"+strings.ToLower(group(1)), group(2)
+	case group(9) != "":
+		return "title-" + strings.ToLower(group(9))
+	case group(5) != "":
+		// Of another provision of the same section
+		base, labels = relativeTo(group(5)), group(6)+group(4)
+	case group(7) != "":
+		// Of another section
+		base, labels = "sec-"+strings.ToLower(group(7)), group(8)+group(4)
+	default:
+		base, labels = relativeTo(group(3)), group(4)
+	}
+
+	anchor := base
+	for _, l := range label.FindAllStringSubmatch(labels, -1) {
+		if anchor != "" {
+			anchor += "-"
+		}
+		anchor += strings.ToLower(l[1])
+	}
+	return anchor
+}
+
+// provisions converts the parsed tree, turning byte offsets into
+// character offsets
+func (p *parser) provisions() []models.Provision {
+	var offsets []int
+	var collect func(nodes []*node)
+	collect = func(nodes []*node) {
+		for _, n := range nodes {
+			offsets = append(offsets, n.start, trimEnd(p.text, n.start, n.end))
+			for _, ref := range n.provision.References {
+				offsets = append(offsets, ref.Start, ref.End)
+			}
+			collect(n.children)
+		}
+	}
+	collect(p.roots)
+	chars := characterOffsets(p.text, offsets)
+
+	var convert func(nodes []*node) []models.Provision
+	convert = func(nodes []*node) []models.Provision {
+		if len(nodes) == 0 {
+			return nil
+		}
+		provisions := make([]models.Provision, len(nodes))
+		for i, n := range nodes {
+			provision := n.provision
+			provision.Text = strings.Join(strings.Fields(p.text[n.ownStart:n.ownEnd]), " ")
+			provision.Start = chars[n.start]
+			provision.End = chars[trimEnd(p.text, n.start, n.end)]
+			for j := range provision.References {
+				provision.References[j].Start = chars[provision.References[j].Start]
+				provision.References[j].End = chars[provision.References[j].End]
+			}
+			provision.Children = convert(n.children)
+			provisions[i] = provision
+		}
+		return provisions
+	}
+	return convert(p.roots)
+}
+
+// trimEnd moves end back over the whitespace before it, but not before start
+func trimEnd(text string, start, end int) int {
+	return start + len(strings.TrimRightFunc(text[start:end], unicode.IsSpace))
+}
+
+// characterOffsets maps byte offsets in text to character offsets
+func characterOffsets(text string, offsets []int) map[int]int {
+	for i, offset := range offsets {
+		offsets[i] = min(offset, len(text))
+	}
+	sort.Ints(offsets)
+	chars := make(map[int]int, len(offsets)+1)
+	at, count := 0, 0
+	for _, offset := range offsets {
+		count += utf8.RuneCountInString(text[at:offset])
+		at = offset
+		chars[offset] = count
+	}
+	chars[len(text)] = utf8.RuneCountInString(text)
+	return chars
+}
+
+// Find returns the provision anchored at anchor and the provisions
+// containing it, from the outermost, or nil if there is none
+func Find(provisions []models.Provision, anchor string) (*models.Provision, []models.Provision) {
+	for i := range provisions {
+		if provisions[i].Anchor == anchor {
+			return &provisions[i], nil
+		}
+		if found, path := Find(provisions[i].Children, anchor); found != nil {
+			return found, append([]models.Provision{provisions[i]}, path...)
+		}
+	}
+	return nil, nil
+}
+
+// Cite returns the conventional citation of provision within path, the
+// provisions containing it, such as "§ 3(b)(2)" or "title II"
+func Cite(path []models.Provision, provision models.Provision) string {
+	var cite string
+	for _, p := range append(path, provision) {
+		switch p.Kind {
+		case models.ProvisionTitle:
+			cite = "title " + p.Number
+		case models.ProvisionSection:
+			cite = "§ " + p.Number
+		default:
+			if strings.HasPrefix(cite, "title ") {
+				cite = ""
+			}
+			cite += "(" + p.Number + ")"
+		}
+	}
+	return cite
+}
+
+// FullText returns the text of provision and its children, without their
+// labels and headings
+func FullText(provision models.Provision) string {
+	texts := []string{provision.Text}
+	for _, child := range provision.Children {
+		texts = append(texts, FullText(child))
+	}
+	return strings.Join(strings.Fields(strings.Join(texts, " ")), " ")
+}
+
+// Containing returns the innermost provision containing the characters from
+// start to end and the provisions containing it, or nil if there is none
+func Containing(provisions []models.Provision, start, end int) (*models.Provision, []models.Provision) {
+	for i := range provisions {
+		p := &provisions[i]
+		if p.Start > start || p.End < end {
+			continue
+		}
+		if inner, path := Containing(p.Children, start, end); inner != nil {
+			return inner, append([]models.Provision{*p}, path...)
+		}
+		return p, nil
+	}
+	return nil, nil
+}
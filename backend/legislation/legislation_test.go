@@ -0,0 +1,157 @@
+package legislation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/api/models"
+)
+
+const bill = `A BILL To test the drinking water of schools—
+
+TITLE I—TESTING
+SEC. 101. SHORT TITLE.
+This Act may be cited as the “Safe School Water Act”.
+SEC. 102. TESTING OF DRINKING WATER.
+(a) In General.—Each school shall test its water—
+  (1) every year; and
+  (2) after any repair described in subsection (c).
+(b) Reports.—Results under subsection (a)(1) shall be published, as
+required by section 1412 of the Safe Drinking Water Act.
+(c) REPAIRS.—(1) A school that fails a test under paragraph (2) of subsection (a) shall—
+    (A) replace its fixtures; or
+    (B) install filters.
+(2) Nothing in this section affects title II.
+TITLE II—FUNDING
+SEC. 201. GRANTS.
+The Secretary shall award grants to carry out section 102(c)(1)(B) and section 7(a).
+`
+
+// anchors returns the anchors of provisions and their children, depth first
+func anchors(provisions []models.Provision) []string {
+	var all []string
+	for _, p := range provisions {
+		all = append(all, p.Anchor)
+		all = append(all, anchors(p.Children)...)
+	}
+	return all
+}
+
+func TestParseStructure(t *testing.T) {
+	provisions := Parse(bill)
+	want := []string{
+		"title-i", "sec-101", "sec-102",
+		"sec-102-a", "sec-102-a-1", "sec-102-a-2", "sec-102-b",
+		"sec-102-c", "sec-102-c-1", "sec-102-c-1-a", "sec-102-c-1-b", "sec-102-c-2",
+		"title-ii", "sec-201",
+	}
+	if got := anchors(provisions); !reflect.DeepEqual(got, want) {
+		t.Fatalf("anchors = %v, want %v", got, want)
+	}
+
+	section, _ := Find(provisions, "sec-102")
+	if section.Kind != models.ProvisionSection || section.Number != "102" || section.Heading != "TESTING OF DRINKING WATER" || section.Text != "" {
+		t.Errorf("section = %+v", section)
+	}
+	subsection, _ := Find(provisions, "sec-102-a")
+	if subsection.Heading != "In General" || subsection.Text != "Each school shall test its water—" {
+		t.Errorf("subsection = %+v", subsection)
+	}
+	// Text continued on the next line belongs to the same provision
+	reports, _ := Find(provisions, "sec-102-b")
+	if !strings.HasSuffix(reports.Text, "as required by section 1412 of the Safe Drinking Water Act.") {
+		t.Errorf("text = %q", reports.Text)
+	}
+	// A label can follow another on the same line
+	paragraph, _ := Find(provisions, "sec-102-c-1")
+	if paragraph.Kind != models.ProvisionParagraph || !strings.HasPrefix(paragraph.Text, "A school that fails") {
+		t.Errorf("paragraph = %+v", paragraph)
+	}
+	subparagraph, _ := Find(provisions, "sec-102-c-1-b")
+	if subparagraph.Kind != models.ProvisionSubparagraph || subparagraph.Text != "install filters." {
+		t.Errorf("subparagraph = %+v", subparagraph)
+	}
+}
+
+func TestParseOffsets(t *testing.T) {
+	provisions := Parse(bill)
+	runes := []rune(bill)
+
+	// Offsets count characters, so the curly quotes before section 102 do
+	// not shift it
+	section, _ := Find(provisions, "sec-102")
+	if got := string(runes[section.Start:section.End]); !strings.HasPrefix(got, "SEC. 102.") || !strings.HasSuffix(got, "affects title II.") {
+		t.Errorf("section 102 spans %q", got)
+	}
+	grants, _ := Find(provisions, "sec-201")
+	if got := string(runes[grants.Start:grants.End]); !strings.HasPrefix(got, "SEC. 201. GRANTS.") || !strings.HasSuffix(got, "section 7(a).") {
+		t.Errorf("section 201 spans %q", got)
+	}
+	for _, ref := range grants.References {
+		if got := string(runes[ref.Start:ref.End]); got != ref.Text {
+			t.Errorf("reference spans %q, want %q", got, ref.Text)
+		}
+	}
+}
+
+func TestParseReferences(t *testing.T) {
+	provisions := Parse(bill)
+	tests := []struct {
+		anchor string
+		want   []models.CrossReference
+	}{
+		{"sec-102-a-2", []models.CrossReference{{Text: "subsection (c)", Anchor: "sec-102-c"}}},
+		// References to other laws are not resolved
+		{"sec-102-b", []models.CrossReference{{Text: "subsection (a)(1)", Anchor: "sec-102-a-1"}, {Text: "section 1412"}}},
+		{"sec-102-c-1", []models.CrossReference{{Text: "paragraph (2) of subsection (a)", Anchor: "sec-102-a-2"}}},
+		{"sec-102-c-2", []models.CrossReference{{Text: "title II", Anchor: "title-ii"}}},
+		// Neither are references to provisions the text does not have
+		{"sec-201", []models.CrossReference{{Text: "section 102(c)(1)(B)", Anchor: "sec-102-c-1-b"}, {Text: "section 7(a)"}}},
+	}
+	for _, tt := range tests {
+		provision, _ := Find(provisions, tt.anchor)
+		var got []models.CrossReference
+		for _, ref := range provision.References {
+			got = append(got, models.CrossReference{Text: ref.Text, Anchor: ref.Anchor})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: references = %+v, want %+v", tt.anchor, got, tt.want)
+		}
+	}
+}
+
+func TestParseUnstructured(t *testing.T) {
+	if provisions := Parse("Opens parks at night. Section 3 of the Parks Act is repealed."); provisions != nil {
+		t.Errorf("provisions = %+v, want none in prose", provisions)
+	}
+
+	// Short sections are headings alone
+	provisions := Parse("Section 1. Short title.\nSection 2. Parks open at night.")
+	if len(provisions) != 2 || provisions[0].Heading != "Short title" || provisions[0].Text != "" {
+		t.Errorf("provisions = %+v", provisions)
+	}
+
+	// Repeated numbers still have unique anchors
+	if got := anchors(Parse("(a) One.\n(b) Two.\n(a) Three.")); !reflect.DeepEqual(got, []string{"a", "b", "a-2"}) {
+		t.Errorf("anchors = %v", got)
+	}
+}
+
+func TestCite(t *testing.T) {
+	provisions := Parse(bill)
+	tests := map[string]string{
+		"title-ii":      "title II",
+		"sec-101":       "§ 101",
+		"sec-102-c-1-b": "§ 102(c)(1)(B)",
+	}
+	for anchor, want := range tests {
+		provision, path := Find(provisions, anchor)
+		if got := Cite(path, *provision); got != want {
+			t.Errorf("%s: citation = %q, want %q", anchor, got, want)
+		}
+	}
+	if provision, _ := Find(provisions, "sec-9"); provision != nil {
+		t.Errorf("found %+v, want nothing", provision)
+	}
+}
@@ -0,0 +1,91 @@
+// Package logging provides the application's structured logger. Records are
+// written as JSON through log/slog, with personal data such as email
+// addresses and credentials redacted before they reach the output.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/benjamingetches/govtrack/api/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New creates a JSON logger writing to w at level, redacting personal data
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(NewRedactingHandler(handler))
+}
+
+// ParseLevel converts a level name to a slog level, defaulting to info
+func ParseLevel(name string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// fields holds the contextual attributes of a request. It is shared by every
+// handler in the chain so that fields added deep in a handler are included in
+// the request log written by the middleware.
+type fields struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type fieldsKey struct{}
+
+// NewContext returns a copy of ctx that can collect request fields with AddFields
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, &fields{})
+}
+
+// AddFields attaches key-value pairs to the request carried by ctx. They are
+// included in the request log and in every record from FromContext. It does
+// nothing if ctx was not created by NewContext.
+func AddFields(ctx context.Context, args ...any) {
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return
+	}
+
+	record := slog.Record{}
+	record.Add(args...)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record.Attrs(func(attr slog.Attr) bool {
+		f.attrs = append(f.attrs, attr)
+		return true
+	})
+}
+
+// Fields returns the attributes attached to the request carried by ctx
+func Fields(ctx context.Context) []slog.Attr {
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]slog.Attr(nil), f.attrs...)
+}
+
+// FromContext returns the default logger annotated with the request ID, trace
+// ID and fields of the request carried by ctx
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := requestid.FromContext(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		logger = logger.With("trace_id", span.TraceID().String())
+	}
+	for _, attr := range Fields(ctx) {
+		logger = logger.With(attr)
+	}
+	return logger
+}
@@ -1,101 +1,180 @@
-package main
-
-import (
-	"context"
-	"log"
-	"net/http"
-	"os"
-	"os/signal"
-	"time"
-
-	"github.com/benjamingetches/govtrack/api/routes"
-	"github.com/gorilla/handlers"
-	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-func main() {
-	// Set up MongoDB connection
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Replace with your MongoDB connection string
-	mongoURI := os.Getenv("MONGO_URI")
-	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017"
-	}
-
-	log.Println("Connecting to MongoDB at", mongoURI)
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
-	if err != nil {
-		log.Println("Error connecting to MongoDB:", err)
-		log.Println("Make sure MongoDB is installed and running.")
-		log.Println("You can download MongoDB from: https://www.mongodb.com/try/download/community")
-		log.Println("Or use MongoDB Atlas cloud service.")
-		log.Fatal("Exiting due to database connection error")
-	}
-
-	// Ping the database to verify connection
-	pingCtx, pingCancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer pingCancel()
-
-	if err := client.Ping(pingCtx, nil); err != nil {
-		log.Println("Error connecting to MongoDB:", err)
-		log.Println("Make sure MongoDB is installed and running.")
-		log.Println("You can download MongoDB from: https://www.mongodb.com/try/download/community")
-		log.Println("Or use MongoDB Atlas cloud service.")
-		log.Fatal("Exiting due to database connection error")
-	}
-
-	log.Println("Successfully connected to MongoDB")
-	defer client.Disconnect(ctx)
-
-	// Initialize router
-	r := mux.NewRouter()
-
-	// Register routes
-	routes.SetupRoutes(r, client)
-
-	// CORS middleware
-	corsMiddleware := handlers.CORS(
-		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}),
-	)
-
-	// Set up server
-	srv := &http.Server{
-		Addr:         ":" + getPort(),
-		WriteTimeout: time.Second * 15,
-		ReadTimeout:  time.Second * 15,
-		IdleTimeout:  time.Second * 60,
-		Handler:      corsMiddleware(r),
-	}
-
-	// Start server
-	go func() {
-		log.Println("Starting server on port", getPort())
-		if err := srv.ListenAndServe(); err != nil {
-			log.Println(err)
-		}
-	}()
-
-	// Graceful shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
-
-	ctx, cancel = context.WithTimeout(context.Background(), time.Second*15)
-	defer cancel()
-	srv.Shutdown(ctx)
-	log.Println("Server gracefully stopped")
-}
-
-func getPort() string {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	return port
-}
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/apikey"
+	"github.com/benjamingetches/govtrack/api/httpcache"
+	"github.com/benjamingetches/govtrack/api/middleware"
+	"github.com/benjamingetches/govtrack/api/requestid"
+	"github.com/benjamingetches/govtrack/api/routes"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/health"
+	"github.com/benjamingetches/govtrack/logging"
+	"github.com/benjamingetches/govtrack/migrations"
+	"github.com/benjamingetches/govtrack/readability"
+	"github.com/benjamingetches/govtrack/repository"
+	"github.com/benjamingetches/govtrack/summarize"
+	"github.com/benjamingetches/govtrack/tracing"
+	"github.com/benjamingetches/govtrack/trash"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	// Log structured JSON; the standard log package is routed through it too
+	slog.SetDefault(logging.New(os.Stdout, slog.LevelInfo))
+
+	// Load and validate the configuration before touching any dependency
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Refusing to start", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logging.New(os.Stdout, logging.ParseLevel(cfg.Log.Level)))
+	for _, warning := range cfg.Warnings {
+		slog.Warn("Unsafe configuration", "warning", warning)
+	}
+	slog.Info("Configuration loaded", "environment", cfg.Environment)
+
+	// Set up MongoDB connection
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Mongo.ConnectTimeout)
+	defer cancel()
+
+	// Set up tracing before the database so that its commands are traced
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Tracing configured", "exporter", tracing.ExporterName())
+
+	slog.Info("Connecting to MongoDB", "uri", redactURI(cfg.Mongo.URI))
+	client, db, err := config.ConnectDB(ctx, cfg.Mongo)
+	if err != nil {
+		slog.Error("Error connecting to MongoDB. Make sure MongoDB is installed and running, or use MongoDB Atlas.", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Successfully connected to MongoDB")
+	defer config.DisconnectDB(client)
+
+	migrator, err := migrations.New(db, migrations.All())
+	if err != nil {
+		slog.Error("Error loading migrations", "error", err)
+		os.Exit(1)
+	}
+
+	// Apply pending database migrations unless disabled
+	if cfg.Mongo.MigrateOnStart {
+		if err := runMigrations(migrator); err != nil {
+			slog.Error("Exiting due to migration error", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Readiness checks for the server's dependencies
+	checker := health.NewChecker(health.DefaultTimeout)
+	checker.Add("mongodb", health.MongoPing(client))
+	checker.Add("migrations", health.Migrations(migrator))
+
+	store := repository.NewMongoStore(db)
+
+	// Public responses are cached in memory until content changes
+	cache := httpcache.New(cfg.HTTPCache.Entries, cfg.HTTPCache.TTL)
+
+	// Purge expired content from the trash in the background. The worker is
+	// unhealthy if it has not completed a purge in three intervals.
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	purger := trash.NewPurger(store, cfg.Trash.Retention, cache)
+	go purger.Run(workers, cfg.Trash.PurgeInterval, checker.Heartbeat("purge", 3*cfg.Trash.PurgeInterval))
+
+	// Draft summaries of policies that have none in the background, if an
+	// interval is set. The worker is unhealthy if it has not completed a run
+	// in three intervals.
+	if generator := cfg.Summaries.Generator; generator.Interval > 0 {
+		drafter := summarize.NewDrafter(store, summarize.FromConfig(generator), readability.FromConfig(cfg.Readability), generator.Batch)
+		go drafter.Run(workers, generator.Interval, checker.Heartbeat("drafts", 3*generator.Interval))
+	}
+
+	// Initialize router
+	r := mux.NewRouter()
+
+	// Register routes
+	routes.SetupRoutes(r, cfg, store, checker, cache)
+
+	// CORS middleware
+	corsMiddleware := handlers.CORS(
+		handlers.AllowedOrigins(cfg.Server.CORSOrigins),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", apikey.Header, requestid.Header, "If-None-Match", "If-Match", "traceparent", "tracestate"}),
+		handlers.ExposedHeaders([]string{requestid.Header, "ETag", middleware.QuotaLimitHeader, middleware.QuotaRemainingHeader, "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"}),
+	)
+
+	// Set up server
+	srv := &http.Server{
+		Addr:         cfg.Addr(),
+		WriteTimeout: cfg.Server.WriteTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		Handler:      corsMiddleware(r),
+	}
+
+	// Start server
+	go func() {
+		slog.Info("Starting server", "port", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server stopped", "error", err)
+		}
+	}()
+
+	// Graceful shutdown
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+
+	// Fail readiness first and keep serving while load balancers stop
+	// sending traffic
+	checker.Drain()
+	slog.Info("Draining before shutdown", "delay", cfg.Server.ShutdownDelay.String())
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+	srv.Shutdown(ctx)
+	stopWorkers()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+	slog.Info("Server gracefully stopped")
+}
+
+// runMigrations applies every pending migration
+func runMigrations(migrator *migrations.Migrator) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	applied, err := migrator.Up(ctx, 0)
+	for _, m := range applied {
+		slog.Info("Applied migration", "version", m.Version, "description", m.Description)
+	}
+	return err
+}
+
+// redactURI removes the password from a connection string so it can be logged
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "[unparseable]"
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,206 @@
+// Package metrics exposes application metrics in the Prometheus text format.
+// Collectors are registered on Registry, which is served by Handler together
+// with the Go runtime and process metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every application metric
+const namespace = "govtrack"
+
+// SessionWindow is how recently a user must have made an authenticated
+// request to count as an active session
+const SessionWindow = 15 * time.Minute
+
+// Registry holds every collector served by Handler
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by result (success, failure or locked).",
+	}, []string{"result"})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by rate limiting, by route group.",
+	}, []string{"group"})
+
+	apiKeyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_key_requests_total",
+		Help:      "Requests authenticated with an API key, by result.",
+	}, []string{"result"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_cache_requests_total",
+		Help:      "GET requests looked up in the response cache, by result (hit or miss).",
+	}, []string{"result"})
+
+	quizSubmissions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quiz_submissions_total",
+		Help:      "Quiz results submitted.",
+	})
+
+	comments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_posted_total",
+		Help:      "Comments posted and edited, by resulting status (published or held).",
+	}, []string{"status"})
+
+	ballots = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "poll_ballots_total",
+		Help:      "Ballots cast or changed in policy polls, by choice.",
+	}, []string{"choice"})
+
+	activeSessions = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Users with an authenticated request in the last 15 minutes.",
+	}, func() float64 { return float64(sessions.count(time.Now())) })
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		mongoDuration, mongoErrors,
+		logins, rateLimited, apiKeyRequests, cacheLookups, quizSubmissions, comments, ballots, activeSessions,
+	)
+}
+
+// Handler serves the metrics in Registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// knownMethods are recorded as-is; any other method is recorded as OTHER so
+// that clients cannot create unbounded label values
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// ObserveRequest records a completed HTTP request. route must be a route
+// template such as /api/policies/{id}, never a raw path.
+func ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	if !knownMethods[method] {
+		method = "OTHER"
+	}
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// LoginSucceeded records a successful login
+func LoginSucceeded() {
+	logins.WithLabelValues("success").Inc()
+}
+
+// LoginFailed records a login rejected because of wrong credentials
+func LoginFailed() {
+	logins.WithLabelValues("failure").Inc()
+}
+
+// LoginLocked records a login refused because the account is locked out
+func LoginLocked() {
+	logins.WithLabelValues("locked").Inc()
+}
+
+// RateLimited records a request rejected by the rate limit of group
+func RateLimited(group string) {
+	rateLimited.WithLabelValues(group).Inc()
+}
+
+// APIKeyRequest records a request carrying an API key. result is "accepted"
+// or the reason the key was refused, such as "revoked" or "quota_exceeded".
+func APIKeyRequest(result string) {
+	apiKeyRequests.WithLabelValues(result).Inc()
+}
+
+// CacheLookup records a response cache hit or miss
+func CacheLookup(hit bool) {
+	if hit {
+		cacheLookups.WithLabelValues("hit").Inc()
+		return
+	}
+	cacheLookups.WithLabelValues("miss").Inc()
+}
+
+// QuizSubmitted records a submitted quiz result
+func QuizSubmitted() {
+	quizSubmissions.Inc()
+}
+
+// CommentPosted records a posted or edited comment and whether the content
+// filter held it
+func CommentPosted(status string) {
+	comments.WithLabelValues(status).Inc()
+}
+
+// BallotCast records a ballot cast or changed in a policy's poll
+func BallotCast(choice string) {
+	ballots.WithLabelValues(choice).Inc()
+}
+
+// SessionSeen marks the user as active now
+func SessionSeen(userID string) {
+	sessions.seen(userID, time.Now())
+}
+
+// sessionTracker remembers when each user was last active
+type sessionTracker struct {
+	mu       sync.Mutex
+	lastSeen map[string]time.Time
+}
+
+var sessions = &sessionTracker{lastSeen: map[string]time.Time{}}
+
+func (s *sessionTracker) seen(userID string, now time.Time) {
+	if userID == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSeen[userID] = now
+}
+
+// count returns the number of users active within SessionWindow of now,
+// forgetting the rest
+func (s *sessionTracker) count(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, last := range s.lastSeen {
+		if now.Sub(last) > SessionWindow {
+			delete(s.lastSeen, userID)
+		}
+	}
+	return len(s.lastSeen)
+}
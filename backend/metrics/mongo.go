@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	mongoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "MongoDB command latency by collection and command.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"collection", "command"})
+
+	mongoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_errors_total",
+		Help:      "Failed MongoDB commands by collection and command.",
+	}, []string{"collection", "command"})
+)
+
+// noCollection labels commands that do not target a collection, such as ping
+const noCollection = "none"
+
+// CommandMonitor returns a MongoDB command monitor that records the latency
+// and failures of every command. Install it with options.Client().SetMonitor.
+func CommandMonitor() *event.CommandMonitor {
+	// Finished events do not carry the command document, so the collection
+	// is remembered from the started event by request ID
+	var collections sync.Map
+
+	finished := func(requestID int64) string {
+		collection := noCollection
+		if value, ok := collections.LoadAndDelete(requestID); ok {
+			collection = value.(string)
+		}
+		return collection
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, evt *event.CommandStartedEvent) {
+			collections.Store(evt.RequestID, commandCollection(evt))
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			collection := finished(evt.RequestID)
+			mongoDuration.WithLabelValues(collection, evt.CommandName).Observe(evt.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			collection := finished(evt.RequestID)
+			mongoDuration.WithLabelValues(collection, evt.CommandName).Observe(evt.Duration.Seconds())
+			mongoErrors.WithLabelValues(collection, evt.CommandName).Inc()
+		},
+	}
+}
+
+// commandCollection returns the collection a command targets. Most commands
+// name it as the value of the command itself, as in {find: "policies"};
+// getMore names it in a separate field.
+func commandCollection(evt *event.CommandStartedEvent) string {
+	field := evt.CommandName
+	if field == "getMore" {
+		field = "collection"
+	}
+	if value, err := evt.Command.LookupErr(field); err == nil {
+		if collection, ok := value.StringValueOK(); ok {
+			return collection
+		}
+	}
+	return noCollection
+}
@@ -0,0 +1,224 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CollectionName is the collection that records applied migrations
+const CollectionName = "schema_migrations"
+
+// Migration is a single versioned change to the database schema or data
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// Record is a document in the schema_migrations collection
+type Record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// Status describes whether a known migration has been applied
+type Status struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+// Migrator applies and rolls back migrations against a database
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+
+	// DryRun reports the migrations that would run without executing them
+	DryRun bool
+}
+
+// New creates a Migrator for the given migrations, ordered by version
+func New(db *mongo.Database, migrations []Migration) (*Migrator, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration %q has invalid version %d", m.Description, m.Version)
+		}
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d has no Up function", m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+	}
+
+	return &Migrator{db: db, migrations: sorted}, nil
+}
+
+// applied returns the applied migration records keyed by version
+func (m *Migrator) applied(ctx context.Context) (map[int]Record, error) {
+	cursor, err := m.db.Collection(CollectionName).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", CollectionName, err)
+	}
+	defer cursor.Close(ctx)
+
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", CollectionName, err)
+	}
+
+	applied := make(map[int]Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		record, ok := applied[migration.Version]
+		statuses = append(statuses, Status{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Applied:     ok,
+			AppliedAt:   record.AppliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies pending migrations in order up to and including target.
+// A target of 0 applies every pending migration. It returns the migrations
+// that were applied, or that would be applied in dry-run mode.
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range pending {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		if m.DryRun {
+			ran = append(ran, migration)
+			continue
+		}
+
+		if err := migration.Up(ctx, m.db); err != nil {
+			return ran, fmt.Errorf("migration %d (%s) failed: %v", migration.Version, migration.Description, err)
+		}
+
+		record := Record{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now(),
+		}
+		if _, err := m.db.Collection(CollectionName).InsertOne(ctx, record); err != nil {
+			// Another instance applied the same migration concurrently
+			if !mongo.IsDuplicateKeyError(err) {
+				return ran, fmt.Errorf("failed to record migration %d: %v", migration.Version, err)
+			}
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// Down rolls back the given number of most recently applied migrations.
+// It returns the migrations that were rolled back, or that would be rolled
+// back in dry-run mode.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rolledBack []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == nil {
+			return rolledBack, fmt.Errorf("migration %d (%s) cannot be rolled back", migration.Version, migration.Description)
+		}
+		if m.DryRun {
+			rolledBack = append(rolledBack, migration)
+			continue
+		}
+
+		if err := migration.Down(ctx, m.db); err != nil {
+			return rolledBack, fmt.Errorf("rollback of migration %d (%s) failed: %v", migration.Version, migration.Description, err)
+		}
+		if _, err := m.db.Collection(CollectionName).DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return rolledBack, fmt.Errorf("failed to remove migration record %d: %v", migration.Version, err)
+		}
+		rolledBack = append(rolledBack, migration)
+	}
+	return rolledBack, nil
+}
+
+// createIndexes returns a migration step that creates the given indexes
+func createIndexes(collection string, indexes ...mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+}
+
+// dropIndexes returns a migration step that drops the named indexes
+func dropIndexes(collection string, names ...string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, name := range names {
+			if _, err := db.Collection(collection).Indexes().DropOne(ctx, name); err != nil && !isIndexNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// isIndexNotFound reports whether err is MongoDB's IndexNotFound error
+func isIndexNotFound(err error) bool {
+	if cmdErr, ok := err.(mongo.CommandError); ok {
+		return cmdErr.Code == 27 || cmdErr.Name == "IndexNotFound"
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// recorder builds migrations that log the steps they run
+type recorder struct {
+	steps []string
+}
+
+// migration returns a migration with version whose Up and Down log their
+// runs, or fail with err if it is set
+func (rec *recorder) migration(version int, description string, err error) Migration {
+	step := func(name string) func(context.Context, *mongo.Database) error {
+		return func(context.Context, *mongo.Database) error {
+			rec.steps = append(rec.steps, name+" "+description)
+			return err
+		}
+	}
+	return Migration{Version: version, Description: description, Up: step("up"), Down: step("down")}
+}
+
+// appliedRecords is the server's answer to reading schema_migrations
+func appliedRecords(mt *mtest.T, versions ...int) bson.D {
+	docs := make([]bson.D, 0, len(versions))
+	for _, version := range versions {
+		docs = append(docs, bson.D{{Key: "_id", Value: version}, {Key: "description", Value: "applied"}})
+	}
+	return mtest.CreateCursorResponse(0, mt.DB.Name()+"."+CollectionName, mtest.FirstBatch, docs...)
+}
+
+// commands returns the names of the commands sent to the server, except
+// for reading schema_migrations, with the version they wrote or removed
+func commands(mt *mtest.T) []string {
+	var names []string
+	for _, event := range mt.GetAllStartedEvents() {
+		switch event.CommandName {
+		case "insert":
+			doc := event.Command.Lookup("documents").Array().Index(0).Value().Document()
+			names = append(names, "insert "+strconv.Itoa(int(doc.Lookup("_id").Int32())))
+		case "delete":
+			doc := event.Command.Lookup("deletes").Array().Index(0).Value().Document()
+			names = append(names, "delete "+strconv.Itoa(int(doc.Lookup("q", "_id").Int32())))
+		case "find":
+		default:
+			names = append(names, event.CommandName)
+		}
+	}
+	return names
+}
+
+// versions returns the versions of migrations
+func versions(migrations []Migration) []int {
+	var versions []int
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	return versions
+}
+
+func TestNewRejectsInvalidMigrations(t *testing.T) {
+	rec := &recorder{}
+	noUp := rec.migration(2, "no up", nil)
+	noUp.Up = nil
+	for name, migrations := range map[string][]Migration{
+		"zero version":      {rec.migration(0, "zero", nil)},
+		"missing up":        {noUp},
+		"duplicate version": {rec.migration(1, "a", nil), rec.migration(1, "b", nil)},
+	} {
+		if _, err := New(nil, migrations); err == nil {
+			t.Errorf("%s: New succeeded, want an error", name)
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("up applies pending migrations in version order", func(mt *mtest.T) {
+		rec := &recorder{}
+		migrator, err := New(mt.DB, []Migration{rec.migration(3, "three", nil), rec.migration(1, "one", nil), rec.migration(2, "two", nil), rec.migration(4, "four", nil)})
+		if err != nil {
+			mt.Fatal(err)
+		}
+		mt.AddMockResponses(appliedRecords(mt, 1), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		ran, err := migrator.Up(context.Background(), 3)
+		if err != nil {
+			mt.Fatalf("up: %v", err)
+		}
+		if got := versions(ran); !slices.Equal(got, []int{2, 3}) {
+			mt.Errorf("ran = %v, want [2 3]", got)
+		}
+		if want := []string{"up two", "up three"}; !slices.Equal(rec.steps, want) {
+			mt.Errorf("steps = %v, want %v", rec.steps, want)
+		}
+		if got, want := commands(mt), []string{"insert 2", "insert 3"}; !slices.Equal(got, want) {
+			mt.Errorf("commands = %v, want %v", got, want)
+		}
+	})
+
+	mt.Run("down rolls back the most recent migrations first", func(mt *mtest.T) {
+		rec := &recorder{}
+		migrator, err := New(mt.DB, []Migration{rec.migration(1, "one", nil), rec.migration(2, "two", nil), rec.migration(3, "three", nil)})
+		if err != nil {
+			mt.Fatal(err)
+		}
+		mt.AddMockResponses(appliedRecords(mt, 1, 2), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		rolledBack, err := migrator.Down(context.Background(), 2)
+		if err != nil {
+			mt.Fatalf("down: %v", err)
+		}
+		if got := versions(rolledBack); !slices.Equal(got, []int{2, 1}) {
+			mt.Errorf("rolled back = %v, want [2 1]", got)
+		}
+		if want := []string{"down two", "down one"}; !slices.Equal(rec.steps, want) {
+			mt.Errorf("steps = %v, want %v", rec.steps, want)
+		}
+		if got, want := commands(mt), []string{"delete 2", "delete 1"}; !slices.Equal(got, want) {
+			mt.Errorf("commands = %v, want %v", got, want)
+		}
+	})
+
+	mt.Run("dry runs write nothing", func(mt *mtest.T) {
+		rec := &recorder{}
+		migrator, err := New(mt.DB, []Migration{rec.migration(1, "one", nil), rec.migration(2, "two", nil)})
+		if err != nil {
+			mt.Fatal(err)
+		}
+		migrator.DryRun = true
+		mt.AddMockResponses(appliedRecords(mt, 1), appliedRecords(mt, 1))
+
+		ran, err := migrator.Up(context.Background(), 0)
+		if err != nil {
+			mt.Fatalf("up: %v", err)
+		}
+		if got := versions(ran); !slices.Equal(got, []int{2}) {
+			mt.Errorf("ran = %v, want [2]", got)
+		}
+		rolledBack, err := migrator.Down(context.Background(), 1)
+		if err != nil {
+			mt.Fatalf("down: %v", err)
+		}
+		if got := versions(rolledBack); !slices.Equal(got, []int{1}) {
+			mt.Errorf("rolled back = %v, want [1]", got)
+		}
+		if len(rec.steps) != 0 {
+			mt.Errorf("steps = %v, want none", rec.steps)
+		}
+		if got := commands(mt); len(got) != 0 {
+			mt.Errorf("commands = %v, want none", got)
+		}
+	})
+
+	mt.Run("a failing migration stops the run", func(mt *mtest.T) {
+		rec := &recorder{}
+		migrator, err := New(mt.DB, []Migration{rec.migration(1, "one", nil), rec.migration(2, "two", errors.New("boom")), rec.migration(3, "three", nil)})
+		if err != nil {
+			mt.Fatal(err)
+		}
+		mt.AddMockResponses(appliedRecords(mt), mtest.CreateSuccessResponse())
+
+		ran, err := migrator.Up(context.Background(), 0)
+		if err == nil || !strings.Contains(err.Error(), "migration 2 (two) failed: boom") {
+			mt.Fatalf("err = %v, want migration 2 to fail", err)
+		}
+		if got := versions(ran); !slices.Equal(got, []int{1}) {
+			mt.Errorf("ran = %v, want [1]", got)
+		}
+		if want := []string{"up one", "up two"}; !slices.Equal(rec.steps, want) {
+			mt.Errorf("steps = %v, want %v", rec.steps, want)
+		}
+		// Only the migration that succeeded is recorded, so the failed one
+		// runs again next time
+		if got, want := commands(mt), []string{"insert 1"}; !slices.Equal(got, want) {
+			mt.Errorf("commands = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,481 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/benjamingetches/govtrack/legislation"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All returns every migration known to the application.
+// New migrations must be appended with the next version number; applied
+// versions must never be renumbered or edited.
+func All() []Migration {
+	return []Migration{
+		{
+			Version:     1,
+			Description: "unique index on users.email",
+			Up: createIndexes("users", mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetName("users_email_unique").SetUnique(true),
+			}),
+			Down: dropIndexes("users", "users_email_unique"),
+		},
+		{
+			Version:     2,
+			Description: "policy filter and sort indexes",
+			Up: createIndexes("policies",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "introduced_date", Value: -1}},
+					Options: options.Index().SetName("policies_introduced_date"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "jurisdiction.state", Value: 1}, {Key: "jurisdiction.city", Value: 1}, {Key: "introduced_date", Value: -1}},
+					Options: options.Index().SetName("policies_jurisdiction_introduced"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "level", Value: 1}, {Key: "status", Value: 1}, {Key: "introduced_date", Value: -1}},
+					Options: options.Index().SetName("policies_level_status_introduced"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "voting_record.representative_id", Value: 1}},
+					Options: options.Index().SetName("policies_voting_record_representative"),
+				},
+			),
+			Down: dropIndexes("policies",
+				"policies_introduced_date",
+				"policies_jurisdiction_introduced",
+				"policies_level_status_introduced",
+				"policies_voting_record_representative",
+			),
+		},
+		{
+			Version:     3,
+			Description: "policy full-text search index",
+			Up: createIndexes("policies", mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "title", Value: "text"},
+					{Key: "simplified_desc", Value: "text"},
+					{Key: "description", Value: "text"},
+					{Key: "tags", Value: "text"},
+				},
+				Options: options.Index().
+					SetName("policies_text").
+					SetDefaultLanguage("english").
+					SetWeights(bson.D{
+						{Key: "title", Value: 10},
+						{Key: "tags", Value: 5},
+						{Key: "simplified_desc", Value: 3},
+						{Key: "description", Value: 1},
+					}),
+			}),
+			Down: dropIndexes("policies", "policies_text"),
+		},
+		{
+			Version:     4,
+			Description: "representative filter indexes",
+			Up: createIndexes("representatives",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "state", Value: 1}, {Key: "party", Value: 1}, {Key: "title", Value: 1}},
+					Options: options.Index().SetName("representatives_state_party_title"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "level", Value: 1}, {Key: "state", Value: 1}, {Key: "district", Value: 1}},
+					Options: options.Index().SetName("representatives_level_state_district"),
+				},
+			),
+			Down: dropIndexes("representatives",
+				"representatives_state_party_title",
+				"representatives_level_state_district",
+			),
+		},
+		{
+			Version:     5,
+			Description: "GeoJSON user locations with 2dsphere index",
+			Up:          upUserGeo,
+			Down:        downUserGeo,
+		},
+		{
+			Version:     6,
+			Description: "quiz result lookup index",
+			Up: createIndexes("quiz_results", mongo.IndexModel{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "taken_at", Value: -1}},
+				Options: options.Index().SetName("quiz_results_user_taken"),
+			}),
+			Down: dropIndexes("quiz_results", "quiz_results_user_taken"),
+		},
+		{
+			Version:     7,
+			Description: "API key hash and owner indexes",
+			Up: createIndexes("api_keys",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "hash", Value: 1}},
+					Options: options.Index().SetName("api_keys_hash").SetUnique(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("api_keys_user_created"),
+				},
+			),
+			Down: dropIndexes("api_keys", "api_keys_hash", "api_keys_user_created"),
+		},
+		{
+			Version:     8,
+			Description: "document revisions for optimistic concurrency",
+			Up:          upRevisions,
+			Down:        downRevisions,
+		},
+		{
+			Version:     9,
+			Description: "trash listing indexes",
+			Up:          upTrashIndexes,
+			Down:        downTrashIndexes,
+		},
+		{
+			Version:     10,
+			Description: "audit log chain and filter indexes",
+			Up: createIndexes("audit_log",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "sequence", Value: 1}},
+					Options: options.Index().SetName("audit_log_sequence").SetUnique(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "actor_id", Value: 1}, {Key: "sequence", Value: -1}},
+					Options: options.Index().SetName("audit_log_actor"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "resource_type", Value: 1}, {Key: "resource_id", Value: 1}, {Key: "sequence", Value: -1}},
+					Options: options.Index().SetName("audit_log_resource"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "at", Value: -1}},
+					Options: options.Index().SetName("audit_log_at"),
+				},
+			),
+			Down: dropIndexes("audit_log", "audit_log_sequence", "audit_log_actor", "audit_log_resource", "audit_log_at"),
+		},
+		{
+			Version:     11,
+			Description: "move unreviewed simplified descriptions into draft summaries",
+			Up:          upSummaries,
+			Down:        downSummaries,
+		},
+		{
+			Version:     12,
+			Description: "parse the sections of policies' original text",
+			Up:          upSections,
+			Down:        downSections,
+		},
+		{
+			Version:     13,
+			Description: "number policies' text versions and index annotations",
+			Up:          upAnnotations,
+			Down:        downAnnotations,
+		},
+		{
+			Version:     14,
+			Description: "index comments by policy and by moderation status",
+			Up: createIndexes("comments",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "policy_id", Value: 1}, {Key: "created_at", Value: 1}},
+					Options: options.Index().SetName("comments_policy"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+					Options: options.Index().SetName("comments_status"),
+				},
+			),
+			Down: dropIndexes("comments", "comments_policy", "comments_status"),
+		},
+		{
+			Version:     15,
+			Description: "index poll ballots, one per user and policy",
+			Up: createIndexes("ballots",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "policy_id", Value: 1}, {Key: "user_id", Value: 1}},
+					Options: options.Index().SetName("ballots_policy_user").SetUnique(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "policy_id", Value: 1}, {Key: "state", Value: 1}, {Key: "district", Value: 1}},
+					Options: options.Index().SetName("ballots_policy_district"),
+				},
+			),
+			Down: dropIndexes("ballots", "ballots_policy_user", "ballots_policy_district"),
+		},
+	}
+}
+
+// revisionedCollections hold documents with a revision counter
+var revisionedCollections = []string{"users", "policies", "representatives", "quizzes"}
+
+// upRevisions gives every existing document revision 1
+func upRevisions(ctx context.Context, db *mongo.Database) error {
+	for _, name := range revisionedCollections {
+		_, err := db.Collection(name).UpdateMany(ctx,
+			bson.M{"revision": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"revision": int64(1)}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downRevisions removes the revision counters
+func downRevisions(ctx context.Context, db *mongo.Database) error {
+	for _, name := range revisionedCollections {
+		_, err := db.Collection(name).UpdateMany(ctx,
+			bson.M{"revision": bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{"revision": ""}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashCollections hold content that is soft-deleted
+var trashCollections = []string{"policies", "representatives", "quizzes"}
+
+// upTrashIndexes indexes the deletion time of trashed documents, which only
+// they have, so that the trash can be listed and purged without a scan
+func upTrashIndexes(ctx context.Context, db *mongo.Database) error {
+	for _, name := range trashCollections {
+		err := createIndexes(name, mongo.IndexModel{
+			Keys:    bson.D{{Key: "deleted_at", Value: -1}},
+			Options: options.Index().SetName(name + "_deleted_at").SetSparse(true),
+		})(ctx, db)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downTrashIndexes drops the trash indexes. Trashed documents are kept, and
+// versions without soft deletion show them again.
+func downTrashIndexes(ctx context.Context, db *mongo.Database) error {
+	for _, name := range trashCollections {
+		if err := dropIndexes(name, name+"_deleted_at")(ctx, db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upUserGeo copies location.coordinates into a GeoJSON location.geo point
+// and indexes it for proximity queries
+func upUserGeo(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("users")
+
+	filter := bson.M{
+		"location.coordinates": bson.M{"$exists": true},
+		"$or": bson.A{
+			bson.M{"location.coordinates.latitude": bson.M{"$ne": 0}},
+			bson.M{"location.coordinates.longitude": bson.M{"$ne": 0}},
+		},
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"location.geo": bson.M{
+				"type":        "Point",
+				"coordinates": bson.A{"$location.coordinates.longitude", "$location.coordinates.latitude"},
+			},
+		}}},
+	}
+	if _, err := users.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return createIndexes("users", mongo.IndexModel{
+		Keys:    bson.D{{Key: "location.geo", Value: "2dsphere"}},
+		Options: options.Index().SetName("users_location_geo").SetSparse(true),
+	})(ctx, db)
+}
+
+// downUserGeo removes the 2dsphere index and the derived location.geo field
+func downUserGeo(ctx context.Context, db *mongo.Database) error {
+	if err := dropIndexes("users", "users_location_geo")(ctx, db); err != nil {
+		return err
+	}
+	_, err := db.Collection("users").UpdateMany(ctx,
+		bson.M{"location.geo": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"location.geo": ""}},
+	)
+	return err
+}
+
+// upSummaries moves every policy's simplified description, none of which
+// has been reviewed, into a draft summary without an author, clears it, and
+// indexes summaries for listing by policy and status
+func upSummaries(ctx context.Context, db *mongo.Database) error {
+	policies := db.Collection("policies")
+	summaries := db.Collection("summaries")
+
+	cursor, err := policies.Find(ctx,
+		bson.M{"simplified_desc": bson.M{"$nin": bson.A{"", nil}}},
+		options.Find().SetProjection(bson.M{"simplified_desc": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	var described []struct {
+		ID             interface{} `bson:"_id"`
+		SimplifiedDesc string      `bson:"simplified_desc"`
+	}
+	if err := cursor.All(ctx, &described); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, policy := range described {
+		_, err := summaries.InsertOne(ctx, bson.M{
+			"policy_id":  policy.ID,
+			"text":       policy.SimplifiedDesc,
+			"status":     "draft",
+			"reviewers":  bson.A{},
+			"comments":   bson.A{},
+			"approvals":  bson.A{},
+			"created_at": now,
+			"updated_at": now,
+			"revision":   1,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = policies.UpdateOne(ctx,
+			bson.M{"_id": policy.ID},
+			bson.M{"$set": bson.M{"simplified_desc": ""}, "$inc": bson.M{"revision": 1}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return createIndexes("summaries", mongo.IndexModel{
+		Keys:    bson.D{{Key: "policy_id", Value: 1}, {Key: "status", Value: 1}, {Key: "updated_at", Value: -1}},
+		Options: options.Index().SetName("summaries_policy_status"),
+	})(ctx, db)
+}
+
+// downSummaries copies the drafts created by upSummaries back to policies
+// that have no simplified description and deletes them. Summaries written
+// through the review workflow or by a generator are kept.
+func downSummaries(ctx context.Context, db *mongo.Database) error {
+	if err := dropIndexes("summaries", "summaries_policy_status")(ctx, db); err != nil {
+		return err
+	}
+
+	summaries := db.Collection("summaries")
+	moved := bson.M{"status": "draft", "author_id": bson.M{"$exists": false}, "generator": bson.M{"$exists": false}}
+	cursor, err := summaries.Find(ctx, moved)
+	if err != nil {
+		return err
+	}
+	var drafts []struct {
+		PolicyID interface{} `bson:"policy_id"`
+		Text     string      `bson:"text"`
+	}
+	if err := cursor.All(ctx, &drafts); err != nil {
+		return err
+	}
+
+	for _, draft := range drafts {
+		_, err := db.Collection("policies").UpdateOne(ctx,
+			bson.M{"_id": draft.PolicyID, "simplified_desc": bson.M{"$in": bson.A{"", nil}}},
+			bson.M{"$set": bson.M{"simplified_desc": draft.Text}, "$inc": bson.M{"revision": 1}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = summaries.DeleteMany(ctx, moved)
+	return err
+}
+
+// upSections parses the sections of every policy with original text
+func upSections(ctx context.Context, db *mongo.Database) error {
+	policies := db.Collection("policies")
+
+	cursor, err := policies.Find(ctx,
+		bson.M{"original_text": bson.M{"$gt": ""}},
+		options.Find().SetProjection(bson.M{"original_text": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	var texts []struct {
+		ID           interface{} `bson:"_id"`
+		OriginalText string      `bson:"original_text"`
+	}
+	if err := cursor.All(ctx, &texts); err != nil {
+		return err
+	}
+
+	for _, policy := range texts {
+		sections := legislation.Parse(policy.OriginalText)
+		if len(sections) == 0 {
+			continue
+		}
+		_, err := policies.UpdateOne(ctx,
+			bson.M{"_id": policy.ID},
+			bson.M{"$set": bson.M{"sections": sections}, "$inc": bson.M{"revision": 1}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downSections removes the parsed sections
+func downSections(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("policies").UpdateMany(ctx,
+		bson.M{"sections": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"sections": ""}, "$inc": bson.M{"revision": 1}},
+	)
+	return err
+}
+
+// upAnnotations numbers the current text of every policy as version 1, so
+// that annotations can tell which version they were made on, and indexes
+// annotations by policy and by text version
+func upAnnotations(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("policies").UpdateMany(ctx,
+		bson.M{"text_version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"text_version": 1}, "$inc": bson.M{"revision": 1}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return createIndexes("annotations",
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "policy_id", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("annotations_policy"),
+		},
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "policy_id", Value: 1}, {Key: "target.text_version", Value: 1}},
+			Options: options.Index().SetName("annotations_text_version"),
+		},
+	)(ctx, db)
+}
+
+// downAnnotations drops the annotation indexes and the text versions.
+// Annotations are kept.
+func downAnnotations(ctx context.Context, db *mongo.Database) error {
+	if err := dropIndexes("annotations", "annotations_policy", "annotations_text_version")(ctx, db); err != nil {
+		return err
+	}
+
+	_, err := db.Collection("policies").UpdateMany(ctx,
+		bson.M{"text_version": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"text_version": ""}, "$inc": bson.M{"revision": 1}},
+	)
+	return err
+}
@@ -0,0 +1,41 @@
+// Package moderation checks users' comments before they are published. A
+// Filter decides whether a comment is suspicious enough to be held for a
+// moderator: WordList flags comments using words or phrases of a list, such
+// as abuse or spam, and None publishes everything.
+package moderation
+
+import (
+	"context"
+
+	"github.com/benjamingetches/govtrack/config"
+)
+
+// Verdict is a filter's decision on a comment
+type Verdict struct {
+	// Hold is set if the comment must be reviewed before it is published
+	Hold bool
+
+	// Reasons explains why the comment is held
+	Reasons []string
+}
+
+// Filter checks the text of comments
+type Filter interface {
+	Check(ctx context.Context, text string) (Verdict, error)
+}
+
+// FromConfig creates the Filter configured by cfg
+func FromConfig(cfg config.FilterConfig) Filter {
+	if cfg.Kind == config.FilterNone {
+		return None{}
+	}
+	return NewWordList(cfg.Words)
+}
+
+// None is a Filter that holds nothing
+type None struct{}
+
+// Check publishes every comment
+func (None) Check(ctx context.Context, text string) (Verdict, error) {
+	return Verdict{}, nil
+}
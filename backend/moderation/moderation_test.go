@@ -0,0 +1,55 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benjamingetches/govtrack/config"
+)
+
+func TestWordList(t *testing.T) {
+	filter := NewWordList(nil)
+	tests := []struct {
+		text    string
+		reasons []string
+	}{
+		{"This bill is a classic example of good policy.", nil},
+		{"Shut up! Nobody asked.", []string{`uses "shut up"`}},
+		{"CLICK HERE for free-money, you moron", []string{`uses "click here"`, `uses "free money"`, `uses "moron"`}},
+		{"Click on the link here", nil},
+	}
+	for _, test := range tests {
+		verdict, err := filter.Check(context.Background(), test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verdict.Hold != (len(test.reasons) > 0) || len(verdict.Reasons) != len(test.reasons) {
+			t.Errorf("Check(%q) = %+v, want reasons %v", test.text, verdict, test.reasons)
+			continue
+		}
+		for i, reason := range test.reasons {
+			if verdict.Reasons[i] != reason {
+				t.Errorf("Check(%q) reasons = %v, want %v", test.text, verdict.Reasons, test.reasons)
+				break
+			}
+		}
+	}
+
+	// Configured words replace the defaults
+	filter = NewWordList([]string{"Lorem Ipsum"})
+	if verdict, _ := filter.Check(context.Background(), "lorem, ipsum!"); !verdict.Hold {
+		t.Errorf("configured phrase not matched")
+	}
+	if verdict, _ := filter.Check(context.Background(), "shut up"); verdict.Hold {
+		t.Errorf("default words still matched")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if _, ok := FromConfig(config.FilterConfig{Kind: config.FilterNone}).(None); !ok {
+		t.Errorf("none filter not created")
+	}
+	if _, ok := FromConfig(config.FilterConfig{Kind: config.FilterWordList}).(*WordList); !ok {
+		t.Errorf("wordlist filter not created")
+	}
+}
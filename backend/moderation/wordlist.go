@@ -0,0 +1,76 @@
+package moderation
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultWords lists abusive wording and spam phrases that hold a comment
+var DefaultWords = []string{
+	"asshole", "bastard", "bitch", "bullshit", "buy now", "casino",
+	"click here", "crypto giveaway", "dickhead", "die in a fire",
+	"free money", "fuck", "fucking", "kill yourself", "kys",
+	"limited time offer", "make money fast", "moron", "motherfucker",
+	"retard", "scumbag", "shit", "shut up", "viagra", "work from home",
+}
+
+// WordList is a Filter that holds comments using any of its words or
+// phrases. Matching ignores case and punctuation and only matches whole
+// words, so that "class" does not match "ass".
+type WordList struct {
+	phrases [][]string
+}
+
+// NewWordList creates a WordList of words, or of DefaultWords if it is empty
+func NewWordList(words []string) *WordList {
+	if len(words) == 0 {
+		words = DefaultWords
+	}
+	list := &WordList{}
+	for _, word := range words {
+		if phrase := tokens(word); len(phrase) > 0 {
+			list.phrases = append(list.phrases, phrase)
+		}
+	}
+	return list
+}
+
+// Check holds text that uses words of the list, giving the words it uses as
+// reasons
+func (l *WordList) Check(ctx context.Context, text string) (Verdict, error) {
+	words := tokens(text)
+	var found []string
+	for _, phrase := range l.phrases {
+		if occurs(words, phrase) {
+			found = append(found, `uses "`+strings.Join(phrase, " ")+`"`)
+		}
+	}
+	sort.Strings(found)
+	return Verdict{Hold: len(found) > 0, Reasons: found}, nil
+}
+
+// tokens splits text into lowercase words
+func tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// occurs reports whether the phrase occurs in words
+func occurs(words, phrase []string) bool {
+	for start := 0; start+len(phrase) <= len(words); start++ {
+		matched := true
+		for i, want := range phrase {
+			if words[start+i] != want {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
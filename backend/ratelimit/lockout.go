@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// LockoutPolicy locks an account after Threshold consecutive failed logins.
+// The first lock lasts Base and each further failure doubles it, up to Max.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+// attempts records the failed logins of one account
+type attempts struct {
+	failures    int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// Lockout tracks failed logins per account
+type Lockout struct {
+	policy LockoutPolicy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	accounts  map[string]*attempts
+	lastSweep time.Time
+}
+
+// NewLockout creates a Lockout enforcing policy
+func NewLockout(policy LockoutPolicy) *Lockout {
+	return &Lockout{policy: policy, now: time.Now, accounts: map[string]*attempts{}}
+}
+
+// Locked returns how much longer account is locked, or zero if it may try
+// to log in
+func (l *Lockout) Locked(account string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a, ok := l.accounts[account]; ok {
+		if remaining := a.lockedUntil.Sub(l.now()); remaining > 0 {
+			return remaining
+		}
+	}
+	return 0
+}
+
+// Fail records a failed login and returns how long the account is now
+// locked, or zero if it is still below the threshold
+func (l *Lockout) Fail(account string) time.Duration {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	a, ok := l.accounts[account]
+	if !ok {
+		a = &attempts{}
+		l.accounts[account] = a
+	}
+	a.failures++
+	a.lastFailure = now
+	if a.failures < l.policy.Threshold {
+		return 0
+	}
+
+	lock := l.policy.Base
+	for i := l.policy.Threshold; i < a.failures && lock < l.policy.Max; i++ {
+		lock *= 2
+	}
+	if lock > l.policy.Max {
+		lock = l.policy.Max
+	}
+	a.lockedUntil = now.Add(lock)
+	return lock
+}
+
+// Succeed clears the failed logins of account
+func (l *Lockout) Succeed(account string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.accounts, account)
+}
+
+// sweep forgets accounts whose last failure is older than the longest lock,
+// so their count starts again from zero
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for account, a := range l.accounts {
+		if now.Sub(a.lastFailure) > l.policy.Max && now.After(a.lockedUntil) {
+			delete(l.accounts, account)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package ratelimit throttles clients with token buckets and locks out
+// accounts after repeated failed logins. State is kept in memory, so limits
+// apply per server instance.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are forgotten
+const sweepInterval = time.Minute
+
+// Limit allows Requests requests per period Per, refilled continuously
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses a limit written as "requests/period", such as "10/1m"
+func ParseLimit(s string) (Limit, error) {
+	requests, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q must be written as requests/period, such as 10/1m", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("limit %q must allow a positive number of requests", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("limit %q must have a positive period, such as 1m", s)
+	}
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// String formats the limit as "requests/period"
+func (l Limit) String() string {
+	return strconv.Itoa(l.Requests) + "/" + l.Per.String()
+}
+
+// UnmarshalText parses a limit from configuration
+func (l *Limit) UnmarshalText(text []byte) error {
+	parsed, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// MarshalText formats a limit for configuration
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+
+	// RetryAfter is the time until a request is allowed, zero if allowed
+	RetryAfter time.Duration
+}
+
+// bucket holds the tokens of one client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter applies one Limit to many clients, each with its own bucket
+type Limiter struct {
+	limit Limit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter creates a Limiter enforcing limit
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{limit: limit, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// Limit returns the limit the limiter enforces
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow takes a token from the bucket of key
+func (l *Limiter) Allow(key string) Result {
+	now := l.now()
+	rate := float64(l.limit.Requests) / l.limit.Per.Seconds()
+	capacity := float64(l.limit.Requests)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now, rate, capacity)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	result := Result{Limit: l.limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((capacity - b.tokens) / rate)
+	return result
+}
+
+// sweep forgets buckets that have refilled completely, since a new bucket
+// starts full anyway
+func (l *Limiter) sweep(now time.Time, rate, capacity float64) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// seconds converts a number of seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a manually advanced time source
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time          { return c.now }
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestLimiterRefills(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	limiter := NewLimiter(Limit{Requests: 2, Per: time.Minute})
+	limiter.now = c.Now
+
+	for i, remaining := range []int{1, 0} {
+		if r := limiter.Allow("a"); !r.Allowed || r.Remaining != remaining {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i, r, remaining)
+		}
+	}
+	r := limiter.Allow("a")
+	if r.Allowed || r.RetryAfter != 30*time.Second || r.Reset != time.Minute {
+		t.Fatalf("over limit = %+v, want retry after 30s and reset after 1m", r)
+	}
+	if !limiter.Allow("b").Allowed {
+		t.Fatal("other clients should have their own bucket")
+	}
+
+	c.Advance(30 * time.Second)
+	if !limiter.Allow("a").Allowed {
+		t.Fatal("a token should refill after 30s")
+	}
+	if limiter.Allow("a").Allowed {
+		t.Fatal("only one token should have refilled")
+	}
+
+	c.Advance(time.Hour)
+	limiter.Allow("c")
+	if _, ok := limiter.buckets["a"]; ok {
+		t.Error("full buckets should be swept")
+	}
+}
+
+func TestLockoutBacksOffExponentially(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	lockout := NewLockout(LockoutPolicy{Threshold: 3, Base: time.Minute, Max: 5 * time.Minute})
+	lockout.now = c.Now
+
+	for _, want := range []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		if got := lockout.Fail("ada"); got != want {
+			t.Fatalf("lock = %v, want %v", got, want)
+		}
+	}
+	if got := lockout.Locked("ada"); got != 5*time.Minute {
+		t.Errorf("locked for %v, want 5m", got)
+	}
+
+	c.Advance(5 * time.Minute)
+	if got := lockout.Locked("ada"); got != 0 {
+		t.Errorf("locked for %v after the lock expired", got)
+	}
+	lockout.Succeed("ada")
+	if got := lockout.Fail("ada"); got != 0 {
+		t.Errorf("a success should reset the count; lock = %v", got)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("10/1m")
+	if err != nil || limit != (Limit{Requests: 10, Per: time.Minute}) {
+		t.Errorf("ParseLimit = %v, %v", limit, err)
+	}
+	for _, bad := range []string{"10", "0/1m", "ten/1m", "10/soon", "10/-1s"} {
+		if _, err := ParseLimit(bad); err == nil {
+			t.Errorf("ParseLimit(%q) should fail", bad)
+		}
+	}
+}
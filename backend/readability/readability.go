@@ -0,0 +1,291 @@
+// Package readability scores how plain and neutral a policy's simplified
+// description is. It computes the Flesch-Kincaid grade level and Flesch
+// reading ease, sentence lengths, and the legislative jargon and loaded or
+// partisan wording the text uses, and reports the thresholds it misses.
+package readability
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/config"
+)
+
+// DefaultGlossary lists legislative terms that plain-language text should
+// explain or avoid
+var DefaultGlossary = []string{
+	"aforementioned", "appropriation", "authorization", "cloture", "codify",
+	"codified", "enactment", "fiscal year", "hereby", "herein", "hereinafter",
+	"heretofore", "markup", "notwithstanding", "omnibus", "promulgate",
+	"pursuant to", "reconciliation", "rescission", "rulemaking",
+	"sequestration", "statutory", "subparagraph", "subsection", "thereof",
+	"therein", "whereas",
+}
+
+// DefaultLexicon lists loaded or partisan wording that neutral text avoids
+var DefaultLexicon = []string{
+	"anchor baby", "big government", "boondoggle", "common-sense",
+	"corporate greed", "death panel", "death tax", "disastrous", "draconian",
+	"extremist", "far-left", "far-right", "gun grab", "handout", "heartless",
+	"illegal alien", "job-killing", "power grab", "radical", "reckless",
+	"rigged", "slush fund", "so-called", "socialist", "tax-and-spend",
+	"un-american", "war on", "welfare queen", "woke",
+}
+
+// Thresholds are the limits text must stay within to be published
+type Thresholds struct {
+	MaxGrade          float64
+	MaxSentenceLength float64
+	MaxJargon         int
+	MaxLoadedTerms    int
+}
+
+// Analyzer scores text against a glossary, a lexicon and thresholds
+type Analyzer struct {
+	glossary   []term
+	lexicon    []term
+	thresholds Thresholds
+	now        func() time.Time
+}
+
+// term is a glossary or lexicon entry and its words
+type term struct {
+	text  string
+	words []string
+}
+
+// NewAnalyzer creates an Analyzer that flags the terms of glossary and
+// lexicon, or of DefaultGlossary and DefaultLexicon if they are empty
+func NewAnalyzer(glossary, lexicon []string, thresholds Thresholds) *Analyzer {
+	if len(glossary) == 0 {
+		glossary = DefaultGlossary
+	}
+	if len(lexicon) == 0 {
+		lexicon = DefaultLexicon
+	}
+	return &Analyzer{
+		glossary:   terms(glossary),
+		lexicon:    terms(lexicon),
+		thresholds: thresholds,
+		now:        time.Now,
+	}
+}
+
+// FromConfig creates an Analyzer with the glossary, lexicon and thresholds
+// in cfg
+func FromConfig(cfg config.ReadabilityConfig) *Analyzer {
+	return NewAnalyzer(cfg.Glossary, cfg.Lexicon, Thresholds{
+		MaxGrade:          cfg.MaxGrade,
+		MaxSentenceLength: cfg.MaxSentenceLength,
+		MaxJargon:         cfg.MaxJargon,
+		MaxLoadedTerms:    cfg.MaxLoadedTerms,
+	})
+}
+
+// terms splits entries into words, leaving out entries without any
+func terms(entries []string) []term {
+	parsed := make([]term, 0, len(entries))
+	for _, entry := range entries {
+		if words := tokens(entry); len(words) > 0 {
+			parsed = append(parsed, term{text: strings.ToLower(strings.TrimSpace(entry)), words: words})
+		}
+	}
+	return parsed
+}
+
+// Analyze scores text and lists the thresholds it misses
+func (a *Analyzer) Analyze(text string) *models.Readability {
+	score := &models.Readability{AnalyzedAt: a.now().UTC().Truncate(time.Millisecond)}
+
+	syllables := 0
+	for _, sentence := range sentences(text) {
+		score.Sentences++
+		score.Words += len(sentence)
+		if len(sentence) > score.LongestSentence {
+			score.LongestSentence = len(sentence)
+		}
+		for _, word := range sentence {
+			syllables += countSyllables(word)
+		}
+	}
+	if score.Words > 0 {
+		wordsPerSentence := float64(score.Words) / float64(score.Sentences)
+		syllablesPerWord := float64(syllables) / float64(score.Words)
+		score.SentenceLength = round(wordsPerSentence)
+		score.Grade = round(0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59)
+		score.ReadingEase = round(206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord)
+	}
+
+	words := tokens(text)
+	score.JargonTerms = matches(words, a.glossary)
+	score.LoadedTerms = matches(words, a.lexicon)
+	score.Problems = a.problems(score)
+	return score
+}
+
+// problems returns why score misses the analyzer's thresholds
+func (a *Analyzer) problems(score *models.Readability) []string {
+	problems := make([]string, 0)
+	if score.Words == 0 {
+		return append(problems, "has no words")
+	}
+	if score.Grade > a.thresholds.MaxGrade {
+		problems = append(problems, fmt.Sprintf("reads at grade %.1f; at most %g is allowed", score.Grade, a.thresholds.MaxGrade))
+	}
+	if score.SentenceLength > a.thresholds.MaxSentenceLength {
+		problems = append(problems, fmt.Sprintf("averages %.1f words per sentence; at most %g is allowed", score.SentenceLength, a.thresholds.MaxSentenceLength))
+	}
+	if len(score.JargonTerms) > a.thresholds.MaxJargon {
+		problems = append(problems, fmt.Sprintf("uses %d legislative terms (%s); at most %d are allowed", len(score.JargonTerms), strings.Join(score.JargonTerms, ", "), a.thresholds.MaxJargon))
+	}
+	if len(score.LoadedTerms) > a.thresholds.MaxLoadedTerms {
+		problems = append(problems, fmt.Sprintf("uses loaded or partisan wording (%s); at most %d terms are allowed", strings.Join(score.LoadedTerms, ", "), a.thresholds.MaxLoadedTerms))
+	}
+	return problems
+}
+
+// abbreviations end with a period without ending a sentence
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "sen": true, "rep": true,
+	"gov": true, "no": true, "sec": true, "st": true, "jr": true, "sr": true,
+	"vs": true, "inc": true, "corp": true, "co": true, "e.g": true, "i.e": true,
+}
+
+// Sentences splits text into sentences, separating their words by single
+// spaces
+func Sentences(text string) []string {
+	split := sentences(text)
+	joined := make([]string, len(split))
+	for i, sentence := range split {
+		joined[i] = strings.Join(sentence, " ")
+	}
+	return joined
+}
+
+// sentences splits text into sentences of words. A word ending with ., ! or
+// ? ends a sentence unless it is an abbreviation or an initial.
+func sentences(text string) [][]string {
+	var all [][]string
+	var current []string
+	for _, field := range strings.Fields(text) {
+		word := strings.TrimRight(field, `"')]`)
+		if !strings.ContainsFunc(word, isWordRune) {
+			continue
+		}
+		current = append(current, field)
+
+		bare := strings.TrimRight(word, ".!?")
+		if bare == word {
+			continue
+		}
+		lower := strings.ToLower(bare)
+		if strings.HasSuffix(word, ".") && !strings.HasSuffix(word, "..") &&
+			(abbreviations[lower] || strings.Contains(bare, ".") || len([]rune(bare)) == 1) {
+			continue
+		}
+		all = append(all, current)
+		current = nil
+	}
+	if len(current) > 0 {
+		all = append(all, current)
+	}
+	return all
+}
+
+// tokens returns the lowercased words of text for matching terms. Hyphens
+// and punctuation separate words, so "job-killing" matches "job killing".
+func tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r) && r != '\''
+	})
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// matches returns the terms that occur in words, sorted. The last word of a
+// term also matches its plural.
+func matches(words []string, terms []term) []string {
+	found := make([]string, 0)
+	for _, t := range terms {
+		if occurs(words, t.words) {
+			found = append(found, t.text)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// occurs reports whether the phrase occurs in words
+func occurs(words, phrase []string) bool {
+	for start := 0; start+len(phrase) <= len(words); start++ {
+		matched := true
+		for i, want := range phrase {
+			got := words[start+i]
+			if got != want && (i < len(phrase)-1 || (got != want+"s" && got != want+"es")) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// countSyllables estimates the syllables of an English word by counting its
+// groups of vowels, not counting a silent final e
+func countSyllables(word string) int {
+	letters := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, word)
+	if len(letters) == 0 {
+		return 1 // Numbers and symbols
+	}
+	runes := []rune(letters)
+	if len(runes) <= 3 {
+		return 1
+	}
+	before := func(suffix int) rune { return runes[len(runes)-suffix-1] }
+	switch {
+	case strings.HasSuffix(letters, "le") && !isVowel(before(2)):
+		// "table" keeps its final syllable
+	case strings.HasSuffix(letters, "es") && !strings.ContainsRune("sxzcgh", before(2)),
+		strings.HasSuffix(letters, "ed") && !strings.ContainsRune("td", before(2)):
+		runes = runes[:len(runes)-2]
+	case strings.HasSuffix(letters, "e"):
+		runes = runes[:len(runes)-1]
+	}
+
+	count, previous := 0, false
+	for _, r := range runes {
+		vowel := isVowel(r)
+		if vowel && !previous {
+			count++
+		}
+		previous = vowel
+	}
+	if count == 0 {
+		return 1
+	}
+	return count
+}
+
+func isVowel(r rune) bool {
+	return strings.ContainsRune("aeiouy", r)
+}
+
+// round rounds to one decimal place
+func round(x float64) float64 {
+	return math.Round(x*10) / 10
+}
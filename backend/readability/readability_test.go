@@ -0,0 +1,91 @@
+package readability
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var thresholds = Thresholds{MaxGrade: 10, MaxSentenceLength: 25, MaxJargon: 1, MaxLoadedTerms: 0}
+
+func TestPlainTextPasses(t *testing.T) {
+	score := NewAnalyzer(nil, nil, thresholds).Analyze("The bill keeps tap water safe. Cities must test their water each year. The state pays for the tests.")
+	if score.Words != 19 || score.Sentences != 3 || score.LongestSentence != 7 {
+		t.Errorf("words, sentences, longest = %d, %d, %d; want 19, 3, 7", score.Words, score.Sentences, score.LongestSentence)
+	}
+	if score.SentenceLength != 6.3 || score.Grade > 4 || score.ReadingEase < 80 {
+		t.Errorf("score = %+v, want short, easy sentences", score)
+	}
+	if len(score.Problems) != 0 {
+		t.Errorf("problems = %v, want none", score.Problems)
+	}
+}
+
+func TestDenseTextFails(t *testing.T) {
+	text := "Notwithstanding any other provision of law, the appropriation authorized pursuant to subsection (b) shall remain available for the administration of comprehensive environmental remediation initiatives throughout the fiscal year."
+	score := NewAnalyzer(nil, nil, thresholds).Analyze(text)
+
+	want := []string{"appropriation", "fiscal year", "notwithstanding", "pursuant to", "subsection"}
+	if !reflect.DeepEqual(score.JargonTerms, want) {
+		t.Errorf("jargon = %v, want %v", score.JargonTerms, want)
+	}
+	if len(score.Problems) != 3 {
+		t.Fatalf("problems = %v, want grade, sentence length and jargon", score.Problems)
+	}
+	for i, want := range []string{"grade", "words per sentence", "legislative terms"} {
+		if !strings.Contains(score.Problems[i], want) {
+			t.Errorf("problem %d = %q, want it about %s", i, score.Problems[i], want)
+		}
+	}
+}
+
+func TestLoadedWording(t *testing.T) {
+	analyzer := NewAnalyzer(nil, nil, thresholds)
+	score := analyzer.Analyze("This job killing bill is a power grab by radicals. It ends the so-called death tax.")
+	want := []string{"death tax", "job-killing", "power grab", "radical", "so-called"}
+	if !reflect.DeepEqual(score.LoadedTerms, want) {
+		t.Errorf("loaded terms = %v, want %v", score.LoadedTerms, want)
+	}
+	if len(score.Problems) != 1 || !strings.Contains(score.Problems[0], "loaded") {
+		t.Errorf("problems = %v, want the loaded wording", score.Problems)
+	}
+
+	// Words that merely contain a term are not flagged
+	if score := analyzer.Analyze("The radicchio tariff is wholesome."); len(score.LoadedTerms) != 0 {
+		t.Errorf("loaded terms = %v, want none", score.LoadedTerms)
+	}
+
+	// A configured lexicon replaces the default one
+	custom := NewAnalyzer(nil, []string{"Sweeping"}, thresholds)
+	if score := custom.Analyze("A sweeping and radical change."); !reflect.DeepEqual(score.LoadedTerms, []string{"sweeping"}) {
+		t.Errorf("loaded terms = %v, want only the configured term", score.LoadedTerms)
+	}
+}
+
+func TestSentences(t *testing.T) {
+	got := sentences(`Sen. Smith of the U.S. Senate wrote it. Does it pass? "Yes!" said Dr. J. Doe`)
+	if len(got) != 4 {
+		t.Fatalf("sentences = %q, want 4", got)
+	}
+	if len(got[0]) != 8 {
+		t.Errorf("first sentence = %q, want abbreviations and initials kept in it", got[0])
+	}
+}
+
+func TestCountSyllables(t *testing.T) {
+	for word, want := range map[string]int{
+		"water": 2, "safe": 1, "table": 2, "tests": 1, "wanted": 2, "passes": 2,
+		"legislation": 4, "the": 1, "2024": 1, "environmental": 5,
+	} {
+		if got := countSyllables(word); got != want {
+			t.Errorf("syllables(%q) = %d, want %d", word, got, want)
+		}
+	}
+}
+
+func TestEmptyText(t *testing.T) {
+	score := NewAnalyzer(nil, nil, thresholds).Analyze("  ")
+	if score.Words != 0 || score.Grade != 0 || len(score.Problems) != 1 {
+		t.Errorf("score = %+v, want no words reported", score)
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"bytes"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ChangedFields returns the names of the top-level fields whose BSON values
+// differ between two documents of the same type, including fields present in
+// only one of them. Embedded documents and arrays count as one field.
+func ChangedFields(before, after interface{}) ([]string, error) {
+	old, err := topLevelValues(before)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := bson.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := bson.Raw(raw).Elements()
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for _, element := range elements {
+		key, value := element.Key(), element.Value()
+		previous, ok := old[key]
+		if !ok || previous.Type != value.Type || !bytes.Equal(previous.Value, value.Value) {
+			fields = append(fields, key)
+		}
+		delete(old, key)
+	}
+	for key := range old {
+		fields = append(fields, key)
+	}
+	return fields, nil
+}
+
+// topLevelValues returns the top-level fields of doc's BSON form
+func topLevelValues(doc interface{}) (map[string]bson.RawValue, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := bson.Raw(raw).Elements()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]bson.RawValue, len(elements))
+	for _, element := range elements {
+		values[element.Key()] = element.Value()
+	}
+	return values, nil
+}
@@ -0,0 +1,1106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewMemoryStore creates a Store that keeps all data in process memory.
+// Documents are copied through BSON on every read and write, so callers
+// observe the same field handling as with MongoDB.
+func NewMemoryStore() *Store {
+	return &Store{
+		Users: &memoryUsers{newMemoryCollection(
+			func(u *models.User) *primitive.ObjectID { return &u.ID },
+			func(a, b *models.User) bool { return a.Email == b.Email },
+			func(u *models.User) *int64 { return &u.Revision },
+		)},
+		Policies: &memoryPolicies{newMemoryCollection(
+			func(p *models.Policy) *primitive.ObjectID { return &p.ID }, nil,
+			func(p *models.Policy) *int64 { return &p.Revision },
+		)},
+		Representatives: &memoryRepresentatives{newMemoryCollection(
+			func(r *models.Representative) *primitive.ObjectID { return &r.ID }, nil,
+			func(r *models.Representative) *int64 { return &r.Revision },
+		)},
+		Quizzes: &memoryQuizzes{newMemoryCollection(
+			func(q *models.PoliticalQuiz) *primitive.ObjectID { return &q.ID }, nil,
+			func(q *models.PoliticalQuiz) *int64 { return &q.Revision },
+		)},
+		QuizResults: &memoryQuizResults{newMemoryCollection(
+			func(r *models.QuizResult) *primitive.ObjectID { return &r.ID }, nil, nil,
+		)},
+		APIKeys: &memoryAPIKeys{newMemoryCollection(
+			func(k *models.APIKey) *primitive.ObjectID { return &k.ID },
+			func(a, b *models.APIKey) bool { return a.Hash == b.Hash }, nil,
+		)},
+		Audit: &memoryAudit{newMemoryCollection(
+			func(e *models.AuditEntry) *primitive.ObjectID { return &e.ID },
+			func(a, b *models.AuditEntry) bool { return a.Sequence == b.Sequence }, nil,
+		)},
+		Summaries: &memorySummaries{newMemoryCollection(
+			func(s *models.Summary) *primitive.ObjectID { return &s.ID }, nil,
+			func(s *models.Summary) *int64 { return &s.Revision },
+		)},
+		Annotations: &memoryAnnotations{newMemoryCollection(
+			func(a *models.Annotation) *primitive.ObjectID { return &a.ID }, nil,
+			func(a *models.Annotation) *int64 { return &a.Revision },
+		)},
+		Comments: &memoryComments{newMemoryCollection(
+			func(c *models.Comment) *primitive.ObjectID { return &c.ID }, nil,
+			func(c *models.Comment) *int64 { return &c.Revision },
+		)},
+		Ballots: &memoryBallots{newMemoryCollection(
+			func(b *models.Ballot) *primitive.ObjectID { return &b.ID },
+			func(a, b *models.Ballot) bool { return a.PolicyID == b.PolicyID && a.UserID == b.UserID }, nil,
+		)},
+	}
+}
+
+// memoryCollection is an insertion-ordered set of documents keyed by ID
+type memoryCollection[T any] struct {
+	mu    sync.RWMutex
+	docs  map[primitive.ObjectID][]byte
+	order []primitive.ObjectID
+
+	// id returns a pointer to the document's ID field
+	id func(*T) *primitive.ObjectID
+	// conflicts reports whether two documents violate a unique constraint
+	conflicts func(a, b *T) bool
+	// revision returns a pointer to the document's revision field, if it has one
+	revision func(*T) *int64
+}
+
+func newMemoryCollection[T any](id func(*T) *primitive.ObjectID, conflicts func(a, b *T) bool, revision func(*T) *int64) *memoryCollection[T] {
+	return &memoryCollection[T]{
+		docs:      make(map[primitive.ObjectID][]byte),
+		id:        id,
+		conflicts: conflicts,
+		revision:  revision,
+	}
+}
+
+func (c *memoryCollection[T]) decode(raw []byte) (*T, error) {
+	var doc T
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// get returns a copy of the document with the given ID
+func (c *memoryCollection[T]) get(id primitive.ObjectID) (*T, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	raw, ok := c.docs[id]
+	if !ok || trashed(raw) {
+		return nil, ErrNotFound
+	}
+	return c.decode(raw)
+}
+
+// find returns copies of every document accepted by match, in insertion
+// order, leaving out trashed documents
+func (c *memoryCollection[T]) find(match func(*T) bool) ([]T, error) {
+	return c.scan(false, match)
+}
+
+// findTrashed returns copies of every trashed document, in insertion order
+func (c *memoryCollection[T]) findTrashed() ([]T, error) {
+	return c.scan(true, nil)
+}
+
+// scan returns copies of the documents in or out of the trash that are
+// accepted by match, in insertion order
+func (c *memoryCollection[T]) scan(inTrash bool, match func(*T) bool) ([]T, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	docs := make([]T, 0)
+	for _, id := range c.order {
+		raw := c.docs[id]
+		if trashed(raw) != inTrash {
+			continue
+		}
+		doc, err := c.decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		if match == nil || match(doc) {
+			docs = append(docs, *doc)
+		}
+	}
+	return docs, nil
+}
+
+// trashed reports whether a stored document is in the trash
+func trashed(raw []byte) bool {
+	_, err := bson.Raw(raw).LookupErr("deleted_at")
+	return err == nil
+}
+
+// checkConflicts must be called with the lock held
+func (c *memoryCollection[T]) checkConflicts(doc *T) error {
+	if c.conflicts == nil {
+		return nil
+	}
+	for id, raw := range c.docs {
+		if id == *c.id(doc) {
+			continue
+		}
+		existing, err := c.decode(raw)
+		if err != nil {
+			return err
+		}
+		if c.conflicts(existing, doc) {
+			return ErrDuplicate
+		}
+	}
+	return nil
+}
+
+func (c *memoryCollection[T]) insert(doc *T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.id(doc)
+	if id.IsZero() {
+		*id = primitive.NewObjectID()
+	}
+	if _, ok := c.docs[*id]; ok {
+		return ErrDuplicate
+	}
+	if err := c.checkConflicts(doc); err != nil {
+		return err
+	}
+	if c.revision != nil {
+		*c.revision(doc) = 1
+	}
+
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	c.docs[*id] = raw
+	c.order = append(c.order, *id)
+	return nil
+}
+
+// replace stores doc in place of the document with the same ID. For
+// collections with revisions, the stored revision must match doc's unless
+// that is zero, and doc is given the next revision.
+func (c *memoryCollection[T]) replace(doc *T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := *c.id(doc)
+	stored, ok := c.docs[id]
+	if !ok || trashed(stored) {
+		return ErrNotFound
+	}
+	if err := c.checkConflicts(doc); err != nil {
+		return err
+	}
+
+	if c.revision != nil {
+		existing, err := c.decode(stored)
+		if err != nil {
+			return err
+		}
+		current, expected := *c.revision(existing), c.revision(doc)
+		if *expected != 0 && *expected != current {
+			return ErrRevisionConflict
+		}
+		*expected = current + 1
+	}
+
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	c.docs[id] = raw
+	return nil
+}
+
+// patch writes the named top-level fields of doc to the stored document with
+// the same ID, checking and incrementing the revision as replace does, and
+// sets doc to the result
+func (c *memoryCollection[T]) patch(doc *T, fields []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := *c.id(doc)
+	stored, ok := c.docs[id]
+	if !ok || trashed(stored) {
+		return ErrNotFound
+	}
+	existing, err := c.decode(stored)
+	if err != nil {
+		return err
+	}
+	current, expected := *c.revision(existing), c.revision(doc)
+	if *expected != 0 && *expected != current {
+		return ErrRevisionConflict
+	}
+	*expected = current + 1
+
+	changes, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var merged bson.D
+	if err := bson.Unmarshal(stored, &merged); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		value, err := bson.Raw(changes).LookupErr(field)
+		merged = setField(merged, field, value, err == nil)
+	}
+	merged = setField(merged, "revision", bson.Raw(changes).Lookup("revision"), true)
+
+	raw, err := bson.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	result, err := c.decode(raw)
+	if err != nil {
+		return err
+	}
+	if err := c.checkConflicts(result); err != nil {
+		return err
+	}
+	c.docs[id] = raw
+	*doc = *result
+	return nil
+}
+
+// setField sets or, if present is false, removes a top-level field of doc
+func setField(doc bson.D, key string, value interface{}, present bool) bson.D {
+	for i, element := range doc {
+		if element.Key != key {
+			continue
+		}
+		if present {
+			doc[i].Value = value
+			return doc
+		}
+		return append(doc[:i], doc[i+1:]...)
+	}
+	if present {
+		doc = append(doc, bson.E{Key: key, Value: value})
+	}
+	return doc
+}
+
+// update applies change to the stored document with the given ID and
+// returns a copy of the result. The document is left unchanged if change
+// returns an error.
+func (c *memoryCollection[T]) update(id primitive.ObjectID, change func(*T) error) (*T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	raw, ok := c.docs[id]
+	if !ok || trashed(raw) {
+		return nil, ErrNotFound
+	}
+	doc, err := c.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(doc); err != nil {
+		return nil, err
+	}
+
+	raw, err = bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	c.docs[id] = raw
+	return doc, nil
+}
+
+// updateAll applies change to every stored document, including trashed
+// ones, and increments the revision of those it reports as changed
+func (c *memoryCollection[T]) updateAll(change func(*T) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, raw := range c.docs {
+		doc, err := c.decode(raw)
+		if err != nil {
+			return err
+		}
+		if !change(doc) {
+			continue
+		}
+		*c.revision(doc)++
+		if raw, err = bson.Marshal(doc); err != nil {
+			return err
+		}
+		c.docs[id] = raw
+	}
+	return nil
+}
+
+// moveToTrash marks the document with the given ID as deleted by the given
+// user at the given time
+func (c *memoryCollection[T]) moveToTrash(id, by primitive.ObjectID, at time.Time) error {
+	_, err := c.setTrashed(id, false, func(doc bson.D) bson.D {
+		doc = setField(doc, "deleted_at", at, true)
+		return setField(doc, "deleted_by", by, true)
+	})
+	return err
+}
+
+// restore takes the document with the given ID out of the trash
+func (c *memoryCollection[T]) restore(id primitive.ObjectID) (*T, error) {
+	return c.setTrashed(id, true, func(doc bson.D) bson.D {
+		doc = setField(doc, "deleted_at", nil, false)
+		return setField(doc, "deleted_by", nil, false)
+	})
+}
+
+// setTrashed applies change to the stored document with the given ID, which
+// must be in the trash if inTrash is set and out of it otherwise, increments
+// its revision and returns a copy of the result
+func (c *memoryCollection[T]) setTrashed(id primitive.ObjectID, inTrash bool, change func(bson.D) bson.D) (*T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	raw, ok := c.docs[id]
+	if !ok || trashed(raw) != inTrash {
+		return nil, ErrNotFound
+	}
+	existing, err := c.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	doc = change(doc)
+	doc = setField(doc, "revision", *c.revision(existing)+1, true)
+
+	if raw, err = bson.Marshal(doc); err != nil {
+		return nil, err
+	}
+	c.docs[id] = raw
+	return c.decode(raw)
+}
+
+// purge removes the trashed documents among ids
+func (c *memoryCollection[T]) purge(ids []primitive.ObjectID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range ids {
+		if raw, ok := c.docs[id]; ok && trashed(raw) {
+			c.delete(id)
+		}
+	}
+	return nil
+}
+
+func (c *memoryCollection[T]) remove(id primitive.ObjectID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.docs[id]; !ok {
+		return ErrNotFound
+	}
+	c.delete(id)
+	return nil
+}
+
+// delete must be called with the lock held
+func (c *memoryCollection[T]) delete(id primitive.ObjectID) {
+	delete(c.docs, id)
+	for i, existing := range c.order {
+		if existing == id {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// byDeletion sorts trashed documents most recently deleted first
+func byDeletion[T any](docs []T, deletedAt func(*T) *time.Time) []T {
+	sort.SliceStable(docs, func(i, j int) bool {
+		a, b := deletedAt(&docs[i]), deletedAt(&docs[j])
+		return a != nil && b != nil && a.After(*b)
+	})
+	return docs
+}
+
+// paginate applies skip and limit to docs
+func paginate[T any](docs []T, limit, skip int64) []T {
+	if skip > 0 {
+		if skip >= int64(len(docs)) {
+			return docs[:0]
+		}
+		docs = docs[skip:]
+	}
+	if limit > 0 && limit < int64(len(docs)) {
+		docs = docs[:limit]
+	}
+	return docs
+}
+
+type memoryUsers struct {
+	*memoryCollection[models.User]
+}
+
+func (r *memoryUsers) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return r.get(id)
+}
+
+func (r *memoryUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	users, err := r.find(func(u *models.User) bool { return u.Email == email })
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, ErrNotFound
+	}
+	return &users[0], nil
+}
+
+func (r *memoryUsers) List(ctx context.Context) ([]models.User, error) {
+	return r.find(nil)
+}
+
+func (r *memoryUsers) Create(ctx context.Context, user *models.User) error {
+	return r.insert(user)
+}
+
+func (r *memoryUsers) Update(ctx context.Context, user *models.User) error {
+	return r.replace(user)
+}
+
+func (r *memoryUsers) Patch(ctx context.Context, user *models.User, fields []string) error {
+	return r.patch(user, fields)
+}
+
+func (r *memoryUsers) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.remove(id)
+}
+
+type memoryPolicies struct {
+	*memoryCollection[models.Policy]
+}
+
+func (r *memoryPolicies) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Policy, error) {
+	return r.get(id)
+}
+
+func (r *memoryPolicies) List(ctx context.Context, filter PolicyFilter) ([]models.Policy, error) {
+	policies, err := r.find(func(p *models.Policy) bool {
+		return (filter.Level == "" || p.Level == filter.Level) &&
+			(filter.State == "" || p.Jurisdiction.State == filter.State) &&
+			(filter.City == "" || p.Jurisdiction.City == filter.City) &&
+			(filter.Status == "" || p.Status == filter.Status) &&
+			(filter.Type == "" || p.Type == filter.Type) &&
+			(!filter.Unsummarized || (p.OriginalText != "" && p.SimplifiedDesc == ""))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first
+	sort.SliceStable(policies, func(i, j int) bool {
+		return policies[i].IntroducedDate.After(policies[j].IntroducedDate)
+	})
+	return paginate(policies, filter.Limit, filter.Skip), nil
+}
+
+func (r *memoryPolicies) Create(ctx context.Context, policy *models.Policy) error {
+	return r.insert(policy)
+}
+
+func (r *memoryPolicies) Update(ctx context.Context, policy *models.Policy) error {
+	return r.replace(policy)
+}
+
+func (r *memoryPolicies) Patch(ctx context.Context, policy *models.Policy, fields []string) error {
+	return r.patch(policy, fields)
+}
+
+func (r *memoryPolicies) Delete(ctx context.Context, id, by primitive.ObjectID, at time.Time) error {
+	return r.moveToTrash(id, by, at)
+}
+
+func (r *memoryPolicies) ListTrash(ctx context.Context) ([]models.Policy, error) {
+	policies, err := r.findTrashed()
+	if err != nil {
+		return nil, err
+	}
+	return byDeletion(policies, func(p *models.Policy) *time.Time { return p.DeletedAt }), nil
+}
+
+func (r *memoryPolicies) Restore(ctx context.Context, id primitive.ObjectID) (*models.Policy, error) {
+	return r.restore(id)
+}
+
+func (r *memoryPolicies) Purge(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.purge(ids)
+}
+
+func (r *memoryPolicies) RemovePolicyReferences(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.updateAll(func(p *models.Policy) bool {
+		related := p.RelatedPolicies[:0]
+		for _, id := range p.RelatedPolicies {
+			if !slices.Contains(ids, id) {
+				related = append(related, id)
+			}
+		}
+		changed := len(related) != len(p.RelatedPolicies)
+		p.RelatedPolicies = related
+		return changed
+	})
+}
+
+func (r *memoryPolicies) RemoveRepresentativeReferences(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.updateAll(func(p *models.Policy) bool {
+		votes := p.VotingRecord[:0]
+		for _, vote := range p.VotingRecord {
+			if !slices.Contains(ids, vote.RepresentativeID) {
+				votes = append(votes, vote)
+			}
+		}
+		sponsors := p.Sponsors[:0]
+		for _, sponsor := range p.Sponsors {
+			if !slices.Contains(ids, sponsor.ID) {
+				sponsors = append(sponsors, sponsor)
+			}
+		}
+		changed := len(votes) != len(p.VotingRecord) || len(sponsors) != len(p.Sponsors)
+		p.VotingRecord, p.Sponsors = votes, sponsors
+		return changed
+	})
+}
+
+func (r *memoryPolicies) VotesByRepresentative(ctx context.Context, representativeID primitive.ObjectID) ([]models.RepresentativeVote, error) {
+	policies, err := r.List(ctx, PolicyFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make([]models.RepresentativeVote, 0)
+	for _, policy := range policies {
+		for _, vote := range policy.VotingRecord {
+			if vote.RepresentativeID != representativeID {
+				continue
+			}
+			votes = append(votes, models.RepresentativeVote{
+				PolicyID: policy.ID,
+				Title:    policy.Title,
+				Status:   policy.Status,
+				Vote:     vote,
+			})
+		}
+	}
+	return votes, nil
+}
+
+type memoryRepresentatives struct {
+	*memoryCollection[models.Representative]
+}
+
+func (r *memoryRepresentatives) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Representative, error) {
+	return r.get(id)
+}
+
+func (r *memoryRepresentatives) List(ctx context.Context, filter RepresentativeFilter) ([]models.Representative, error) {
+	representatives, err := r.find(func(rep *models.Representative) bool {
+		return (filter.State == "" || rep.State == filter.State) &&
+			(filter.Party == "" || rep.Party == filter.Party) &&
+			(filter.Title == "" || rep.Title == filter.Title)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paginate(representatives, filter.Limit, 0), nil
+}
+
+func (r *memoryRepresentatives) Create(ctx context.Context, representative *models.Representative) error {
+	return r.insert(representative)
+}
+
+func (r *memoryRepresentatives) Update(ctx context.Context, representative *models.Representative) error {
+	return r.replace(representative)
+}
+
+func (r *memoryRepresentatives) Patch(ctx context.Context, representative *models.Representative, fields []string) error {
+	return r.patch(representative, fields)
+}
+
+func (r *memoryRepresentatives) Delete(ctx context.Context, id, by primitive.ObjectID, at time.Time) error {
+	return r.moveToTrash(id, by, at)
+}
+
+func (r *memoryRepresentatives) ListTrash(ctx context.Context) ([]models.Representative, error) {
+	representatives, err := r.findTrashed()
+	if err != nil {
+		return nil, err
+	}
+	return byDeletion(representatives, func(rep *models.Representative) *time.Time { return rep.DeletedAt }), nil
+}
+
+func (r *memoryRepresentatives) Restore(ctx context.Context, id primitive.ObjectID) (*models.Representative, error) {
+	return r.restore(id)
+}
+
+func (r *memoryRepresentatives) Purge(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.purge(ids)
+}
+
+func (r *memoryRepresentatives) RemovePolicyReferences(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.updateAll(func(rep *models.Representative) bool {
+		history := rep.VotingHistory[:0]
+		for _, id := range rep.VotingHistory {
+			if !slices.Contains(ids, id) {
+				history = append(history, id)
+			}
+		}
+		changed := len(history) != len(rep.VotingHistory)
+		rep.VotingHistory = history
+		return changed
+	})
+}
+
+type memoryQuizzes struct {
+	*memoryCollection[models.PoliticalQuiz]
+}
+
+func (r *memoryQuizzes) FindByID(ctx context.Context, id primitive.ObjectID) (*models.PoliticalQuiz, error) {
+	return r.get(id)
+}
+
+func (r *memoryQuizzes) List(ctx context.Context, filter QuizFilter) ([]models.PoliticalQuiz, error) {
+	quizzes, err := r.find(func(q *models.PoliticalQuiz) bool {
+		if filter.Category == "" {
+			return true
+		}
+		for _, category := range q.Categories {
+			if category == filter.Category {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paginate(quizzes, filter.Limit, 0), nil
+}
+
+func (r *memoryQuizzes) Create(ctx context.Context, quiz *models.PoliticalQuiz) error {
+	return r.insert(quiz)
+}
+
+func (r *memoryQuizzes) Update(ctx context.Context, quiz *models.PoliticalQuiz) error {
+	return r.replace(quiz)
+}
+
+func (r *memoryQuizzes) Patch(ctx context.Context, quiz *models.PoliticalQuiz, fields []string) error {
+	return r.patch(quiz, fields)
+}
+
+func (r *memoryQuizzes) Delete(ctx context.Context, id, by primitive.ObjectID, at time.Time) error {
+	return r.moveToTrash(id, by, at)
+}
+
+func (r *memoryQuizzes) ListTrash(ctx context.Context) ([]models.PoliticalQuiz, error) {
+	quizzes, err := r.findTrashed()
+	if err != nil {
+		return nil, err
+	}
+	return byDeletion(quizzes, func(q *models.PoliticalQuiz) *time.Time { return q.DeletedAt }), nil
+}
+
+func (r *memoryQuizzes) Restore(ctx context.Context, id primitive.ObjectID) (*models.PoliticalQuiz, error) {
+	return r.restore(id)
+}
+
+func (r *memoryQuizzes) Purge(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.purge(ids)
+}
+
+func (r *memoryQuizzes) RemoveRepresentativeReferences(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.updateAll(func(q *models.PoliticalQuiz) bool {
+		changed := false
+		for i := range q.Questions {
+			question := &q.Questions[i]
+			stances := question.RepresentativeStances[:0]
+			for _, stance := range question.RepresentativeStances {
+				if !slices.Contains(ids, stance.RepresentativeID) {
+					stances = append(stances, stance)
+				}
+			}
+			changed = changed || len(stances) != len(question.RepresentativeStances)
+			question.RepresentativeStances = stances
+		}
+		return changed
+	})
+}
+
+type memoryQuizResults struct {
+	*memoryCollection[models.QuizResult]
+}
+
+func (r *memoryQuizResults) FindByID(ctx context.Context, id primitive.ObjectID) (*models.QuizResult, error) {
+	return r.get(id)
+}
+
+func (r *memoryQuizResults) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.QuizResult, error) {
+	results, err := r.find(func(result *models.QuizResult) bool { return result.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].TakenAt.After(results[j].TakenAt) })
+	return results, nil
+}
+
+func (r *memoryQuizResults) Create(ctx context.Context, result *models.QuizResult) error {
+	return r.insert(result)
+}
+
+type memoryAPIKeys struct {
+	*memoryCollection[models.APIKey]
+}
+
+func (r *memoryAPIKeys) FindByID(ctx context.Context, id primitive.ObjectID) (*models.APIKey, error) {
+	return r.get(id)
+}
+
+func (r *memoryAPIKeys) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	keys, err := r.find(func(k *models.APIKey) bool { return k.Hash == hash })
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+	return &keys[0], nil
+}
+
+func (r *memoryAPIKeys) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.APIKey, error) {
+	keys, err := r.find(func(k *models.APIKey) bool { return k.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (r *memoryAPIKeys) Create(ctx context.Context, key *models.APIKey) error {
+	return r.insert(key)
+}
+
+func (r *memoryAPIKeys) Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.update(id, func(k *models.APIKey) error {
+		if k.RevokedAt == nil {
+			k.RevokedAt = &at
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *memoryAPIKeys) RecordUse(ctx context.Context, id primitive.ObjectID, at time.Time) (*models.APIKey, error) {
+	day := usageDay(at)
+	return r.update(id, func(k *models.APIKey) error {
+		if k.UsageDay != day {
+			k.UsageDay = day
+			k.UsageToday = 0
+		}
+		if k.UsageToday >= k.DailyQuota {
+			return ErrQuotaExceeded
+		}
+		k.UsageToday++
+		k.UsageCount++
+		k.LastUsedAt = &at
+		return nil
+	})
+}
+
+// usageDay returns the UTC day that API key usage at t is counted against
+func usageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+type memoryAudit struct {
+	*memoryCollection[models.AuditEntry]
+}
+
+func (r *memoryAudit) Append(ctx context.Context, entry *models.AuditEntry) error {
+	return r.insert(entry)
+}
+
+func (r *memoryAudit) Last(ctx context.Context) (*models.AuditEntry, error) {
+	entries, err := r.find(func(*models.AuditEntry) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNotFound
+	}
+	last := &entries[0]
+	for i := range entries {
+		if entries[i].Sequence > last.Sequence {
+			last = &entries[i]
+		}
+	}
+	return last, nil
+}
+
+func (r *memoryAudit) List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	entries, err := r.find(func(e *models.AuditEntry) bool {
+		return (filter.ActorID.IsZero() || (e.ActorID != nil && *e.ActorID == filter.ActorID)) &&
+			(filter.Action == "" || e.Action == filter.Action) &&
+			(filter.ResourceType == "" || e.ResourceType == filter.ResourceType) &&
+			(filter.ResourceID.IsZero() || e.ResourceID == filter.ResourceID) &&
+			(filter.From.IsZero() || !e.At.Before(filter.From)) &&
+			(filter.To.IsZero() || e.At.Before(filter.To))
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Sequence > entries[j].Sequence })
+	return paginate(entries, filter.Limit, filter.Skip), nil
+}
+
+func (r *memoryAudit) Range(ctx context.Context, after, limit int64) ([]models.AuditEntry, error) {
+	entries, err := r.find(func(e *models.AuditEntry) bool { return e.Sequence > after })
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Sequence < entries[j].Sequence })
+	return paginate(entries, limit, 0), nil
+}
+
+type memorySummaries struct {
+	*memoryCollection[models.Summary]
+}
+
+func (r *memorySummaries) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Summary, error) {
+	return r.get(id)
+}
+
+func (r *memorySummaries) List(ctx context.Context, filter SummaryFilter) ([]models.Summary, error) {
+	summaries, err := r.find(func(s *models.Summary) bool {
+		policy := s.PolicyID == filter.PolicyID ||
+			filter.PolicyID.IsZero() && (len(filter.PolicyIDs) == 0 || slices.Contains(filter.PolicyIDs, s.PolicyID))
+		return policy && (filter.Status == "" || s.Status == filter.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt) })
+	return paginate(summaries, filter.Limit, filter.Skip), nil
+}
+
+func (r *memorySummaries) Create(ctx context.Context, summary *models.Summary) error {
+	return r.insert(summary)
+}
+
+func (r *memorySummaries) Update(ctx context.Context, summary *models.Summary) error {
+	return r.replace(summary)
+}
+
+func (r *memorySummaries) DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error {
+	summaries, err := r.find(func(s *models.Summary) bool { return slices.Contains(policyIDs, s.PolicyID) })
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range summaries {
+		r.delete(summaries[i].ID)
+	}
+	return nil
+}
+
+type memoryAnnotations struct {
+	*memoryCollection[models.Annotation]
+}
+
+func (r *memoryAnnotations) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Annotation, error) {
+	return r.get(id)
+}
+
+func (r *memoryAnnotations) List(ctx context.Context, filter AnnotationFilter) ([]models.Annotation, error) {
+	annotations, err := r.find(func(a *models.Annotation) bool {
+		public := a.Visibility == models.AnnotationPublic
+		return (filter.PolicyID.IsZero() || a.PolicyID == filter.PolicyID) &&
+			(filter.Anchor == "" || a.Target != nil && a.Target.Anchor == filter.Anchor) &&
+			(filter.AuthorID.IsZero() || a.AuthorID == filter.AuthorID) &&
+			(filter.VisibleTo.IsZero() || public || a.AuthorID == filter.VisibleTo) &&
+			(!filter.PublicOnly || public) &&
+			(filter.TextVersionBelow == 0 || a.Target != nil && a.Target.TextVersion < filter.TextVersionBelow)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(annotations, func(i, j int) bool { return annotations[i].CreatedAt.Before(annotations[j].CreatedAt) })
+	return paginate(annotations, filter.Limit, filter.Skip), nil
+}
+
+func (r *memoryAnnotations) Create(ctx context.Context, annotation *models.Annotation) error {
+	return r.insert(annotation)
+}
+
+func (r *memoryAnnotations) Update(ctx context.Context, annotation *models.Annotation) error {
+	return r.replace(annotation)
+}
+
+func (r *memoryAnnotations) Delete(ctx context.Context, ids []primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		r.delete(id)
+	}
+	return nil
+}
+
+func (r *memoryAnnotations) DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error {
+	annotations, err := r.find(func(a *models.Annotation) bool { return slices.Contains(policyIDs, a.PolicyID) })
+	if err != nil {
+		return err
+	}
+	ids := make([]primitive.ObjectID, len(annotations))
+	for i := range annotations {
+		ids[i] = annotations[i].ID
+	}
+	return r.Delete(ctx, ids)
+}
+
+type memoryComments struct {
+	*memoryCollection[models.Comment]
+}
+
+func (r *memoryComments) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	return r.get(id)
+}
+
+func (r *memoryComments) List(ctx context.Context, filter CommentFilter) ([]models.Comment, error) {
+	comments, err := r.find(func(c *models.Comment) bool {
+		inThread := slices.Contains(ThreadStatuses, c.Status)
+		queued := c.Status == models.CommentHeld || c.Status == models.CommentPublished && len(c.Reports) > 0
+		return (filter.PolicyID.IsZero() || c.PolicyID == filter.PolicyID) &&
+			(filter.AuthorID.IsZero() || c.AuthorID == filter.AuthorID) &&
+			(len(filter.Statuses) == 0 || slices.Contains(filter.Statuses, c.Status)) &&
+			(filter.VisibleTo.IsZero() || inThread || c.AuthorID == filter.VisibleTo) &&
+			(!filter.Queue || queued)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].CreatedAt.Before(comments[j].CreatedAt) })
+	return paginate(comments, filter.Limit, filter.Skip), nil
+}
+
+func (r *memoryComments) Create(ctx context.Context, comment *models.Comment) error {
+	return r.insert(comment)
+}
+
+func (r *memoryComments) Update(ctx context.Context, comment *models.Comment) error {
+	return r.replace(comment)
+}
+
+func (r *memoryComments) DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error {
+	comments, err := r.find(func(c *models.Comment) bool { return slices.Contains(policyIDs, c.PolicyID) })
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range comments {
+		r.delete(comments[i].ID)
+	}
+	return nil
+}
+
+type memoryBallots struct {
+	*memoryCollection[models.Ballot]
+}
+
+func (r *memoryBallots) Find(ctx context.Context, policyID, userID primitive.ObjectID) (*models.Ballot, error) {
+	ballots, err := r.find(func(b *models.Ballot) bool { return b.PolicyID == policyID && b.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	if len(ballots) == 0 {
+		return nil, ErrNotFound
+	}
+	return &ballots[0], nil
+}
+
+func (r *memoryBallots) Cast(ctx context.Context, ballot *models.Ballot) error {
+	existing, err := r.Find(ctx, ballot.PolicyID, ballot.UserID)
+	if errors.Is(err, ErrNotFound) {
+		ballot.ID = primitive.NilObjectID
+		return r.insert(ballot)
+	}
+	if err != nil {
+		return err
+	}
+	ballot.ID = existing.ID
+	return r.replace(ballot)
+}
+
+func (r *memoryBallots) Withdraw(ctx context.Context, policyID, userID primitive.ObjectID) error {
+	existing, err := r.Find(ctx, policyID, userID)
+	if err != nil {
+		return err
+	}
+	return r.remove(existing.ID)
+}
+
+func (r *memoryBallots) Count(ctx context.Context, policyIDs []primitive.ObjectID) ([]models.BallotCount, error) {
+	ballots, err := r.find(func(b *models.Ballot) bool { return slices.Contains(policyIDs, b.PolicyID) })
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]models.BallotCount, 0)
+	index := map[models.BallotCount]int{}
+	for _, ballot := range ballots {
+		key := models.BallotCount{PolicyID: ballot.PolicyID, State: ballot.State, District: ballot.District, Choice: ballot.Choice}
+		i, ok := index[key]
+		if !ok {
+			i = len(counts)
+			index[key] = i
+			counts = append(counts, key)
+		}
+		counts[i].Count++
+	}
+	return counts, nil
+}
+
+func (r *memoryBallots) DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error {
+	ballots, err := r.find(func(b *models.Ballot) bool { return slices.Contains(policyIDs, b.PolicyID) })
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range ballots {
+		r.delete(ballots[i].ID)
+	}
+	return nil
+}
@@ -0,0 +1,941 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoStore creates a Store backed by the collections of db
+func NewMongoStore(db *mongo.Database) *Store {
+	return &Store{
+		Users:           &mongoUsers{mongoCollection[models.User]{db.Collection(config.UsersCollection)}},
+		Policies:        &mongoPolicies{mongoCollection[models.Policy]{db.Collection(config.PoliciesCollection)}},
+		Representatives: &mongoRepresentatives{mongoCollection[models.Representative]{db.Collection(config.RepresentativesCollection)}},
+		Quizzes:         &mongoQuizzes{mongoCollection[models.PoliticalQuiz]{db.Collection(config.QuizzesCollection)}},
+		QuizResults:     &mongoQuizResults{mongoCollection[models.QuizResult]{db.Collection(config.QuizResultsCollection)}},
+		APIKeys:         &mongoAPIKeys{mongoCollection[models.APIKey]{db.Collection(config.APIKeysCollection)}},
+		Audit:           &mongoAudit{mongoCollection[models.AuditEntry]{db.Collection(config.AuditCollection)}},
+		Summaries:       &mongoSummaries{mongoCollection[models.Summary]{db.Collection(config.SummariesCollection)}},
+		Annotations:     &mongoAnnotations{mongoCollection[models.Annotation]{db.Collection(config.AnnotationsCollection)}},
+		Comments:        &mongoComments{mongoCollection[models.Comment]{db.Collection(config.CommentsCollection)}},
+		Ballots:         &mongoBallots{mongoCollection[models.Ballot]{db.Collection(config.BallotsCollection)}},
+	}
+}
+
+// mongoCollection implements the operations shared by every repository
+type mongoCollection[T any] struct {
+	collection *mongo.Collection
+}
+
+// notTrashed matches documents that are not in the trash
+var notTrashed = bson.M{"$exists": false}
+
+// findOne returns the document matching filter, leaving out trashed documents
+func (c mongoCollection[T]) findOne(ctx context.Context, filter bson.M) (*T, error) {
+	filter["deleted_at"] = notTrashed
+	var doc T
+	err := c.collection.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// find returns the documents matching filter, leaving out trashed documents
+func (c mongoCollection[T]) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]T, error) {
+	filter["deleted_at"] = notTrashed
+	return c.findAny(ctx, filter, opts...)
+}
+
+// findAny returns the documents matching filter, in the trash or not
+func (c mongoCollection[T]) findAny(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := c.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := make([]T, 0)
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (c mongoCollection[T]) insert(ctx context.Context, doc *T) error {
+	_, err := c.collection.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// maxReplaceAttempts bounds how often an unconditional replace is retried
+// when concurrent writes keep changing the revision it read
+const maxReplaceAttempts = 5
+
+// replace stores doc in place of the document with the given ID, which must
+// be at the revision *revision points to, or at any revision if that is zero.
+// The revision is compared and incremented in the same write, and *revision
+// is set to the new revision.
+func (c mongoCollection[T]) replace(ctx context.Context, id primitive.ObjectID, revision *int64, doc *T) error {
+	expected := *revision
+	for attempt := 0; attempt < maxReplaceAttempts; attempt++ {
+		current := expected
+		if expected == 0 {
+			stored, err := c.storedRevision(ctx, id)
+			if err != nil {
+				return err
+			}
+			current = stored
+		}
+
+		*revision = current + 1
+		result, err := c.collection.ReplaceOne(ctx, bson.M{"_id": id, "revision": revisionFilter(current), "deleted_at": notTrashed}, doc)
+		if err != nil || result.MatchedCount == 0 {
+			*revision = expected
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 1 {
+			return nil
+		}
+
+		// Either the document is gone or its revision changed
+		if _, err := c.storedRevision(ctx, id); err != nil {
+			return err
+		}
+		if expected != 0 {
+			return ErrRevisionConflict
+		}
+	}
+	return ErrRevisionConflict
+}
+
+// patch writes the named top-level fields of doc to the document with the
+// given ID, checking and incrementing the revision *revision points to as
+// replace does, and sets doc to the stored result
+func (c mongoCollection[T]) patch(ctx context.Context, id primitive.ObjectID, revision *int64, doc *T, fields []string) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	set, unset := bson.D{}, bson.D{}
+	for _, field := range fields {
+		if value, err := bson.Raw(raw).LookupErr(field); err == nil {
+			set = append(set, bson.E{Key: field, Value: value})
+		} else {
+			unset = append(unset, bson.E{Key: field, Value: ""})
+		}
+	}
+
+	expected := *revision
+	filter := bson.M{"_id": id, "deleted_at": notTrashed}
+	update := bson.D{}
+	if expected == 0 {
+		update = append(update, bson.E{Key: "$inc", Value: bson.M{"revision": 1}})
+	} else {
+		filter["revision"] = expected
+		set = append(set, bson.E{Key: "revision", Value: expected + 1})
+	}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = c.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Either the document is gone or its revision changed
+		if _, err := c.storedRevision(ctx, id); err != nil {
+			return err
+		}
+		return ErrRevisionConflict
+	}
+	return err
+}
+
+// storedRevision returns the revision of the document with the given ID,
+// which must not be in the trash
+func (c mongoCollection[T]) storedRevision(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	var doc struct {
+		Revision int64 `bson:"revision"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"revision": 1})
+	err := c.collection.FindOne(ctx, bson.M{"_id": id, "deleted_at": notTrashed}, opts).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, ErrNotFound
+	}
+	return doc.Revision, err
+}
+
+// revisionFilter matches a stored revision. Documents written before
+// revisions were introduced have none, which counts as zero.
+func revisionFilter(revision int64) interface{} {
+	if revision == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return revision
+}
+
+func (c mongoCollection[T]) delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := c.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// moveToTrash marks the document with the given ID as deleted by the given
+// user at the given time
+func (c mongoCollection[T]) moveToTrash(ctx context.Context, id, by primitive.ObjectID, at time.Time) error {
+	result, err := c.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "deleted_at": notTrashed},
+		bson.M{"$set": bson.M{"deleted_at": at, "deleted_by": by}, "$inc": bson.M{"revision": 1}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// listTrash returns the trashed documents, most recently deleted first
+func (c mongoCollection[T]) listTrash(ctx context.Context) ([]T, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	return c.findAny(ctx, bson.M{"deleted_at": bson.M{"$exists": true}}, opts)
+}
+
+// restore takes the document with the given ID out of the trash
+func (c mongoCollection[T]) restore(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	var doc T
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := c.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "deleted_at": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}, "$inc": bson.M{"revision": 1}},
+		opts,
+	).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// purge deletes the trashed documents among ids
+func (c mongoCollection[T]) purge(ctx context.Context, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := c.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$exists": true}})
+	return err
+}
+
+// pull removes the array elements matching condition from every document,
+// including trashed ones, that filter matches, and increments their revision
+func (c mongoCollection[T]) pull(ctx context.Context, filter bson.M, condition bson.M) error {
+	_, err := c.collection.UpdateMany(ctx, filter, bson.M{"$pull": condition, "$inc": bson.M{"revision": 1}})
+	return err
+}
+
+// limitOptions returns find options with the given limit and skip, if set
+func limitOptions(limit, skip int64) *options.FindOptions {
+	opts := options.Find()
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	if skip > 0 {
+		opts.SetSkip(skip)
+	}
+	return opts
+}
+
+type mongoUsers struct {
+	mongoCollection[models.User]
+}
+
+func (r *mongoUsers) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+func (r *mongoUsers) List(ctx context.Context) ([]models.User, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *mongoUsers) Create(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	user.Revision = 1
+	return r.insert(ctx, user)
+}
+
+func (r *mongoUsers) Update(ctx context.Context, user *models.User) error {
+	return r.replace(ctx, user.ID, &user.Revision, user)
+}
+
+func (r *mongoUsers) Patch(ctx context.Context, user *models.User, fields []string) error {
+	return r.patch(ctx, user.ID, &user.Revision, user, fields)
+}
+
+func (r *mongoUsers) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.delete(ctx, id)
+}
+
+type mongoPolicies struct {
+	mongoCollection[models.Policy]
+}
+
+func (r *mongoPolicies) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Policy, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoPolicies) List(ctx context.Context, filter PolicyFilter) ([]models.Policy, error) {
+	query := bson.M{}
+	if filter.Level != "" {
+		query["level"] = filter.Level
+	}
+	if filter.State != "" {
+		query["jurisdiction.state"] = filter.State
+	}
+	if filter.City != "" {
+		query["jurisdiction.city"] = filter.City
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+	if filter.Unsummarized {
+		query["original_text"] = bson.M{"$gt": ""}
+		query["simplified_desc"] = bson.M{"$in": bson.A{"", nil}}
+	}
+
+	opts := limitOptions(filter.Limit, filter.Skip)
+	opts.SetSort(bson.D{{Key: "introduced_date", Value: -1}}) // Newest first
+	return r.find(ctx, query, opts)
+}
+
+func (r *mongoPolicies) Create(ctx context.Context, policy *models.Policy) error {
+	if policy.ID.IsZero() {
+		policy.ID = primitive.NewObjectID()
+	}
+	policy.Revision = 1
+	return r.insert(ctx, policy)
+}
+
+func (r *mongoPolicies) Update(ctx context.Context, policy *models.Policy) error {
+	return r.replace(ctx, policy.ID, &policy.Revision, policy)
+}
+
+func (r *mongoPolicies) Patch(ctx context.Context, policy *models.Policy, fields []string) error {
+	return r.patch(ctx, policy.ID, &policy.Revision, policy, fields)
+}
+
+func (r *mongoPolicies) Delete(ctx context.Context, id, by primitive.ObjectID, at time.Time) error {
+	return r.moveToTrash(ctx, id, by, at)
+}
+
+func (r *mongoPolicies) ListTrash(ctx context.Context) ([]models.Policy, error) {
+	return r.listTrash(ctx)
+}
+
+func (r *mongoPolicies) Restore(ctx context.Context, id primitive.ObjectID) (*models.Policy, error) {
+	return r.restore(ctx, id)
+}
+
+func (r *mongoPolicies) Purge(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.purge(ctx, ids)
+}
+
+func (r *mongoPolicies) RemovePolicyReferences(ctx context.Context, ids []primitive.ObjectID) error {
+	in := bson.M{"$in": ids}
+	return r.pull(ctx, bson.M{"related_policies": in}, bson.M{"related_policies": in})
+}
+
+func (r *mongoPolicies) RemoveRepresentativeReferences(ctx context.Context, ids []primitive.ObjectID) error {
+	in := bson.M{"$in": ids}
+	return r.pull(ctx,
+		bson.M{"$or": bson.A{bson.M{"voting_record.representative_id": in}, bson.M{"sponsors._id": in}}},
+		bson.M{"voting_record": bson.M{"representative_id": in}, "sponsors": bson.M{"_id": in}},
+	)
+}
+
+func (r *mongoPolicies) VotesByRepresentative(ctx context.Context, representativeID primitive.ObjectID) ([]models.RepresentativeVote, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"voting_record.representative_id": representativeID, "deleted_at": notTrashed}}},
+		{{Key: "$sort", Value: bson.M{"introduced_date": -1}}},
+		{{Key: "$unwind", Value: "$voting_record"}},
+		{{Key: "$match", Value: bson.M{"voting_record.representative_id": representativeID}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"policy_id": "$_id",
+			"title":     1,
+			"status":    1,
+			"vote":      "$voting_record",
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	votes := make([]models.RepresentativeVote, 0)
+	if err := cursor.All(ctx, &votes); err != nil {
+		return nil, err
+	}
+	return votes, nil
+}
+
+type mongoRepresentatives struct {
+	mongoCollection[models.Representative]
+}
+
+func (r *mongoRepresentatives) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Representative, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoRepresentatives) List(ctx context.Context, filter RepresentativeFilter) ([]models.Representative, error) {
+	query := bson.M{}
+	if filter.State != "" {
+		query["state"] = filter.State
+	}
+	if filter.Party != "" {
+		query["party"] = filter.Party
+	}
+	if filter.Title != "" {
+		query["title"] = filter.Title
+	}
+	return r.find(ctx, query, limitOptions(filter.Limit, 0))
+}
+
+func (r *mongoRepresentatives) Create(ctx context.Context, representative *models.Representative) error {
+	if representative.ID.IsZero() {
+		representative.ID = primitive.NewObjectID()
+	}
+	representative.Revision = 1
+	return r.insert(ctx, representative)
+}
+
+func (r *mongoRepresentatives) Update(ctx context.Context, representative *models.Representative) error {
+	return r.replace(ctx, representative.ID, &representative.Revision, representative)
+}
+
+func (r *mongoRepresentatives) Patch(ctx context.Context, representative *models.Representative, fields []string) error {
+	return r.patch(ctx, representative.ID, &representative.Revision, representative, fields)
+}
+
+func (r *mongoRepresentatives) Delete(ctx context.Context, id, by primitive.ObjectID, at time.Time) error {
+	return r.moveToTrash(ctx, id, by, at)
+}
+
+func (r *mongoRepresentatives) ListTrash(ctx context.Context) ([]models.Representative, error) {
+	return r.listTrash(ctx)
+}
+
+func (r *mongoRepresentatives) Restore(ctx context.Context, id primitive.ObjectID) (*models.Representative, error) {
+	return r.restore(ctx, id)
+}
+
+func (r *mongoRepresentatives) Purge(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.purge(ctx, ids)
+}
+
+func (r *mongoRepresentatives) RemovePolicyReferences(ctx context.Context, ids []primitive.ObjectID) error {
+	in := bson.M{"$in": ids}
+	return r.pull(ctx, bson.M{"voting_history": in}, bson.M{"voting_history": in})
+}
+
+type mongoQuizzes struct {
+	mongoCollection[models.PoliticalQuiz]
+}
+
+func (r *mongoQuizzes) FindByID(ctx context.Context, id primitive.ObjectID) (*models.PoliticalQuiz, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoQuizzes) List(ctx context.Context, filter QuizFilter) ([]models.PoliticalQuiz, error) {
+	query := bson.M{}
+	if filter.Category != "" {
+		query["categories"] = filter.Category
+	}
+	return r.find(ctx, query, limitOptions(filter.Limit, 0))
+}
+
+func (r *mongoQuizzes) Create(ctx context.Context, quiz *models.PoliticalQuiz) error {
+	if quiz.ID.IsZero() {
+		quiz.ID = primitive.NewObjectID()
+	}
+	quiz.Revision = 1
+	return r.insert(ctx, quiz)
+}
+
+func (r *mongoQuizzes) Update(ctx context.Context, quiz *models.PoliticalQuiz) error {
+	return r.replace(ctx, quiz.ID, &quiz.Revision, quiz)
+}
+
+func (r *mongoQuizzes) Patch(ctx context.Context, quiz *models.PoliticalQuiz, fields []string) error {
+	return r.patch(ctx, quiz.ID, &quiz.Revision, quiz, fields)
+}
+
+func (r *mongoQuizzes) Delete(ctx context.Context, id, by primitive.ObjectID, at time.Time) error {
+	return r.moveToTrash(ctx, id, by, at)
+}
+
+func (r *mongoQuizzes) ListTrash(ctx context.Context) ([]models.PoliticalQuiz, error) {
+	return r.listTrash(ctx)
+}
+
+func (r *mongoQuizzes) Restore(ctx context.Context, id primitive.ObjectID) (*models.PoliticalQuiz, error) {
+	return r.restore(ctx, id)
+}
+
+func (r *mongoQuizzes) Purge(ctx context.Context, ids []primitive.ObjectID) error {
+	return r.purge(ctx, ids)
+}
+
+func (r *mongoQuizzes) RemoveRepresentativeReferences(ctx context.Context, ids []primitive.ObjectID) error {
+	in := bson.M{"$in": ids}
+	return r.pull(ctx,
+		bson.M{"questions.representative_stances.representative_id": in},
+		bson.M{"questions.$[].representative_stances": bson.M{"representative_id": in}},
+	)
+}
+
+type mongoQuizResults struct {
+	mongoCollection[models.QuizResult]
+}
+
+func (r *mongoQuizResults) FindByID(ctx context.Context, id primitive.ObjectID) (*models.QuizResult, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoQuizResults) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.QuizResult, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "taken_at", Value: -1}})
+	return r.find(ctx, bson.M{"user_id": userID}, opts)
+}
+
+func (r *mongoQuizResults) Create(ctx context.Context, result *models.QuizResult) error {
+	if result.ID.IsZero() {
+		result.ID = primitive.NewObjectID()
+	}
+	return r.insert(ctx, result)
+}
+
+type mongoAPIKeys struct {
+	mongoCollection[models.APIKey]
+}
+
+func (r *mongoAPIKeys) FindByID(ctx context.Context, id primitive.ObjectID) (*models.APIKey, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoAPIKeys) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return r.findOne(ctx, bson.M{"hash": hash})
+}
+
+func (r *mongoAPIKeys) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.APIKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return r.find(ctx, bson.M{"user_id": userID}, opts)
+}
+
+func (r *mongoAPIKeys) Create(ctx context.Context, key *models.APIKey) error {
+	if key.ID.IsZero() {
+		key.ID = primitive.NewObjectID()
+	}
+	return r.insert(ctx, key)
+}
+
+func (r *mongoAPIKeys) Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	if _, err := r.FindByID(ctx, id); err != nil {
+		return err
+	}
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at}},
+	)
+	return err
+}
+
+func (r *mongoAPIKeys) RecordUse(ctx context.Context, id primitive.ObjectID, at time.Time) (*models.APIKey, error) {
+	day := usageDay(at)
+
+	// Match only while the key is under its quota for the day, so that
+	// concurrent requests cannot push it over
+	filter := bson.M{
+		"_id": id,
+		"$or": bson.A{
+			bson.M{"usage_day": bson.M{"$ne": day}},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$usage_today", "$daily_quota"}}},
+		},
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"usage_today": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$usage_day", day}},
+				bson.M{"$add": bson.A{"$usage_today", 1}},
+				1,
+			}},
+			"usage_day":    day,
+			"usage_count":  bson.M{"$add": bson.A{"$usage_count", 1}},
+			"last_used_at": at,
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var key models.APIKey
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Tell a missing key apart from one that has used its quota
+		if _, err := r.FindByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrQuotaExceeded
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+type mongoAudit struct {
+	mongoCollection[models.AuditEntry]
+}
+
+func (r *mongoAudit) Append(ctx context.Context, entry *models.AuditEntry) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	return r.insert(ctx, entry)
+}
+
+func (r *mongoAudit) Last(ctx context.Context) (*models.AuditEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: -1}}).SetLimit(1)
+	entries, err := r.find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNotFound
+	}
+	return &entries[0], nil
+}
+
+func (r *mongoAudit) List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	query := bson.M{}
+	if !filter.ActorID.IsZero() {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.ResourceType != "" {
+		query["resource_type"] = filter.ResourceType
+	}
+	if !filter.ResourceID.IsZero() {
+		query["resource_id"] = filter.ResourceID
+	}
+	at := bson.M{}
+	if !filter.From.IsZero() {
+		at["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		at["$lt"] = filter.To
+	}
+	if len(at) > 0 {
+		query["at"] = at
+	}
+
+	opts := limitOptions(filter.Limit, filter.Skip).SetSort(bson.D{{Key: "sequence", Value: -1}})
+	return r.find(ctx, query, opts)
+}
+
+func (r *mongoAudit) Range(ctx context.Context, after, limit int64) ([]models.AuditEntry, error) {
+	opts := limitOptions(limit, 0).SetSort(bson.D{{Key: "sequence", Value: 1}})
+	return r.find(ctx, bson.M{"sequence": bson.M{"$gt": after}}, opts)
+}
+
+type mongoSummaries struct {
+	mongoCollection[models.Summary]
+}
+
+func (r *mongoSummaries) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Summary, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoSummaries) List(ctx context.Context, filter SummaryFilter) ([]models.Summary, error) {
+	query := bson.M{}
+	if !filter.PolicyID.IsZero() {
+		query["policy_id"] = filter.PolicyID
+	} else if len(filter.PolicyIDs) > 0 {
+		query["policy_id"] = bson.M{"$in": filter.PolicyIDs}
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	opts := limitOptions(filter.Limit, filter.Skip)
+	opts.SetSort(bson.D{{Key: "updated_at", Value: -1}})
+	return r.find(ctx, query, opts)
+}
+
+func (r *mongoSummaries) Create(ctx context.Context, summary *models.Summary) error {
+	if summary.ID.IsZero() {
+		summary.ID = primitive.NewObjectID()
+	}
+	summary.Revision = 1
+	return r.insert(ctx, summary)
+}
+
+func (r *mongoSummaries) Update(ctx context.Context, summary *models.Summary) error {
+	return r.replace(ctx, summary.ID, &summary.Revision, summary)
+}
+
+func (r *mongoSummaries) DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error {
+	if len(policyIDs) == 0 {
+		return nil
+	}
+	_, err := r.collection.DeleteMany(ctx, bson.M{"policy_id": bson.M{"$in": policyIDs}})
+	return err
+}
+
+type mongoAnnotations struct {
+	mongoCollection[models.Annotation]
+}
+
+func (r *mongoAnnotations) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Annotation, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoAnnotations) List(ctx context.Context, filter AnnotationFilter) ([]models.Annotation, error) {
+	query := bson.M{}
+	if !filter.PolicyID.IsZero() {
+		query["policy_id"] = filter.PolicyID
+	}
+	if filter.Anchor != "" {
+		query["target.anchor"] = filter.Anchor
+	}
+	if !filter.AuthorID.IsZero() {
+		query["author_id"] = filter.AuthorID
+	}
+	if !filter.VisibleTo.IsZero() {
+		query["$or"] = bson.A{
+			bson.M{"visibility": models.AnnotationPublic},
+			bson.M{"author_id": filter.VisibleTo},
+		}
+	}
+	if filter.PublicOnly {
+		query["visibility"] = models.AnnotationPublic
+	}
+	if filter.TextVersionBelow > 0 {
+		query["target.text_version"] = bson.M{"$lt": filter.TextVersionBelow}
+	}
+
+	opts := limitOptions(filter.Limit, filter.Skip)
+	opts.SetSort(bson.D{{Key: "created_at", Value: 1}})
+	return r.find(ctx, query, opts)
+}
+
+func (r *mongoAnnotations) Create(ctx context.Context, annotation *models.Annotation) error {
+	if annotation.ID.IsZero() {
+		annotation.ID = primitive.NewObjectID()
+	}
+	annotation.Revision = 1
+	return r.insert(ctx, annotation)
+}
+
+func (r *mongoAnnotations) Update(ctx context.Context, annotation *models.Annotation) error {
+	return r.replace(ctx, annotation.ID, &annotation.Revision, annotation)
+}
+
+func (r *mongoAnnotations) Delete(ctx context.Context, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
+
+func (r *mongoAnnotations) DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error {
+	if len(policyIDs) == 0 {
+		return nil
+	}
+	_, err := r.collection.DeleteMany(ctx, bson.M{"policy_id": bson.M{"$in": policyIDs}})
+	return err
+}
+
+type mongoComments struct {
+	mongoCollection[models.Comment]
+}
+
+func (r *mongoComments) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoComments) List(ctx context.Context, filter CommentFilter) ([]models.Comment, error) {
+	query := bson.M{}
+	if !filter.PolicyID.IsZero() {
+		query["policy_id"] = filter.PolicyID
+	}
+	if !filter.AuthorID.IsZero() {
+		query["author_id"] = filter.AuthorID
+	}
+	var and bson.A
+	if len(filter.Statuses) > 0 {
+		and = append(and, bson.M{"status": bson.M{"$in": filter.Statuses}})
+	}
+	if !filter.VisibleTo.IsZero() {
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"status": bson.M{"$in": ThreadStatuses}},
+			bson.M{"author_id": filter.VisibleTo},
+		}})
+	}
+	if filter.Queue {
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"status": models.CommentHeld},
+			bson.M{"status": models.CommentPublished, "reports.0": bson.M{"$exists": true}},
+		}})
+	}
+	if len(and) > 0 {
+		query["$and"] = and
+	}
+
+	opts := limitOptions(filter.Limit, filter.Skip)
+	opts.SetSort(bson.D{{Key: "created_at", Value: 1}})
+	return r.find(ctx, query, opts)
+}
+
+func (r *mongoComments) Create(ctx context.Context, comment *models.Comment) error {
+	if comment.ID.IsZero() {
+		comment.ID = primitive.NewObjectID()
+	}
+	comment.Revision = 1
+	return r.insert(ctx, comment)
+}
+
+func (r *mongoComments) Update(ctx context.Context, comment *models.Comment) error {
+	return r.replace(ctx, comment.ID, &comment.Revision, comment)
+}
+
+func (r *mongoComments) DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error {
+	if len(policyIDs) == 0 {
+		return nil
+	}
+	_, err := r.collection.DeleteMany(ctx, bson.M{"policy_id": bson.M{"$in": policyIDs}})
+	return err
+}
+
+type mongoBallots struct {
+	mongoCollection[models.Ballot]
+}
+
+func (r *mongoBallots) Find(ctx context.Context, policyID, userID primitive.ObjectID) (*models.Ballot, error) {
+	return r.findOne(ctx, bson.M{"policy_id": policyID, "user_id": userID})
+}
+
+func (r *mongoBallots) Cast(ctx context.Context, ballot *models.Ballot) error {
+	// The unique index on policy and user makes this the user's only ballot
+	ballot.ID = primitive.NilObjectID
+	opts := options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.After)
+	var stored models.Ballot
+	err := r.collection.FindOneAndReplace(ctx, bson.M{"policy_id": ballot.PolicyID, "user_id": ballot.UserID}, ballot, opts).Decode(&stored)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	ballot.ID = stored.ID
+	return nil
+}
+
+func (r *mongoBallots) Withdraw(ctx context.Context, policyID, userID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"policy_id": policyID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoBallots) Count(ctx context.Context, policyIDs []primitive.ObjectID) ([]models.BallotCount, error) {
+	counts := make([]models.BallotCount, 0)
+	if len(policyIDs) == 0 {
+		return counts, nil
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"policy_id": bson.M{"$in": policyIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"policy_id": "$policy_id",
+				"state":     bson.M{"$ifNull": bson.A{"$state", ""}},
+				"district":  bson.M{"$ifNull": bson.A{"$district", ""}},
+				"choice":    "$choice",
+			},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"policy_id": "$_id.policy_id",
+			"state":     "$_id.state",
+			"district":  "$_id.district",
+			"choice":    "$_id.choice",
+			"count":     1,
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (r *mongoBallots) DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error {
+	if len(policyIDs) == 0 {
+		return nil
+	}
+	_, err := r.collection.DeleteMany(ctx, bson.M{"policy_id": bson.M{"$in": policyIDs}})
+	return err
+}
@@ -0,0 +1,303 @@
+// Package repository defines the persistence interfaces used by the API
+// handlers, with a MongoDB implementation for production and an in-memory
+// implementation for tests and local development.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when no document matches the requested ID or key
+	ErrNotFound = errors.New("not found")
+
+	// ErrDuplicate is returned when a write violates a unique constraint
+	ErrDuplicate = errors.New("duplicate key")
+
+	// ErrQuotaExceeded is returned when an API key has used its daily quota
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// ErrRevisionConflict is returned when a write expects a revision of a
+	// document that has since been replaced
+	ErrRevisionConflict = errors.New("revision conflict")
+)
+
+// Users, policies, representatives and quizzes carry a revision that Create
+// sets to 1 and every Update or Patch increments atomically. Update replaces
+// the document only if its stored revision equals the given document's, or
+// unconditionally if that is zero, and sets the given document's revision to
+// the new one. Patch does the same but writes only the named top-level
+// fields, as returned by ChangedFields, and sets the given document to the
+// stored result.
+
+// TrashRepository is implemented by the repositories of content that is
+// soft-deleted. Delete moves a document to the trash, recording who deleted
+// it and when; trashed documents are left out of every other query and write
+// until Restore takes them out of the trash or Purge deletes them for good.
+// Both increment the revision. References to a trashed document are kept so
+// that restoring it loses nothing.
+type TrashRepository[T any] interface {
+	Delete(ctx context.Context, id primitive.ObjectID, by primitive.ObjectID, at time.Time) error
+	// ListTrash returns the trashed documents, most recently deleted first
+	ListTrash(ctx context.Context) ([]T, error)
+	Restore(ctx context.Context, id primitive.ObjectID) (*T, error)
+	// Purge deletes the trashed documents among ids; others are left alone
+	Purge(ctx context.Context, ids []primitive.ObjectID) error
+}
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	List(ctx context.Context) ([]models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	Update(ctx context.Context, user *models.User) error
+	Patch(ctx context.Context, user *models.User, fields []string) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// PolicyFilter narrows a policy listing. Zero values are ignored.
+type PolicyFilter struct {
+	Level  string
+	State  string
+	City   string
+	Status string
+	Type   string
+	Limit  int64
+	Skip   int64
+
+	// Unsummarized only lists policies with original text and no simplified
+	// description
+	Unsummarized bool
+}
+
+// PolicyRepository stores policies and their voting records
+type PolicyRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Policy, error)
+	List(ctx context.Context, filter PolicyFilter) ([]models.Policy, error)
+	Create(ctx context.Context, policy *models.Policy) error
+	Update(ctx context.Context, policy *models.Policy) error
+	Patch(ctx context.Context, policy *models.Policy, fields []string) error
+	VotesByRepresentative(ctx context.Context, representativeID primitive.ObjectID) ([]models.RepresentativeVote, error)
+	TrashRepository[models.Policy]
+
+	// RemovePolicyReferences removes the given policies from every policy's
+	// related policies
+	RemovePolicyReferences(ctx context.Context, ids []primitive.ObjectID) error
+	// RemoveRepresentativeReferences removes the votes and sponsorships of
+	// the given representatives from every policy
+	RemoveRepresentativeReferences(ctx context.Context, ids []primitive.ObjectID) error
+}
+
+// RepresentativeFilter narrows a representative listing. Zero values are ignored.
+type RepresentativeFilter struct {
+	State string
+	Party string
+	Title string
+	Limit int64
+}
+
+// RepresentativeRepository stores representatives
+type RepresentativeRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Representative, error)
+	List(ctx context.Context, filter RepresentativeFilter) ([]models.Representative, error)
+	Create(ctx context.Context, representative *models.Representative) error
+	Update(ctx context.Context, representative *models.Representative) error
+	Patch(ctx context.Context, representative *models.Representative, fields []string) error
+	TrashRepository[models.Representative]
+
+	// RemovePolicyReferences removes the given policies from every
+	// representative's voting history
+	RemovePolicyReferences(ctx context.Context, ids []primitive.ObjectID) error
+}
+
+// QuizFilter narrows a quiz listing. Zero values are ignored.
+type QuizFilter struct {
+	Category string
+	Limit    int64
+}
+
+// QuizRepository stores political quizzes
+type QuizRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.PoliticalQuiz, error)
+	List(ctx context.Context, filter QuizFilter) ([]models.PoliticalQuiz, error)
+	Create(ctx context.Context, quiz *models.PoliticalQuiz) error
+	Update(ctx context.Context, quiz *models.PoliticalQuiz) error
+	Patch(ctx context.Context, quiz *models.PoliticalQuiz, fields []string) error
+	TrashRepository[models.PoliticalQuiz]
+
+	// RemoveRepresentativeReferences removes the stances of the given
+	// representatives from every quiz question
+	RemoveRepresentativeReferences(ctx context.Context, ids []primitive.ObjectID) error
+}
+
+// QuizResultRepository stores users' quiz results
+type QuizResultRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.QuizResult, error)
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.QuizResult, error)
+	Create(ctx context.Context, result *models.QuizResult) error
+}
+
+// APIKeyRepository stores developer API keys
+type APIKeyRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.APIKey, error)
+	FindByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.APIKey, error)
+	Create(ctx context.Context, key *models.APIKey) error
+	Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// RecordUse counts one request made with the key at the given time,
+	// starting a new daily count on each UTC day. It returns
+	// ErrQuotaExceeded, without counting the request, once the key has made
+	// DailyQuota requests that day.
+	RecordUse(ctx context.Context, id primitive.ObjectID, at time.Time) (*models.APIKey, error)
+}
+
+// AuditFilter narrows an audit log listing. Zero values are ignored; From
+// is inclusive and To exclusive.
+type AuditFilter struct {
+	ActorID      primitive.ObjectID
+	Action       string
+	ResourceType string
+	ResourceID   primitive.ObjectID
+	From         time.Time
+	To           time.Time
+	Limit        int64
+	Skip         int64
+}
+
+// AuditRepository stores the audit log. Entries can only be appended; there
+// is no way to change or remove them.
+type AuditRepository interface {
+	// Append inserts entry, returning ErrDuplicate if an entry with its
+	// sequence already exists
+	Append(ctx context.Context, entry *models.AuditEntry) error
+	// Last returns the entry with the highest sequence, or ErrNotFound if
+	// the log is empty
+	Last(ctx context.Context) (*models.AuditEntry, error)
+	// List returns the entries matching filter, newest first
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error)
+	// Range returns up to limit entries with a sequence above after, in
+	// sequence order
+	Range(ctx context.Context, after, limit int64) ([]models.AuditEntry, error)
+}
+
+// SummaryFilter narrows a summary listing. Zero values are ignored.
+type SummaryFilter struct {
+	PolicyID primitive.ObjectID
+	// PolicyIDs matches the summaries of any of the policies, unless
+	// PolicyID is set
+	PolicyIDs []primitive.ObjectID
+	Status    string
+	Limit     int64
+	Skip      int64
+}
+
+// SummaryRepository stores policy summaries under review. Summaries carry a
+// revision like policies do, so that review transitions are never lost to
+// concurrent ones.
+type SummaryRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Summary, error)
+	// List returns the summaries matching filter, most recently updated first
+	List(ctx context.Context, filter SummaryFilter) ([]models.Summary, error)
+	Create(ctx context.Context, summary *models.Summary) error
+	Update(ctx context.Context, summary *models.Summary) error
+	// DeleteByPolicies removes every summary of the given policies
+	DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error
+}
+
+// AnnotationFilter narrows an annotation listing. Zero values are ignored.
+type AnnotationFilter struct {
+	PolicyID primitive.ObjectID
+	Anchor   string
+	AuthorID primitive.ObjectID
+	// VisibleTo, if set, limits the listing to public annotations and the
+	// private ones of that user
+	VisibleTo primitive.ObjectID
+	// PublicOnly limits the listing to public annotations
+	PublicOnly bool
+	// TextVersionBelow limits the listing to annotations targeting an
+	// earlier version of the policy's text
+	TextVersionBelow int64
+	Limit            int64
+	Skip             int64
+}
+
+// AnnotationRepository stores users' annotations of policies' text
+type AnnotationRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Annotation, error)
+	// List returns the annotations matching filter, oldest first
+	List(ctx context.Context, filter AnnotationFilter) ([]models.Annotation, error)
+	Create(ctx context.Context, annotation *models.Annotation) error
+	Update(ctx context.Context, annotation *models.Annotation) error
+	// Delete removes the given annotations
+	Delete(ctx context.Context, ids []primitive.ObjectID) error
+	// DeleteByPolicies removes every annotation of the given policies
+	DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error
+}
+
+// CommentFilter narrows a comment listing. Zero values are ignored.
+type CommentFilter struct {
+	PolicyID primitive.ObjectID
+	AuthorID primitive.ObjectID
+	Statuses []string
+	// VisibleTo, if set, limits the listing to the comments in the thread,
+	// which are published, removed or deleted, and those of that user
+	VisibleTo primitive.ObjectID
+	// Queue limits the listing to comments awaiting moderation: held ones
+	// and published ones that were reported
+	Queue bool
+	Limit int64
+	Skip  int64
+}
+
+// ThreadStatuses are the statuses of comments shown in threads. Removed and
+// deleted comments are shown without their body.
+var ThreadStatuses = []string{models.CommentPublished, models.CommentRemoved, models.CommentDeleted}
+
+// CommentRepository stores public comments on policies
+type CommentRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error)
+	// List returns the comments matching filter, oldest first
+	List(ctx context.Context, filter CommentFilter) ([]models.Comment, error)
+	Create(ctx context.Context, comment *models.Comment) error
+	Update(ctx context.Context, comment *models.Comment) error
+	// DeleteByPolicies removes every comment on the given policies
+	DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error
+}
+
+// BallotRepository stores users' answers to the polls of policies. Each
+// user has at most one ballot per policy.
+type BallotRepository interface {
+	// Find returns the user's ballot on the policy
+	Find(ctx context.Context, policyID, userID primitive.ObjectID) (*models.Ballot, error)
+	// Cast stores ballot in place of the user's earlier ballot on the
+	// policy, if any, and sets its ID
+	Cast(ctx context.Context, ballot *models.Ballot) error
+	// Withdraw removes the user's ballot on the policy
+	Withdraw(ctx context.Context, policyID, userID primitive.ObjectID) error
+	// Count returns the number of ballots on the given policies by policy,
+	// state, district and choice
+	Count(ctx context.Context, policyIDs []primitive.ObjectID) ([]models.BallotCount, error)
+	// DeleteByPolicies removes every ballot on the given policies
+	DeleteByPolicies(ctx context.Context, policyIDs []primitive.ObjectID) error
+}
+
+// Store groups the repositories used by the API
+type Store struct {
+	Users           UserRepository
+	Policies        PolicyRepository
+	Representatives RepresentativeRepository
+	Quizzes         QuizRepository
+	QuizResults     QuizResultRepository
+	APIKeys         APIKeyRepository
+	Audit           AuditRepository
+	Summaries       SummaryRepository
+	Annotations     AnnotationRepository
+	Comments        CommentRepository
+	Ballots         BallotRepository
+}
@@ -0,0 +1,220 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/health"
+	"github.com/benjamingetches/govtrack/readability"
+	"github.com/benjamingetches/govtrack/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxTextLength is the longest summary text, as for summaries written by hand
+const maxTextLength = 5000
+
+// policyPage is the number of policies read at a time when looking for
+// policies to draft
+const policyPage = 100
+
+var (
+	// ErrNoText is returned when drafting a summary of a policy without
+	// original text
+	ErrNoText = errors.New("policy has no original text")
+
+	// ErrGenerator wraps failures of the summarizer
+	ErrGenerator = errors.New("summary generator failed")
+
+	// ErrUnusable is returned when the summarizer writes a summary that
+	// cannot be reviewed
+	ErrUnusable = errors.New("generated summary is unusable")
+)
+
+// Drafter stores generated summaries of policies as drafts for review
+type Drafter struct {
+	policies   repository.PolicyRepository
+	summaries  repository.SummaryRepository
+	summarizer Summarizer
+	analyzer   *readability.Analyzer
+	audit      *audit.Log
+	batch      int
+	now        func() time.Time
+}
+
+// NewDrafter creates a Drafter writing summaries with summarizer and scoring
+// them with analyzer. Each run of Pending drafts up to batch summaries.
+func NewDrafter(store *repository.Store, summarizer Summarizer, analyzer *readability.Analyzer, batch int) *Drafter {
+	return &Drafter{
+		policies:   store.Policies,
+		summaries:  store.Summaries,
+		summarizer: summarizer,
+		analyzer:   analyzer,
+		audit:      audit.NewLog(store.Audit),
+		batch:      batch,
+		now:        time.Now,
+	}
+}
+
+// Draft generates a summary of policy's original text and stores it as a
+// draft without an author. The caller records it in the audit log.
+func (d *Drafter) Draft(ctx context.Context, policy *models.Policy) (*models.Summary, error) {
+	if strings.TrimSpace(policy.OriginalText) == "" {
+		return nil, ErrNoText
+	}
+	result, err := d.summarizer.Summarize(ctx, NewDocument(policy))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGenerator, err)
+	}
+	text := strings.TrimSpace(result.Text)
+	if text == "" {
+		return nil, fmt.Errorf("%w: it has no text", ErrUnusable)
+	}
+	if length := utf8.RuneCountInString(text); length > maxTextLength {
+		return nil, fmt.Errorf("%w: it has %d characters; at most %d are allowed", ErrUnusable, length, maxTextLength)
+	}
+
+	now := d.now()
+	summary := models.Summary{
+		PolicyID:    policy.ID,
+		Text:        text,
+		Status:      models.SummaryDraft,
+		Readability: d.analyzer.Analyze(text),
+		Generator: &models.SummaryGenerator{
+			Name:        result.Generator,
+			Version:     result.Version,
+			GeneratedAt: now,
+		},
+		Reviewers: []primitive.ObjectID{},
+		Comments:  []models.SummaryComment{},
+		Approvals: []models.SummaryApproval{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := d.summaries.Create(ctx, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// Pending drafts summaries of up to batch policies that have original text
+// but neither a simplified description nor a summary in review, and returns
+// how many it drafted. Policies whose summaries are unusable are skipped;
+// a failing summarizer ends the run.
+func (d *Drafter) Pending(ctx context.Context) (int, error) {
+	drafted := 0
+	for skip := int64(0); drafted < d.batch; skip += policyPage {
+		policies, err := d.policies.List(ctx, repository.PolicyFilter{Unsummarized: true, Limit: policyPage, Skip: skip})
+		if err != nil {
+			return drafted, err
+		}
+		reviewing, err := d.inReview(ctx, policies)
+		if err != nil {
+			return drafted, err
+		}
+		for i := range policies {
+			if drafted == d.batch {
+				break
+			}
+			policy := &policies[i]
+			if reviewing[policy.ID] {
+				continue
+			}
+
+			summary, err := d.Draft(ctx, policy)
+			if errors.Is(err, ErrUnusable) {
+				slog.Warn("Skipping policy with an unusable generated summary", "error", err, "policy_id", policy.ID.Hex())
+				continue
+			}
+			if err != nil {
+				return drafted, err
+			}
+			drafted++
+			d.record(ctx, summary)
+		}
+		if len(policies) < policyPage {
+			break
+		}
+	}
+	return drafted, nil
+}
+
+// inReview returns the IDs of the policies that have a summary that has not
+// been published or superseded, reading the summaries of all of them at once
+func (d *Drafter) inReview(ctx context.Context, policies []models.Policy) (map[primitive.ObjectID]bool, error) {
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	ids := make([]primitive.ObjectID, len(policies))
+	for i := range policies {
+		ids[i] = policies[i].ID
+	}
+	summaries, err := d.summaries.List(ctx, repository.SummaryFilter{PolicyIDs: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	reviewing := make(map[primitive.ObjectID]bool)
+	for _, summary := range summaries {
+		switch summary.Status {
+		case models.SummaryDraft, models.SummaryInReview, models.SummaryApproved:
+			reviewing[summary.PolicyID] = true
+		}
+	}
+	return reviewing, nil
+}
+
+// record adds a drafted summary to the audit log. Drafts made in the
+// background have no actor.
+func (d *Drafter) record(ctx context.Context, summary *models.Summary) {
+	changes, err := audit.Diff(nil, summary)
+	if err == nil {
+		err = d.audit.Record(ctx, &models.AuditEntry{
+			Action:       audit.ActionCreate,
+			ResourceType: audit.ResourceSummary,
+			ResourceID:   summary.ID,
+			Changes:      changes,
+		})
+	}
+	if err != nil {
+		slog.Error("Error recording audit entry", "error", err, "resource", audit.ResourceSummary, "id", summary.ID.Hex())
+	}
+}
+
+// Run drafts pending summaries every interval until ctx is done, beating
+// heartbeat after every run that completes
+func (d *Drafter) Run(ctx context.Context, interval time.Duration, heartbeat *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.runOnce(ctx, interval, heartbeat)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce drafts pending summaries within interval and logs the outcome
+func (d *Drafter) runOnce(ctx context.Context, interval time.Duration, heartbeat *health.Heartbeat) {
+	ctx, cancel := context.WithTimeout(ctx, interval)
+	defer cancel()
+
+	drafted, err := d.Pending(ctx)
+	if drafted > 0 {
+		slog.Info("Drafted summaries", "summaries", drafted)
+	}
+	if err != nil {
+		slog.Error("Error drafting summaries", "error", err)
+		return
+	}
+	heartbeat.Beat()
+}
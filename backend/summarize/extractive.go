@@ -0,0 +1,202 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/benjamingetches/govtrack/readability"
+)
+
+// Extractive identifies summaries written by Extractive. Change
+// ExtractiveVersion whenever the ranking changes.
+const (
+	ExtractiveName    = "extractive"
+	ExtractiveVersion = "1"
+)
+
+// Sentences of these lengths are picked in preference to others, which
+// tend to be headings, list items or run-on legal clauses
+const (
+	minSentenceWords = 5
+	maxSentenceWords = 40
+)
+
+// maxOverlap is the share of content words a sentence may share with one
+// already picked
+const maxOverlap = 0.5
+
+// boilerplate matches sentences that say nothing about what a bill does.
+// Like sentences of other lengths, they are only picked if nothing else is.
+var boilerplate = regexp.MustCompile(`(?i)\bmay be cited as\b|\bshort title\b`)
+
+// errNoSentences is returned for documents without any sentences
+var errNoSentences = errors.New("document has no sentences")
+
+// Extractive summarizes documents by picking their most representative
+// sentences. Sentences are scored by how frequent their content words are
+// across the document and whether they appear in its title; the best
+// sentence of each section is preferred, so that every part of a bill is
+// covered before any part is covered twice.
+type Extractive struct {
+	maxSentences int
+}
+
+// NewExtractive creates an Extractive summarizer picking up to maxSentences
+// sentences
+func NewExtractive(maxSentences int) *Extractive {
+	return &Extractive{maxSentences: maxSentences}
+}
+
+// candidate is a sentence that may be picked
+type candidate struct {
+	text     string
+	position int // Position in the document
+	section  int
+	words    map[string]bool
+	score    float64
+}
+
+// Summarize picks the document's best sentences and joins them in the order
+// they appear
+func (e *Extractive) Summarize(ctx context.Context, doc Document) (Result, error) {
+	all := candidates(doc)
+	if len(all) == 0 {
+		return Result{}, errNoSentences
+	}
+	preferred := make([]*candidate, 0, len(all))
+	for _, c := range all {
+		if n := len(strings.Fields(c.text)); n >= minSentenceWords && n <= maxSentenceWords && !boilerplate.MatchString(c.text) {
+			preferred = append(preferred, c)
+		}
+	}
+	if len(preferred) == 0 {
+		preferred = all
+	}
+
+	weights := weigh(all, doc.Title)
+	for _, c := range preferred {
+		for word := range c.words {
+			c.score += weights[word]
+		}
+		// Longer sentences cover more words but are harder to read
+		c.score /= math.Sqrt(float64(len(strings.Fields(c.text))))
+	}
+	sort.SliceStable(preferred, func(i, j int) bool { return preferred[i].score > preferred[j].score })
+
+	// The best sentence of each section first, then the rest
+	var ranked, rest []*candidate
+	covered := map[int]bool{}
+	for _, c := range preferred {
+		if covered[c.section] {
+			rest = append(rest, c)
+			continue
+		}
+		covered[c.section] = true
+		ranked = append(ranked, c)
+	}
+	ranked = append(ranked, rest...)
+
+	var picked []*candidate
+	for _, c := range ranked {
+		if len(picked) == e.maxSentences {
+			break
+		}
+		if !redundant(c, picked) {
+			picked = append(picked, c)
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].position < picked[j].position })
+
+	texts := make([]string, len(picked))
+	for i, c := range picked {
+		texts[i] = c.text
+	}
+	return Result{Text: strings.Join(texts, " "), Generator: ExtractiveName, Version: ExtractiveVersion}, nil
+}
+
+// candidates returns the sentences of every section of doc
+func candidates(doc Document) []*candidate {
+	var all []*candidate
+	for i, section := range doc.Sections {
+		for _, sentence := range readability.Sentences(section.Text) {
+			all = append(all, &candidate{text: sentence, position: len(all), section: i, words: contentWords(sentence)})
+		}
+	}
+	return all
+}
+
+// weigh returns the weight of each content word: its frequency relative to
+// the most frequent one, plus one if it appears in the title
+func weigh(all []*candidate, title string) map[string]float64 {
+	counts := map[string]float64{}
+	most := 0.0
+	for _, c := range all {
+		for word := range c.words {
+			counts[word]++
+			most = math.Max(most, counts[word])
+		}
+	}
+	weights := make(map[string]float64, len(counts))
+	for word, count := range counts {
+		weights[word] = count / most
+	}
+	for word := range contentWords(title) {
+		weights[word]++
+	}
+	return weights
+}
+
+// redundant reports whether most of the content words of c are in a
+// sentence already picked
+func redundant(c *candidate, picked []*candidate) bool {
+	if len(c.words) == 0 {
+		return false
+	}
+	for _, p := range picked {
+		shared := 0
+		for word := range c.words {
+			if p.words[word] {
+				shared++
+			}
+		}
+		if float64(shared)/float64(len(c.words)) > maxOverlap {
+			return true
+		}
+	}
+	return false
+}
+
+// contentWords returns the lowercased words of text, leaving out short and
+// common words
+func contentWords(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) > 2 && !stopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// stopWords are common words that say little about what a sentence covers
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		about above after again all also and any are because been before being
+		between both but can could did does each either for from had has have
+		her here his how into its may more most must not now only other our out
+		over per same shall she should such than that the their them then there
+		these they this those through under until upon very was were what when
+		where which while who whom why will with within without would you your
+		act bill section sec subsection paragraph`) {
+		stopWords[word] = true
+	}
+}
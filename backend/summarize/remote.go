@@ -0,0 +1,103 @@
+package summarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// RemoteName identifies summaries written by a remote generator that does
+// not name itself
+const RemoteName = "remote"
+
+// maxResponseBytes bounds the responses read from a remote generator
+const maxResponseBytes = 1 << 20
+
+// Remote asks an external model endpoint for summaries, so that a hosted
+// model or a local stub can write them. The endpoint receives a POST of the
+// document and the number of sentences wanted:
+//
+//	{"title": "...", "sections": [{"heading": "SEC. 2. ...", "text": "..."}], "max_sentences": 3}
+//
+// and responds 200 with the summary and the generator's name and version:
+//
+//	{"text": "...", "generator": "bill-summarizer", "version": "2024-06-01"}
+type Remote struct {
+	url          string
+	token        string
+	maxSentences int
+	client       *http.Client
+}
+
+// remoteRequest is the body sent to a remote generator
+type remoteRequest struct {
+	Document
+	MaxSentences int `json:"max_sentences"`
+}
+
+// remoteResponse is the body a remote generator responds with
+type remoteResponse struct {
+	Text      string `json:"text"`
+	Generator string `json:"generator"`
+	Version   string `json:"version"`
+}
+
+// NewRemote creates a Remote summarizer posting to url, authenticated with
+// token if it is set. Requests time out after timeout.
+func NewRemote(url, token string, timeout time.Duration, maxSentences int) *Remote {
+	return &Remote{
+		url:          url,
+		token:        token,
+		maxSentences: maxSentences,
+		client:       &http.Client{Timeout: timeout},
+	}
+}
+
+// Summarize posts doc to the remote generator and returns its summary
+func (r *Remote) Summarize(ctx context.Context, doc Document) (Result, error) {
+	body, err := json.Marshal(remoteRequest{Document: doc, MaxSentences: r.maxSentences})
+	if err != nil {
+		return Result{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	// Continue the request's trace in the generator
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("summary generator responded %s", resp.Status)
+	}
+
+	var generated remoteResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&generated); err != nil {
+		return Result{}, fmt.Errorf("decoding summary generator response: %w", err)
+	}
+	if strings.TrimSpace(generated.Version) == "" {
+		return Result{}, errors.New("summary generator did not report its version")
+	}
+	if generated.Generator == "" {
+		generated.Generator = RemoteName
+	}
+	return Result{Text: generated.Text, Generator: generated.Generator, Version: generated.Version}, nil
+}
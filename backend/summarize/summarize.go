@@ -0,0 +1,97 @@
+// Package summarize drafts plain-language summaries of policies for the
+// editorial review. A Summarizer writes the text: Extractive ranks the
+// sentences of a policy's sections in process, and Remote asks an external
+// model endpoint. A Drafter stores the results as drafts recording the
+// generator and version that wrote them.
+package summarize
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/config"
+	"github.com/benjamingetches/govtrack/legislation"
+)
+
+// Document is the text of a policy to summarize, split into sections
+type Document struct {
+	Title    string    `json:"title"`
+	Sections []Section `json:"sections"`
+}
+
+// Section is a part of a policy's text and its heading, if it has one
+type Section struct {
+	Heading string `json:"heading,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Result is a summary and the generator that wrote it
+type Result struct {
+	Text      string
+	Generator string
+	Version   string
+}
+
+// Summarizer writes summaries of documents
+type Summarizer interface {
+	Summarize(ctx context.Context, doc Document) (Result, error)
+}
+
+// FromConfig creates the Summarizer configured by cfg
+func FromConfig(cfg config.GeneratorConfig) Summarizer {
+	if cfg.Kind == config.GeneratorRemote {
+		return NewRemote(cfg.URL, cfg.Token, cfg.Timeout, cfg.MaxSentences)
+	}
+	return NewExtractive(cfg.MaxSentences)
+}
+
+// paragraphBreak matches the blank lines between paragraphs
+var paragraphBreak = regexp.MustCompile(`\n[ \t]*\n`)
+
+// NewDocument splits a policy's original text into its sections, or into
+// paragraphs if it has none
+func NewDocument(policy *models.Policy) Document {
+	doc := Document{Title: policy.Title, Sections: []Section{}}
+	text := policy.OriginalText
+
+	provisions := legislation.Parse(text)
+	sections := sectionsOf(provisions)
+	if len(sections) == 0 {
+		for _, paragraph := range paragraphBreak.Split(text, -1) {
+			doc.add("", paragraph)
+		}
+		return doc
+	}
+
+	// Text before the first provision, such as a bill's preamble
+	doc.add("", string([]rune(text)[:provisions[0].Start]))
+	for _, section := range sections {
+		doc.add(section.Heading, legislation.FullText(section))
+	}
+	return doc
+}
+
+// sectionsOf returns the sections of provisions, including those within
+// titles
+func sectionsOf(provisions []models.Provision) []models.Provision {
+	var sections []models.Provision
+	for _, provision := range provisions {
+		switch provision.Kind {
+		case models.ProvisionSection:
+			sections = append(sections, provision)
+		case models.ProvisionTitle:
+			sections = append(sections, sectionsOf(provision.Children)...)
+		}
+	}
+	return sections
+}
+
+// add appends a section unless it has no text
+func (d *Document) add(heading, text string) {
+	text = strings.Join(strings.Fields(text), " ")
+	if text != "" {
+		d.Sections = append(d.Sections, Section{Heading: heading, Text: text})
+	}
+}
@@ -0,0 +1,218 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/readability"
+	"github.com/benjamingetches/govtrack/repository"
+)
+
+const billText = `A BILL To protect the sources of drinking water.
+
+SEC. 1. SHORT TITLE.
+This Act may be cited as the Clean Water Act.
+
+SEC. 2. WATER TESTING.
+Every city must test its drinking water for lead each year. Cities must
+publish the results of each water test online. Tests are paid for by the state.
+
+SEC. 3. FUNDING.
+The state gives cities grants to replace lead pipes that carry drinking water.
+Grants are awarded by the Department of Health.`
+
+func policyWithText(title, text string) *models.Policy {
+	return &models.Policy{Title: title, OriginalText: text, Status: "proposed", Level: "state"}
+}
+
+func TestNewDocument(t *testing.T) {
+	doc := NewDocument(policyWithText("Clean Water Act", billText))
+	if len(doc.Sections) != 4 {
+		t.Fatalf("sections = %+v, want the preamble and three sections", doc.Sections)
+	}
+	if doc.Sections[0].Heading != "" || doc.Sections[2].Heading != "WATER TESTING" {
+		t.Errorf("headings = %q and %q", doc.Sections[0].Heading, doc.Sections[2].Heading)
+	}
+	if strings.Contains(doc.Sections[2].Text, "\n") || !strings.HasPrefix(doc.Sections[2].Text, "Every city must test") {
+		t.Errorf("section text = %q, want it on one line without its heading", doc.Sections[2].Text)
+	}
+
+	// Text without headings is split into paragraphs
+	doc = NewDocument(policyWithText("Parks", "Opens parks at night.\n\n  \nCloses them in winter.\n"))
+	if len(doc.Sections) != 2 || doc.Sections[1].Text != "Closes them in winter." {
+		t.Errorf("sections = %+v, want two paragraphs", doc.Sections)
+	}
+}
+
+func TestExtractive(t *testing.T) {
+	doc := NewDocument(policyWithText("Clean Water Act", billText))
+	result, err := NewExtractive(2).Summarize(context.Background(), doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Generator != ExtractiveName || result.Version != ExtractiveVersion {
+		t.Errorf("generator = %s %s", result.Generator, result.Version)
+	}
+
+	// The best sentences of different sections, in document order
+	want := "Every city must test its drinking water for lead each year. The state gives cities grants to replace lead pipes that carry drinking water."
+	if result.Text != want {
+		t.Errorf("summary = %q, want %q", result.Text, want)
+	}
+
+	// Boilerplate is left out
+	result, _ = NewExtractive(4).Summarize(context.Background(), doc)
+	if sentences := readability.Sentences(result.Text); len(sentences) != 4 || strings.Contains(result.Text, "may be cited") {
+		t.Errorf("summary = %q, want four sentences without the short title", result.Text)
+	}
+
+	// Short documents are summarized by all their sentences
+	result, err = NewExtractive(3).Summarize(context.Background(), NewDocument(policyWithText("Parks", "Opens parks at night.")))
+	if err != nil || result.Text != "Opens parks at night." {
+		t.Errorf("summary = %q, %v", result.Text, err)
+	}
+	if _, err := NewExtractive(3).Summarize(context.Background(), Document{}); err == nil {
+		t.Error("want an error for a document without sentences")
+	}
+}
+
+func TestRemote(t *testing.T) {
+	var received remoteRequest
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		switch received.Title {
+		case "Unversioned":
+			json.NewEncoder(w).Encode(map[string]string{"text": "Summary."})
+		case "Broken":
+			http.Error(w, "model unavailable", http.StatusServiceUnavailable)
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"text": "Tests water for lead.", "generator": "bill-model", "version": "2024-06"})
+		}
+	}))
+	defer stub.Close()
+
+	remote := NewRemote(stub.URL, "secret", time.Second, 2)
+	result, err := remote.Summarize(context.Background(), NewDocument(policyWithText("Clean Water Act", billText)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (Result{Text: "Tests water for lead.", Generator: "bill-model", Version: "2024-06"}) {
+		t.Errorf("result = %+v", result)
+	}
+	if received.MaxSentences != 2 || len(received.Sections) != 4 {
+		t.Errorf("request = %+v, want the sections and the sentences wanted", received)
+	}
+
+	for _, title := range []string{"Unversioned", "Broken"} {
+		if _, err := remote.Summarize(context.Background(), Document{Title: title}); err == nil {
+			t.Errorf("%s: want an error", title)
+		}
+	}
+	if _, err := NewRemote(stub.URL, "", time.Second, 2).Summarize(context.Background(), Document{}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want the status reported", err)
+	}
+}
+
+// fixedSummarizer returns text, or err if it is set
+type fixedSummarizer struct {
+	text string
+	err  error
+}
+
+func (f fixedSummarizer) Summarize(ctx context.Context, doc Document) (Result, error) {
+	return Result{Text: f.text, Generator: "fixed", Version: "1"}, f.err
+}
+
+func TestDrafter(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	analyzer := readability.NewAnalyzer(nil, nil, readability.Thresholds{MaxGrade: 10, MaxSentenceLength: 25, MaxJargon: 3})
+	create := func(policy *models.Policy) *models.Policy {
+		t.Helper()
+		if err := store.Policies.Create(ctx, policy); err != nil {
+			t.Fatal(err)
+		}
+		return policy
+	}
+	water := create(policyWithText("Clean Water Act", billText))
+	create(policyWithText("Parks Act", "Opens parks at night."))
+	create(policyWithText("Empty Act", ""))
+	described := policyWithText("Described Act", "Opens libraries on Sundays.")
+	described.SimplifiedDesc = "Libraries open on Sundays."
+	create(described)
+
+	drafter := NewDrafter(store, NewExtractive(2), analyzer, 1)
+	summary, err := drafter.Draft(ctx, water)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Status != models.SummaryDraft || summary.AuthorID != nil || summary.Readability == nil {
+		t.Errorf("summary = %+v, want a scored draft without an author", summary)
+	}
+	if g := summary.Generator; g == nil || g.Name != ExtractiveName || g.Version != ExtractiveVersion || g.GeneratedAt.IsZero() {
+		t.Errorf("generator = %+v", summary.Generator)
+	}
+	if _, err := drafter.Draft(ctx, policyWithText("Empty", " ")); !errors.Is(err, ErrNoText) {
+		t.Errorf("err = %v, want ErrNoText", err)
+	}
+
+	t.Run("pending policies are drafted in batches", func(t *testing.T) {
+		// Only the parks act has text, no description and no draft
+		for _, want := range []int{1, 0} {
+			drafted, err := drafter.Pending(ctx)
+			if err != nil || drafted != want {
+				t.Fatalf("drafted = %d, %v; want %d", drafted, err, want)
+			}
+		}
+		entries, err := store.Audit.List(ctx, repository.AuditFilter{ResourceType: "summary"})
+		if err != nil || len(entries) != 1 || entries[0].ActorID != nil {
+			t.Errorf("audit entries = %+v, %v; want one without an actor", entries, err)
+		}
+	})
+
+	t.Run("drafts are looked up once per page of policies", func(t *testing.T) {
+		counted := *store
+		summaries := &countingSummaries{SummaryRepository: store.Summaries}
+		counted.Summaries = summaries
+		if _, err := NewDrafter(&counted, NewExtractive(2), analyzer, 5).Pending(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if summaries.lists != 1 {
+			t.Errorf("summaries were listed %d times, want once", summaries.lists)
+		}
+	})
+
+	t.Run("unusable summaries are skipped and failures end the run", func(t *testing.T) {
+		create(policyWithText("Roads Act", "Repaves roads."))
+		drafted, err := NewDrafter(store, fixedSummarizer{text: strings.Repeat("a", 5001)}, analyzer, 5).Pending(ctx)
+		if err != nil || drafted != 0 {
+			t.Errorf("drafted = %d, %v; want the policy skipped", drafted, err)
+		}
+		_, err = NewDrafter(store, fixedSummarizer{err: errors.New("down")}, analyzer, 5).Pending(ctx)
+		if !errors.Is(err, ErrGenerator) {
+			t.Errorf("err = %v, want ErrGenerator", err)
+		}
+	})
+}
+
+// countingSummaries counts the listings of a summary repository
+type countingSummaries struct {
+	repository.SummaryRepository
+	lists int
+}
+
+func (c *countingSummaries) List(ctx context.Context, filter repository.SummaryFilter) ([]models.Summary, error) {
+	c.lists++
+	return c.SummaryRepository.List(ctx, filter)
+}
@@ -0,0 +1,91 @@
+// Package tracing configures OpenTelemetry tracing. Spans are exported to an
+// OTLP collector or to stdout depending on the environment, and W3C trace
+// context is propagated so that traces started by clients continue through
+// the API.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// ServiceName identifies the API in traces unless OTEL_SERVICE_NAME is set
+const ServiceName = "govtrack-api"
+
+// Exporter names accepted in OTEL_TRACES_EXPORTER
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// ShutdownFunc flushes pending spans and stops the exporter
+type ShutdownFunc func(context.Context) error
+
+// Setup installs the global tracer provider and propagator. The exporter is
+// chosen by OTEL_TRACES_EXPORTER: "otlp" sends spans over OTLP/HTTP to
+// OTEL_EXPORTER_OTLP_ENDPOINT, "stdout" prints them, and "none" disables
+// tracing. It defaults to "otlp" when an endpoint is configured and "none"
+// otherwise. The standard OTEL_* variables for the endpoint, headers,
+// resource attributes and sampler are honored.
+func Setup(ctx context.Context) (ShutdownFunc, error) {
+	// Accept W3C trace context from clients even when not exporting, so
+	// that downstream calls keep the caller's trace ID
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	name := ExporterName()
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch name {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q: use otlp, stdout or none", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %v", name, err)
+	}
+
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(ServiceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// ExporterName returns the exporter selected by the environment
+func ExporterName() string {
+	if name := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER"))); name != "" {
+		return name
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		return ExporterOTLP
+	}
+	return ExporterNone
+}
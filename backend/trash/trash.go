@@ -0,0 +1,184 @@
+// Package trash permanently deletes content that has stayed in the trash for
+// longer than the retention period. References to purged documents, such as
+// related policies, votes, sponsorships and quiz stances, and the summaries,
+// annotations, comments and poll ballots of purged policies are removed
+// first, so that an interrupted purge is completed by the next one. Every
+// purged document is recorded in the audit log, and cached responses that
+// may show purged content are invalidated.
+package trash
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/benjamingetches/govtrack/api/httpcache"
+	"github.com/benjamingetches/govtrack/api/models"
+	"github.com/benjamingetches/govtrack/audit"
+	"github.com/benjamingetches/govtrack/health"
+	"github.com/benjamingetches/govtrack/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Result counts the documents purged by one run
+type Result struct {
+	Policies        int
+	Representatives int
+	Quizzes         int
+}
+
+// Purger purges expired content from a store
+type Purger struct {
+	store     *repository.Store
+	audit     *audit.Log
+	cache     *httpcache.Cache
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewPurger creates a Purger for content deleted more than retention ago
+// that invalidates the responses in cache after purging
+func NewPurger(store *repository.Store, retention time.Duration, cache *httpcache.Cache) *Purger {
+	return &Purger{store: store, audit: audit.NewLog(store.Audit), cache: cache, retention: retention, now: time.Now}
+}
+
+// Purge deletes every document that was moved to the trash before the
+// retention period, after removing references to it
+func (p *Purger) Purge(ctx context.Context) (Result, error) {
+	cutoff := p.now().Add(-p.retention)
+
+	policies, err := p.store.Policies.ListTrash(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	representatives, err := p.store.Representatives.ListTrash(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	quizzes, err := p.store.Quizzes.ListTrash(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var policyIDs, representativeIDs, quizIDs []primitive.ObjectID
+	var purged []models.AuditEntry
+	for _, policy := range policies {
+		if expired(policy.DeletedAt, cutoff) {
+			policyIDs = append(policyIDs, policy.ID)
+			purged = appendEntry(purged, audit.ResourcePolicy, policy.ID, policy)
+		}
+	}
+	for _, representative := range representatives {
+		if expired(representative.DeletedAt, cutoff) {
+			representativeIDs = append(representativeIDs, representative.ID)
+			purged = appendEntry(purged, audit.ResourceRepresentative, representative.ID, representative)
+		}
+	}
+	for _, quiz := range quizzes {
+		if expired(quiz.DeletedAt, cutoff) {
+			quizIDs = append(quizIDs, quiz.ID)
+			purged = appendEntry(purged, audit.ResourceQuiz, quiz.ID, quiz)
+		}
+	}
+
+	// Nothing refers to quizzes; results keep the ID of the quiz they scored
+	if len(policyIDs) > 0 {
+		if err := p.store.Policies.RemovePolicyReferences(ctx, policyIDs); err != nil {
+			return Result{}, err
+		}
+		if err := p.store.Representatives.RemovePolicyReferences(ctx, policyIDs); err != nil {
+			return Result{}, err
+		}
+		if err := p.store.Summaries.DeleteByPolicies(ctx, policyIDs); err != nil {
+			return Result{}, err
+		}
+		if err := p.store.Annotations.DeleteByPolicies(ctx, policyIDs); err != nil {
+			return Result{}, err
+		}
+		if err := p.store.Comments.DeleteByPolicies(ctx, policyIDs); err != nil {
+			return Result{}, err
+		}
+		if err := p.store.Ballots.DeleteByPolicies(ctx, policyIDs); err != nil {
+			return Result{}, err
+		}
+	}
+	if len(representativeIDs) > 0 {
+		if err := p.store.Policies.RemoveRepresentativeReferences(ctx, representativeIDs); err != nil {
+			return Result{}, err
+		}
+		if err := p.store.Quizzes.RemoveRepresentativeReferences(ctx, representativeIDs); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if err := p.store.Policies.Purge(ctx, policyIDs); err != nil {
+		return Result{}, err
+	}
+	if err := p.store.Representatives.Purge(ctx, representativeIDs); err != nil {
+		return Result{}, err
+	}
+	if err := p.store.Quizzes.Purge(ctx, quizIDs); err != nil {
+		return Result{}, err
+	}
+
+	// Cached policies and representatives may show the purged documents or
+	// references to them
+	if len(policyIDs) > 0 || len(representativeIDs) > 0 {
+		p.cache.Invalidate("policies", "representatives")
+	}
+
+	// The documents are gone, so failing to record them does not fail the purge
+	for i := range purged {
+		if err := p.audit.Record(ctx, &purged[i]); err != nil {
+			slog.Error("Error recording audit entry", "error", err, "resource", purged[i].ResourceType, "id", purged[i].ResourceID.Hex())
+		}
+	}
+	return Result{Policies: len(policyIDs), Representatives: len(representativeIDs), Quizzes: len(quizIDs)}, nil
+}
+
+// Run purges the trash every interval until ctx is done. The heartbeat is
+// beaten after every successful purge.
+func (p *Purger) Run(ctx context.Context, interval time.Duration, heartbeat *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.runOnce(ctx, interval, heartbeat)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce purges the trash within interval and logs the outcome
+func (p *Purger) runOnce(ctx context.Context, interval time.Duration, heartbeat *health.Heartbeat) {
+	ctx, cancel := context.WithTimeout(ctx, interval)
+	defer cancel()
+
+	result, err := p.Purge(ctx)
+	if err != nil {
+		slog.Error("Error purging trash", "error", err)
+		return
+	}
+	heartbeat.Beat()
+	if result != (Result{}) {
+		slog.Info("Purged trash", "policies", result.Policies, "representatives", result.Representatives, "quizzes", result.Quizzes)
+	}
+}
+
+// appendEntry appends the audit entry of purging doc to entries
+func appendEntry(entries []models.AuditEntry, resource string, id primitive.ObjectID, doc interface{}) []models.AuditEntry {
+	changes, err := audit.Diff(doc, nil)
+	if err != nil {
+		slog.Error("Error recording audit entry", "error", err, "resource", resource, "id", id.Hex())
+		return entries
+	}
+	return append(entries, models.AuditEntry{Action: audit.ActionPurge, ResourceType: resource, ResourceID: id, Changes: changes})
+}
+
+// expired reports whether content deleted at deletedAt is due for purging
+func expired(deletedAt *time.Time, cutoff time.Time) bool {
+	return deletedAt != nil && deletedAt.Before(cutoff)
+}